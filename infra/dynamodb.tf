@@ -22,3 +22,26 @@ resource "aws_dynamodb_table" "orders" {
   )
 }
 
+
+# DynamoDB table for failed-order records (processor FAILURES_TABLE)
+resource "aws_dynamodb_table" "order_failures" {
+  name         = "${local.name_prefix}-OrderFailures"
+  billing_mode = var.dynamodb_billing_mode
+  hash_key     = "message_id"
+
+  attribute {
+    name = "message_id"
+    type = "S"
+  }
+
+  point_in_time_recovery {
+    enabled = var.enable_point_in_time_recovery
+  }
+
+  tags = merge(
+    local.common_tags,
+    {
+      Name = "${local.name_prefix}-OrderFailures"
+    }
+  )
+}
@@ -28,3 +28,8 @@ output "dynamodb_table_arn" {
   value       = aws_dynamodb_table.orders.arn
 }
 
+output "dynamodb_failures_table_name" {
+  description = "Name of the DynamoDB failed-order records table"
+  value       = aws_dynamodb_table.order_failures.name
+}
+
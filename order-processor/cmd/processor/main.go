@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"order-processor/internal/processor"
 	"os"
 	"os/signal"
@@ -12,6 +13,9 @@ import (
 )
 
 func main() {
+	redrive := flag.Bool("redrive", false, "move messages from DLQ_QUEUE_URL back to the queue, then exit")
+	flag.Parse()
+
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
@@ -19,11 +23,34 @@ func main() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to create processor")
 	}
+	if *redrive {
+		// Redrive logs its own summary
+		if _, err := p.Redrive(ctx); err != nil {
+			log.Fatal().Err(err).Msg("DLQ redrive failed")
+		}
+		return
+	}
+	if err := p.StartMetricsServer(); err != nil {
+		log.Fatal().Err(err).Msg("failed to start metrics server")
+	}
+
+	// SIGUSR1 pauses writes (read-only mode), SIGUSR2 resumes them
+	modeSignals := make(chan os.Signal, 1)
+	signal.Notify(modeSignals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range modeSignals {
+			p.SetReadOnly(sig == syscall.SIGUSR1)
+		}
+	}()
 
 	log.Info().Msg("starting SQS poller")
-	if err := p.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+	err = p.Start(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
 		log.Fatal().Err(err).Msg("processor stopped with error")
 	}
+	if errors.Is(err, processor.ErrUncleanShutdown) {
+		log.Fatal().Err(err).Msg("processor did not shut down cleanly")
+	}
 
 	log.Info().Msg("shutting down gracefully")
 }
@@ -0,0 +1,49 @@
+package processor
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Admin endpoints are served next to /metrics and require
+// "Authorization: Bearer <ADMIN_TOKEN>".
+const (
+	adminReadOnlyPath    = "/admin/readonly"
+	adminConcurrencyPath = "/admin/concurrency"
+)
+
+// registerAdminHandlers adds the admin endpoints to mux. With no token
+// configured they are not registered at all.
+func (p *Processor) registerAdminHandlers(mux *http.ServeMux, token string) {
+	if token == "" {
+		log.Info().Msg("ADMIN_TOKEN is not set, admin endpoints are disabled")
+		return
+	}
+	mux.Handle(adminReadOnlyPath, requireBearerToken(token, http.HandlerFunc(p.handleReadOnly)))
+	mux.Handle(adminConcurrencyPath, requireBearerToken(token, http.HandlerFunc(p.handleConcurrency)))
+}
+
+// requireBearerToken rejects requests without "Authorization: Bearer
+// <token>".
+func requireBearerToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes a small, pre-encoded JSON response.
+func writeJSON(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write([]byte(body)); err != nil {
+		log.Error().Err(err).Msg("failed to write admin response")
+	}
+}
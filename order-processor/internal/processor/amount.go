@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// amountRange bounds accepted order amounts, inclusive on both ends.
+type amountRange struct {
+	min int64
+	max int64
+}
+
+// defaultAmountRange is the default range of Order.Amount. Bounds are int64
+// so AMOUNT_MIN/AMOUNT_MAX parse the same way everywhere, but both are
+// validated against this range, which keeps the platform int range amounts
+// were limited to before they became decimals.
+var defaultAmountRange = amountRange{min: math.MinInt, max: math.MaxInt}
+
+// orderPayload is the wire shape of an order. Amount is kept as the raw JSON
+// value so it can be type- and range-checked before being parsed into
+// Order.Amount.
+type orderPayload struct {
+	Order
+	Amount json.RawMessage `json:"amount"`
+}
+
+// parseOrder decodes body into an Order. Amounts that are not numbers or
+// fall outside the configured range are rejected as validation errors
+// instead of losing precision or failing as opaque JSON errors.
+func (p *Processor) parseOrder(body string) (Order, error) {
+	var payload orderPayload
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		return Order{}, &ValidationError{Type: "invalid_json", Err: fmt.Errorf("invalid JSON: %w", err)}
+	}
+
+	order := payload.Order
+	amount, err := p.checkAmount(payload.Amount)
+	if err != nil {
+		return Order{}, &ValidationError{Type: "invalid_amount", OrderID: order.OrderID, Err: err}
+	}
+	order.Amount = amount
+	return order, nil
+}
+
+// checkAmount parses raw into an exact decimal, fractional amounts such as
+// 99.99 included, and checks it against the configured range. A missing or
+// null amount is treated as zero; strings and other non-number values are
+// rejected.
+func (p *Processor) checkAmount(raw json.RawMessage) (Decimal, error) {
+	n := string(raw)
+	if n == "" || n == "null" {
+		n = "0"
+	}
+	if n[0] != '-' && (n[0] < '0' || n[0] > '9') {
+		return "", fmt.Errorf("amount must be a JSON number, got %s", n)
+	}
+
+	amount, err := parseDecimal(n)
+	if err != nil {
+		return "", fmt.Errorf("amount %w", err)
+	}
+
+	bounds := defaultAmountRange
+	if p.amountRange != nil {
+		bounds = *p.amountRange
+	}
+	value := amount.rat()
+	if value.Cmp(new(big.Rat).SetInt64(bounds.min)) < 0 || value.Cmp(new(big.Rat).SetInt64(bounds.max)) > 0 {
+		return "", fmt.Errorf("amount %s is outside the allowed range [%d, %d]", n, bounds.min, bounds.max)
+	}
+	return amount, nil
+}
+
+// checkAmountSign rejects orders with a negative amount, and with a zero
+// amount unless ALLOW_ZERO_AMOUNT is set. A missing amount counts as zero.
+func (p *Processor) checkAmountSign(order Order) error {
+	if sign := order.Amount.Sign(); sign > 0 || (sign == 0 && p.allowZeroAmount) {
+		return nil
+	}
+	return &ValidationError{
+		Type:    "invalid_amount",
+		OrderID: order.OrderID,
+		Err:     fmt.Errorf("amount must be positive, got %s", order.Amount),
+	}
+}
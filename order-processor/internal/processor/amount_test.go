@@ -0,0 +1,169 @@
+package processor
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOrder_AmountOverflow(t *testing.T) {
+	proc := &Processor{}
+
+	_, err := proc.parseOrder(`{"order_id":"o1","user_id":"u1","amount":99999999999999999999999}`)
+
+	var vErr *ValidationError
+	require.True(t, errors.As(err, &vErr))
+	assert.Equal(t, "invalid_amount", vErr.Type)
+	assert.Equal(t, "o1", vErr.OrderID)
+	assert.Contains(t, err.Error(), "amount 99999999999999999999999 is outside the allowed range")
+}
+
+func TestParseOrder_AmountBoundary(t *testing.T) {
+	proc := &Processor{}
+
+	order, err := proc.parseOrder(`{"order_id":"o1","amount":` + strconv.Itoa(math.MaxInt) + `}`)
+
+	require.NoError(t, err)
+	assert.Equal(t, Decimal(strconv.Itoa(math.MaxInt)), order.Amount)
+}
+
+func TestParseOrder_ConfiguredRange(t *testing.T) {
+	proc := &Processor{amountRange: &amountRange{min: 1, max: 1000}}
+
+	tests := []struct {
+		amount  string
+		wantErr bool
+	}{
+		{amount: "1"},
+		{amount: "999.99"},
+		{amount: "1000"},
+		{amount: "0", wantErr: true},
+		{amount: "0.99", wantErr: true},
+		{amount: "1000.01", wantErr: true},
+		{amount: "1001", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount, func(t *testing.T) {
+			order, err := proc.parseOrder(`{"order_id":"o1","amount":` + tt.amount + `}`)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "outside the allowed range [1, 1000]")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.amount, order.Amount.String())
+		})
+	}
+}
+
+func TestParseOrder_DecimalAmounts(t *testing.T) {
+	proc := &Processor{}
+
+	tests := []struct {
+		amount string
+		want   Decimal
+	}{
+		{amount: "100", want: "100"},
+		{amount: "100.50", want: "100.5"},
+		{amount: "0.01", want: "0.01"},
+		{amount: "99.99", want: "99.99"},
+		{amount: "1e3", want: "1000"},
+		{amount: "1.5E-1", want: "0.15"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount, func(t *testing.T) {
+			order, err := proc.parseOrder(`{"order_id":"o1","amount":` + tt.amount + `}`)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, order.Amount)
+
+			// Stored as a Number with every digit kept
+			item, err := proc.orderItem(order)
+			require.NoError(t, err)
+			assert.Equal(t, &dtypes.AttributeValueMemberN{Value: string(tt.want)}, item["amount"])
+		})
+	}
+}
+
+func TestParseOrder_AmountTooPrecise(t *testing.T) {
+	proc := &Processor{}
+
+	for _, amount := range []string{"0.000000000000000000000000000000000000001", "1e-999999999"} {
+		_, err := proc.parseOrder(`{"order_id":"o1","amount":` + amount + `}`)
+
+		var vErr *ValidationError
+		require.True(t, errors.As(err, &vErr), amount)
+		assert.Equal(t, "invalid_amount", vErr.Type)
+		assert.ErrorContains(t, err, "has more than 38 digits")
+	}
+}
+
+func TestParseOrder_AmountMustBeNumber(t *testing.T) {
+	proc := &Processor{}
+
+	_, err := proc.parseOrder(`{"order_id":"o1","amount":"42"}`)
+
+	var vErr *ValidationError
+	require.True(t, errors.As(err, &vErr))
+	assert.Equal(t, "invalid_amount", vErr.Type)
+	assert.EqualError(t, err, `amount must be a JSON number, got "42"`)
+}
+
+func TestParseOrder_TrailingDataIsInvalidJSON(t *testing.T) {
+	proc := &Processor{}
+
+	_, err := proc.parseOrder(`{"order_id":"o1","amount":5} trailing garbage`)
+
+	var vErr *ValidationError
+	require.True(t, errors.As(err, &vErr))
+	assert.Equal(t, "invalid_json", vErr.Type)
+}
+
+func TestParseOrder_MissingAmountIsZero(t *testing.T) {
+	proc := &Processor{}
+
+	order, err := proc.parseOrder(`{"order_id":"o1"}`)
+
+	require.NoError(t, err)
+	assert.Equal(t, Decimal("0"), order.Amount)
+}
+
+func TestCheckAmountSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    Decimal
+		allowZero bool
+		wantErr   bool
+	}{
+		{name: "positive", amount: "1"},
+		{name: "positive fraction", amount: "0.01"},
+		{name: "negative", amount: "-50", wantErr: true},
+		{name: "negative fraction", amount: "-0.01", allowZero: true, wantErr: true},
+		{name: "negative with zero allowed", amount: "-1", allowZero: true, wantErr: true},
+		{name: "zero", amount: "0", wantErr: true},
+		{name: "missing", amount: "", wantErr: true},
+		{name: "zero allowed", amount: "0", allowZero: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := &Processor{allowZeroAmount: tt.allowZero}
+
+			err := proc.checkAmountSign(Order{OrderID: "o1", Amount: tt.amount})
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			var vErr *ValidationError
+			require.True(t, errors.As(err, &vErr))
+			assert.Equal(t, "invalid_amount", vErr.Type)
+			assert.Equal(t, "o1", vErr.OrderID)
+			assert.ErrorContains(t, err, "amount must be positive")
+		})
+	}
+}
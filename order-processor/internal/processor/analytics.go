@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// analyticsWriteTimeout bounds each analytics write, so a slow sink holds
+// up a worker by at most this much
+const analyticsWriteTimeout = 5 * time.Second
+
+// Analytics write results
+const (
+	analyticsSuccess = "success"
+	analyticsFailure = "failure"
+)
+
+// AnalyticsSink receives every stored order for aggregation in a
+// time-series or analytics store such as Timestream or ClickHouse. It is
+// written after the order handler has stored the order and is not the
+// record of it: a failed write is logged and counted, and the message is
+// still deleted.
+type AnalyticsSink interface {
+	WriteOrder(ctx context.Context, order Order) error
+}
+
+// WithAnalyticsSink streams each stored order to sink. It returns p.
+func (p *Processor) WithAnalyticsSink(sink AnalyticsSink) *Processor {
+	p.analytics = sink
+	return p
+}
+
+func newAnalyticsWritesCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "analytics_writes_total",
+			Help: "Orders written to the analytics sink by result",
+		},
+		[]string{"result", "env"},
+	)
+}
+
+// writeAnalytics writes a stored order to the analytics sink, if any.
+func (p *Processor) writeAnalytics(ctx context.Context, order Order) {
+	if p.analytics == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, analyticsWriteTimeout)
+	defer cancel()
+
+	ctx, span := p.startChildSpan(ctx, "write_analytics")
+	err := p.analytics.WriteOrder(ctx, order)
+	endSpan(span, err)
+
+	result := analyticsSuccess
+	if err != nil {
+		result = analyticsFailure
+		log.Warn().
+			Str("order_id", order.OrderID).
+			Err(err).
+			Msg("failed to write order to analytics sink")
+	}
+	if p.analyticsWrites != nil {
+		p.analyticsWrites.WithLabelValues(result, p.environment).Inc()
+	}
+	p.statsd.count(statsdAnalyticsWrites, 1, "result:"+result, "env:"+p.environment)
+}
@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAnalyticsSink is a mock AnalyticsSink.
+type MockAnalyticsSink struct {
+	mock.Mock
+}
+
+func (m *MockAnalyticsSink) WriteOrder(ctx context.Context, order Order) error {
+	args := m.Called(ctx, order)
+	return args.Error(0)
+}
+
+func TestPollAndProcess_WritesEachStoredOrderToAnalytics(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	sink := &MockAnalyticsSink{}
+	at := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	proc := (&Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		analyticsWrites: newAnalyticsWritesCounter(),
+		environment:     "test",
+		clock:           fixedClock{at: at},
+	}).WithAnalyticsSink(sink)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(3)}, nil).Once()
+	// o1 is already stored, so only o0 and o2 reach the sink
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return input.Item["order_id"].(*dtypes.AttributeValueMemberS).Value == "o1"
+	})).Return((*dynamodb.PutItemOutput)(nil), &dtypes.ConditionalCheckFailedException{Message: aws.String("exists")}).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Twice()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+	for _, id := range []string{"o0", "o2"} {
+		sink.On("WriteOrder", mock.Anything, Order{
+			OrderID:     id,
+			UserID:      "u1",
+			Amount:      "100",
+			Status:      "PROCESSED",
+			Currency:    "USD",
+			CreatedAt:   at,
+			ProcessedAt: at,
+		}).Return(nil).Once()
+	}
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	sink.AssertExpectations(t)
+	sink.AssertNumberOfCalls(t, "WriteOrder", 2)
+	mockSQS.AssertExpectations(t)
+	assert.Equal(t, 2.0, testutil.ToFloat64(proc.analyticsWrites.WithLabelValues("success", "test")))
+}
+
+func TestHandleMessage_AnalyticsFailureDoesNotFailOrder(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	sink := &MockAnalyticsSink{}
+	proc := (&Processor{
+		ddbClient:       mockDDB,
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		analyticsWrites: newAnalyticsWritesCounter(),
+		environment:     "test",
+	}).WithAnalyticsSink(sink)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	sink.On("WriteOrder", mock.Anything, mock.Anything).Return(errors.New("ClickHouse unavailable")).Once()
+
+	err := proc.handleMessage(context.Background(), orderMessage("msg-1"))
+
+	assert.NoError(t, err)
+	sink.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.analyticsWrites.WithLabelValues("failure", "test")))
+}
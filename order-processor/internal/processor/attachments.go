@@ -0,0 +1,202 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// Attachment validation defaults
+	defaultAttachmentMaxBytes = 5 << 20
+	attachmentFetchTimeout    = 10 * time.Second
+)
+
+// defaultAttachmentTypes are the receipt formats accepted when
+// ATTACHMENT_ALLOWED_TYPES is unset.
+var defaultAttachmentTypes = []string{"application/pdf", "image/jpeg", "image/png"}
+
+// errAttachmentAddressBlocked is returned when an attachment host resolves
+// to a loopback, private, or link-local address.
+var errAttachmentAddressBlocked = errors.New("attachment host resolves to a non-public address")
+
+// AttachmentMetadata describes an attachment fetched for an order. It is
+// stored as a nested attribute on the order item; the content itself stays
+// wherever the producer put it.
+type AttachmentMetadata struct {
+	URL         string `json:"url" dynamodbav:"url"`
+	ContentType string `json:"content_type" dynamodbav:"content_type"`
+	SizeBytes   int64  `json:"size_bytes" dynamodbav:"size_bytes"`
+	SHA256      string `json:"sha256" dynamodbav:"sha256"`
+	FetchedAt   string `json:"fetched_at" dynamodbav:"fetched_at"`
+}
+
+// AttachmentFetcher retrieves an attachment and reports its metadata. A
+// FetchedAt left empty is stamped by the processor.
+type AttachmentFetcher interface {
+	Fetch(ctx context.Context, url string) (AttachmentMetadata, error)
+}
+
+// attachmentPolicy holds the checks applied to every attachment. URLs are
+// checked before fetching, size and type after.
+type attachmentPolicy struct {
+	maxBytes     int64
+	allowedTypes []string
+	// allowedHosts must list every host attachments may be fetched from;
+	// an empty list rejects all attachment URLs
+	allowedHosts []string
+}
+
+// checkURL rejects attachment URLs that are not https or not on an
+// allowed host, so order bodies cannot point the processor at arbitrary
+// (including internal) addresses.
+func (ap attachmentPolicy) checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid attachment URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("attachment URL scheme %q is not allowed", u.Scheme)
+	}
+	if u.User != nil {
+		return errors.New("attachment URL must not contain credentials")
+	}
+	if !slices.Contains(ap.allowedHosts, strings.ToLower(u.Hostname())) {
+		return fmt.Errorf("attachment host %q is not allowed", u.Hostname())
+	}
+	return nil
+}
+
+// processAttachment fetches and validates the order's attachment, if any,
+// and records its metadata on the order. Fetch errors are retryable;
+// attachments that violate the policy are rejected as validation errors.
+func (p *Processor) processAttachment(ctx context.Context, order *Order) error {
+	if p.attachmentFetcher == nil || order.AttachmentURL == "" {
+		return nil
+	}
+
+	if err := p.attachmentPolicy.checkURL(order.AttachmentURL); err != nil {
+		return &ValidationError{Type: "invalid_attachment", OrderID: order.OrderID, Err: err}
+	}
+
+	meta, err := p.attachmentFetcher.Fetch(ctx, order.AttachmentURL)
+	if err != nil {
+		if errors.Is(err, errAttachmentAddressBlocked) {
+			return &ValidationError{Type: "invalid_attachment", OrderID: order.OrderID, Err: err}
+		}
+		return fmt.Errorf("failed to fetch attachment: %w", err)
+	}
+
+	if p.attachmentPolicy.maxBytes > 0 && meta.SizeBytes > p.attachmentPolicy.maxBytes {
+		return &ValidationError{
+			Type:    "invalid_attachment",
+			OrderID: order.OrderID,
+			Err:     fmt.Errorf("attachment is %d bytes, limit is %d", meta.SizeBytes, p.attachmentPolicy.maxBytes),
+		}
+	}
+	if len(p.attachmentPolicy.allowedTypes) > 0 && !slices.Contains(p.attachmentPolicy.allowedTypes, meta.ContentType) {
+		return &ValidationError{
+			Type:    "invalid_attachment",
+			OrderID: order.OrderID,
+			Err:     fmt.Errorf("attachment content type %q is not allowed", meta.ContentType),
+		}
+	}
+
+	if meta.FetchedAt == "" {
+		meta.FetchedAt = p.now().UTC().Format(time.RFC3339)
+	}
+	order.Attachment = &meta
+	return nil
+}
+
+// httpAttachmentFetcher downloads attachments over HTTPS, hashing the
+// content without keeping it in memory. It never follows redirects and
+// refuses to connect to non-public addresses.
+type httpAttachmentFetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+func newHTTPAttachmentFetcher(maxBytes int64) *httpAttachmentFetcher {
+	dialer := &net.Dialer{
+		Timeout: attachmentFetchTimeout,
+		// Checked on the resolved address, so DNS cannot smuggle in an
+		// internal IP after the host allowlist passed
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return errAttachmentAddressBlocked
+			}
+			return nil
+		},
+	}
+
+	return &httpAttachmentFetcher{
+		client: &http.Client{
+			Timeout: attachmentFetchTimeout,
+			Transport: &http.Transport{
+				Proxy:       nil,
+				DialContext: dialer.DialContext,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		maxBytes: maxBytes,
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+func (f *httpAttachmentFetcher) Fetch(ctx context.Context, url string) (AttachmentMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return AttachmentMetadata{}, fmt.Errorf("build request: %w", err)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return AttachmentMetadata{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return AttachmentMetadata{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// Read at most one byte past the limit: enough to know it was exceeded
+	hash := sha256.New()
+	size, err := io.Copy(hash, io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return AttachmentMetadata{}, fmt.Errorf("read body: %w", err)
+	}
+
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		contentType = "application/octet-stream"
+	}
+
+	return AttachmentMetadata{
+		URL:         url,
+		ContentType: contentType,
+		SizeBytes:   size,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
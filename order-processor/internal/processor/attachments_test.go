@@ -0,0 +1,201 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockAttachmentFetcher struct {
+	mock.Mock
+}
+
+func (m *MockAttachmentFetcher) Fetch(ctx context.Context, url string) (AttachmentMetadata, error) {
+	args := m.Called(ctx, url)
+	return args.Get(0).(AttachmentMetadata), args.Error(1)
+}
+
+func newAttachmentProcessor(fetcher AttachmentFetcher) (*Processor, *MockDynamoDBClient) {
+	mockDDB := &MockDynamoDBClient{}
+	return &Processor{
+		ddbClient:         mockDDB,
+		tableName:         "Orders",
+		ordersProcessed:   NewCounterVec(),
+		environment:       "test",
+		attachmentFetcher: fetcher,
+		attachmentPolicy: attachmentPolicy{
+			maxBytes:     1024,
+			allowedTypes: defaultAttachmentTypes,
+			allowedHosts: []string{"files.example.com"},
+		},
+	}, mockDDB
+}
+
+func attachmentMessage(url string) stypes.Message {
+	return stypes.Message{
+		Body: aws.String(`{"order_id":"o1","user_id":"u1","amount":100,"attachment_url":"` + url + `"}`),
+	}
+}
+
+func TestHandleMessage_AttachmentStored(t *testing.T) {
+	fetcher := &MockAttachmentFetcher{}
+	proc, mockDDB := newAttachmentProcessor(fetcher)
+
+	meta := AttachmentMetadata{
+		URL:         "https://files.example.com/r1.pdf",
+		ContentType: "application/pdf",
+		SizeBytes:   512,
+		SHA256:      "abc",
+		FetchedAt:   "2024-01-01T00:00:00Z",
+	}
+	fetcher.On("Fetch", mock.Anything, "https://files.example.com/r1.pdf").Return(meta, nil)
+
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		attachment, ok := input.Item["attachment"].(*dtypes.AttributeValueMemberM)
+		if !ok {
+			return false
+		}
+		return assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "application/pdf"}, attachment.Value["content_type"]) &&
+			assert.Equal(t, &dtypes.AttributeValueMemberN{Value: "512"}, attachment.Value["size_bytes"])
+	})).Return(&dynamodb.PutItemOutput{}, nil)
+
+	err := proc.handleMessage(context.Background(), attachmentMessage("https://files.example.com/r1.pdf"))
+
+	assert.NoError(t, err)
+	fetcher.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestHandleMessage_AttachmentStampedFromClock(t *testing.T) {
+	fetcher := &MockAttachmentFetcher{}
+	proc, mockDDB := newAttachmentProcessor(fetcher)
+	proc.WithClock(fixedClock{at: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)})
+
+	fetcher.On("Fetch", mock.Anything, "https://files.example.com/r1.pdf").
+		Return(AttachmentMetadata{URL: "https://files.example.com/r1.pdf", ContentType: "application/pdf"}, nil)
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		attachment, ok := input.Item["attachment"].(*dtypes.AttributeValueMemberM)
+		return ok && assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "2024-05-01T08:00:00Z"}, attachment.Value["fetched_at"])
+	})).Return(&dynamodb.PutItemOutput{}, nil)
+
+	require.NoError(t, proc.handleMessage(context.Background(), attachmentMessage("https://files.example.com/r1.pdf")))
+	mockDDB.AssertExpectations(t)
+}
+
+func TestHandleMessage_AttachmentFetchFailureIsRetryable(t *testing.T) {
+	fetcher := &MockAttachmentFetcher{}
+	proc, mockDDB := newAttachmentProcessor(fetcher)
+
+	fetcher.On("Fetch", mock.Anything, mock.Anything).
+		Return(AttachmentMetadata{}, errors.New("connection refused"))
+
+	err := proc.handleMessage(context.Background(), attachmentMessage("https://files.example.com/r1.pdf"))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to fetch attachment")
+	var vErr *ValidationError
+	assert.False(t, errors.As(err, &vErr))
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+}
+
+func TestHandleMessage_AttachmentRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		meta AttachmentMetadata
+		want string
+	}{
+		{
+			name: "too large",
+			meta: AttachmentMetadata{ContentType: "application/pdf", SizeBytes: 2048},
+			want: "attachment is 2048 bytes, limit is 1024",
+		},
+		{
+			name: "wrong type",
+			meta: AttachmentMetadata{ContentType: "text/html", SizeBytes: 10},
+			want: `attachment content type "text/html" is not allowed`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := &MockAttachmentFetcher{}
+			proc, _ := newAttachmentProcessor(fetcher)
+			fetcher.On("Fetch", mock.Anything, mock.Anything).Return(tt.meta, nil)
+
+			err := proc.handleMessage(context.Background(), attachmentMessage("https://files.example.com/r1.pdf"))
+
+			var vErr *ValidationError
+			require.True(t, errors.As(err, &vErr))
+			assert.Equal(t, "invalid_attachment", vErr.Type)
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
+
+func TestHandleMessage_AttachmentURLNotAllowed(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "http://files.example.com/r1.pdf", want: `attachment URL scheme "http" is not allowed`},
+		{url: "https://169.254.169.254/latest/meta-data", want: `attachment host "169.254.169.254" is not allowed`},
+		{url: "https://user:pw@files.example.com/r1.pdf", want: "attachment URL must not contain credentials"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			fetcher := &MockAttachmentFetcher{}
+			proc, _ := newAttachmentProcessor(fetcher)
+
+			err := proc.handleMessage(context.Background(), attachmentMessage(tt.url))
+
+			assert.EqualError(t, err, tt.want)
+			fetcher.AssertNotCalled(t, "Fetch", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestHTTPAttachmentFetcher_BlocksNonPublicAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	_, err := newHTTPAttachmentFetcher(1024).Fetch(context.Background(), server.URL)
+
+	assert.ErrorIs(t, err, errAttachmentAddressBlocked)
+}
+
+func TestHTTPAttachmentFetcher_DoesNotFollowRedirects(t *testing.T) {
+	fetcher := newHTTPAttachmentFetcher(4)
+	// Allow loopback for this test only, to exercise redirect and size handling
+	fetcher.client.Transport = http.DefaultTransport
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png; charset=binary")
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer redirect.Close()
+
+	_, err := fetcher.Fetch(context.Background(), redirect.URL)
+	assert.EqualError(t, err, "unexpected status 302")
+
+	meta, err := fetcher.Fetch(context.Background(), target.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", meta.ContentType)
+	// Reading stops one byte past the limit
+	assert.Equal(t, int64(5), meta.SizeBytes)
+}
@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Audit trail actions
+	auditActionStored    = "stored"
+	auditActionDuplicate = "duplicate_skipped"
+
+	// auditAppendExpression appends :entry to audit_trail, creating the
+	// list on the first entry
+	auditAppendExpression = "SET audit_trail = list_append(if_not_exists(audit_trail, :empty), :entry)"
+)
+
+// auditTrail configures the per-order audit trail.
+type auditTrail struct {
+	// processorID identifies this processor in the entries it appends
+	processorID string
+}
+
+// auditEntry is one element of an order's audit_trail list.
+type auditEntry struct {
+	At          time.Time `dynamodbav:"at"`
+	Action      string    `dynamodbav:"action"`
+	ProcessorID string    `dynamodbav:"processor_id"`
+}
+
+// defaultProcessorID is the host name, which is the task or pod name in
+// most deployments.
+func defaultProcessorID() string {
+	if host, err := os.Hostname(); err == nil {
+		return host
+	}
+	return "unknown"
+}
+
+// appendAudit appends an action to order's audit_trail with list_append,
+// so entries from earlier processings are kept. The order is already
+// stored, so a failed append is logged rather than failing the message,
+// which a retry would only record as a duplicate.
+func (p *Processor) appendAudit(ctx context.Context, order Order, action string) {
+	if p.audit == nil || !p.storesToDynamo() {
+		return
+	}
+
+	entry, err := attributevalue.MarshalMap(auditEntry{
+		At:          p.now().UTC(),
+		Action:      action,
+		ProcessorID: p.audit.processorID,
+	})
+	var table string
+	if err == nil {
+		table, err = p.orderTable(order)
+	}
+	if err == nil {
+		_, err = p.ddbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:        &table,
+			Key:              p.tableKey(order),
+			UpdateExpression: aws.String(auditAppendExpression),
+			// Never create an item that holds nothing but a trail
+			ConditionExpression:      aws.String("attribute_exists(#pk)"),
+			ExpressionAttributeNames: map[string]string{"#pk": p.partitionKeyAttr()},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":empty": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+				":entry": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberM{Value: entry}}},
+			},
+		})
+	}
+	if err != nil {
+		log.Error().
+			Str("order_id", order.OrderID).
+			Str("action", action).
+			Err(err).
+			Msg("failed to append to order audit trail")
+	}
+}
@@ -0,0 +1,120 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// auditTable backs mockDDB with a single-order table that applies the
+// conditional put and the audit_trail list_append like DynamoDB does.
+func auditTable(t *testing.T, mockDDB *MockDynamoDBClient) *map[string]dtypes.AttributeValue {
+	var item map[string]dtypes.AttributeValue
+	// The first put stores the order; later ones fail the condition
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { item = args.Get(1).(*dynamodb.PutItemInput).Item }).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), &dtypes.ConditionalCheckFailedException{Message: aws.String("exists")})
+	mockDDB.On("UpdateItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.UpdateItemOutput{}, nil).
+		Run(func(args mock.Arguments) {
+			input := args.Get(1).(*dynamodb.UpdateItemInput)
+			require.Equal(t, auditAppendExpression, aws.ToString(input.UpdateExpression))
+			require.Equal(t, "attribute_exists(#pk)", aws.ToString(input.ConditionExpression))
+			require.NotNil(t, item)
+
+			trail, ok := item["audit_trail"].(*dtypes.AttributeValueMemberL)
+			if !ok {
+				trail = input.ExpressionAttributeValues[":empty"].(*dtypes.AttributeValueMemberL)
+			}
+			entry := input.ExpressionAttributeValues[":entry"].(*dtypes.AttributeValueMemberL)
+			item["audit_trail"] = &dtypes.AttributeValueMemberL{Value: append(append([]dtypes.AttributeValue{}, trail.Value...), entry.Value...)}
+		})
+	return &item
+}
+
+func auditEntryOf(action, at string) dtypes.AttributeValue {
+	return &dtypes.AttributeValueMemberM{Value: map[string]dtypes.AttributeValue{
+		"at":           &dtypes.AttributeValueMemberS{Value: at},
+		"action":       &dtypes.AttributeValueMemberS{Value: action},
+		"processor_id": &dtypes.AttributeValueMemberS{Value: "worker-1"},
+	}}
+}
+
+func TestHandleMessage_AuditTrailAppendsEachProcessing(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	proc := &Processor{
+		ddbClient:       mockDDB,
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		clock:           clock,
+		audit:           &auditTrail{processorID: "worker-1"},
+	}
+	item := auditTable(t, mockDDB)
+	msg := stypes.Message{
+		MessageId: aws.String("msg-1"),
+		Body:      aws.String(`{"order_id":"o1","user_id":"u1","amount":100}`),
+	}
+
+	require.NoError(t, proc.handleMessage(context.Background(), msg))
+	// Two redeliveries, each appended after what came before
+	clock.advance(time.Minute)
+	require.NoError(t, proc.handleMessage(context.Background(), msg))
+	clock.advance(time.Minute)
+	require.NoError(t, proc.handleMessage(context.Background(), msg))
+
+	assert.Equal(t, &dtypes.AttributeValueMemberL{Value: []dtypes.AttributeValue{
+		auditEntryOf("stored", "2024-05-01T08:00:00Z"),
+		auditEntryOf("duplicate_skipped", "2024-05-01T08:01:00Z"),
+		auditEntryOf("duplicate_skipped", "2024-05-01T08:02:00Z"),
+	}}, (*item)["audit_trail"])
+	assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "o1"}, (*item)["order_id"])
+	mockDDB.AssertNumberOfCalls(t, "UpdateItem", 3)
+}
+
+func TestHandleMessage_AuditTrailFailureDoesNotFailOrder(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		ddbClient:       mockDDB,
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		audit:           &auditTrail{processorID: "worker-1"},
+	}
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("UpdateItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.UpdateItemOutput)(nil), errors.New("ProvisionedThroughputExceededException")).Once()
+
+	err := proc.handleMessage(context.Background(), orderMessage("msg-1"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "")))
+	mockDDB.AssertExpectations(t)
+}
+
+func TestHandleMessage_NoAuditTrailByDefault(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		ddbClient:       mockDDB,
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	require.NoError(t, proc.handleMessage(context.Background(), orderMessage("msg-1")))
+	mockDDB.AssertNotCalled(t, "UpdateItem", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,49 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// maxAWSRetries caps AWS_MAX_RETRIES
+const maxAWSRetries = 20
+
+// clientOptions bound how long the AWS clients spend on one call.
+type clientOptions struct {
+	// maxRetries is how often a failed call is retried; negative keeps
+	// the SDK default
+	maxRetries int
+	// httpTimeout bounds each HTTP request, response body included; zero
+	// keeps the SDK default of none
+	httpTimeout time.Duration
+}
+
+// clientOptionsFromEnv reads AWS_MAX_RETRIES and AWS_HTTP_TIMEOUT. A
+// timeout must outlast the long-poll wait, or every empty receive would
+// fail.
+func clientOptionsFromEnv(receive receiveOptions) (clientOptions, error) {
+	opts := clientOptions{
+		maxRetries:  int(envInt64(envAWSMaxRetries, -1, 0, maxAWSRetries)),
+		httpTimeout: envDuration(envAWSHTTPTimeout, 0, 0, time.Hour),
+	}
+	wait := time.Duration(receive.waitTimeSeconds) * time.Second
+	if opts.httpTimeout > 0 && opts.httpTimeout <= wait {
+		return clientOptions{}, fmt.Errorf("%s %s must be longer than the %s receive wait", envAWSHTTPTimeout, opts.httpTimeout, wait)
+	}
+	return opts, nil
+}
+
+// configOptions returns the config.LoadDefaultConfig options applying o.
+func (o clientOptions) configOptions() []func(*config.LoadOptions) error {
+	var cfgOpts []func(*config.LoadOptions) error
+	if o.maxRetries >= 0 {
+		cfgOpts = append(cfgOpts, config.WithRetryMaxAttempts(o.maxRetries+1))
+	}
+	if o.httpTimeout > 0 {
+		cfgOpts = append(cfgOpts, config.WithHTTPClient(awshttp.NewBuildableClient().WithTimeout(o.httpTimeout)))
+	}
+	return cfgOpts
+}
@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientOptionsFromEnv_AppliedToConfig(t *testing.T) {
+	t.Setenv(envAWSMaxRetries, "2")
+	t.Setenv(envAWSHTTPTimeout, "25s")
+
+	opts, err := clientOptionsFromEnv(defaultReceiveOptions)
+	require.NoError(t, err)
+	assert.Equal(t, clientOptions{maxRetries: 2, httpTimeout: 25 * time.Second}, opts)
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), append(opts.configOptions(), config.WithRegion("us-west-2"))...)
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.RetryMaxAttempts)
+	client, ok := cfg.HTTPClient.(*awshttp.BuildableClient)
+	require.True(t, ok)
+	assert.Equal(t, 25*time.Second, client.GetTimeout())
+}
+
+func TestClientOptionsFromEnv_UnsetKeepsSDKDefaults(t *testing.T) {
+	opts, err := clientOptionsFromEnv(defaultReceiveOptions)
+	require.NoError(t, err)
+
+	assert.Empty(t, opts.configOptions())
+}
+
+func TestClientOptionsFromEnv_TimeoutMustOutlastReceiveWait(t *testing.T) {
+	t.Setenv(envAWSHTTPTimeout, "10s")
+
+	_, err := clientOptionsFromEnv(receiveOptions{waitTimeSeconds: 10})
+	assert.ErrorContains(t, err, "must be longer than the 10s receive wait")
+
+	_, err = clientOptionsFromEnv(receiveOptions{waitTimeSeconds: 5})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,85 @@
+package processor
+
+import "time"
+
+const (
+	// Empty-poll backoff: once emptyPollsBeforeBackoff polls in a row
+	// receive nothing, each further one is followed by a sleep doubling
+	// from emptyPollBaseDelay up to EMPTY_POLL_BACKOFF
+	defaultEmptyPollsBeforeBackoff = 3
+	emptyPollBaseDelay             = time.Second
+)
+
+// pollBackoff spaces out retries of failed polls: each consecutive failure
+// doubles the delay from pollRetryDelay up to max, so a sustained outage is
+// not met with a constant rate of calls. A successful poll resets it.
+type pollBackoff struct {
+	max time.Duration
+	// random returns a number in [0, 1) used to jitter the delay
+	random   func() float64
+	failures int
+}
+
+// next returns the delay before retrying after one more failure. It is
+// jittered within the upper half of the backed-off delay, so replicas that
+// failed together spread out while the delay still grows.
+func (b *pollBackoff) next() time.Duration {
+	delay := pollRetryDelay
+	for i := 0; i < b.failures && delay < b.max; i++ {
+		delay *= 2
+	}
+	delay = min(delay, b.max)
+	b.failures++
+
+	return delay/2 + time.Duration(b.random()*float64(delay/2))
+}
+
+// reset starts the next run of failures at pollRetryDelay again.
+func (b *pollBackoff) reset() {
+	b.failures = 0
+}
+
+// emptyPollBackoff adds a sleep between polls of a queue that keeps
+// coming back empty, so idle replicas make fewer ReceiveMessage calls.
+// After the first `after` consecutive empty polls the sleep doubles from
+// emptyPollBaseDelay up to max, jittered as pollBackoff's is; a poll that
+// receives anything resets it, so a busy queue is never slowed. A nil
+// emptyPollBackoff never sleeps.
+type emptyPollBackoff struct {
+	after int
+	max   time.Duration
+	// random returns a number in [0, 1) used to jitter the delay
+	random func() float64
+	empty  int
+}
+
+// newEmptyPollBackoff returns a backoff capped at max, or nil when max is 0.
+func newEmptyPollBackoff(after int, max time.Duration, random func() float64) *emptyPollBackoff {
+	if max <= 0 {
+		return nil
+	}
+	return &emptyPollBackoff{after: after, max: max, random: random}
+}
+
+// next returns the sleep before the poll following one that received
+// received messages.
+func (b *emptyPollBackoff) next(received int) time.Duration {
+	if b == nil {
+		return 0
+	}
+	if received > 0 {
+		b.empty = 0
+		return 0
+	}
+	b.empty++
+	if b.empty < b.after {
+		return 0
+	}
+	delay := emptyPollBaseDelay
+	for i := b.after; i < b.empty && delay < b.max; i++ {
+		delay *= 2
+	}
+	delay = min(delay, b.max)
+
+	return delay/2 + time.Duration(b.random()*float64(delay/2))
+}
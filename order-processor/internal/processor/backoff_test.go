@@ -0,0 +1,146 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPollBackoff_DoublesUpToMaxAndResets(t *testing.T) {
+	b := &pollBackoff{max: 10 * time.Second, random: func() float64 { return 0.5 }}
+
+	var delays []time.Duration
+	for range 5 {
+		delays = append(delays, b.next())
+	}
+	// 2s, 4s, 8s, then capped at 10s, each jittered to 3/4
+	assert.Equal(t, []time.Duration{
+		1500 * time.Millisecond,
+		3 * time.Second,
+		6 * time.Second,
+		7500 * time.Millisecond,
+		7500 * time.Millisecond,
+	}, delays)
+
+	b.reset()
+	assert.Equal(t, 1500*time.Millisecond, b.next())
+}
+
+func TestPollBackoff_JitterStaysInUpperHalf(t *testing.T) {
+	low := &pollBackoff{max: time.Minute, random: func() float64 { return 0 }}
+	high := &pollBackoff{max: time.Minute, random: func() float64 { return 0.999 }}
+
+	assert.Equal(t, pollRetryDelay/2, low.next())
+	assert.Less(t, high.next(), pollRetryDelay)
+}
+
+func TestStart_PollRetriesBackOffUntilSuccess(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	clock := &fakeClock{}
+	proc := &Processor{
+		sqsClient:         mockSQS,
+		queueURL:          "test-queue",
+		tableName:         "Orders",
+		ordersProcessed:   NewCounterVec(),
+		environment:       "test",
+		clock:             clock,
+		randFloat:         func() float64 { return 0.5 },
+		pollMaxRetryDelay: time.Minute,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	empty := &sqs.ReceiveMessageOutput{Messages: []stypes.Message{}}
+	outage := errors.New("service unavailable")
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return((*sqs.ReceiveMessageOutput)(nil), outage).Times(3)
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).Return(empty, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return((*sqs.ReceiveMessageOutput)(nil), outage).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(empty, nil).Once()
+
+	assert.Equal(t, context.Canceled, proc.Start(ctx))
+
+	mockSQS.AssertExpectations(t)
+	assert.Equal(t, []time.Duration{
+		1500 * time.Millisecond,
+		3 * time.Second,
+		6 * time.Second,
+		// Reset by the successful poll
+		1500 * time.Millisecond,
+	}, clock.pollWaits())
+}
+
+func TestEmptyPollBackoff_SleepsAfterRunOfEmptyPolls(t *testing.T) {
+	b := newEmptyPollBackoff(2, 4*time.Second, func() float64 { return 0.5 })
+
+	var delays []time.Duration
+	for range 5 {
+		delays = append(delays, b.next(0))
+	}
+	// None until the second empty poll, then 1s, 2s, 4s and capped at 4s,
+	// each jittered to 3/4
+	assert.Equal(t, []time.Duration{
+		0,
+		750 * time.Millisecond,
+		1500 * time.Millisecond,
+		3 * time.Second,
+		3 * time.Second,
+	}, delays)
+
+	// Anything received resets it
+	assert.Zero(t, b.next(3))
+	assert.Zero(t, b.next(0))
+	assert.Equal(t, 750*time.Millisecond, b.next(0))
+}
+
+func TestEmptyPollBackoff_DisabledNeverSleeps(t *testing.T) {
+	b := newEmptyPollBackoff(1, 0, nil)
+	assert.Nil(t, b)
+	assert.Zero(t, b.next(0))
+}
+
+func TestStart_EmptyPollsBackOff(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	clock := &fakeClock{}
+	proc := &Processor{
+		sqsClient:               mockSQS,
+		ddbClient:               mockDDB,
+		queueURL:                "test-queue",
+		tableName:               "Orders",
+		ordersProcessed:         NewCounterVec(),
+		environment:             "test",
+		clock:                   clock,
+		randFloat:               func() float64 { return 0.5 },
+		emptyPollBackoff:        time.Minute,
+		emptyPollsBeforeBackoff: 2,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	empty := &sqs.ReceiveMessageOutput{Messages: []stypes.Message{}}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).Return(empty, nil).Times(3)
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(1)}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(empty, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, mock.Anything).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+	assert.Equal(t, context.Canceled, proc.Start(ctx))
+
+	mockSQS.AssertExpectations(t)
+	// Sleeps after the second and third empty polls; the order resets the
+	// run, so the final empty poll is the first of a new one
+	assert.Equal(t, []time.Duration{750 * time.Millisecond, 1500 * time.Millisecond}, clock.pollWaits())
+}
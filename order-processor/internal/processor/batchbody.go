@@ -0,0 +1,127 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// batchBodyError is a batch body with orders that could not be stored.
+// It deliberately does not unwrap to a line's *ValidationError: a body is
+// only terminal when every failed line is (see handleBatchBody).
+type batchBodyError struct {
+	failed, total int
+	errs          []error
+}
+
+func (e *batchBodyError) Error() string {
+	return fmt.Sprintf("%d of %d orders in batch body failed: %v", e.failed, e.total, errors.Join(e.errs...))
+}
+
+// bodyLines splits a JSON-lines body into its non-blank lines.
+func bodyLines(body string) []string {
+	var lines []string
+	for line := range strings.Lines(body) {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// handleBatchBody handles a BATCH_BODY message, whose body holds one order
+// per line. Each line is prepared as a message of its own would be, and
+// the valid orders are written together, with BatchWriteItem when they go
+// to DynamoDB. A failed line is logged and the others are still stored.
+// When a line failed retryably the message is redelivered, and the lines
+// already stored are then skipped as duplicates rather than written and
+// counted again. When every failed line is terminal, redelivery cannot
+// help: the body fails as a ValidationError, so the message is recorded
+// or dead-lettered whole and deleted.
+func (p *Processor) handleBatchBody(ctx context.Context, msg types.Message) error {
+	if msg.Body == nil {
+		return &ValidationError{Type: "nil_body", Err: errors.New("message body is nil")}
+	}
+	body, err := p.messageBody(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if err := p.checkBodySize(body); err != nil {
+		return err
+	}
+	lines := bodyLines(body)
+	if len(lines) == 0 {
+		return &ValidationError{Type: "empty_batch_body", Err: errors.New("batch body holds no orders")}
+	}
+
+	var (
+		prepared []*preparedOrder
+		// lineOf is the line number of each prepared order
+		lineOf []int
+		errs   []error
+	)
+	terminal := true
+	fail := func(line int, err error) {
+		logger(ctx).Error().Int("line", line).Err(err).Msg("order in batch body failed")
+		errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+		if failureClass(err) != failureTerminal {
+			terminal = false
+		}
+	}
+	for i, line := range lines {
+		po, err := p.prepareBody(ctx, msg, line)
+		if err == nil && po != nil {
+			err = p.storeLineItems(ctx, msg, po.order)
+		}
+		switch {
+		case err != nil:
+			fail(i+1, err)
+		case po != nil:
+			prepared = append(prepared, po)
+			lineOf = append(lineOf, i+1)
+		}
+	}
+
+	processedAt := p.now().UTC()
+	for _, po := range prepared {
+		po.order.ProcessedAt = processedAt
+	}
+	var writeErrs []error
+	if p.storesToDynamo() {
+		for chunk := range slices.Chunk(prepared, maxBatchWriteItems) {
+			writeErrs = append(writeErrs, p.storeOrders(ctx, chunk)...)
+		}
+	} else {
+		writeErrs = make([]error, len(prepared))
+		for i, po := range prepared {
+			writeErrs[i] = p.orderHandler().Handle(ctx, po.order)
+		}
+	}
+	for i, po := range prepared {
+		switch err := writeErrs[i]; {
+		case isDuplicateWrite(err):
+			p.skipDuplicateOrder(ctx, po)
+		case err != nil:
+			fail(lineOf[i], err)
+		default:
+			p.completeOrder(withOrderLogger(ctx, po.order.OrderID), po)
+		}
+	}
+
+	if len(errs) > 0 {
+		// Shutdown is not a failure of the batch
+		if ctx.Err() != nil {
+			return errors.Join(append(errs, ctx.Err())...)
+		}
+		bErr := &batchBodyError{failed: len(errs), total: len(lines), errs: errs}
+		if terminal {
+			return &ValidationError{Type: "invalid_batch_body", Err: bErr}
+		}
+		return bErr
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchBodyProcessor(mockSQS *MockSQSClient, mockDDB *MockDynamoDBClient) *Processor {
+	return &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		failuresByClass: newFailuresCounter(),
+		environment:     "test",
+		batchBody:       true,
+	}
+}
+
+// batchWriteOf matches a BatchWriteItem of n orders to the Orders table.
+func batchWriteOf(n int) interface{} {
+	return mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		return len(input.RequestItems) == 1 && len(input.RequestItems["Orders"]) == n
+	})
+}
+
+func TestBodyLines(t *testing.T) {
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`}, bodyLines("{\"a\":1}\r\n\n  {\"b\":2}\n"))
+	assert.Empty(t, bodyLines("\n \n"))
+}
+
+func TestPollAndProcess_BatchBodyWritesEveryLine(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBatchBodyProcessor(mockSQS, mockDDB)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{bodyMessage("m1",
+			`{"order_id":"o1","user_id":"u1","amount":100}
+{"order_id":"o2","user_id":"u1","amount":50}
+{"order_id":"o3","user_id":"u2","amount":25}
+`)}}, nil).Once()
+	noneStored(mockDDB)
+	mockDDB.On("BatchWriteItem", mock.Anything, batchWriteOf(3)).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r-m1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 3.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+}
+
+func TestPollAndProcess_BatchBodyInvalidLineIsDeadLettered(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBatchBodyProcessor(mockSQS, mockDDB)
+	proc.dlqURL = "test-dlq"
+	body := `{"order_id":"o1","user_id":"u1","amount":100}
+{"user_id":"u1","amount":50}
+{"order_id":"o3","user_id":"u2","amount":25}`
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{bodyMessage("m1", body)}}, nil).Once()
+	// The valid orders are stored all the same
+	noneStored(mockDDB)
+	mockDDB.On("BatchWriteItem", mock.Anything, batchWriteOf(2)).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+	// Redelivery cannot fix the bad line, so the whole body is dead-lettered
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		return aws.ToString(input.QueueUrl) == "test-dlq" && aws.ToString(input.MessageBody) == body
+	})).Return(&sqs.SendMessageOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r-m1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 2.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.failuresByClass.WithLabelValues(failureTerminal, "test")))
+}
+
+func TestPollAndProcess_BatchBodyRetryableLineKeepsMessage(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBatchBodyProcessor(mockSQS, mockDDB)
+	proc.clock = &fakeClock{}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{bodyMessage("m1",
+			`{"order_id":"o1","user_id":"u1","amount":100}
+{"user_id":"u1","amount":50}`)}}, nil).Once()
+	// o1 is never accepted, so the body fails retryably despite the bad line
+	noneStored(mockDDB)
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.BatchWriteItemOutput{
+			UnprocessedItems: map[string][]dtypes.WriteRequest{"Orders": {putRequestFor("o1")}},
+		}, nil).Times(batchWriteMaxAttempts)
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	mockDDB.AssertExpectations(t)
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.failuresByClass.WithLabelValues(failureRetryable, "test")))
+}
+
+func TestPollAndProcess_BatchBodyRedeliverySkipsStoredLines(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBatchBodyProcessor(mockSQS, mockDDB)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{bodyMessage("m1",
+			`{"order_id":"o1","user_id":"u1","amount":100}
+{"order_id":"o2","user_id":"u1","amount":50}`)}}, nil).Once()
+	// o1 was stored by the delivery that failed on o2
+	mockDDB.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]dtypes.AttributeValue{"Orders": {putRequestFor("o1").PutRequest.Item}},
+	}, nil).Once()
+	mockDDB.On("BatchWriteItem", mock.Anything, batchWriteOf(1)).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r-m1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("duplicate", "test", "test-queue")))
+}
+
+func TestHandleMessage_BatchBodyOfInvalidLinesIsTerminal(t *testing.T) {
+	proc := &Processor{tableName: "Orders", ordersProcessed: NewCounterVec(), batchBody: true}
+
+	err := proc.handleMessage(context.Background(), bodyMessage("m1", "not json\n{\"amount\":1}"))
+
+	var vErr *ValidationError
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "invalid_batch_body", vErr.Type)
+	var bErr *batchBodyError
+	require.ErrorAs(t, err, &bErr)
+	assert.Equal(t, 2, bErr.failed)
+	assert.Equal(t, failureTerminal, failureClass(err))
+	assert.ErrorContains(t, err, "2 of 2 orders in batch body failed: line 1:")
+}
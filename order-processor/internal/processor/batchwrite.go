@@ -0,0 +1,328 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Retries of UnprocessedItems, backing off exponentially from
+	// batchWriteBaseBackoff
+	batchWriteMaxAttempts = 5
+	batchWriteBaseBackoff = 50 * time.Millisecond
+
+	// maxBatchWriteItems is the most items BatchWriteItem takes per call;
+	// a poll receiving more than one receive's worth can exceed it
+	maxBatchWriteItems = 25
+)
+
+// prepareMessage runs the pre-write steps for one message of a batch-write
+// poll. It returns the order to write, or nil and whether the message is
+// done with (see processMessage) when there is nothing to write.
+func (p *Processor) prepareMessage(ctx context.Context, msg types.Message) (*preparedOrder, bool) {
+	ctx = withMessageLogger(ctx, msg)
+	p.recordLifecycle(ctx, msg, stageReceived)
+	defer p.startInFlight(1)()
+
+	// With batch writes the span covers the per-order work only; the
+	// shared write happens after it ends
+	start := time.Now()
+	spanCtx, span := p.startOrderSpan(ctx, msg)
+	handleCtx, cancel := p.withHandleTimeout(spanCtx)
+	prepared, err := p.prepareOrder(handleCtx, msg)
+	if err == nil && prepared != nil {
+		err = p.storeLineItems(handleCtx, msg, prepared.order)
+	}
+	err = p.handleTimeoutError(handleCtx, err)
+	cancel()
+	endSpan(span, err)
+
+	if err != nil {
+		p.observeProcessing(spanCtx, start, err)
+		return nil, p.handleFailure(ctx, msg, err)
+	}
+	if prepared == nil {
+		p.observeProcessing(spanCtx, start, nil)
+		p.recordLifecycle(ctx, msg, stageProcessed)
+		tallyOf(ctx).addSucceeded(1)
+		return nil, true
+	}
+	prepared.start = start
+	prepared.span = trace.SpanContextFromContext(spanCtx)
+	prepared.logger = logger(withOrderLogger(ctx, prepared.order.OrderID))
+	return prepared, false
+}
+
+// storePrepared writes the orders prepared in a poll, with BatchWriteItem
+// when there is more than one and they go to DynamoDB, and sorts their
+// messages into done (to delete) and failed (left for redelivery).
+func (p *Processor) storePrepared(ctx context.Context, prepared []*preparedOrder) (done, failed []types.Message) {
+	defer p.startInFlight(len(prepared))()
+
+	processedAt := p.now().UTC()
+	for _, po := range prepared {
+		po.order.ProcessedAt = processedAt
+	}
+
+	start := time.Now()
+	var errs []error
+	if len(prepared) > 1 && p.storesToDynamo() {
+		for chunk := range slices.Chunk(prepared, maxBatchWriteItems) {
+			errs = append(errs, p.storeOrders(ctx, chunk)...)
+		}
+	} else {
+		// A single order, or a handler other than DynamoDB, which has no
+		// batch form
+		errs = make([]error, len(prepared))
+		for i, po := range prepared {
+			errs[i] = p.orderHandler().Handle(ctx, po.order)
+		}
+	}
+	for _, po := range prepared {
+		p.observePhase(trace.ContextWithSpanContext(ctx, po.span), phaseStore, start)
+	}
+
+	for i, po := range prepared {
+		ctx := ctx
+		if po.logger != nil {
+			ctx = po.logger.WithContext(ctx)
+		}
+		orderCtx := trace.ContextWithSpanContext(ctx, po.span)
+		if isDuplicateWrite(errs[i]) {
+			p.observeProcessing(orderCtx, po.start, nil)
+			p.skipDuplicateOrder(ctx, po)
+			p.recordLifecycle(ctx, po.msg, stageProcessed)
+			tallyOf(ctx).addSucceeded(1)
+			done = append(done, po.msg)
+			continue
+		}
+		p.observeProcessing(orderCtx, po.start, errs[i])
+		if errs[i] != nil {
+			if p.handleFailure(ctx, po.msg, errs[i]) {
+				done = append(done, po.msg)
+			} else {
+				failed = append(failed, po.msg)
+			}
+			continue
+		}
+		p.completeOrder(ctx, po)
+		p.recordLifecycle(ctx, po.msg, stageProcessed)
+		tallyOf(ctx).addSucceeded(1)
+		done = append(done, po.msg)
+	}
+	return done, failed
+}
+
+// storeOrders writes orders with BatchWriteItem, retrying UnprocessedItems
+// with exponential backoff. Orders already stored are not rewritten but
+// fail with ErrDuplicateOrder, like storeOrder's condition (see
+// storedKeys). It returns one error per order, nil for the orders that
+// were persisted. A batch never holds more than the 25 items
+// BatchWriteItem accepts; orders of different tenants share the request,
+// each under its own table.
+func (p *Processor) storeOrders(ctx context.Context, prepared []*preparedOrder) []error {
+	ctx, span := p.startChildSpan(ctx, "batch_write_orders",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int("messaging.batch.message_count", len(prepared))),
+	)
+	defer span.End()
+
+	errs := make([]error, len(prepared))
+
+	// BatchWriteItem rejects a request that writes the same key twice, so
+	// orders repeating a key, which templated and dedup ID keys give
+	// distinct order IDs too, are written one at a time afterwards
+	pending := make(map[itemKey]int, len(prepared))
+	items := make(map[itemKey]map[string]dtypes.AttributeValue, len(prepared))
+	var keys []itemKey
+	var repeats []int
+	for i, po := range prepared {
+		table, err := p.orderTable(po.order)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		item, err := p.orderItem(po.order)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		key := p.itemKeyOf(table, item)
+		if _, ok := pending[key]; ok {
+			repeats = append(repeats, i)
+			continue
+		}
+		pending[key] = i
+		items[key] = item
+		keys = append(keys, key)
+	}
+
+	stored, unchecked, err := p.storedKeys(ctx, keys)
+	if err != nil {
+		for _, i := range pending {
+			errs[i] = fmt.Errorf("failed to look up stored orders: %w", err)
+		}
+		keys = nil
+	}
+	requests := make(map[string][]dtypes.WriteRequest)
+	for _, key := range keys {
+		switch i := pending[key]; {
+		case stored[key]:
+			errs[i] = ErrDuplicateOrder
+			delete(pending, key)
+		case unchecked[key]:
+			repeats = append(repeats, i)
+			delete(pending, key)
+		default:
+			requests[key.table] = append(requests[key.table], dtypes.WriteRequest{PutRequest: &dtypes.PutRequest{Item: items[key]}})
+		}
+	}
+	span.SetAttributes(attribute.StringSlice("aws.dynamodb.table_names", slices.Sorted(maps.Keys(requests))))
+
+	backoff := batchWriteBaseBackoff
+	for attempt := 1; len(requests) > 0; attempt++ {
+		out, err := p.ddbClient.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: requests,
+		})
+		if err != nil {
+			for _, i := range pending {
+				errs[i] = fmt.Errorf("failed to batch write to DynamoDB: %w", err)
+			}
+			break
+		}
+
+		requests = out.UnprocessedItems
+		unprocessed := make(map[itemKey]int, len(pending))
+		for table, reqs := range requests {
+			for _, req := range reqs {
+				if req.PutRequest == nil {
+					continue
+				}
+				key := p.itemKeyOf(table, req.PutRequest.Item)
+				if i, ok := pending[key]; ok {
+					unprocessed[key] = i
+				}
+			}
+		}
+		pending = unprocessed
+
+		if len(requests) == 0 {
+			break
+		}
+		if attempt == batchWriteMaxAttempts {
+			for _, i := range pending {
+				errs[i] = fmt.Errorf("order not persisted after %d BatchWriteItem attempts", attempt)
+			}
+			break
+		}
+		select {
+		case <-ctx.Done():
+			for _, i := range pending {
+				errs[i] = fmt.Errorf("batch write interrupted: %w", ctx.Err())
+			}
+			return errs
+		case <-p.after(backoff):
+		}
+		backoff *= 2
+	}
+
+	slices.Sort(repeats)
+	for _, i := range repeats {
+		errs[i] = p.storeOrder(ctx, prepared[i].order)
+	}
+	return errs
+}
+
+// storedKeys looks keys up with one consistent BatchGetItem, returning
+// those naming an item already stored and those DynamoDB left
+// unprocessed, which storeOrders writes with storeOrder's conditional put
+// instead. BatchWriteItem cannot be conditional, so this check stands in
+// for the condition; an order first stored by another writer between the
+// lookup and the write is still overwritten. A batch's at most 25 keys are
+// well within the 100 BatchGetItem takes.
+func (p *Processor) storedKeys(ctx context.Context, keys []itemKey) (stored, unchecked map[itemKey]bool, err error) {
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+	names := map[string]string{"#pk": p.partitionKeyAttr()}
+	projection := "#pk"
+	if p.keys.sortAttr != "" {
+		names["#sk"] = p.keys.sortAttr
+		projection += ", #sk"
+	}
+	request := make(map[string]dtypes.KeysAndAttributes)
+	for _, key := range keys {
+		lookup, ok := request[key.table]
+		if !ok {
+			lookup = dtypes.KeysAndAttributes{
+				ConsistentRead:           aws.Bool(true),
+				ProjectionExpression:     aws.String(projection),
+				ExpressionAttributeNames: names,
+			}
+		}
+		lookup.Keys = append(lookup.Keys, p.keyAttrsOf(key))
+		request[key.table] = lookup
+	}
+
+	out, err := p.ddbClient.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: request})
+	if err != nil {
+		return nil, nil, err
+	}
+	stored = make(map[itemKey]bool)
+	for table, items := range out.Responses {
+		for _, item := range items {
+			stored[p.itemKeyOf(table, item)] = true
+		}
+	}
+	unchecked = make(map[itemKey]bool)
+	for table, lookup := range out.UnprocessedKeys {
+		for _, attrs := range lookup.Keys {
+			unchecked[p.itemKeyOf(table, attrs)] = true
+		}
+	}
+	return stored, unchecked, nil
+}
+
+// itemKey identifies an item written by storeOrders by its table and
+// primary key.
+type itemKey struct {
+	table     string
+	partition string
+	sort      string
+}
+
+// itemKeyOf returns the key of item, an order item of table.
+func (p *Processor) itemKeyOf(table string, item map[string]dtypes.AttributeValue) itemKey {
+	key := itemKey{table: table}
+	if v, ok := item[p.partitionKeyAttr()].(*dtypes.AttributeValueMemberS); ok {
+		key.partition = v.Value
+	}
+	if p.keys.sortAttr != "" {
+		if v, ok := item[p.keys.sortAttr].(*dtypes.AttributeValueMemberS); ok {
+			key.sort = v.Value
+		}
+	}
+	return key
+}
+
+// keyAttrsOf returns the primary key attributes of key, the inverse of
+// itemKeyOf.
+func (p *Processor) keyAttrsOf(key itemKey) map[string]dtypes.AttributeValue {
+	attrs := map[string]dtypes.AttributeValue{
+		p.partitionKeyAttr(): &dtypes.AttributeValueMemberS{Value: key.partition},
+	}
+	if p.keys.sortAttr != "" && key.sort != "" {
+		attrs[p.keys.sortAttr] = &dtypes.AttributeValueMemberS{Value: key.sort}
+	}
+	return attrs
+}
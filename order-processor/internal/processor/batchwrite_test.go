@@ -0,0 +1,286 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchWriteProcessor(mockSQS *MockSQSClient, mockDDB *MockDynamoDBClient) *Processor {
+	return &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		batchWrite:      true,
+	}
+}
+
+// noneStored has mockDDB find none of a batch's orders already stored.
+func noneStored(mockDDB *MockDynamoDBClient) {
+	mockDDB.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{}, nil)
+}
+
+// putRequestFor is an UnprocessedItems entry for the given order.
+func putRequestFor(orderID string) dtypes.WriteRequest {
+	return dtypes.WriteRequest{PutRequest: &dtypes.PutRequest{Item: map[string]dtypes.AttributeValue{
+		"order_id": &dtypes.AttributeValueMemberS{Value: orderID},
+	}}}
+}
+
+func TestPollAndProcess_BatchWriteSingleCall(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBatchWriteProcessor(mockSQS, mockDDB)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(3)}, nil)
+	noneStored(mockDDB)
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		return len(input.RequestItems["Orders"]) == 3
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+	assert.Equal(t, 3.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+}
+
+func TestPollAndProcess_BatchWriteRetriesUnprocessedItems(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBatchWriteProcessor(mockSQS, mockDDB)
+	clock := &fakeClock{}
+	proc.clock = clock
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(3)}, nil)
+	noneStored(mockDDB)
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		return len(input.RequestItems["Orders"]) == 3
+	})).Return(&dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]dtypes.WriteRequest{"Orders": {putRequestFor("o1")}},
+	}, nil).Once()
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		reqs := input.RequestItems["Orders"]
+		return len(reqs) == 1 && proc.itemKeyOf("Orders", reqs[0].PutRequest.Item).partition == "o1"
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 3.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	assert.Equal(t, []time.Duration{batchWriteBaseBackoff}, clock.waits)
+}
+
+func TestPollAndProcess_BatchWriteCountsOnlyPersisted(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBatchWriteProcessor(mockSQS, mockDDB)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(2)}, nil)
+	// o1 is never accepted
+	noneStored(mockDDB)
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.BatchWriteItemOutput{
+			UnprocessedItems: map[string][]dtypes.WriteRequest{"Orders": {putRequestFor("o1")}},
+		}, nil).Times(batchWriteMaxAttempts)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue")))
+}
+
+func TestPollAndProcess_BatchWriteSingleOrderDuplicate(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBatchWriteProcessor(mockSQS, mockDDB)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(1)}, nil)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), &dtypes.ConditionalCheckFailedException{}).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("duplicate", "test", "test-queue")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+}
+
+func TestStoreOrders_CallErrorFailsAll(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders"}
+
+	noneStored(mockDDB)
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.BatchWriteItemOutput)(nil), errors.New("DynamoDB error")).Once()
+
+	errs := proc.storeOrders(context.Background(), []*preparedOrder{
+		{order: Order{OrderID: "o1"}},
+		{order: Order{OrderID: "o2"}},
+	})
+
+	assert.Len(t, errs, 2)
+	for _, err := range errs {
+		assert.ErrorContains(t, err, "DynamoDB error")
+	}
+}
+
+func TestStoreOrders_RepeatedOrderIDWrittenSeparately(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders"}
+
+	noneStored(mockDDB)
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		return len(input.RequestItems["Orders"]) == 2
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	errs := proc.storeOrders(context.Background(), []*preparedOrder{
+		{order: Order{OrderID: "o1"}},
+		{order: Order{OrderID: "o2"}},
+		{order: Order{OrderID: "o1"}},
+	})
+
+	assert.Equal(t, []error{nil, nil, nil}, errs)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestStoreOrders_RepeatedTemplatedKeyWrittenSeparately(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	layout, err := templatedKeyLayout(keyLayout{partitionAttr: "pk"}, "USER#{user_id}", "")
+	require.NoError(t, err)
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders", keys: layout}
+
+	// Three orders of one user share a partition key, so only the first
+	// may go in the batch
+	noneStored(mockDDB)
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		reqs := input.RequestItems["Orders"]
+		return len(reqs) == 2 &&
+			proc.itemKeyOf("Orders", reqs[0].PutRequest.Item) != proc.itemKeyOf("Orders", reqs[1].PutRequest.Item)
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Twice()
+
+	errs := proc.storeOrders(context.Background(), []*preparedOrder{
+		{order: Order{OrderID: "o1", UserID: "u1"}},
+		{order: Order{OrderID: "o2", UserID: "u1"}},
+		{order: Order{OrderID: "o3", UserID: "u2"}},
+		{order: Order{OrderID: "o4", UserID: "u1"}},
+	})
+
+	assert.Equal(t, []error{nil, nil, nil, nil}, errs)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestPollAndProcess_BatchWriteSkipsStoredOrders(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBatchWriteProcessor(mockSQS, mockDDB)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(3)}, nil)
+	// o1 was stored by an earlier delivery
+	mockDDB.On("BatchGetItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchGetItemInput) bool {
+		lookup := input.RequestItems["Orders"]
+		return len(lookup.Keys) == 3 && aws.ToBool(lookup.ConsistentRead)
+	})).Return(&dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]dtypes.AttributeValue{"Orders": {putRequestFor("o1").PutRequest.Item}},
+	}, nil).Once()
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		reqs := input.RequestItems["Orders"]
+		return len(reqs) == 2 &&
+			proc.itemKeyOf("Orders", reqs[0].PutRequest.Item).partition == "o0" &&
+			proc.itemKeyOf("Orders", reqs[1].PutRequest.Item).partition == "o2"
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 2.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("duplicate", "test", "test-queue")))
+}
+
+func TestStoreOrders_UncheckedKeysWrittenConditionally(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders"}
+
+	mockDDB.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{
+		UnprocessedKeys: map[string]dtypes.KeysAndAttributes{"Orders": {
+			Keys: []map[string]dtypes.AttributeValue{putRequestFor("o2").PutRequest.Item},
+		}},
+	}, nil).Once()
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		return len(input.RequestItems["Orders"]) == 1
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+	// The order the lookup missed is put under storeOrder's condition
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return aws.ToString(input.ConditionExpression) == "attribute_not_exists(#pk)"
+	})).Return((*dynamodb.PutItemOutput)(nil), &dtypes.ConditionalCheckFailedException{}).Once()
+
+	errs := proc.storeOrders(context.Background(), []*preparedOrder{
+		{order: Order{OrderID: "o1"}},
+		{order: Order{OrderID: "o2"}},
+	})
+
+	assert.NoError(t, errs[0])
+	assert.True(t, isDuplicateWrite(errs[1]))
+	mockDDB.AssertExpectations(t)
+}
+
+func TestStoreOrders_LookupErrorFailsAll(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders"}
+
+	mockDDB.On("BatchGetItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.BatchGetItemOutput)(nil), errors.New("throttled")).Once()
+
+	errs := proc.storeOrders(context.Background(), []*preparedOrder{
+		{order: Order{OrderID: "o1"}},
+		{order: Order{OrderID: "o2"}},
+	})
+
+	for _, err := range errs {
+		assert.ErrorContains(t, err, "failed to look up stored orders: throttled")
+		assert.Equal(t, failureRetryable, failureClass(err))
+	}
+	mockDDB.AssertNotCalled(t, "BatchWriteItem", mock.Anything, mock.Anything)
+}
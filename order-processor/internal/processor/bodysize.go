@@ -0,0 +1,19 @@
+package processor
+
+import "fmt"
+
+// defaultMaxBodyBytes is the largest body SQS accepts, 256 KiB
+const defaultMaxBodyBytes = 256 * 1024
+
+// checkBodySize rejects a message body over MAX_BODY_BYTES before it is
+// parsed, so an oversized body is dead-lettered rather than unmarshalled
+// on every redelivery. A maxBodyBytes of 0 accepts any size.
+func (p *Processor) checkBodySize(body string) error {
+	if p.maxBodyBytes <= 0 || len(body) <= p.maxBodyBytes {
+		return nil
+	}
+	return &ValidationError{
+		Type: "body_too_large",
+		Err:  fmt.Errorf("message body is %d bytes, over the %d byte limit", len(body), p.maxBodyBytes),
+	}
+}
@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckBodySize(t *testing.T) {
+	proc := &Processor{maxBodyBytes: 10}
+
+	assert.NoError(t, proc.checkBodySize(strings.Repeat("x", 10)))
+
+	err := proc.checkBodySize(strings.Repeat("x", 11))
+	var vErr *ValidationError
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "body_too_large", vErr.Type)
+	assert.EqualError(t, err, "message body is 11 bytes, over the 10 byte limit")
+
+	// Unbounded when unset
+	assert.NoError(t, (&Processor{}).checkBodySize(strings.Repeat("x", 1<<20)))
+}
+
+func TestPollAndProcess_OversizedBodyIsTerminal(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		failuresByClass: newFailuresCounter(),
+		environment:     "test",
+		maxBodyBytes:    64,
+	}
+	body := `{"order_id":"o1","user_id":"u1","amount":100,"note":"` + strings.Repeat("x", 64) + `"}`
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{bodyMessage("m1", body)}}, nil).Once()
+	// Dropped without being parsed or stored
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r-m1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.failuresByClass.WithLabelValues(failureTerminal, "test")))
+}
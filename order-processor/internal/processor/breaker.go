@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// Circuit breaker defaults
+const (
+	defaultReceiveBreakerThreshold = 5
+	defaultReceiveBreakerCooldown  = 30 * time.Second
+	defaultWriteBreakerThreshold   = 10
+	defaultWriteBreakerCooldown    = 30 * time.Second
+)
+
+var (
+	// errReceiveBreakerOpen is returned by a poll made while the receive
+	// breaker is open; SQS is not called.
+	errReceiveBreakerOpen = errors.New("sqs receive circuit breaker is open")
+	// errWriteBreakerOpen is returned for an order stored while the write
+	// breaker is open; DynamoDB is not called and, being retryable, the
+	// message stays on the queue.
+	errWriteBreakerOpen = errors.New("dynamodb write circuit breaker is open")
+)
+
+// breakerState is the state of a circuit breaker, exported as the value of
+// its gauge.
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops calls to a failing dependency after threshold
+// consecutive failures. While open, calls fail without being made until
+// cooldown has passed; the next call is then let through as a probe
+// (half-open), which closes the breaker if it succeeds and reopens it if
+// it fails. Other calls are held back while the probe is in flight, or
+// until cooldown has passed again should its outcome never be recorded.
+// A nil circuitBreaker never opens.
+type circuitBreaker struct {
+	// name is the breaker's dependency in its logs
+	name      string
+	threshold int
+	cooldown  time.Duration
+	// gauge, when set, holds the current breakerState
+	gauge prometheus.Gauge
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	// probeAt is when the half-open probe was let through
+	probeAt time.Time
+}
+
+// newCircuitBreaker returns a breaker opening after threshold consecutive
+// failures, or nil when threshold is 0.
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration, gauge prometheus.Gauge) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	b := &circuitBreaker{name: name, threshold: threshold, cooldown: cooldown, gauge: gauge}
+	b.setState(breakerClosed)
+	return b
+}
+
+// newReceiveBreaker returns the breaker around ReceiveMessage.
+func newReceiveBreaker(threshold int, cooldown time.Duration, gauge prometheus.Gauge) *circuitBreaker {
+	return newCircuitBreaker("sqs receive", threshold, cooldown, gauge)
+}
+
+// newWriteBreaker returns the breaker around the order PutItem.
+func newWriteBreaker(threshold int, cooldown time.Duration, gauge prometheus.Gauge) *circuitBreaker {
+	return newCircuitBreaker("dynamodb write", threshold, cooldown, gauge)
+}
+
+func newReceiveBreakerGauge() prometheus.Gauge {
+	return prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sqs_receive_breaker_state",
+		Help: "State of the SQS receive circuit breaker: 0 closed, 1 open, 2 half-open",
+	})
+}
+
+func newWriteBreakerGauge() prometheus.Gauge {
+	return prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "dynamodb_write_breaker_state",
+		Help: "State of the DynamoDB order write circuit breaker: 0 closed, 1 open, 2 half-open",
+	})
+}
+
+// allow reports whether a call may be made at now, moving an open breaker
+// whose cooldown has passed to half-open.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if now.Before(b.openedAt.Add(b.cooldown)) {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+	case breakerHalfOpen:
+		if now.Before(b.probeAt.Add(b.cooldown)) {
+			return false
+		}
+	default:
+		return true
+	}
+	b.probeAt = now
+	return true
+}
+
+// retryIn returns how long until an open breaker lets a probe through, or
+// 0 when it is not open.
+func (b *circuitBreaker) retryIn(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != breakerOpen {
+		return 0
+	}
+	return max(b.openedAt.Add(b.cooldown).Sub(now), 0)
+}
+
+// record counts the outcome of a call made at now.
+func (b *circuitBreaker) record(now time.Time, err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		b.setState(breakerClosed)
+		return
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = now
+		b.setState(breakerOpen)
+	}
+}
+
+// setState moves the breaker to state; b.mu must be held.
+func (b *circuitBreaker) setState(state breakerState) {
+	if state != b.state {
+		log.Warn().
+			Str("breaker", b.name).
+			Stringer("from", b.state).
+			Stringer("to", state).
+			Int("consecutive_failures", b.failures).
+			Msg("circuit breaker changed state")
+	}
+	b.state = state
+	if b.gauge != nil {
+		b.gauge.Set(float64(state))
+	}
+}
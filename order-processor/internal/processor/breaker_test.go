@@ -0,0 +1,209 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiveBreaker_States(t *testing.T) {
+	gauge := newReceiveBreakerGauge()
+	b := newReceiveBreaker(3, 30*time.Second, gauge)
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	outage := errors.New("service unavailable")
+
+	// Closed until the third consecutive failure
+	b.record(start, outage)
+	b.record(start, outage)
+	assert.True(t, b.allow(start))
+	assert.Equal(t, 0.0, testutil.ToFloat64(gauge))
+	b.record(start, outage)
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge))
+
+	// Open for the cooldown
+	assert.False(t, b.allow(start.Add(10*time.Second)))
+	assert.Equal(t, 20*time.Second, b.retryIn(start.Add(10*time.Second)))
+
+	// Half-open once it has passed; a failed probe reopens
+	probe := start.Add(30 * time.Second)
+	assert.True(t, b.allow(probe))
+	assert.Equal(t, 2.0, testutil.ToFloat64(gauge))
+	assert.Zero(t, b.retryIn(probe))
+	b.record(probe, outage)
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge))
+	assert.False(t, b.allow(probe.Add(29*time.Second)))
+
+	// A successful probe closes it and clears the failure count
+	probe = probe.Add(30 * time.Second)
+	assert.True(t, b.allow(probe))
+	b.record(probe, nil)
+	assert.Equal(t, 0.0, testutil.ToFloat64(gauge))
+	b.record(probe, outage)
+	assert.True(t, b.allow(probe))
+}
+
+func TestReceiveBreaker_DisabledNeverOpens(t *testing.T) {
+	b := newReceiveBreaker(0, time.Minute, nil)
+	assert.Nil(t, b)
+
+	for range 10 {
+		b.record(time.Time{}, errors.New("service unavailable"))
+	}
+	assert.True(t, b.allow(time.Time{}))
+	assert.Zero(t, b.retryIn(time.Time{}))
+}
+
+func TestPollAndProcess_OpenReceiveBreakerSkipsSQS(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		clock:           fixedClock{at: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)},
+		receiveBreaker:  newReceiveBreaker(1, time.Minute, nil),
+	}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return((*sqs.ReceiveMessageOutput)(nil), errors.New("service unavailable")).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.Error(t, err)
+	_, err = proc.pollAndProcess(context.Background())
+	assert.ErrorIs(t, err, errReceiveBreakerOpen)
+	mockSQS.AssertNumberOfCalls(t, "ReceiveMessage", 1)
+}
+
+func TestPollAndProcess_ShutdownDoesNotTripReceiveBreaker(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		receiveBreaker:  newReceiveBreaker(1, time.Minute, nil),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return((*sqs.ReceiveMessageOutput)(nil), context.Canceled).Once()
+
+	_, err := proc.pollAndProcess(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.True(t, proc.receiveBreaker.allow(time.Now()))
+}
+
+func TestStart_ReceiveBreakerWaitsOutCooldown(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	clock := &fakeClock{}
+	gauge := newReceiveBreakerGauge()
+	proc := &Processor{
+		sqsClient:         mockSQS,
+		queueURL:          "test-queue",
+		tableName:         "Orders",
+		ordersProcessed:   NewCounterVec(),
+		environment:       "test",
+		clock:             clock,
+		randFloat:         func() float64 { return 0.5 },
+		pollMaxRetryDelay: time.Minute,
+		receiveBreaker:    newReceiveBreaker(2, 30*time.Second, gauge),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	outage := errors.New("service unavailable")
+	// Two failures open the breaker, then a failed and a successful probe
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return((*sqs.ReceiveMessageOutput)(nil), outage).Times(3)
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{}}, nil).Once()
+
+	assert.Equal(t, context.Canceled, proc.Start(ctx))
+
+	mockSQS.AssertExpectations(t)
+	assert.Equal(t, []time.Duration{
+		1500 * time.Millisecond,
+		// Each wait for the probe is the cooldown, not the backoff
+		30 * time.Second,
+		30 * time.Second,
+	}, clock.pollWaits())
+	assert.Equal(t, 0.0, testutil.ToFloat64(gauge))
+}
+
+func TestCircuitBreaker_HalfOpenLetsOneProbeThrough(t *testing.T) {
+	b := newWriteBreaker(1, 30*time.Second, nil)
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	b.record(start, errors.New("service unavailable"))
+
+	probe := start.Add(30 * time.Second)
+	assert.True(t, b.allow(probe))
+	assert.False(t, b.allow(probe.Add(time.Second)), "second call during the probe")
+	// A probe whose outcome is never recorded is replaced after the cooldown
+	assert.True(t, b.allow(probe.Add(30*time.Second)))
+	b.record(probe.Add(31*time.Second), nil)
+	assert.True(t, b.allow(probe.Add(31*time.Second)))
+	assert.True(t, b.allow(probe.Add(31*time.Second)))
+}
+
+func TestStoreOrder_WriteBreakerOpensOnDynamoFailures(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	gauge := newWriteBreakerGauge()
+	proc := &Processor{
+		ddbClient:    mockDDB,
+		tableName:    "Orders",
+		clock:        clock,
+		writeBreaker: newWriteBreaker(2, 30*time.Second, gauge),
+	}
+	order := Order{OrderID: "o1", UserID: "u1"}
+
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), &dtypes.InternalServerError{}).Twice()
+	assert.Error(t, proc.storeOrder(context.Background(), order))
+	assert.Error(t, proc.storeOrder(context.Background(), order))
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge))
+
+	// Open: DynamoDB is not called and the message is left for redelivery
+	err := proc.storeOrder(context.Background(), order)
+	assert.ErrorIs(t, err, errWriteBreakerOpen)
+	assert.Equal(t, failureRetryable, failureClass(err))
+	mockDDB.AssertNumberOfCalls(t, "PutItem", 2)
+
+	// A successful probe closes it
+	clock.advance(30 * time.Second)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	require.NoError(t, proc.storeOrder(context.Background(), order))
+	assert.Equal(t, 0.0, testutil.ToFloat64(gauge))
+	mockDDB.AssertExpectations(t)
+}
+
+func TestStoreOrder_RefusedWritesDoNotTripWriteBreaker(t *testing.T) {
+	for _, refusal := range []error{
+		&dtypes.ConditionalCheckFailedException{},
+		&smithy.GenericAPIError{Code: "ValidationException", Fault: smithy.FaultClient},
+	} {
+		mockDDB := &MockDynamoDBClient{}
+		proc := &Processor{
+			ddbClient:    mockDDB,
+			tableName:    "Orders",
+			writeBreaker: newWriteBreaker(1, time.Minute, nil),
+		}
+		mockDDB.On("PutItem", mock.Anything, mock.Anything).Return((*dynamodb.PutItemOutput)(nil), refusal).Twice()
+
+		assert.ErrorIs(t, proc.storeOrder(context.Background(), Order{OrderID: "o1", UserID: "u1"}), refusal)
+		assert.ErrorIs(t, proc.storeOrder(context.Background(), Order{OrderID: "o1", UserID: "u1"}), refusal)
+		mockDDB.AssertExpectations(t)
+	}
+}
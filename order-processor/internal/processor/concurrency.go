@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// Worker pool bounds; SQS never returns more than 10 messages per receive,
+// so more workers than that would sit idle
+const (
+	minWorkerCount = 1
+	maxWorkerCount = 10
+)
+
+// SetConcurrency changes how many messages of a batch are processed at
+// once. The next batch uses the new value; a batch already in progress
+// finishes with the old one.
+func (p *Processor) SetConcurrency(n int) error {
+	if n < minWorkerCount || n > maxWorkerCount {
+		return fmt.Errorf("concurrency must be between %d and %d, got %d", minWorkerCount, maxWorkerCount, n)
+	}
+	if old := p.workerCount.Swap(int64(n)); old != int64(n) {
+		log.Info().Int64("from", old).Int("to", n).Msg("worker concurrency changed")
+	}
+	if p.concurrencyGauge != nil {
+		p.concurrencyGauge.Set(float64(n))
+	}
+	return nil
+}
+
+// concurrency returns the current worker count, treating unset as 1.
+func (p *Processor) concurrency() int {
+	return int(max(p.workerCount.Load(), minWorkerCount))
+}
+
+// handleConcurrency serves GET (report) and POST ?n=N (resize) on the
+// concurrency admin endpoint.
+func (p *Processor) handleConcurrency(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		n, err := strconv.Atoi(r.URL.Query().Get("n"))
+		if err != nil {
+			http.Error(w, "n must be an integer", http.StatusBadRequest)
+			return
+		}
+		if err := p.SetConcurrency(n); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, fmt.Sprintf(`{"concurrency":%d}`, p.concurrency()))
+}
+
+func newConcurrencyGauge() prometheus.Gauge {
+	return prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "processor_concurrency",
+		Help: "Messages of a batch processed concurrently",
+	})
+}
@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// peakConcurrentWrites runs one poll of n messages and returns the most
+// PutItem calls that were in flight at once.
+func peakConcurrentWrites(t *testing.T, proc *Processor, n int) int64 {
+	t.Helper()
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc.sqsClient = mockSQS
+	proc.ddbClient = mockDDB
+
+	var inFlight, peak atomic.Int64
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(n)}, nil)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			cur := inFlight.Add(1)
+			for {
+				old := peak.Load()
+				if cur <= old || peak.CompareAndSwap(old, cur) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			inFlight.Add(-1)
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, mock.Anything).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil)
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+	return peak.Load()
+}
+
+func TestAdminConcurrencyEndpoint_ResizesPool(t *testing.T) {
+	proc := &Processor{
+		queueURL:         "test-queue",
+		tableName:        "Orders",
+		ordersProcessed:  NewCounterVec(),
+		environment:      "test",
+		concurrencyGauge: newConcurrencyGauge(),
+	}
+	mux := http.NewServeMux()
+	proc.registerAdminHandlers(mux, "secret")
+
+	post := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, int64(1), peakConcurrentWrites(t, proc, 6))
+
+	rec := post("/admin/concurrency?n=3")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"concurrency":3}`, rec.Body.String())
+	assert.Equal(t, 3.0, testutil.ToFloat64(proc.concurrencyGauge))
+	assert.Equal(t, int64(3), peakConcurrentWrites(t, proc, 6))
+
+	// Out-of-bounds and malformed values leave the pool alone
+	assert.Equal(t, http.StatusBadRequest, post("/admin/concurrency?n=0").Code)
+	assert.Equal(t, http.StatusBadRequest, post("/admin/concurrency?n=11").Code)
+	assert.Equal(t, http.StatusBadRequest, post("/admin/concurrency?n=lots").Code)
+	assert.Equal(t, 3, proc.concurrency())
+}
+
+func TestAdminConcurrencyEndpoint_RequiresToken(t *testing.T) {
+	proc := &Processor{}
+	mux := http.NewServeMux()
+	proc.registerAdminHandlers(mux, "secret")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/concurrency?n=5", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, 1, proc.concurrency())
+}
@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/rs/zerolog/log"
+)
+
+// configView is the effective configuration served on /config. It holds
+// nothing secret: credentials are only described by their source.
+type configView struct {
+	QueueURLs   []string `json:"queue_urls"`
+	TableName   string   `json:"table_name"`
+	Environment string   `json:"environment"`
+	Region      string   `json:"region"`
+	Endpoint    string   `json:"endpoint,omitempty"`
+	Credentials string   `json:"credentials"`
+	Poll        struct {
+		MaxMessages       int32  `json:"max_messages"`
+		WaitTimeSeconds   int32  `json:"wait_time_seconds"`
+		VisibilityTimeout int32  `json:"visibility_timeout"`
+		ReceiveTarget     int    `json:"receive_target"`
+		PollersPerQueue   int    `json:"pollers_per_queue"`
+		MaxRetryDelay     string `json:"max_retry_delay"`
+		EmptyPollBackoff  string `json:"empty_poll_backoff"`
+	} `json:"poll"`
+	Workers  int  `json:"workers"`
+	ReadOnly bool `json:"read_only"`
+	DryRun   bool `json:"dry_run"`
+}
+
+// credentialsSource names where the AWS credentials come from: the source
+// of static ones, such as "env", or the SDK's default chain.
+func credentialsSource(provider aws.CredentialsProvider) string {
+	if static, ok := provider.(credentials.StaticCredentialsProvider); ok {
+		return static.Value.Source
+	}
+	return "default_chain"
+}
+
+// redactURL hides any password in rawURL.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "[invalid]"
+	}
+	return u.Redacted()
+}
+
+// configView returns the configuration p is running with.
+func (p *Processor) configView() configView {
+	var v configView
+	for _, q := range p.pollQueues() {
+		v.QueueURLs = append(v.QueueURLs, q.url)
+	}
+	v.TableName = p.tableName
+	v.Environment = p.environment
+	v.Region = p.region
+	if p.awsEndpoint != "" {
+		v.Endpoint = redactURL(p.awsEndpoint)
+	}
+	v.Credentials = p.credentialsSource
+
+	receive := p.receiveOptions()
+	v.Poll.MaxMessages = receive.maxMessages
+	v.Poll.WaitTimeSeconds = receive.waitTimeSeconds
+	v.Poll.VisibilityTimeout = receive.visibilityTimeout
+	v.Poll.ReceiveTarget = max(p.receiveTarget, int(receive.maxMessages))
+	v.Poll.PollersPerQueue = max(p.pollersPerQueue, 1)
+	maxRetryDelay := p.pollMaxRetryDelay
+	if maxRetryDelay <= 0 {
+		maxRetryDelay = defaultPollMaxRetryDelay
+	}
+	v.Poll.MaxRetryDelay = maxRetryDelay.String()
+	v.Poll.EmptyPollBackoff = p.emptyPollBackoff.String()
+
+	v.Workers = p.concurrency()
+	v.ReadOnly = p.readOnly.Load()
+	v.DryRun = p.dryRun
+	return v
+}
+
+// handleConfig serves GET /config.
+func (p *Processor) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := json.Marshal(p.configView())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to encode config")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, string(body))
+}
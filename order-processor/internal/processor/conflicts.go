@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog/log"
+)
+
+// conflictCandidate is the message currently winning an order ID in a
+// batch, with its tiebreaker value.
+type conflictCandidate struct {
+	index      int
+	msg        types.Message
+	tiebreaker json.RawMessage
+}
+
+// resolveConflicts keeps one message per order ID in a batch when
+// conflictField is set, so two versions of an order racing into the same
+// batch are applied deterministically instead of whichever write lands
+// first. The message with the greatest conflictField value wins; a message
+// without the field loses to one with it, and on a tie or incomparable
+// values the later message in the batch wins. Losers are returned as
+// superseded, to be deleted unprocessed. Messages whose order ID cannot be
+// read are kept, to fail as they otherwise would.
+func (p *Processor) resolveConflicts(ctx context.Context, msgs []types.Message) (kept, superseded []types.Message) {
+	if p.conflictField == "" || len(msgs) < 2 {
+		return msgs, nil
+	}
+
+	winners := make(map[string]conflictCandidate)
+	lost := make(map[int]bool)
+	for i, msg := range msgs {
+		orderID, tiebreaker, ok := p.conflictKey(ctx, msg)
+		if !ok {
+			continue
+		}
+		candidate := conflictCandidate{index: i, msg: msg, tiebreaker: tiebreaker}
+		winner, seen := winners[orderID]
+		if !seen {
+			winners[orderID] = candidate
+			continue
+		}
+
+		loser := candidate
+		if compareTiebreakers(tiebreaker, winner.tiebreaker) >= 0 {
+			loser, winners[orderID] = winner, candidate
+		}
+		lost[loser.index] = true
+		p.countOrder(ctx, "superseded")
+		log.Info().
+			Str("order_id", orderID).
+			Str("msg_id", aws.ToString(loser.msg.MessageId)).
+			Str("winner_msg_id", aws.ToString(winners[orderID].msg.MessageId)).
+			Str("field", p.conflictField).
+			Msg("order superseded by another message in the batch - skipping")
+	}
+
+	for i, msg := range msgs {
+		if lost[i] {
+			superseded = append(superseded, msg)
+		} else {
+			kept = append(kept, msg)
+		}
+	}
+	return kept, superseded
+}
+
+// conflictKey reads the normalized order ID and the raw conflictField
+// value of msg. The body is decoded as it will be when handled, in any
+// MESSAGE_FORMAT, and its plaintext is kept for the poll, so an encrypted
+// body is decrypted only once.
+func (p *Processor) conflictKey(ctx context.Context, msg types.Message) (orderID string, tiebreaker json.RawMessage, ok bool) {
+	if msg.Body == nil {
+		return "", nil, false
+	}
+	body, err := p.messageBody(ctx, msg)
+	if err != nil {
+		return "", nil, false
+	}
+	order, err := p.parseMessageOrder(msg, body)
+	if err != nil {
+		return "", nil, false
+	}
+	p.normalization.apply(&order)
+	if order.OrderID == "" {
+		return "", nil, false
+	}
+	return order.OrderID, p.conflictValue(order, body), true
+}
+
+// conflictValue returns the conflictField value of an order. JSON bodies
+// are read directly, as the field need not be one Order keeps; for other
+// formats it is the field of the decoded order, by its JSON name.
+func (p *Processor) conflictValue(order Order, body string) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if p.decoder == nil && json.Unmarshal([]byte(body), &fields) == nil {
+		return fields[p.conflictField]
+	}
+	encoded, err := json.Marshal(order)
+	if err != nil || json.Unmarshal(encoded, &fields) != nil {
+		return nil
+	}
+	return fields[p.conflictField]
+}
+
+// compareTiebreakers orders two raw JSON tiebreaker values: numbers
+// numerically, strings (such as RFC 3339 UTC timestamps) lexically, and a
+// missing or null value below any other. Values of different types
+// compare equal.
+func compareTiebreakers(a, b json.RawMessage) int {
+	aMissing, bMissing := len(a) == 0 || string(a) == "null", len(b) == 0 || string(b) == "null"
+	switch {
+	case aMissing && bMissing:
+		return 0
+	case aMissing:
+		return -1
+	case bMissing:
+		return 1
+	}
+
+	if x, err := parseDecimal(string(a)); err == nil {
+		if y, err := parseDecimal(string(b)); err == nil {
+			return x.rat().Cmp(y.rat())
+		}
+		return 0
+	}
+	var x, y string
+	if json.Unmarshal(a, &x) == nil && json.Unmarshal(b, &y) == nil {
+		return strings.Compare(x, y)
+	}
+	return 0
+}
@@ -0,0 +1,219 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func conflictMessage(n int, body string) stypes.Message {
+	return stypes.Message{
+		MessageId:     aws.String(fmt.Sprintf("msg-%d", n)),
+		Body:          aws.String(body),
+		ReceiptHandle: aws.String(fmt.Sprintf("r%d", n)),
+	}
+}
+
+func TestPollAndProcess_BatchConflictAppliesTiebreakerWinner(t *testing.T) {
+	tests := []struct {
+		name       string
+		field      string
+		first      string
+		second     string
+		wantAmount string
+	}{
+		{
+			name:       "higher sequence first",
+			field:      "sequence",
+			first:      `{"order_id":"o1","amount":200,"sequence":2}`,
+			second:     `{"order_id":"o1","amount":100,"sequence":1}`,
+			wantAmount: "200",
+		},
+		{
+			name:       "higher sequence last",
+			field:      "sequence",
+			first:      `{"order_id":"o1","amount":100,"sequence":9}`,
+			second:     `{"order_id":"o1","amount":200,"sequence":10}`,
+			wantAmount: "200",
+		},
+		{
+			name:       "timestamps",
+			field:      "updated_at",
+			first:      `{"order_id":"o1","amount":200,"updated_at":"2024-05-01T08:00:05Z"}`,
+			second:     `{"order_id":"o1","amount":100,"updated_at":"2024-05-01T08:00:01Z"}`,
+			wantAmount: "200",
+		},
+		{
+			name:       "missing field loses",
+			field:      "sequence",
+			first:      `{"order_id":"o1","amount":200,"sequence":1}`,
+			second:     `{"order_id":"o1","amount":100}`,
+			wantAmount: "200",
+		},
+		{
+			name:       "tie goes to the last message",
+			field:      "sequence",
+			first:      `{"order_id":"o1","amount":100,"sequence":3}`,
+			second:     `{"order_id":"o1","amount":200,"sequence":3}`,
+			wantAmount: "200",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSQS := &MockSQSClient{}
+			mockDDB := &MockDynamoDBClient{}
+			proc := &Processor{
+				sqsClient:       mockSQS,
+				ddbClient:       mockDDB,
+				queueURL:        "test-queue",
+				tableName:       "Orders",
+				ordersProcessed: NewCounterVec(),
+				environment:     "test",
+				conflictField:   tt.field,
+			}
+
+			mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+				Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+					conflictMessage(1, tt.first),
+					conflictMessage(2, tt.second),
+					conflictMessage(3, `{"order_id":"o2","amount":50}`),
+				}}, nil).Once()
+			var written []string
+			mockDDB.On("PutItem", mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) {
+					item := args.Get(1).(*dynamodb.PutItemInput).Item
+					if item["order_id"].(*dtypes.AttributeValueMemberS).Value == "o1" {
+						written = append(written, item["amount"].(*dtypes.AttributeValueMemberN).Value)
+					}
+				}).
+				Return(&dynamodb.PutItemOutput{}, nil).Twice()
+			// The superseded message is deleted along with the others
+			mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1", "r2", "r3")).
+				Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+			_, err := proc.pollAndProcess(context.Background())
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{tt.wantAmount}, written)
+			mockSQS.AssertExpectations(t)
+			mockDDB.AssertExpectations(t)
+			assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("superseded", "test", "test-queue")))
+			assert.Equal(t, 2.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+		})
+	}
+}
+
+func TestResolveConflicts_DisabledOrUnreadable(t *testing.T) {
+	msgs := []stypes.Message{
+		conflictMessage(1, `{"order_id":"o1","sequence":2}`),
+		conflictMessage(2, `{"order_id":"o1","sequence":1}`),
+	}
+
+	kept, superseded := (&Processor{}).resolveConflicts(context.Background(), msgs)
+	assert.Equal(t, msgs, kept)
+	assert.Empty(t, superseded)
+
+	// Bodies without a readable order ID are left to fail validation
+	unreadable := []stypes.Message{conflictMessage(1, `not json`), conflictMessage(2, `{"amount":1}`), {MessageId: aws.String("nil-body")}}
+	kept, superseded = (&Processor{conflictField: "sequence"}).resolveConflicts(context.Background(), unreadable)
+	assert.Equal(t, unreadable, kept)
+	assert.Empty(t, superseded)
+}
+
+func TestCompareTiebreakers(t *testing.T) {
+	assert.Equal(t, 1, compareTiebreakers([]byte(`10`), []byte(`9.5`)))
+	assert.Equal(t, -1, compareTiebreakers([]byte(`"a"`), []byte(`"b"`)))
+	assert.Equal(t, 1, compareTiebreakers([]byte(`0`), nil))
+	assert.Equal(t, -1, compareTiebreakers([]byte(`null`), []byte(`"x"`)))
+	assert.Equal(t, 0, compareTiebreakers([]byte(`1`), []byte(`"1"`)))
+}
+
+func TestPollAndProcess_BatchConflictProtobufBodies(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		conflictField:   "created_at",
+	}
+	proc.WithDecoder(protobufDecoder{p: proc})
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			conflictMessage(1, protoOrder(map[protowire.Number]string{
+				protoOrderID: "o1", protoUserID: "u1", protoAmount: "200", protoCreatedAt: "2024-05-01T08:00:05Z",
+			})),
+			conflictMessage(2, protoOrder(map[protowire.Number]string{
+				protoOrderID: "o1", protoUserID: "u1", protoAmount: "100", protoCreatedAt: "2024-05-01T08:00:01Z",
+			})),
+		}}, nil).Once()
+	var written []string
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			written = append(written, args.Get(1).(*dynamodb.PutItemInput).Item["amount"].(*dtypes.AttributeValueMemberN).Value)
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"200"}, written)
+	mockSQS.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("superseded", "test", "test-queue")))
+}
+
+func TestPollAndProcess_BatchConflictDecryptsOnce(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	decrypter := &MockDecrypter{}
+	proc := &Processor{
+		sqsClient:             mockSQS,
+		ddbClient:             mockDDB,
+		queueURL:              "test-queue",
+		tableName:             "Orders",
+		ordersProcessed:       NewCounterVec(),
+		environment:           "test",
+		conflictField:         "sequence",
+		decrypter:             decrypter,
+		encryptedContentTypes: defaultEncryptedContentTypes,
+	}
+	encrypted := func(n int, ciphertext string) stypes.Message {
+		return withContentType(conflictMessage(n, ciphertext), defaultEncryptedContentTypes[0])
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{encrypted(1, "c1"), encrypted(2, "c2")}}, nil).Once()
+	// Read for the conflict check and again when handled, decrypted once
+	decrypter.On("Decrypt", mock.Anything, "c1").
+		Return(`{"order_id":"o1","user_id":"u1","amount":100,"sequence":1}`, nil).Once()
+	decrypter.On("Decrypt", mock.Anything, "c2").
+		Return(`{"order_id":"o2","user_id":"u1","amount":100,"sequence":1}`, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Twice()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	decrypter.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	mockSQS.AssertExpectations(t)
+}
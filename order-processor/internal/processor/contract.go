@@ -0,0 +1,466 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// contract is a compiled registered schema. Bodies are checked after
+// decoding with json.Decoder.UseNumber, so numbers arrive as json.Number
+// and keep their exact value. path names v in errors, e.g. "$.items[0]".
+type contract interface {
+	validate(path string, v any) error
+}
+
+// jsonSchema is the subset of JSON Schema contracts use: type, enum,
+// properties, required, additionalProperties (false), items, minimum,
+// maximum, minLength, maxLength and pattern. Other keywords are ignored.
+type jsonSchema struct {
+	types                []string
+	enum                 []any
+	properties           map[string]*jsonSchema
+	required             []string
+	noAdditionalProperty bool
+	items                *jsonSchema
+	minimum, maximum     *big.Rat
+	minLength, maxLength int
+	pattern              *regexp.Regexp
+}
+
+func compileJSONSchema(def any) (*jsonSchema, error) {
+	if b, ok := def.(bool); ok && b {
+		return &jsonSchema{minLength: -1, maxLength: -1}, nil
+	}
+	m, ok := def.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("JSON schema must be an object, got %T", def)
+	}
+
+	s := &jsonSchema{minLength: -1, maxLength: -1}
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []any:
+		for _, name := range t {
+			name, ok := name.(string)
+			if !ok {
+				return nil, fmt.Errorf("type must list strings")
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return nil, fmt.Errorf("type must be a string or an array")
+	}
+	if enum, ok := m["enum"].([]any); ok {
+		s.enum = enum
+	}
+	if props, ok := m["properties"].(map[string]any); ok {
+		s.properties = make(map[string]*jsonSchema, len(props))
+		for name, def := range props {
+			prop, err := compileJSONSchema(def)
+			if err != nil {
+				return nil, fmt.Errorf("property %s: %w", name, err)
+			}
+			s.properties[name] = prop
+		}
+	}
+	if required, ok := m["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				s.required = append(s.required, name)
+			}
+		}
+	}
+	if additional, ok := m["additionalProperties"].(bool); ok {
+		s.noAdditionalProperty = !additional
+	}
+	if items, ok := m["items"]; ok {
+		var err error
+		if s.items, err = compileJSONSchema(items); err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+	}
+	var err error
+	if s.minimum, err = schemaNumber(m, "minimum"); err != nil {
+		return nil, err
+	}
+	if s.maximum, err = schemaNumber(m, "maximum"); err != nil {
+		return nil, err
+	}
+	if s.minLength, err = schemaLength(m, "minLength"); err != nil {
+		return nil, err
+	}
+	if s.maxLength, err = schemaLength(m, "maxLength"); err != nil {
+		return nil, err
+	}
+	if pattern, ok := m["pattern"].(string); ok {
+		if s.pattern, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("pattern: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// schemaNumber returns the number under key in a schema, or nil when unset.
+func schemaNumber(m map[string]any, key string) (*big.Rat, error) {
+	raw, ok := m[key]
+	if !ok {
+		return nil, nil
+	}
+	n, ok := raw.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a number", key)
+	}
+	r, ok := new(big.Rat).SetString(n.String())
+	if !ok {
+		return nil, fmt.Errorf("%s must be a number", key)
+	}
+	return r, nil
+}
+
+// schemaLength returns the non-negative integer under key in a schema, or
+// -1 when unset.
+func schemaLength(m map[string]any, key string) (int, error) {
+	raw, ok := m[key]
+	if !ok {
+		return -1, nil
+	}
+	n, ok := raw.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%s must be an integer", key)
+	}
+	i, err := n.Int64()
+	if err != nil || i < 0 || i > math.MaxInt32 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return int(i), nil
+}
+
+func (s *jsonSchema) validate(path string, v any) error {
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return jsonTypeMatches(t, v) }) {
+		return fmt.Errorf("%s must be %s, got %s", path, strings.Join(s.types, " or "), jsonTypeOf(v))
+	}
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(e any) bool { return jsonEqual(e, v) }) {
+		return fmt.Errorf("%s is not one of the allowed values", path)
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength >= 0 && n < s.minLength {
+			return fmt.Errorf("%s must be at least %d characters", path, s.minLength)
+		}
+		if s.maxLength >= 0 && n > s.maxLength {
+			return fmt.Errorf("%s must be at most %d characters", path, s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return fmt.Errorf("%s does not match %s", path, s.pattern)
+		}
+	case json.Number:
+		r, ok := new(big.Rat).SetString(v.String())
+		if !ok {
+			return fmt.Errorf("%s is not a number", path)
+		}
+		if s.minimum != nil && r.Cmp(s.minimum) < 0 {
+			return fmt.Errorf("%s must be at least %s", path, s.minimum.RatString())
+		}
+		if s.maximum != nil && r.Cmp(s.maximum) > 0 {
+			return fmt.Errorf("%s must be at most %s", path, s.maximum.RatString())
+		}
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+		// Sorted, so the first violation reported is always the same
+		for _, name := range slices.Sorted(maps.Keys(v)) {
+			prop, ok := s.properties[name]
+			if !ok {
+				if s.noAdditionalProperty {
+					return fmt.Errorf("%s.%s is not allowed", path, name)
+				}
+				continue
+			}
+			if err := prop.validate(path+"."+name, v[name]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if s.items != nil {
+			for i, item := range v {
+				if err := s.items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// jsonTypeOf names the JSON type of a decoded value.
+func jsonTypeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if isInteger(v) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func jsonTypeMatches(t string, v any) bool {
+	actual := jsonTypeOf(v)
+	return t == actual || t == "number" && actual == "integer"
+}
+
+func isInteger(n json.Number) bool {
+	r, ok := new(big.Rat).SetString(n.String())
+	return ok && r.IsInt()
+}
+
+// jsonEqual compares decoded values, numbers by value so 1 equals 1.0.
+func jsonEqual(a, b any) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		ar, aok := new(big.Rat).SetString(an.String())
+		br, bok := new(big.Rat).SetString(bn.String())
+		return aok && bok && ar.Cmp(br) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// avroSchema is an Avro schema checked against a plain JSON body: records
+// are objects, unions take the value of any branch as is (not wrapped in
+// a {"type": value} object), and bytes and fixed are strings.
+type avroSchema struct {
+	// kind is a primitive type name or record, enum, array, map, fixed
+	// or union
+	kind     string
+	name     string
+	fields   []avroField
+	symbols  []string
+	items    *avroSchema
+	branches []*avroSchema
+}
+
+type avroField struct {
+	name   string
+	schema *avroSchema
+	// hasDefault fields may be left out
+	hasDefault bool
+}
+
+var avroPrimitives = []string{"null", "boolean", "int", "long", "float", "double", "bytes", "string"}
+
+func compileAvroSchema(def any) (*avroSchema, error) {
+	return (&avroCompiler{named: make(map[string]*avroSchema)}).compile(def, "")
+}
+
+// avroCompiler resolves references to named types (records, enums and
+// fixed) defined earlier in the schema.
+type avroCompiler struct {
+	named map[string]*avroSchema
+}
+
+func (c *avroCompiler) compile(def any, namespace string) (*avroSchema, error) {
+	switch def := def.(type) {
+	case string:
+		if slices.Contains(avroPrimitives, def) {
+			return &avroSchema{kind: def}, nil
+		}
+		if s, ok := c.named[def]; ok {
+			return s, nil
+		}
+		if s, ok := c.named[namespace+"."+def]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown Avro type %q", def)
+	case []any:
+		union := &avroSchema{kind: "union"}
+		for _, branch := range def {
+			s, err := c.compile(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			union.branches = append(union.branches, s)
+		}
+		return union, nil
+	case map[string]any:
+		return c.compileComplex(def, namespace)
+	default:
+		return nil, fmt.Errorf("invalid Avro schema %v", def)
+	}
+}
+
+func (c *avroCompiler) compileComplex(def map[string]any, namespace string) (*avroSchema, error) {
+	kind, ok := def["type"].(string)
+	if !ok {
+		// {"type": {...}} or {"type": [...]} wraps another schema
+		return c.compile(def["type"], namespace)
+	}
+
+	s := &avroSchema{kind: kind}
+	switch kind {
+	case "record", "error", "enum", "fixed":
+		name, _ := def["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("Avro %s has no name", kind)
+		}
+		if ns, ok := def["namespace"].(string); ok {
+			namespace = ns
+		}
+		s.name = name
+		if !strings.Contains(name, ".") && namespace != "" {
+			s.name = namespace + "." + name
+		}
+		// Registered before the fields, so a record can refer to itself
+		c.named[s.name] = s
+		c.named[name] = s
+	}
+
+	switch kind {
+	case "record", "error":
+		s.kind = "record"
+		fields, _ := def["fields"].([]any)
+		for _, f := range fields {
+			f, ok := f.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("record %s: invalid field", s.name)
+			}
+			name, _ := f["name"].(string)
+			schema, err := c.compile(f["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("record %s field %s: %w", s.name, name, err)
+			}
+			_, hasDefault := f["default"]
+			s.fields = append(s.fields, avroField{name: name, schema: schema, hasDefault: hasDefault})
+		}
+	case "enum":
+		symbols, _ := def["symbols"].([]any)
+		for _, sym := range symbols {
+			if sym, ok := sym.(string); ok {
+				s.symbols = append(s.symbols, sym)
+			}
+		}
+	case "array", "map":
+		key := "items"
+		if kind == "map" {
+			key = "values"
+		}
+		items, err := c.compile(def[key], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", kind, key, err)
+		}
+		s.items = items
+	case "fixed":
+	default:
+		// A primitive in complex form, e.g. with a logicalType
+		if !slices.Contains(avroPrimitives, kind) {
+			return c.compile(kind, namespace)
+		}
+	}
+	return s, nil
+}
+
+func (s *avroSchema) validate(path string, v any) error {
+	switch s.kind {
+	case "null":
+		if v != nil {
+			return fmt.Errorf("%s must be null", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+	case "int", "long":
+		n, ok := v.(json.Number)
+		if !ok || !isInteger(n) {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+		i, err := n.Int64()
+		if err != nil || s.kind == "int" && (i < math.MinInt32 || i > math.MaxInt32) {
+			return fmt.Errorf("%s is out of range for %s", path, s.kind)
+		}
+	case "float", "double":
+		if _, ok := v.(json.Number); !ok {
+			return fmt.Errorf("%s must be a number", path)
+		}
+	case "string", "bytes", "fixed":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+	case "enum":
+		sym, ok := v.(string)
+		if !ok || !slices.Contains(s.symbols, sym) {
+			return fmt.Errorf("%s must be one of %s", path, strings.Join(s.symbols, ", "))
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		for i, item := range items {
+			if err := s.items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case "map":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, key := range slices.Sorted(maps.Keys(m)) {
+			if err := s.items.validate(path+"."+key, m[key]); err != nil {
+				return err
+			}
+		}
+	case "record":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, f := range s.fields {
+			value, present := m[f.name]
+			if !present && (f.hasDefault || f.schema.validate(path, nil) == nil) {
+				continue
+			}
+			if !present {
+				return fmt.Errorf("%s.%s is required", path, f.name)
+			}
+			if err := f.schema.validate(path+"."+f.name, value); err != nil {
+				return err
+			}
+		}
+	case "union":
+		for _, branch := range s.branches {
+			if branch.validate(path, v) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s matches no type of its union", path)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileContract_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  RegisteredSchema
+		body    string
+		wantErr string
+	}{
+		{
+			name:   "JSON valid",
+			schema: RegisteredSchema{Type: schemaTypeJSON, Schema: `{"type":"object","properties":{"sku":{"type":"string","pattern":"^P[0-9]+$","maxLength":5}},"additionalProperties":false}`},
+			body:   `{"sku":"P12"}`,
+		},
+		{
+			name:    "JSON additional property",
+			schema:  RegisteredSchema{Type: schemaTypeJSON, Schema: `{"type":"object","properties":{"sku":{}},"additionalProperties":false}`},
+			body:    `{"sku":"P1","note":"x"}`,
+			wantErr: "$.note is not allowed",
+		},
+		{
+			name:    "JSON pattern",
+			schema:  RegisteredSchema{Type: schemaTypeJSON, Schema: `{"properties":{"sku":{"pattern":"^P[0-9]+$"}}}`},
+			body:    `{"sku":"X1"}`,
+			wantErr: "$.sku does not match ^P[0-9]+$",
+		},
+		{
+			name:    "JSON integer",
+			schema:  RegisteredSchema{Type: schemaTypeJSON, Schema: `{"type":"array","items":{"type":"integer","maximum":10}}`},
+			body:    `[1, 2.0, 11]`,
+			wantErr: "$[2] must be at most 10",
+		},
+		{
+			name:    "Avro int range",
+			schema:  RegisteredSchema{Type: schemaTypeAvro, Schema: `{"type":"record","name":"R","fields":[{"name":"n","type":"int"}]}`},
+			body:    `{"n":3000000000}`,
+			wantErr: "$.n is out of range for int",
+		},
+		{
+			name:    "Avro required field",
+			schema:  RegisteredSchema{Type: schemaTypeAvro, Schema: `{"type":"record","name":"R","fields":[{"name":"a","type":"string"},{"name":"b","type":["null","string"]}]}`},
+			body:    `{}`,
+			wantErr: "$.a is required",
+		},
+		{
+			name:   "Avro recursive record",
+			schema: RegisteredSchema{Type: schemaTypeAvro, Schema: `{"type":"record","name":"Node","fields":[{"name":"next","type":["null","Node"]},{"name":"tags","type":{"type":"map","values":"string"},"default":{}}]}`},
+			body:   `{"next":{"next":null,"tags":{"a":"b"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := compileContract(tt.schema)
+			require.NoError(t, err)
+
+			var value any
+			require.NoError(t, decodeJSONNumbers(tt.body, &value))
+			err = c.validate("$", value)
+
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileContract_InvalidSchemas(t *testing.T) {
+	for _, s := range []RegisteredSchema{
+		{Type: schemaTypeJSON, Schema: `{"type":1}`},
+		{Type: schemaTypeJSON, Schema: `{"pattern":"("}`},
+		{Type: schemaTypeAvro, Schema: `"Unknown"`},
+		{Type: schemaTypeAvro, Schema: `{"type":"record","fields":[]}`},
+		{Type: "PROTOBUF", Schema: `{}`},
+	} {
+		_, err := compileContract(s)
+		assert.Error(t, err, s.Schema)
+	}
+}
@@ -0,0 +1,49 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultCurrency is assumed for orders that name no currency.
+const defaultCurrency = "USD"
+
+// iso4217Codes are the active ISO 4217 currency codes.
+var iso4217Codes = map[string]struct{}{}
+
+func init() {
+	for _, code := range strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND
+		BOB BOV BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CLF CLP CNY COP COU
+		CRC CUC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS
+		GIP GMD GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY
+		KES KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD MAD MDL MGA
+		MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD NGN NIO NOK NPR NZD
+		OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK
+		SGD SHP SLE SLL SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD
+		TWD TZS UAH UGX USD USN UYI UYU UYW UZS VED VES VND VUV WST XAF XCD XCG
+		XOF XPF XSU YER ZAR ZMW ZWG ZWL
+	`) {
+		iso4217Codes[code] = struct{}{}
+	}
+}
+
+// checkCurrency normalizes the order's currency to an upper-case ISO 4217
+// code, defaulting to USD when none is given.
+func checkCurrency(order *Order) error {
+	if order.Currency == "" {
+		order.Currency = defaultCurrency
+		return nil
+	}
+
+	currency := strings.ToUpper(order.Currency)
+	if _, ok := iso4217Codes[currency]; !ok {
+		return &ValidationError{
+			Type:    "invalid_currency",
+			OrderID: order.OrderID,
+			Err:     fmt.Errorf("currency %q is not an ISO 4217 code", order.Currency),
+		}
+	}
+	order.Currency = currency
+	return nil
+}
@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     string
+		wantErr  bool
+	}{
+		{currency: "", want: "USD"},
+		{currency: "eur", want: "EUR"},
+		{currency: "JPY", want: "JPY"},
+		{currency: "XYZ", wantErr: true},
+		{currency: "EURO", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency, func(t *testing.T) {
+			order := Order{OrderID: "o1", Currency: tt.currency}
+
+			err := checkCurrency(&order)
+
+			if tt.wantErr {
+				var vErr *ValidationError
+				require.True(t, errors.As(err, &vErr))
+				assert.Equal(t, "invalid_currency", vErr.Type)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, order.Currency)
+		})
+	}
+}
+
+func TestHandleMessage_StoresGivenCurrencyAndCreationTime(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		ddbClient:       mockDDB,
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		clock:           fixedClock{at: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)},
+	}
+
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "GBP"}, input.Item["currency"]) &&
+			assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "2024-04-30T21:15:00Z"}, input.Item["created_at"]) &&
+			assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "2024-05-01T08:00:00Z"}, input.Item["processed_at"])
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	err := proc.handleMessage(context.Background(), stypes.Message{
+		Body: aws.String(`{"order_id":"o1","amount":100,"currency":"gbp","created_at":"2024-04-30T23:15:00+02:00"}`),
+	})
+
+	require.NoError(t, err)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestHandleMessage_InvalidCreatedAt(t *testing.T) {
+	proc := &Processor{ordersProcessed: NewCounterVec(), environment: "test"}
+
+	err := proc.handleMessage(context.Background(), stypes.Message{
+		Body: aws.String(`{"order_id":"o1","amount":100,"created_at":"yesterday"}`),
+	})
+
+	var vErr *ValidationError
+	require.True(t, errors.As(err, &vErr))
+	assert.Equal(t, "invalid_json", vErr.Type)
+}
@@ -0,0 +1,146 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxDecimalDigits is the precision of a DynamoDB Number.
+const maxDecimalDigits = 38
+
+var (
+	bigTwo  = big.NewInt(2)
+	bigFive = big.NewInt(5)
+	bigTen  = big.NewInt(10)
+)
+
+// Decimal is an exact decimal number, such as an order amount, kept in
+// canonical form ("100.5", "-3", "0.01") so it reaches JSON and DynamoDB
+// Numbers without passing through a float. The zero value is 0.
+type Decimal string
+
+// parseDecimal parses a JSON number exactly. Numbers needing more than
+// maxDecimalDigits digits are rejected, as DynamoDB cannot store them.
+func parseDecimal(s string) (Decimal, error) {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) || !json.Valid([]byte(s)) {
+		return "", fmt.Errorf("%s is not a number", s)
+	}
+	// Bound the exponent before expanding it, so 1e-999999999 cannot cost
+	// a billion-digit denominator
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		if exp, err := strconv.Atoi(s[i+1:]); err != nil || exp < -2*maxDecimalDigits || exp > 2*maxDecimalDigits {
+			return "", fmt.Errorf("%s has more than %d digits", s, maxDecimalDigits)
+		}
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return "", fmt.Errorf("%s is not a number", s)
+	}
+
+	// A decimal's denominator is 2^a * 5^b, and it needs max(a, b) places
+	places := 0
+	denom := new(big.Int).Set(r.Denom())
+	for mod := new(big.Int); denom.Cmp(big.NewInt(1)) != 0; places++ {
+		switch {
+		case mod.Mod(denom, bigTen).Sign() == 0:
+			denom.Quo(denom, bigTen)
+		case mod.Mod(denom, bigTwo).Sign() == 0:
+			denom.Quo(denom, bigTwo)
+		case mod.Mod(denom, bigFive).Sign() == 0:
+			denom.Quo(denom, bigFive)
+		default:
+			return "", fmt.Errorf("%s is not a decimal", s)
+		}
+	}
+
+	d := canonicalDecimal(r.FloatString(places))
+	whole, _, _ := strings.Cut(strings.TrimPrefix(string(d), "-"), ".")
+	if len(strings.TrimLeft(whole, "0"))+d.places() > maxDecimalDigits {
+		return "", fmt.Errorf("%s has more than %d digits", s, maxDecimalDigits)
+	}
+	return d, nil
+}
+
+// canonicalDecimal strips the trailing fractional zeros big.Rat.FloatString
+// pads with.
+func canonicalDecimal(s string) Decimal {
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return Decimal(s)
+}
+
+func (d Decimal) String() string {
+	if d == "" {
+		return "0"
+	}
+	return string(d)
+}
+
+// rat returns d as a big.Rat.
+func (d Decimal) rat() *big.Rat {
+	r, _ := new(big.Rat).SetString(d.String())
+	return r
+}
+
+// places is how many fractional digits d has.
+func (d Decimal) places() int {
+	_, frac, _ := strings.Cut(string(d), ".")
+	return len(frac)
+}
+
+// Sign returns -1, 0 or +1 as d is negative, zero or positive.
+func (d Decimal) Sign() int {
+	return d.rat().Sign()
+}
+
+// Cmp returns -1, 0 or +1 as d is less than, equal to or greater than
+// other.
+func (d Decimal) Cmp(other Decimal) int {
+	return d.rat().Cmp(other.rat())
+}
+
+// Add returns d + other.
+func (d Decimal) Add(other Decimal) Decimal {
+	sum := new(big.Rat).Add(d.rat(), other.rat())
+	return canonicalDecimal(sum.FloatString(max(d.places(), other.places())))
+}
+
+// mulRate returns d times rate rounded to places fractional digits, halves
+// away from zero. rate is taken at its shortest decimal form, so 1.085 is
+// exactly 1.085 rather than its binary approximation.
+func (d Decimal) mulRate(rate float64, places int) (Decimal, error) {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(rate, 'g', -1, 64))
+	if !ok {
+		return "", fmt.Errorf("invalid rate %v", rate)
+	}
+	return canonicalDecimal(r.Mul(r, d.rat()).FloatString(places)), nil
+}
+
+// MarshalJSON writes d as a JSON number.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number exactly; null leaves d unchanged.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	parsed, err := parseDecimal(string(b))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// MarshalDynamoDBAttributeValue stores d as a DynamoDB Number.
+func (d Decimal) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	return &types.AttributeValueMemberN{Value: d.String()}, nil
+}
@@ -0,0 +1,53 @@
+package processor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDecimal_Rejects(t *testing.T) {
+	for _, s := range []string{"", "abc", `"1"`, "1.", "--1", "1e", "1e999"} {
+		_, err := parseDecimal(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestDecimal_Add(t *testing.T) {
+	assert.Equal(t, Decimal("100.51"), Decimal("100.5").Add("0.01"))
+	assert.Equal(t, Decimal("1"), Decimal("0.5").Add("0.5"))
+	assert.Equal(t, Decimal("0.01"), Decimal("").Add("0.01"))
+	assert.Equal(t, Decimal("-0.5"), Decimal("1").Add("-1.5"))
+}
+
+func TestDecimal_MulRateRoundsToPlaces(t *testing.T) {
+	tests := []struct {
+		amount Decimal
+		rate   float64
+		want   Decimal
+	}{
+		{amount: "2000", rate: 1.085, want: "2170"},
+		{amount: "19.99", rate: 1.085, want: "21.69"},
+		// A half cent rounds away from zero; the binary approximation of
+		// 1.005 is just below it
+		{amount: "1", rate: 1.005, want: "1.01"},
+		{amount: "-1", rate: 1.005, want: "-1.01"},
+	}
+	for _, tt := range tests {
+		got, err := tt.amount.mulRate(tt.rate, 2)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%s * %v", tt.amount, tt.rate)
+	}
+}
+
+func TestDecimal_JSONRoundTrip(t *testing.T) {
+	var order Order
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":100.50}`), &order))
+	assert.Equal(t, Decimal("100.5"), order.Amount)
+
+	body, err := json.Marshal(ProcessingReport{AmountTotal: "0.01"})
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `"amount_total":0.01`)
+}
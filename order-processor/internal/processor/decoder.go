@@ -0,0 +1,135 @@
+package processor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Message body formats MESSAGE_FORMAT selects between
+const (
+	messageFormatJSON     = "json"
+	messageFormatProtobuf = "protobuf"
+)
+
+// Decoder turns a message body into an Order. A body that can never
+// decode should fail with a ValidationError, so it is dead-lettered
+// rather than retried.
+type Decoder interface {
+	Decode(body []byte) (Order, error)
+}
+
+// WithDecoder decodes message bodies with decoder instead of as JSON.
+// The body reaches it after decryption and the size check.
+func (p *Processor) WithDecoder(decoder Decoder) *Processor {
+	p.decoder = decoder
+	return p
+}
+
+// decoderFor returns the decoder of MESSAGE_FORMAT format. JSON, the
+// default, returns nil: parseMessageOrder then reads the schema version
+// from the message attributes as well as the body.
+func decoderFor(p *Processor, format string) (Decoder, error) {
+	switch format {
+	case messageFormatJSON:
+		return nil, nil
+	case messageFormatProtobuf:
+		return protobufDecoder{p: p}, nil
+	default:
+		return nil, fmt.Errorf("unknown %s %q: want %s or %s", envMessageFormat, format, messageFormatJSON, messageFormatProtobuf)
+	}
+}
+
+// Field numbers of the protobuf order message:
+//
+//	message Order {
+//	  string order_id = 1;
+//	  string user_id = 2;
+//	  string amount = 3;        // decimal, as in schema version 2
+//	  string status = 4;
+//	  string tenant_id = 5;
+//	  string currency = 6;
+//	  string created_at = 7;    // RFC 3339
+//	  string parent_order_id = 8;
+//	  string attachment_url = 9;
+//	}
+const (
+	protoOrderID       protowire.Number = 1
+	protoUserID        protowire.Number = 2
+	protoAmount        protowire.Number = 3
+	protoStatus        protowire.Number = 4
+	protoTenantID      protowire.Number = 5
+	protoCurrency      protowire.Number = 6
+	protoCreatedAt     protowire.Number = 7
+	protoParentOrderID protowire.Number = 8
+	protoAttachmentURL protowire.Number = 9
+)
+
+// protobufDecoder decodes base64-encoded protobuf orders, base64 because
+// SQS bodies must be text. Unknown fields are skipped, so producers can
+// add fields before the processor reads them.
+type protobufDecoder struct {
+	p *Processor
+}
+
+func (d protobufDecoder) Decode(body []byte) (Order, error) {
+	invalid := func(err error) error {
+		return &ValidationError{Type: "invalid_protobuf", Err: fmt.Errorf("invalid protobuf: %w", err)}
+	}
+	wire, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return Order{}, invalid(err)
+	}
+
+	fields := make(map[protowire.Number]string)
+	for len(wire) > 0 {
+		num, typ, n := protowire.ConsumeTag(wire)
+		if n < 0 {
+			return Order{}, invalid(protowire.ParseError(n))
+		}
+		wire = wire[n:]
+		if typ != protowire.BytesType || num < protoOrderID || num > protoAttachmentURL {
+			if n = protowire.ConsumeFieldValue(num, typ, wire); n < 0 {
+				return Order{}, invalid(protowire.ParseError(n))
+			}
+			wire = wire[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(wire)
+		if n < 0 {
+			return Order{}, invalid(protowire.ParseError(n))
+		}
+		if !utf8.Valid(value) {
+			return Order{}, invalid(fmt.Errorf("field %d is not valid UTF-8", num))
+		}
+		// As in protobuf, the last occurrence of a field wins
+		fields[num] = string(value)
+		wire = wire[n:]
+	}
+
+	order := Order{
+		OrderID:       fields[protoOrderID],
+		UserID:        fields[protoUserID],
+		Status:        fields[protoStatus],
+		TenantID:      fields[protoTenantID],
+		Currency:      fields[protoCurrency],
+		ParentOrderID: fields[protoParentOrderID],
+		AttachmentURL: fields[protoAttachmentURL],
+	}
+	// An unset amount is zero, as a missing JSON amount is
+	if order.Amount, err = d.p.checkAmount(json.RawMessage(fields[protoAmount])); err != nil {
+		return Order{}, &ValidationError{Type: "invalid_amount", OrderID: order.OrderID, Err: err}
+	}
+	if raw := fields[protoCreatedAt]; raw != "" {
+		if order.CreatedAt, err = time.Parse(time.RFC3339, raw); err != nil {
+			return Order{}, invalid(errors.New("created_at must be an RFC 3339 time"))
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,147 @@
+package processor
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoOrder encodes fields as a protobuf order body, base64 as on the queue.
+func protoOrder(fields map[protowire.Number]string) string {
+	var b []byte
+	for num := protoOrderID; num <= protoAttachmentURL; num++ {
+		if v, ok := fields[num]; ok {
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		}
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func TestDecoderFor(t *testing.T) {
+	proc := &Processor{}
+
+	decoder, err := decoderFor(proc, messageFormatJSON)
+	require.NoError(t, err)
+	assert.Nil(t, decoder, "JSON is parsed by the schema-aware default")
+
+	decoder, err = decoderFor(proc, messageFormatProtobuf)
+	require.NoError(t, err)
+	assert.IsType(t, protobufDecoder{}, decoder)
+
+	_, err = decoderFor(proc, "avro")
+	assert.EqualError(t, err, `unknown MESSAGE_FORMAT "avro": want json or protobuf`)
+}
+
+func TestProtobufDecoder(t *testing.T) {
+	decoder := protobufDecoder{p: &Processor{}}
+	body := protoOrder(map[protowire.Number]string{
+		protoOrderID:       "o1",
+		protoUserID:        "u1",
+		protoAmount:        "99.99",
+		protoStatus:        "NEW",
+		protoTenantID:      "t1",
+		protoCurrency:      "EUR",
+		protoCreatedAt:     "2024-05-01T08:00:00Z",
+		protoParentOrderID: "p1",
+		protoAttachmentURL: "https://example.com/r.pdf",
+	})
+
+	order, err := decoder.Decode([]byte(body))
+
+	require.NoError(t, err)
+	assert.Equal(t, Order{
+		OrderID:       "o1",
+		UserID:        "u1",
+		Amount:        "99.99",
+		Status:        "NEW",
+		TenantID:      "t1",
+		Currency:      "EUR",
+		CreatedAt:     time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+		ParentOrderID: "p1",
+		AttachmentURL: "https://example.com/r.pdf",
+	}, order)
+}
+
+func TestProtobufDecoder_SkipsUnknownFields(t *testing.T) {
+	var b []byte
+	b = protowire.AppendTag(b, protoOrderID, protowire.BytesType)
+	b = protowire.AppendString(b, "o1")
+	b = protowire.AppendTag(b, 42, protowire.VarintType)
+	b = protowire.AppendVarint(b, 7)
+	b = protowire.AppendTag(b, 43, protowire.BytesType)
+	b = protowire.AppendString(b, "later")
+
+	order, err := protobufDecoder{p: &Processor{}}.Decode([]byte(base64.StdEncoding.EncodeToString(b)))
+
+	require.NoError(t, err)
+	assert.Equal(t, "o1", order.OrderID)
+	assert.Equal(t, Decimal("0"), order.Amount)
+}
+
+func TestProtobufDecoder_RejectsBadBodies(t *testing.T) {
+	truncated := protowire.AppendTag(nil, protoOrderID, protowire.BytesType)
+	truncated = protowire.AppendVarint(truncated, 10)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantType string
+	}{
+		{"not base64", "{not base64}", "invalid_protobuf"},
+		{"truncated", base64.StdEncoding.EncodeToString(truncated), "invalid_protobuf"},
+		{"bad created_at", protoOrder(map[protowire.Number]string{protoOrderID: "o1", protoCreatedAt: "yesterday"}), "invalid_protobuf"},
+		{"bad amount", protoOrder(map[protowire.Number]string{protoOrderID: "o1", protoAmount: "ten"}), "invalid_amount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := protobufDecoder{p: &Processor{}}.Decode([]byte(tt.body))
+
+			var vErr *ValidationError
+			require.ErrorAs(t, err, &vErr)
+			assert.Equal(t, tt.wantType, vErr.Type)
+			assert.Equal(t, failureTerminal, failureClass(err))
+		})
+	}
+}
+
+func TestPollAndProcess_ProtobufBody(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		failuresByClass: newFailuresCounter(),
+		environment:     "test",
+	}
+	proc.WithDecoder(protobufDecoder{p: proc})
+	body := protoOrder(map[protowire.Number]string{protoOrderID: "o1", protoUserID: "u1", protoAmount: "100"})
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{bodyMessage("m1", body)}}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+		id, ok := in.Item["order_id"].(*dtypes.AttributeValueMemberS)
+		return ok && id.Value == "o1"
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r-m1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	ktypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// contentTypeAttribute is the message attribute producers use to mark how
+// a body is encoded.
+const contentTypeAttribute = "content-type"
+
+// defaultEncryptedContentTypes mark encrypted bodies when
+// ENCRYPTED_CONTENT_TYPES is unset.
+var defaultEncryptedContentTypes = []string{"application/vnd.order+encrypted"}
+
+// Decrypter turns an encrypted message body back into the plaintext order.
+// Bodies that can never be decrypted are reported as validation errors.
+type Decrypter interface {
+	Decrypt(ctx context.Context, body string) (string, error)
+}
+
+// messageBody returns msg's plaintext body, taken out of its SNS envelope
+// first with SNS_ENVELOPE. Only messages whose content-type attribute is
+// one of the encrypted types are decrypted, or every message when all
+// bodies are encrypted (SQS_KMS_KEY_ID); everything else, including
+// messages without the attribute, is passed through as is.
+func (p *Processor) messageBody(ctx context.Context, msg types.Message) (string, error) {
+	cache := bodyCacheOf(ctx)
+	if body, ok := cache.get(msg); ok {
+		return body, nil
+	}
+	body, err := p.readMessageBody(ctx, msg)
+	if err == nil {
+		cache.put(msg, body)
+	}
+	return body, err
+}
+
+// readMessageBody is messageBody without the poll's cache.
+func (p *Processor) readMessageBody(ctx context.Context, msg types.Message) (string, error) {
+	body := aws.ToString(msg.Body)
+	if p.snsEnvelope {
+		body = unwrapSNS(body)
+	}
+	if !p.decryptAll && !slices.Contains(p.encryptedContentTypes, messageContentType(msg)) {
+		return body, nil
+	}
+	if p.decrypter == nil {
+		return "", errors.New("message body is encrypted but no decrypter is configured")
+	}
+	return p.decrypter.Decrypt(ctx, body)
+}
+
+// bodyCache holds the plaintext bodies of a poll's messages by receipt
+// handle, so a body read before its message is handled, as
+// resolveConflicts does, is not decrypted a second time.
+type bodyCache struct {
+	mu     sync.Mutex
+	bodies map[string]string
+}
+
+// bodyCacheKey carries the *bodyCache of the poll a message belongs to.
+type bodyCacheKey struct{}
+
+func withBodyCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bodyCacheKey{}, &bodyCache{bodies: make(map[string]string)})
+}
+
+// bodyCacheOf returns the body cache of the poll ctx belongs to; nil
+// outside a poll, which caches nothing.
+func bodyCacheOf(ctx context.Context) *bodyCache {
+	c, _ := ctx.Value(bodyCacheKey{}).(*bodyCache)
+	return c
+}
+
+func (c *bodyCache) get(msg types.Message) (string, bool) {
+	handle := aws.ToString(msg.ReceiptHandle)
+	if c == nil || handle == "" {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, ok := c.bodies[handle]
+	return body, ok
+}
+
+func (c *bodyCache) put(msg types.Message, body string) {
+	handle := aws.ToString(msg.ReceiptHandle)
+	if c == nil || handle == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bodies[handle] = body
+}
+
+// messageContentType returns the media type from msg's content-type
+// attribute, lowercased and without parameters, or "" when it is unset.
+func messageContentType(msg types.Message) string {
+	attr, ok := msg.MessageAttributes[contentTypeAttribute]
+	if !ok || attr.StringValue == nil {
+		return ""
+	}
+	raw := strings.ToLower(strings.TrimSpace(*attr.StringValue))
+	if mediaType, _, err := mime.ParseMediaType(raw); err == nil {
+		return mediaType
+	}
+	return raw
+}
+
+type kmsClientI interface {
+	Decrypt(context.Context, *kms.DecryptInput, ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// kmsDecrypter decrypts base64-encoded KMS ciphertext. With keyID set,
+// ciphertext encrypted under any other key is rejected.
+type kmsDecrypter struct {
+	client kmsClientI
+	keyID  string
+}
+
+func (d *kmsDecrypter) Decrypt(ctx context.Context, body string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimSpace(body))
+	if err != nil {
+		return "", &ValidationError{Type: "invalid_ciphertext", Err: fmt.Errorf("encrypted body is not base64: %w", err)}
+	}
+
+	input := &kms.DecryptInput{CiphertextBlob: blob}
+	if d.keyID != "" {
+		input.KeyId = aws.String(d.keyID)
+	}
+	out, err := d.client.Decrypt(ctx, input)
+	if err != nil {
+		var invalid *ktypes.InvalidCiphertextException
+		var wrongKey *ktypes.IncorrectKeyException
+		if errors.As(err, &invalid) || errors.As(err, &wrongKey) {
+			return "", &ValidationError{Type: "invalid_ciphertext", Err: fmt.Errorf("decrypt message body: %w", err)}
+		}
+		return "", fmt.Errorf("decrypt message body: %w", err)
+	}
+	return string(out.Plaintext), nil
+}
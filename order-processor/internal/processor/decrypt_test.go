@@ -0,0 +1,192 @@
+package processor
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	ktypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockDecrypter struct {
+	mock.Mock
+}
+
+func (m *MockDecrypter) Decrypt(ctx context.Context, body string) (string, error) {
+	args := m.Called(ctx, body)
+	return args.String(0), args.Error(1)
+}
+
+type MockKMSClient struct {
+	mock.Mock
+}
+
+func (m *MockKMSClient) Decrypt(
+	ctx context.Context,
+	input *kms.DecryptInput,
+	opts ...func(*kms.Options),
+) (*kms.DecryptOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*kms.DecryptOutput), args.Error(1)
+}
+
+func withContentType(msg stypes.Message, contentType string) stypes.Message {
+	msg.MessageAttributes = map[string]stypes.MessageAttributeValue{
+		contentTypeAttribute: {DataType: aws.String("String"), StringValue: aws.String(contentType)},
+	}
+	return msg
+}
+
+func TestPollAndProcess_DecryptsOnlyEncryptedMessages(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	decrypter := &MockDecrypter{}
+
+	proc := &Processor{
+		sqsClient:             mockSQS,
+		ddbClient:             mockDDB,
+		queueURL:              "test-queue",
+		tableName:             "Orders",
+		ordersProcessed:       NewCounterVec(),
+		environment:           "test",
+		decrypter:             decrypter,
+		encryptedContentTypes: defaultEncryptedContentTypes,
+	}
+
+	encrypted := withContentType(stypes.Message{
+		MessageId:     aws.String("m1"),
+		Body:          aws.String("c2VjcmV0"),
+		ReceiptHandle: aws.String("r1"),
+	}, "Application/Vnd.Order+Encrypted; v=1")
+	plaintext := withContentType(stypes.Message{
+		MessageId:     aws.String("m2"),
+		Body:          aws.String(`{"order_id":"o2","user_id":"u2","amount":200}`),
+		ReceiptHandle: aws.String("r2"),
+	}, "application/json")
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.MatchedBy(func(input *sqs.ReceiveMessageInput) bool {
+		return assert.Contains(t, input.MessageAttributeNames, "All")
+	})).Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{encrypted, plaintext}}, nil).Once()
+	decrypter.On("Decrypt", mock.Anything, "c2VjcmV0").
+		Return(`{"order_id":"o1","user_id":"u1","amount":100}`, nil).Once()
+
+	var stored []string
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			item := args.Get(1).(*dynamodb.PutItemInput).Item
+			stored = append(stored, item["order_id"].(*dtypes.AttributeValueMemberS).Value)
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Twice()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	decrypter.AssertExpectations(t)
+	assert.ElementsMatch(t, []string{"o1", "o2"}, stored)
+}
+
+func TestMessageBody_NoContentTypePassesThrough(t *testing.T) {
+	decrypter := &MockDecrypter{}
+	proc := &Processor{decrypter: decrypter, encryptedContentTypes: defaultEncryptedContentTypes}
+
+	body, err := proc.messageBody(context.Background(), stypes.Message{Body: aws.String(`{"order_id":"o1"}`)})
+
+	require.NoError(t, err)
+	assert.Equal(t, `{"order_id":"o1"}`, body)
+	decrypter.AssertNotCalled(t, "Decrypt", mock.Anything, mock.Anything)
+}
+
+func TestMessageBody_DecryptAllWithoutContentType(t *testing.T) {
+	mockKMS := &MockKMSClient{}
+	proc := &Processor{
+		decrypter:             &kmsDecrypter{client: mockKMS, keyID: "alias/orders"},
+		encryptedContentTypes: defaultEncryptedContentTypes,
+		decryptAll:            true,
+	}
+
+	mockKMS.On("Decrypt", mock.Anything, mock.MatchedBy(func(input *kms.DecryptInput) bool {
+		return string(input.CiphertextBlob) == "ciphertext" && aws.ToString(input.KeyId) == "alias/orders"
+	})).Return(&kms.DecryptOutput{Plaintext: []byte(`{"order_id":"o1"}`)}, nil).Once()
+
+	body, err := proc.messageBody(context.Background(), stypes.Message{
+		Body: aws.String(base64.StdEncoding.EncodeToString([]byte("ciphertext"))),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, `{"order_id":"o1"}`, body)
+	mockKMS.AssertExpectations(t)
+
+	// A plaintext body on such a queue is not base64 ciphertext
+	_, err = proc.messageBody(context.Background(), stypes.Message{Body: aws.String(`{"order_id":"o1"}`)})
+	var vErr *ValidationError
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "invalid_ciphertext", vErr.Type)
+}
+
+func TestKMSDecrypter_Decrypt(t *testing.T) {
+	mockKMS := &MockKMSClient{}
+	d := &kmsDecrypter{client: mockKMS, keyID: "alias/orders"}
+
+	mockKMS.On("Decrypt", mock.Anything, mock.MatchedBy(func(input *kms.DecryptInput) bool {
+		return string(input.CiphertextBlob) == "ciphertext" && aws.ToString(input.KeyId) == "alias/orders"
+	})).Return(&kms.DecryptOutput{Plaintext: []byte(`{"order_id":"o1"}`)}, nil).Once()
+
+	body, err := d.Decrypt(context.Background(), base64.StdEncoding.EncodeToString([]byte("ciphertext")))
+
+	require.NoError(t, err)
+	assert.Equal(t, `{"order_id":"o1"}`, body)
+	mockKMS.AssertExpectations(t)
+}
+
+func TestKMSDecrypter_InvalidCiphertextIsValidationError(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		kmsErr error
+	}{
+		{name: "not base64", body: "not base64!"},
+		{name: "rejected by KMS", body: "Y2lwaGVy", kmsErr: &ktypes.InvalidCiphertextException{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockKMS := &MockKMSClient{}
+			mockKMS.On("Decrypt", mock.Anything, mock.Anything).
+				Return((*kms.DecryptOutput)(nil), tt.kmsErr).Maybe()
+			d := &kmsDecrypter{client: mockKMS}
+
+			_, err := d.Decrypt(context.Background(), tt.body)
+
+			var vErr *ValidationError
+			require.True(t, errors.As(err, &vErr))
+			assert.Equal(t, "invalid_ciphertext", vErr.Type)
+		})
+	}
+}
+
+func TestKMSDecrypter_ServiceErrorIsRetryable(t *testing.T) {
+	mockKMS := &MockKMSClient{}
+	mockKMS.On("Decrypt", mock.Anything, mock.Anything).
+		Return((*kms.DecryptOutput)(nil), errors.New("throttled")).Once()
+	d := &kmsDecrypter{client: mockKMS}
+
+	_, err := d.Decrypt(context.Background(), "Y2lwaGVy")
+
+	var vErr *ValidationError
+	assert.False(t, errors.As(err, &vErr))
+	assert.ErrorContains(t, err, "decrypt message body: throttled")
+}
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Content dedup defaults; a zero window disables dedup
+const defaultContentDedupMaxEntries = 10000
+
+// contentDedup remembers the hashes of recently stored bodies for a fixed
+// window, so producers that resend the same order without a dedup ID do
+// not cause repeated writes. It holds at most maxEntries hashes, evicting
+// the oldest first.
+type contentDedup struct {
+	window     time.Duration
+	maxEntries int
+	// now is time.Now when nil
+	now func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+	// fifo lists hashes in insertion order for expiry and eviction
+	fifo []dedupEntry
+}
+
+type dedupEntry struct {
+	hash string
+	at   time.Time
+}
+
+func newContentDedup(window time.Duration, maxEntries int) *contentDedup {
+	return &contentDedup{
+		window:     window,
+		maxEntries: maxEntries,
+		seen:       make(map[string]time.Time),
+	}
+}
+
+// contentHash returns the SHA256 of the normalized body. JSON bodies are
+// re-encoded so whitespace and key order do not matter; anything else is
+// hashed with surrounding whitespace trimmed.
+func contentHash(body string) string {
+	normalized := bytes.TrimSpace([]byte(body))
+
+	dec := json.NewDecoder(bytes.NewReader(normalized))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err == nil && !dec.More() {
+		// encoding/json writes map keys sorted
+		if canonical, err := json.Marshal(v); err == nil {
+			normalized = canonical
+		}
+	}
+
+	sum := sha256.Sum256(normalized)
+	return hex.EncodeToString(sum[:])
+}
+
+// Seen reports whether hash was remembered within the window.
+func (d *contentDedup) Seen(hash string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.expire()
+	_, ok := d.seen[hash]
+	return ok
+}
+
+// Remember records hash as stored now.
+func (d *contentDedup) Remember(hash string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.expire()
+	if _, ok := d.seen[hash]; ok {
+		return
+	}
+	for len(d.fifo) >= d.maxEntries && len(d.fifo) > 0 {
+		d.evictOldest()
+	}
+
+	at := d.clock()
+	d.seen[hash] = at
+	d.fifo = append(d.fifo, dedupEntry{hash: hash, at: at})
+}
+
+// expire drops entries older than the window. Callers hold d.mu.
+func (d *contentDedup) expire() {
+	cutoff := d.clock().Add(-d.window)
+	for len(d.fifo) > 0 && !d.fifo[0].at.After(cutoff) {
+		d.evictOldest()
+	}
+}
+
+func (d *contentDedup) evictOldest() {
+	delete(d.seen, d.fifo[0].hash)
+	d.fifo[0] = dedupEntry{}
+	d.fifo = d.fifo[1:]
+}
+
+func (d *contentDedup) clock() time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPollAndProcess_ContentDuplicateWrittenOnce(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		contentDedup:    newContentDedup(time.Minute, 10),
+	}
+
+	// Same order, different whitespace and key order
+	msgs := []stypes.Message{
+		{
+			MessageId:     aws.String("msg-1"),
+			Body:          aws.String(`{"order_id":"o1","user_id":"u1","amount":100}`),
+			ReceiptHandle: aws.String("r1"),
+		},
+		{
+			MessageId:     aws.String("msg-2"),
+			Body:          aws.String(` { "amount": 100, "user_id": "u1", "order_id": "o1" } `),
+			ReceiptHandle: aws.String("r2"),
+		},
+	}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs}, nil)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	// Duplicates are deleted like any other handled message
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("content_duplicate", "test", "test-queue")))
+}
+
+func TestContentDedup_WindowExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	d := newContentDedup(time.Minute, 10)
+	d.now = func() time.Time { return now }
+
+	h := contentHash(`{"order_id":"o1"}`)
+	d.Remember(h)
+	assert.True(t, d.Seen(h))
+
+	now = now.Add(59 * time.Second)
+	assert.True(t, d.Seen(h))
+
+	now = now.Add(time.Second)
+	assert.False(t, d.Seen(h))
+}
+
+func TestContentDedup_EvictsOldestWhenFull(t *testing.T) {
+	d := newContentDedup(time.Hour, 2)
+
+	d.Remember("a")
+	d.Remember("b")
+	d.Remember("c")
+
+	assert.False(t, d.Seen("a"))
+	assert.True(t, d.Seen("b"))
+	assert.True(t, d.Seen("c"))
+}
+
+func TestContentHash_Normalization(t *testing.T) {
+	assert.Equal(t, contentHash(`{"a":1,"b":"x"}`), contentHash("{ \"b\": \"x\",\n \"a\": 1 }"))
+	assert.NotEqual(t, contentHash(`{"a":1}`), contentHash(`{"a":2}`))
+	// Large integers keep full precision
+	assert.NotEqual(t, contentHash(`{"a":9007199254740993}`), contentHash(`{"a":9007199254740992}`))
+	assert.Equal(t, contentHash("not json"), contentHash("  not json\n"))
+}
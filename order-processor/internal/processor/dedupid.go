@@ -0,0 +1,128 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// dedupIDPlaceholder matches a {field} in a dedup ID or key template.
+var dedupIDPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// dedupIDFields are the order fields, by JSON name, a dedup ID or key
+// template may refer to.
+var dedupIDFields = []string{
+	"order_id", "user_id", "amount", "status", "currency",
+	"created_at", "parent_order_id", "attachment_url", "tenant_id",
+}
+
+// dedupIDTemplate derives a deduplication ID from order fields, for
+// producers that send no dedup ID of their own, e.g. ones that retry an
+// order under a fresh order ID. The template, such as
+// "{user_id}|{amount}|{created_at}", is filled in from the normalized
+// order and hashed, so equivalent orders get the same ID whatever their
+// whitespace, key order or number formatting.
+type dedupIDTemplate struct {
+	template string
+}
+
+// parseDedupIDTemplate checks that template names at least one field and
+// only known ones.
+func parseDedupIDTemplate(template string) (*dedupIDTemplate, error) {
+	if err := checkOrderTemplate("dedup ID", template); err != nil {
+		return nil, err
+	}
+	return &dedupIDTemplate{template: template}, nil
+}
+
+// checkOrderTemplate checks that a kind template names at least one
+// {field} and only known ones.
+func checkOrderTemplate(kind, template string) error {
+	matches := dedupIDPlaceholder.FindAllStringSubmatch(template, -1)
+	if len(matches) == 0 {
+		return fmt.Errorf("%s template %q names no {field}", kind, template)
+	}
+	for _, m := range matches {
+		if !slices.Contains(dedupIDFields, m[1]) {
+			return fmt.Errorf("%s template %q: unknown order field %q", kind, template, m[1])
+		}
+	}
+	return nil
+}
+
+// derive returns the dedup ID of order: the hex SHA-256 of the filled-in
+// template, which also meets SQS's MessageDeduplicationId rules.
+func (t *dedupIDTemplate) derive(order Order) (string, error) {
+	filled, err := fillOrderTemplate(t.template, order)
+	if err != nil {
+		return "", fmt.Errorf("derive dedup ID: %w", err)
+	}
+	sum := sha256.Sum256([]byte(filled))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// fillOrderTemplate replaces each {field} in template with that field of
+// order as it is encoded in JSON; fields order does not set are left
+// empty.
+func fillOrderTemplate(template string, order Order) (string, error) {
+	// The same instant in any offset is the same order
+	order.CreatedAt = order.CreatedAt.UTC()
+	encoded, err := json.Marshal(order)
+	if err != nil {
+		return "", err
+	}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.UseNumber()
+	var fields map[string]any
+	if err := dec.Decode(&fields); err != nil {
+		return "", err
+	}
+
+	return dedupIDPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch v := fields[strings.Trim(placeholder, "{}")].(type) {
+		case nil:
+			return ""
+		case string:
+			return v
+		case json.Number:
+			return v.String()
+		default:
+			raw, _ := json.Marshal(v)
+			return string(raw)
+		}
+	}), nil
+}
+
+// messageDedupID derives the dedup ID of the order in msg as prepareOrder
+// does, or returns "" when no template is configured or msg holds no valid
+// order.
+func (p *Processor) messageDedupID(ctx context.Context, msg types.Message) string {
+	if p.dedupID == nil || msg.Body == nil {
+		return ""
+	}
+	body, err := p.messageBody(ctx, msg)
+	if err != nil {
+		return ""
+	}
+	order, err := p.parseMessageOrder(msg, body)
+	if err != nil {
+		return ""
+	}
+	p.normalization.apply(&order)
+	if err := checkCurrency(&order); err != nil {
+		return ""
+	}
+	id, err := p.dedupID.derive(order)
+	if err != nil {
+		return ""
+	}
+	return id
+}
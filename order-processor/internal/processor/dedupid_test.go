@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testDedupIDTemplate = "{user_id}|{amount}|{currency}|{created_at}"
+
+func dedupIDProcessor(t *testing.T) *Processor {
+	template, err := parseDedupIDTemplate(testDedupIDTemplate)
+	require.NoError(t, err)
+	return &Processor{
+		dedupID:       template,
+		normalization: newOrderNormalization(true, nil, nil),
+	}
+}
+
+func bodyMessage(id, body string) stypes.Message {
+	return stypes.Message{MessageId: aws.String(id), ReceiptHandle: aws.String("r-" + id), Body: aws.String(body)}
+}
+
+func TestDedupIDTemplate_StableForEquivalentOrders(t *testing.T) {
+	proc := dedupIDProcessor(t)
+	ctx := context.Background()
+
+	id := proc.messageDedupID(ctx, bodyMessage("m1",
+		`{"order_id":"o1","user_id":"u1","amount":100.5,"currency":"EUR","created_at":"2024-05-01T08:00:00Z"}`))
+	require.Len(t, id, 64)
+	assert.Equal(t, id, proc.messageDedupID(ctx, bodyMessage("m1",
+		`{"order_id":"o1","user_id":"u1","amount":100.5,"currency":"EUR","created_at":"2024-05-01T08:00:00Z"}`)))
+
+	// A retry under a new order ID, reformatted, is the same order
+	assert.Equal(t, id, proc.messageDedupID(ctx, bodyMessage("m2", `{
+		"created_at": "2024-05-01T10:00:00+02:00",
+		"currency": "eur",
+		"amount": 100.50,
+		"user_id": " u1 ",
+		"order_id": "o2"
+	}`)))
+
+	// A different amount is a different order
+	assert.NotEqual(t, id, proc.messageDedupID(ctx, bodyMessage("m3",
+		`{"order_id":"o1","user_id":"u1","amount":100.6,"currency":"EUR","created_at":"2024-05-01T08:00:00Z"}`)))
+	// No ID for a body that is not an order
+	assert.Empty(t, proc.messageDedupID(ctx, bodyMessage("m4", `not json`)))
+}
+
+func TestParseDedupIDTemplate_Invalid(t *testing.T) {
+	for _, template := range []string{"static", "{user_id}|{sku}", "{}"} {
+		_, err := parseDedupIDTemplate(template)
+		assert.Error(t, err, template)
+	}
+}
+
+func TestPollAndProcess_DedupIDSkipsEquivalentOrders(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := dedupIDProcessor(t)
+	proc.sqsClient = mockSQS
+	proc.ddbClient = mockDDB
+	proc.queueURL = "test-queue"
+	proc.tableName = "Orders"
+	proc.ordersProcessed = NewCounterVec()
+	proc.environment = "test"
+	proc.contentDedup = newContentDedup(time.Minute, 10)
+	proc.keys = keyLayout{strategy: dedupIDKeys{}, partitionAttr: "pk"}
+	want := proc.messageDedupID(context.Background(), bodyMessage("m1",
+		`{"order_id":"o1","user_id":"u1","amount":100,"created_at":"2024-05-01T08:00:00Z"}`))
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			bodyMessage("m1", `{"order_id":"o1","user_id":"u1","amount":100,"created_at":"2024-05-01T08:00:00Z"}`),
+		}}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			bodyMessage("m2", `{"order_id":"o2","user_id":"u1","amount":100.0,"created_at":"2024-05-01T08:00:00Z"}`),
+		}}, nil).Once()
+	// The dedup ID keys the conditional write and is stored with the order
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, &dtypes.AttributeValueMemberS{Value: want}, input.Item["pk"]) &&
+			assert.Equal(t, &dtypes.AttributeValueMemberS{Value: want}, input.Item["dedup_id"]) &&
+			assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "o1"}, input.Item["order_id"])
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, mock.Anything).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Twice()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+	_, err = proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("content_duplicate", "test", "test-queue")))
+}
+
+func TestSendToDLQ_FIFORepublishesWithDedupID(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := dedupIDProcessor(t)
+	proc.sqsClient = mockSQS
+	proc.dlqURL = "https://sqs/orders-dlq.fifo"
+	proc.dedupIDRepublish = true
+	msg := bodyMessage("m1", `{"order_id":"o1","user_id":"u1","amount":100}`)
+	want := proc.messageDedupID(context.Background(), msg)
+
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		return aws.ToString(input.MessageDeduplicationId) == want
+	})).Return(&sqs.SendMessageOutput{}, nil).Once()
+
+	require.NoError(t, proc.sendToDLQ(context.Background(), msg, dlqReasonBudgetExhausted))
+	mockSQS.AssertExpectations(t)
+}
@@ -0,0 +1,41 @@
+package processor
+
+import "sync"
+
+// deletedHandles tracks receipt handles deleted during the current poll,
+// so a handle reached twice (e.g. by the batch delete and the DLQ path) is
+// only deleted once. The zero value is ready to use.
+type deletedHandles struct {
+	mu      sync.Mutex
+	handles map[string]struct{}
+}
+
+// reset forgets all handles; it is called at the start of every poll since
+// receipt handles are only valid for the receive that issued them.
+func (d *deletedHandles) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handles = nil
+}
+
+// claim marks handle as deleted, reporting false if it already was. A
+// claim is taken before the delete call so concurrent callers cannot both
+// issue it; release gives it back if the call fails.
+func (d *deletedHandles) claim(handle string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.handles[handle]; ok {
+		return false
+	}
+	if d.handles == nil {
+		d.handles = make(map[string]struct{})
+	}
+	d.handles[handle] = struct{}{}
+	return true
+}
+
+func (d *deletedHandles) release(handle string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.handles, handle)
+}
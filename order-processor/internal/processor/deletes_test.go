@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDeleteMessage_DoubleDeleteIsNoOp(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{sqsClient: mockSQS, queueURL: "test-queue"}
+	msg := stypes.Message{ReceiptHandle: aws.String("r1")}
+
+	mockSQS.On("DeleteMessage", mock.Anything, mock.Anything).
+		Return(&sqs.DeleteMessageOutput{}, nil).Once()
+
+	assert.NoError(t, proc.deleteMessage(context.Background(), msg))
+	assert.NoError(t, proc.deleteMessage(context.Background(), msg))
+
+	mockSQS.AssertNumberOfCalls(t, "DeleteMessage", 1)
+}
+
+func TestDeleteMessageBatch_SkipsAlreadyDeletedHandles(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{sqsClient: mockSQS, queueURL: "test-queue"}
+	msgs := []stypes.Message{
+		{ReceiptHandle: aws.String("r1")},
+		{ReceiptHandle: aws.String("r2")},
+		{ReceiptHandle: aws.String("r1")},
+	}
+
+	mockSQS.On("DeleteMessage", mock.Anything, mock.Anything).
+		Return(&sqs.DeleteMessageOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	assert.NoError(t, proc.deleteMessage(context.Background(), msgs[0]))
+	assert.NoError(t, proc.deleteMessageBatch(context.Background(), msgs))
+	assert.NoError(t, proc.deleteMessageBatch(context.Background(), msgs))
+
+	mockSQS.AssertExpectations(t)
+}
+
+func TestDeleteMessage_FailedDeleteCanBeRetried(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{sqsClient: mockSQS, queueURL: "test-queue"}
+	msg := stypes.Message{ReceiptHandle: aws.String("r1")}
+
+	mockSQS.On("DeleteMessage", mock.Anything, mock.Anything).
+		Return((*sqs.DeleteMessageOutput)(nil), errors.New("SQS error")).Once()
+	mockSQS.On("DeleteMessage", mock.Anything, mock.Anything).
+		Return(&sqs.DeleteMessageOutput{}, nil).Once()
+
+	assert.Error(t, proc.deleteMessage(context.Background(), msg))
+	assert.NoError(t, proc.deleteMessage(context.Background(), msg))
+
+	mockSQS.AssertExpectations(t)
+}
+
+func TestPollAndProcess_DeletedHandlesResetEachPoll(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{sqsClient: mockSQS, queueURL: "test-queue"}
+
+	assert.True(t, proc.deleted.claim("r1"))
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, proc.deleted.claim("r1"))
+}
@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// deterministicEpoch is the time a deterministic processor's clock is
+// stopped at.
+var deterministicEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Clock is the processor's time source, injectable so tests can fix it.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// fixedClock is stopped at one instant; waits on it end immediately.
+type fixedClock struct {
+	at time.Time
+}
+
+func (c fixedClock) Now() time.Time { return c.at }
+
+func (c fixedClock) After(time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.at
+	return ch
+}
+
+// now returns the current time from p.clock, or the system clock when none
+// is set.
+func (p *Processor) now() time.Time {
+	if p.clock == nil {
+		return time.Now()
+	}
+	return p.clock.Now()
+}
+
+// after waits on p.clock, or the system clock when none is set.
+func (p *Processor) after(d time.Duration) <-chan time.Time {
+	if p.clock == nil {
+		return time.After(d)
+	}
+	return p.clock.After(d)
+}
+
+// random returns a number in [0, 1) from p.randFloat, or math/rand when
+// none is set.
+func (p *Processor) random() float64 {
+	if p.randFloat == nil {
+		return rand.Float64()
+	}
+	return p.randFloat()
+}
+
+// WithClock makes p read the time from clock and wait on it, so
+// time-dependent behaviour can be tested exactly. The pacer, the content
+// dedup window and the exchange rate and reference caches configured on p
+// follow it too. It returns p.
+func (p *Processor) WithClock(clock Clock) *Processor {
+	p.clock = clock
+	if p.pacer != nil {
+		p.pacer.clock = clock
+	}
+	if p.contentDedup != nil {
+		p.contentDedup.now = clock.Now
+	}
+	if rates, ok := p.rateProvider.(*cachedRateProvider); ok {
+		rates.now = clock.Now
+	}
+	store := p.references
+	if cached, ok := store.(*cachedReferenceStore); ok {
+		cached.now = clock.Now
+		store = cached.store
+	}
+	if ddb, ok := store.(*ddbReferenceStore); ok {
+		ddb.after = clock.After
+	}
+	return p
+}
+
+// WithDeterminism fixes p's non-deterministic inputs so downstream
+// consumers can test against reproducible output: timestamps come from a
+// clock stopped at 2000-01-01T00:00:00Z, trace sampling draws from a RNG
+// seeded with seed, and batches are handled by a single worker in receive
+// order. It returns p and is meant for tests only.
+func (p *Processor) WithDeterminism(seed uint64) *Processor {
+	p.WithClock(fixedClock{at: deterministicEpoch})
+
+	// Guarded, since spans are sampled from the worker goroutines
+	var mu sync.Mutex
+	rng := rand.New(rand.NewPCG(seed, seed))
+	p.randFloat = func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return rng.Float64()
+	}
+
+	// One worker is always a valid concurrency
+	_ = p.SetConcurrency(1)
+	return p
+}
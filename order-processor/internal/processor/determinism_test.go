@@ -0,0 +1,155 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// deterministicRun processes one batch with a processor fixed by seed and
+// returns everything it wrote, plus a few trace sampling decisions.
+func deterministicRun(t *testing.T, seed uint64) ([]*dynamodb.PutItemInput, []LifecycleEvent, []bool) {
+	t.Helper()
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	wal := &memoryWAL{}
+
+	proc := (&Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		failuresTable:   "Failures",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		wal:             wal,
+		traceSampleRate: 0.5,
+	}).WithDeterminism(seed)
+
+	msgs := append(orderBatch(3), stypes.Message{
+		MessageId:     aws.String("bad"),
+		Body:          aws.String(`not json`),
+		ReceiptHandle: aws.String("rb"),
+	})
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs}, nil).Once()
+
+	var written []*dynamodb.PutItemInput
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			written = append(written, args.Get(1).(*dynamodb.PutItemInput))
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Times(4)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "r2", "rb")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	var sampled []bool
+	for range 8 {
+		sampled = append(sampled, proc.sampleTrace())
+	}
+	return written, wal.events, sampled
+}
+
+func TestWithDeterminism_SameSeedSameOutput(t *testing.T) {
+	written1, events1, sampled1 := deterministicRun(t, 7)
+	written2, events2, sampled2 := deterministicRun(t, 7)
+
+	require.Len(t, written1, 4)
+	assert.Equal(t, written1, written2)
+	assert.Equal(t, events1, events2)
+	assert.Equal(t, sampled1, sampled2)
+
+	// The failure record carries the fixed timestamp
+	failure := written1[3]
+	assert.Equal(t, "Failures", aws.ToString(failure.TableName))
+	assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "2000-01-01T00:00:00Z"}, failure.Item["failed_at"])
+	assert.Equal(t, "2000-01-01T00:00:00Z", events1[0].At)
+}
+
+func TestWithDeterminism_SeedDrivesSampling(t *testing.T) {
+	_, _, sampled1 := deterministicRun(t, 7)
+	_, _, sampled2 := deterministicRun(t, 8)
+
+	assert.NotEqual(t, sampled1, sampled2)
+}
+
+func TestWithClock_AlsoClocksPacer(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	proc := (&Processor{pacer: newPacer(10, realClock{})}).WithClock(clock)
+
+	assert.Equal(t, clock.now, proc.now())
+	assert.Same(t, clock, proc.pacer.clock)
+}
+
+func TestWithClock_ExpiresCachesOnFakeClock(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	rates := &MockRateProvider{}
+	refs := &MockReferenceStore{}
+	proc := (&Processor{
+		contentDedup: newContentDedup(time.Minute, 10),
+		rateProvider: newCachedRateProvider(rates, time.Hour),
+		references:   newCachedReferenceStore(refs, time.Hour),
+	}).WithClock(clock)
+	ctx := context.Background()
+
+	rates.On("Rate", mock.Anything, "EUR").Return(1.08, nil).Once()
+	rates.On("Rate", mock.Anything, "EUR").Return(1.09, nil).Once()
+	refs.On("Known", mock.Anything, []string{"p1"}).Return(map[string]bool{"p1": true}, nil).Twice()
+
+	proc.contentDedup.Remember("h1")
+	_, err := proc.rateProvider.Rate(ctx, "EUR")
+	require.NoError(t, err)
+	_, err = proc.references.Known(ctx, []string{"p1"})
+	require.NoError(t, err)
+
+	clock.advance(59 * time.Second)
+	assert.True(t, proc.contentDedup.Seen("h1"))
+
+	clock.advance(time.Hour)
+	assert.False(t, proc.contentDedup.Seen("h1"))
+	rate, err := proc.rateProvider.Rate(ctx, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, 1.09, rate)
+	_, err = proc.references.Known(ctx, []string{"p1"})
+	require.NoError(t, err)
+
+	rates.AssertExpectations(t)
+	refs.AssertExpectations(t)
+}
+
+func TestWithClock_ReferenceBackoffWaitsOnClock(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	mockDDB := &MockDynamoDBClient{}
+	proc := (&Processor{
+		references: &ddbReferenceStore{client: mockDDB, table: "Products", keyAttr: "product_id"},
+	}).WithClock(clock)
+
+	unprocessed := map[string]dtypes.KeysAndAttributes{"Products": {
+		Keys: []map[string]dtypes.AttributeValue{{"product_id": &dtypes.AttributeValueMemberS{Value: "p1"}}},
+	}}
+	mockDDB.On("BatchGetItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.BatchGetItemOutput{UnprocessedKeys: unprocessed}, nil).Once()
+	mockDDB.On("BatchGetItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]dtypes.AttributeValue{
+			"Products": {{"product_id": &dtypes.AttributeValueMemberS{Value: "p1"}}},
+		}}, nil).Once()
+
+	known, err := proc.references.Known(context.Background(), []string{"p1"})
+
+	require.NoError(t, err)
+	assert.True(t, known["p1"])
+	assert.Equal(t, []time.Duration{batchWriteBaseBackoff}, clock.waits)
+	mockDDB.AssertExpectations(t)
+}
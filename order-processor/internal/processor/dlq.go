@@ -0,0 +1,148 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// dlqReasonBudgetExhausted tags messages of a batch that used up
+	// BATCH_RETRY_BUDGET.
+	dlqReasonBudgetExhausted = "batch_retry_budget_exhausted"
+	// sqsMaxMessageAttributes is the most message attributes SQS accepts
+	// on one message
+	sqsMaxMessageAttributes = 10
+)
+
+// batchBudgetExhausted reports whether a batch in which every message
+// failed has been redelivered more than the budget allows. The least
+// redelivered message decides, so a message is never dead-lettered before
+// it has had its full budget of retries.
+func (p *Processor) batchBudgetExhausted(failed []types.Message) bool {
+	if p.batchRetryBudget <= 0 || p.dlqURL == "" || len(failed) == 0 {
+		return false
+	}
+	for _, msg := range failed {
+		// The first delivery is not a redelivery
+		if receiveCount(msg)-1 < p.batchRetryBudget {
+			return false
+		}
+	}
+	return true
+}
+
+// receiveCount returns the message's ApproximateReceiveCount, or 1 when
+// SQS did not report it.
+func receiveCount(msg types.Message) int {
+	n, err := strconv.Atoi(msg.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// deadLetterBatch moves every message of a persistently failing batch to
+// the DLQ. A message is only deleted from the source queue once the DLQ
+// holds a copy, so a failed send leaves it to be redelivered.
+func (p *Processor) deadLetterBatch(ctx context.Context, msgs []types.Message) {
+	log.Warn().
+		Int("messages", len(msgs)).
+		Int("budget", p.batchRetryBudget).
+		Msg("batch retry budget exhausted - moving batch to DLQ")
+
+	for _, msg := range msgs {
+		ctx := withMessageLogger(ctx, msg)
+		if err := p.sendToDLQ(ctx, msg, dlqReasonBudgetExhausted); err != nil {
+			logger(ctx).Error().Err(err).Msg("failed to send message to DLQ - message will be retried")
+			continue
+		}
+		p.countOrder(ctx, "dead_lettered")
+		if err := p.deleteMessage(ctx, msg); err != nil {
+			logger(ctx).Error().Err(err).Msg("failed to delete dead-lettered message - it may be reprocessed")
+		}
+	}
+}
+
+// deadLetterInvalid moves a message that failed validation to the DLQ
+// straight away, since redelivering it cannot succeed. It reports whether
+// the DLQ now holds a copy, so the message may be deleted.
+func (p *Processor) deadLetterInvalid(ctx context.Context, msg types.Message, vErr *ValidationError) bool {
+	if err := p.sendToDLQ(ctx, msg, vErr.Type); err != nil {
+		logger(ctx).Error().
+			Err(err).
+			Msg("failed to send invalid message to DLQ - message will be retried")
+		return false
+	}
+	p.countOrder(ctx, "dead_lettered")
+	return true
+}
+
+// sendToDLQ copies msg to the DLQ, tagged with why it was dead-lettered:
+// dlqReasonBudgetExhausted or a validation error type.
+func (p *Processor) sendToDLQ(ctx context.Context, msg types.Message, reason string) error {
+	return p.sendDLQInput(ctx, p.dlqInput(ctx, msg, reason))
+}
+
+// dlqInput builds the DLQ copy of msg tagged with reason. The copy keeps
+// msg's attributes, so a redrive restores them; the tags only take the
+// slots they leave free.
+func (p *Processor) dlqInput(ctx context.Context, msg types.Message, reason string) *sqs.SendMessageInput {
+	input := &sqs.SendMessageInput{
+		QueueUrl:          &p.dlqURL,
+		MessageBody:       msg.Body,
+		MessageAttributes: maps.Clone(msg.MessageAttributes),
+	}
+	if input.MessageAttributes == nil {
+		input.MessageAttributes = make(map[string]types.MessageAttributeValue)
+	}
+	setDLQTag(ctx, input.MessageAttributes, "dlq_reason", reason)
+	setDLQTag(ctx, input.MessageAttributes, "source_message_id", aws.ToString(msg.MessageId))
+
+	// FIFO queues need a group, kept from the source message so the DLQ
+	// preserves its order. Dedup by the source dedup ID (or message ID), or
+	// the derived dedup ID if so configured, keeps a retried send from
+	// landing twice
+	if isFIFOQueue(p.dlqURL) {
+		group := messageGroup(msg)
+		if group == "" {
+			group = "dead-lettered"
+		}
+		input.MessageGroupId = aws.String(group)
+		input.MessageDeduplicationId = sourceDedupID(msg)
+		if p.dedupIDRepublish {
+			if id := p.messageDedupID(ctx, msg); id != "" {
+				input.MessageDeduplicationId = aws.String(id)
+			}
+		}
+	}
+
+	return input
+}
+
+// setDLQTag sets the DLQ tag name on attrs unless they already hold
+// sqsMaxMessageAttributes, which SQS would reject the copy for.
+func setDLQTag(ctx context.Context, attrs map[string]types.MessageAttributeValue, name, value string) {
+	if _, ok := attrs[name]; !ok && len(attrs) >= sqsMaxMessageAttributes {
+		logger(ctx).Debug().Str("tag", name).Msg("message has no attribute left for DLQ tag; tag not added")
+		return
+	}
+	attrs[name] = types.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: aws.String(value),
+	}
+}
+
+func (p *Processor) sendDLQInput(ctx context.Context, input *sqs.SendMessageInput) error {
+	_, err := p.sqsClient.SendMessage(ctx, input)
+	if err != nil {
+		return fmt.Errorf("send message to DLQ: %w", err)
+	}
+	return nil
+}
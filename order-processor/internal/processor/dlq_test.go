@@ -0,0 +1,204 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func failingBatch(receiveCount int) []stypes.Message {
+	attrs := map[string]string{
+		"ApproximateReceiveCount": strconv.Itoa(receiveCount),
+		"MessageGroupId":          "user-1",
+	}
+	return []stypes.Message{
+		{MessageId: aws.String("m1"), Body: aws.String(`{"order_id":"o1","amount":100}`), ReceiptHandle: aws.String("r1"), Attributes: attrs},
+		{MessageId: aws.String("m2"), Body: aws.String(`{"order_id":"o2","amount":100}`), ReceiptHandle: aws.String("r2"), Attributes: attrs},
+	}
+}
+
+func newBudgetProcessor(mockSQS *MockSQSClient, mockDDB *MockDynamoDBClient) *Processor {
+	return &Processor{
+		sqsClient:        mockSQS,
+		ddbClient:        mockDDB,
+		queueURL:         "test-queue",
+		tableName:        "Orders",
+		ordersProcessed:  NewCounterVec(),
+		environment:      "test",
+		batchRetryBudget: 3,
+		dlqURL:           "test-dlq",
+	}
+}
+
+func TestPollAndProcess_PersistentlyFailingBatchDeadLettered(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBudgetProcessor(mockSQS, mockDDB)
+
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("DynamoDB error"))
+
+	// Within budget: the batch is left on the queue for redelivery
+	for receive := 1; receive <= 3; receive++ {
+		mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+			Return(&sqs.ReceiveMessageOutput{Messages: failingBatch(receive)}, nil).Once()
+		_, err := proc.pollAndProcess(context.Background())
+		assert.NoError(t, err)
+	}
+	mockSQS.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything)
+	mockSQS.AssertNotCalled(t, "DeleteMessage", mock.Anything, mock.Anything)
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+
+	// Fourth delivery is the third redelivery: the budget is spent
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: failingBatch(4)}, nil).Once()
+	for _, id := range []string{"m1", "m2"} {
+		mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+			return *input.QueueUrl == "test-dlq" &&
+				input.MessageGroupId == nil &&
+				*input.MessageAttributes["source_message_id"].StringValue == id
+		})).Return(&sqs.SendMessageOutput{}, nil).Once()
+	}
+	mockSQS.On("DeleteMessage", mock.Anything, mock.Anything).
+		Return(&sqs.DeleteMessageOutput{}, nil).Twice()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	assert.Equal(t, 2.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("dead_lettered", "test", "test-queue")))
+}
+
+func TestPollAndProcess_PartiallySucceedingBatchNotDeadLettered(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBudgetProcessor(mockSQS, mockDDB)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: failingBatch(10)}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("DynamoDB error")).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, mock.Anything).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockSQS.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything)
+}
+
+func TestPollAndProcess_DLQSendFailureKeepsMessage(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newBudgetProcessor(mockSQS, mockDDB)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: failingBatch(4)[:1]}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("DynamoDB error"))
+	mockSQS.On("SendMessage", mock.Anything, mock.Anything).
+		Return((*sqs.SendMessageOutput)(nil), errors.New("SQS error")).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockSQS.AssertNotCalled(t, "DeleteMessage", mock.Anything, mock.Anything)
+}
+
+func TestSendToDLQ_FIFOKeepsMessageGroup(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{sqsClient: mockSQS, dlqURL: "https://sqs/orders-dlq.fifo"}
+
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		return aws.ToString(input.MessageGroupId) == "user-1" &&
+			aws.ToString(input.MessageDeduplicationId) == "m1"
+	})).Return(&sqs.SendMessageOutput{}, nil).Once()
+
+	err := proc.sendToDLQ(context.Background(), failingBatch(4)[0], dlqReasonBudgetExhausted)
+
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+}
+
+func TestPollAndProcess_InvalidMessagesDeadLetteredImmediately(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		dlqURL:          "test-dlq",
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			{MessageId: aws.String("m1"), Body: aws.String(`not json`), ReceiptHandle: aws.String("r1")},
+			{MessageId: aws.String("m2"), Body: aws.String(`{"amount":100}`), ReceiptHandle: aws.String("r2")},
+			{MessageId: aws.String("m3"), Body: aws.String(`{"order_id":"o3","amount":-5}`), ReceiptHandle: aws.String("r3")},
+			{MessageId: aws.String("m4"), Body: aws.String(`{"order_id":"o4","amount":100}`), ReceiptHandle: aws.String("r4")},
+		}}, nil).Once()
+	// Throttling is retryable, so o4 stays on the queue
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("ProvisionedThroughputExceededException")).Once()
+
+	var reasons []string
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		return aws.ToString(input.QueueUrl) == "test-dlq"
+	})).
+		Run(func(args mock.Arguments) {
+			input := args.Get(1).(*sqs.SendMessageInput)
+			reasons = append(reasons, aws.ToString(input.MessageAttributes["dlq_reason"].StringValue))
+		}).
+		Return(&sqs.SendMessageOutput{}, nil).Times(3)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1", "r2", "r3")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, []string{"invalid_json", "missing_order_id", "invalid_amount"}, reasons)
+	assert.Equal(t, 3.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("dead_lettered", "test", "test-queue")))
+}
+
+func TestPollAndProcess_InvalidMessageKeptWhenDLQSendFails(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		dlqURL:          "test-dlq",
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			{MessageId: aws.String("m1"), Body: aws.String(`not json`), ReceiptHandle: aws.String("r1")},
+		}}, nil).Once()
+	mockSQS.On("SendMessage", mock.Anything, mock.Anything).
+		Return((*sqs.SendMessageOutput)(nil), errors.New("SQS error")).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,34 @@
+package processor
+
+import (
+	"context"
+)
+
+// WithDryRun puts the processor in dry run mode, for validating a new
+// producer's stream: orders are parsed, validated and enriched as usual
+// but never stored, and messages are never deleted, dead-lettered or
+// recorded as failures, so they all stay on the queue. Each order that
+// would have been written is logged and counted as dry_run.
+func (p *Processor) WithDryRun() *Processor {
+	p.dryRun = true
+	p.dlqURL = ""
+	p.failuresTable = ""
+	p.lineItems = nil
+	return p
+}
+
+// keepsMessages reports whether messages are left on the queue instead of
+// being deleted once handled, as in shadow and dry run modes.
+func (p *Processor) keepsMessages() bool {
+	return p.shadow || p.dryRun
+}
+
+// skipDryRunOrder logs and counts an order dry run mode leaves unstored.
+func (p *Processor) skipDryRunOrder(ctx context.Context, prepared *preparedOrder) {
+	p.countOrder(ctx, "dry_run")
+	table, _ := p.orderTable(prepared.order)
+	logger(ctx).Info().
+		Str("table", table).
+		Interface("order", prepared.order).
+		Msg("dry run - order would have been written")
+}
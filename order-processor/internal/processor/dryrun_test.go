@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollAndProcess_DryRunStoresAndDeletesNothing(t *testing.T) {
+	for _, batchWrite := range []bool{false, true} {
+		mockSQS := &MockSQSClient{}
+		mockDDB := &MockDynamoDBClient{}
+		proc := (&Processor{
+			sqsClient:       mockSQS,
+			ddbClient:       mockDDB,
+			queueURL:        "test-queue",
+			dlqURL:          "test-dlq",
+			failuresTable:   "Failures",
+			tableName:       "Orders",
+			ordersProcessed: NewCounterVec(),
+			environment:     "test",
+			batchWrite:      batchWrite,
+		}).WithDryRun()
+
+		msgs := append(orderBatch(2), stypes.Message{
+			MessageId:     aws.String("bad"),
+			Body:          aws.String(`invalid`),
+			ReceiptHandle: aws.String("rb"),
+		})
+		mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+			Return(&sqs.ReceiveMessageOutput{Messages: msgs}, nil).Once()
+
+		_, err := proc.pollAndProcess(context.Background())
+		require.NoError(t, err)
+
+		mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+		mockDDB.AssertNotCalled(t, "BatchWriteItem", mock.Anything, mock.Anything)
+		mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+		mockSQS.AssertNotCalled(t, "DeleteMessage", mock.Anything, mock.Anything)
+		mockSQS.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything)
+		assert.Equal(t, 2.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("dry_run", "test", "test-queue")))
+		assert.Equal(t, 0.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	}
+}
+
+func TestNewProcessor_DryRun(t *testing.T) {
+	for _, name := range []string{envReportBucket, envOTLPEndpoint, envSQSQueueURLs, envShadowMode} {
+		t.Setenv(name, "")
+	}
+	t.Setenv(envDryRun, "true")
+	t.Setenv(envDLQURL, "test-dlq")
+
+	proc, err := NewProcessor(context.Background(),
+		WithQueueURL("test-queue"),
+		WithTableName("Orders"),
+		WithSQSClient(&MockSQSClient{}),
+		WithDDBClient(&MockDynamoDBClient{}),
+	)
+	require.NoError(t, err)
+	assert.True(t, proc.dryRun)
+	assert.Empty(t, proc.dlqURL)
+}
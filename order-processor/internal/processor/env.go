@@ -0,0 +1,127 @@
+package processor
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// envBool reads a boolean environment variable, returning def when the
+// variable is unset or cannot be parsed.
+func envBool(name string, def bool) bool {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Warn().Str("env", name).Str("value", raw).Msg("invalid boolean, using default")
+		return def
+	}
+	return v
+}
+
+// envOptionalBool reads a boolean environment variable, reporting ok=false
+// when the variable is unset or cannot be parsed so callers can tell an
+// explicit false from no setting at all.
+func envOptionalBool(name string) (value, ok bool) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return false, false
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Warn().Str("env", name).Str("value", raw).Msg("invalid boolean, ignoring")
+		return false, false
+	}
+	return v, true
+}
+
+// envFloat reads a float environment variable, returning def when the
+// variable is unset, unparseable, or outside [min, max].
+func envFloat(name string, def, min, max float64) float64 {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v < min || v > max {
+		log.Warn().
+			Str("env", name).
+			Str("value", raw).
+			Float64("min", min).
+			Float64("max", max).
+			Msg("invalid number, using default")
+		return def
+	}
+	return v
+}
+
+// envInt64 reads an integer environment variable, returning def when the
+// variable is unset, unparseable, or outside [min, max].
+func envInt64(name string, def, min, max int64) int64 {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || v < min || v > max {
+		log.Warn().
+			Str("env", name).
+			Str("value", raw).
+			Int64("min", min).
+			Int64("max", max).
+			Msg("invalid integer, using default")
+		return def
+	}
+	return v
+}
+
+// envList reads a comma-separated environment variable, returning def when
+// the variable is unset. Empty entries are dropped.
+func envList(name string, def []string) []string {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	var out []string
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// envDuration reads a Go duration environment variable (e.g. "30s"),
+// returning def when the variable is unset, unparseable, or outside
+// [min, max].
+func envDuration(name string, def, min, max time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil || v < min || v > max {
+		log.Warn().
+			Str("env", name).
+			Str("value", raw).
+			Dur("min", min).
+			Dur("max", max).
+			Msg("invalid duration, using default")
+		return def
+	}
+	return v
+}
+
+// envString reads a string environment variable, returning def when it is
+// unset or empty.
+func envString(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
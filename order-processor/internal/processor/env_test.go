@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvBool(t *testing.T) {
+	t.Setenv("TEST_BOOL_TRUE", "true")
+	t.Setenv("TEST_BOOL_INVALID", "maybe")
+
+	assert.True(t, envBool("TEST_BOOL_TRUE", false))
+	assert.True(t, envBool("TEST_BOOL_INVALID", true))
+	assert.False(t, envBool("TEST_BOOL_UNSET", false))
+}
+
+func TestEnvOptionalBool(t *testing.T) {
+	t.Setenv("TEST_BOOL_FALSE", "false")
+	t.Setenv("TEST_BOOL_INVALID", "maybe")
+
+	v, ok := envOptionalBool("TEST_BOOL_FALSE")
+	assert.True(t, ok)
+	assert.False(t, v)
+
+	_, ok = envOptionalBool("TEST_BOOL_INVALID")
+	assert.False(t, ok)
+
+	_, ok = envOptionalBool("TEST_BOOL_UNSET")
+	assert.False(t, ok)
+}
+
+func TestReceiveOptionsFromEnv(t *testing.T) {
+	t.Setenv(envSQSMaxMessages, "10")
+	t.Setenv(envSQSWaitTime, "0")
+	t.Setenv(envSQSVisibility, "900")
+
+	assert.Equal(t, receiveOptions{maxMessages: 10, waitTimeSeconds: 0, visibilityTimeout: 900}, receiveOptionsFromEnv())
+}
+
+func TestReceiveOptionsFromEnv_InvalidUsesDefaults(t *testing.T) {
+	t.Setenv(envSQSMaxMessages, "11")
+	t.Setenv(envSQSWaitTime, "-1")
+	t.Setenv(envSQSVisibility, "forever")
+
+	assert.Equal(t, defaultReceiveOptions, receiveOptionsFromEnv())
+}
+
+func TestAWSConfigOptions_EndpointVariants(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          endpointOptions
+		wantFIPS      aws.FIPSEndpointState
+		wantDualStack aws.DualStackEndpointState
+	}{
+		{
+			name:          "defaults",
+			wantFIPS:      aws.FIPSEndpointStateUnset,
+			wantDualStack: aws.DualStackEndpointStateUnset,
+		},
+		{
+			name:          "fips only",
+			opts:          endpointOptions{fips: aws.FIPSEndpointStateEnabled},
+			wantFIPS:      aws.FIPSEndpointStateEnabled,
+			wantDualStack: aws.DualStackEndpointStateUnset,
+		},
+		{
+			name:          "fips and dualstack",
+			opts:          endpointOptions{fips: aws.FIPSEndpointStateEnabled, dualStack: aws.DualStackEndpointStateEnabled},
+			wantFIPS:      aws.FIPSEndpointStateEnabled,
+			wantDualStack: aws.DualStackEndpointStateEnabled,
+		},
+		{
+			name:          "explicitly disabled",
+			opts:          endpointOptions{fips: aws.FIPSEndpointStateDisabled, dualStack: aws.DualStackEndpointStateDisabled},
+			wantFIPS:      aws.FIPSEndpointStateDisabled,
+			wantDualStack: aws.DualStackEndpointStateDisabled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := credentials.NewStaticCredentialsProvider("test", "test", "")
+			cfg, err := config.LoadDefaultConfig(context.Background(), awsConfigOptions("us-west-2", creds, tt.opts)...)
+			require.NoError(t, err)
+
+			assert.Equal(t, "us-west-2", cfg.Region)
+
+			var loadOpts *config.LoadOptions
+			for _, src := range cfg.ConfigSources {
+				if lo, ok := src.(config.LoadOptions); ok {
+					loadOpts = &lo
+				}
+			}
+			require.NotNil(t, loadOpts)
+			assert.Equal(t, tt.wantFIPS, loadOpts.UseFIPSEndpoint)
+			assert.Equal(t, tt.wantDualStack, loadOpts.UseDualStackEndpoint)
+		})
+	}
+}
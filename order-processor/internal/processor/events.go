@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// eventPublishTimeout bounds each event publish, so a slow topic holds up
+// a worker by at most this much
+const eventPublishTimeout = 5 * time.Second
+
+// eventOrderProcessed is the type of the event published for each stored
+// order
+const eventOrderProcessed = "order_processed"
+
+// EventPublisher announces each stored order to other services. It is
+// called after the order handler has stored the order: a failed publish is
+// logged and counted, and the message is still deleted.
+type EventPublisher interface {
+	Publish(ctx context.Context, order Order) error
+}
+
+// NopEventPublisher is the default EventPublisher: it publishes nothing.
+type NopEventPublisher struct{}
+
+func (NopEventPublisher) Publish(context.Context, Order) error { return nil }
+
+// WithEventPublisher publishes an event for each stored order to pub,
+// instead of the SNS topic named by EVENTS_TOPIC_ARN. It returns p.
+func (p *Processor) WithEventPublisher(pub EventPublisher) *Processor {
+	p.events = pub
+	return p
+}
+
+// eventPublisher returns the configured publisher, NopEventPublisher if
+// none.
+func (p *Processor) eventPublisher() EventPublisher {
+	if p.events == nil {
+		return NopEventPublisher{}
+	}
+	return p.events
+}
+
+func newEventsPublishedCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_events_published_total",
+			Help: "Order processed events published by result",
+		},
+		[]string{"result", "env"},
+	)
+}
+
+// publishEvent publishes the order processed event for a stored order.
+func (p *Processor) publishEvent(ctx context.Context, order Order) {
+	if _, ok := p.eventPublisher().(NopEventPublisher); ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, eventPublishTimeout)
+	defer cancel()
+
+	ctx, span := p.startChildSpan(ctx, "publish_event")
+	err := p.eventPublisher().Publish(ctx, order)
+	endSpan(span, err)
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+		log.Warn().
+			Str("order_id", order.OrderID).
+			Err(err).
+			Msg("failed to publish order processed event")
+	}
+	if p.eventsPublished != nil {
+		p.eventsPublished.WithLabelValues(result, p.environment).Inc()
+	}
+}
+
+// orderEvent is the message published for each stored order.
+type orderEvent struct {
+	Type  string `json:"type"`
+	Order Order  `json:"order"`
+}
+
+// snsEventPublisher publishes events to an SNS topic through the SNS query
+// API, signing each request with the processor's AWS credentials.
+type snsEventPublisher struct {
+	client   aws.HTTPClient
+	creds    aws.CredentialsProvider
+	signer   *v4.Signer
+	topicARN string
+	region   string
+	// endpoint is the SNS endpoint URL, regional unless AWS_ENDPOINT_URL
+	// is set
+	endpoint string
+}
+
+// newSNSEventPublisher returns a publisher to the topic topicARN. An empty
+// endpoint means the topic region's SNS endpoint.
+func newSNSEventPublisher(cfg aws.Config, topicARN, endpoint string) (*snsEventPublisher, error) {
+	parsed, err := arn.Parse(topicARN)
+	if err != nil || parsed.Service != "sns" || parsed.Region == "" {
+		return nil, fmt.Errorf("%s %q is not an SNS topic ARN", envEventsTopicARN, topicARN)
+	}
+	if endpoint == "" {
+		endpoint = "https://sns." + parsed.Region + ".amazonaws.com"
+		if strings.HasPrefix(parsed.Partition, "aws-cn") {
+			endpoint += ".cn"
+		}
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &snsEventPublisher{
+		client:   client,
+		creds:    cfg.Credentials,
+		signer:   v4.NewSigner(),
+		topicARN: topicARN,
+		region:   parsed.Region,
+		endpoint: endpoint,
+	}, nil
+}
+
+func (s *snsEventPublisher) Publish(ctx context.Context, order Order) error {
+	message, err := json.Marshal(orderEvent{Type: eventOrderProcessed, Order: order})
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	body := url.Values{
+		"Action":   {"Publish"},
+		"Version":  {"2010-03-31"},
+		"TopicArn": {s.topicARN},
+		"Message":  {string(message)},
+	}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build publish request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds, err := s.creds.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieve credentials: %w", err)
+	}
+	hash := sha256.Sum256([]byte(body))
+	if err := s.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "sns", s.region, time.Now()); err != nil {
+		return fmt.Errorf("sign publish request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	var failure struct {
+		Code    string `xml:"Error>Code"`
+		Message string `xml:"Error>Message"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(raw, &failure) != nil || failure.Code == "" {
+		return fmt.Errorf("publish: unexpected status %s", resp.Status)
+	}
+	return fmt.Errorf("publish: %s: %s", failure.Code, failure.Message)
+}
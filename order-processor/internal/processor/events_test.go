@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testTopicARN = "arn:aws:sns:eu-west-1:123456789012:orders-processed"
+
+// MockEventPublisher is a mock EventPublisher.
+type MockEventPublisher struct {
+	mock.Mock
+}
+
+func (m *MockEventPublisher) Publish(ctx context.Context, order Order) error {
+	args := m.Called(ctx, order)
+	return args.Error(0)
+}
+
+func testAWSConfig() aws.Config {
+	return aws.Config{Credentials: credentials.NewStaticCredentialsProvider("test", "test", "")}
+}
+
+func TestSNSEventPublisher_Publishes(t *testing.T) {
+	var form map[string][]string
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, r.ParseForm())
+		form = r.PostForm
+		_, _ = w.Write([]byte(`<PublishResponse><PublishResult><MessageId>m1</MessageId></PublishResult></PublishResponse>`))
+	}))
+	defer server.Close()
+
+	pub, err := newSNSEventPublisher(testAWSConfig(), testTopicARN, server.URL)
+	require.NoError(t, err)
+	require.NoError(t, pub.Publish(context.Background(), Order{OrderID: "o1", UserID: "u1", Amount: "10"}))
+
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=test/"), auth)
+	assert.Contains(t, auth, "/eu-west-1/sns/aws4_request")
+	assert.Equal(t, []string{"Publish"}, form["Action"])
+	assert.Equal(t, []string{testTopicARN}, form["TopicArn"])
+	var event orderEvent
+	require.NoError(t, json.Unmarshal([]byte(form["Message"][0]), &event))
+	assert.Equal(t, eventOrderProcessed, event.Type)
+	assert.Equal(t, "o1", event.Order.OrderID)
+}
+
+func TestSNSEventPublisher_ReturnsSNSError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>AuthorizationError</Code><Message>not allowed</Message></Error></ErrorResponse>`))
+	}))
+	defer server.Close()
+
+	pub, err := newSNSEventPublisher(testAWSConfig(), testTopicARN, server.URL)
+	require.NoError(t, err)
+
+	err = pub.Publish(context.Background(), Order{OrderID: "o1"})
+	assert.EqualError(t, err, "publish: AuthorizationError: not allowed")
+}
+
+func TestNewSNSEventPublisher(t *testing.T) {
+	pub, err := newSNSEventPublisher(testAWSConfig(), testTopicARN, "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://sns.eu-west-1.amazonaws.com", pub.endpoint)
+
+	pub, err = newSNSEventPublisher(testAWSConfig(), "arn:aws-cn:sns:cn-north-1:123456789012:orders", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://sns.cn-north-1.amazonaws.com.cn", pub.endpoint)
+
+	for _, topic := range []string{"orders-processed", "arn:aws:sqs:eu-west-1:123456789012:orders"} {
+		_, err = newSNSEventPublisher(testAWSConfig(), topic, "")
+		assert.ErrorContains(t, err, "is not an SNS topic ARN")
+	}
+}
+
+func TestPollAndProcess_PublishFailureStillDeletes(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	pub := &MockEventPublisher{}
+	proc := (&Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		eventsPublished: newEventsPublishedCounter(),
+		environment:     "test",
+	}).WithEventPublisher(pub)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(2)}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Twice()
+	pub.On("Publish", mock.Anything, mock.MatchedBy(func(o Order) bool { return o.OrderID == "o0" })).
+		Return(nil).Once()
+	pub.On("Publish", mock.Anything, mock.MatchedBy(func(o Order) bool { return o.OrderID == "o1" })).
+		Return(errors.New("topic unavailable")).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	pub.AssertExpectations(t)
+	assert.Equal(t, 2.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.eventsPublished.WithLabelValues("success", "test")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.eventsPublished.WithLabelValues("failure", "test")))
+}
@@ -0,0 +1,139 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// Exchange rate defaults
+	defaultExchangeRateTTL   = time.Hour
+	exchangeRateFetchTimeout = 5 * time.Second
+
+	// baseCurrency is the currency amount_usd is expressed in, rounded to
+	// baseCurrencyPlaces fractional digits (cents)
+	baseCurrency       = "USD"
+	baseCurrencyPlaces = 2
+)
+
+// RateProvider returns how many USD one unit of a currency is worth. A
+// currency it has no rate for is an error.
+type RateProvider interface {
+	Rate(ctx context.Context, currency string) (float64, error)
+}
+
+// convertAmount records the order's amount in USD. The currency has been
+// checked by checkCurrency; a rate that cannot be looked up fails
+// retryably.
+func (p *Processor) convertAmount(ctx context.Context, order *Order) error {
+	if p.rateProvider == nil {
+		return nil
+	}
+
+	currency := order.Currency
+	if currency == baseCurrency {
+		order.AmountUSD = order.Amount
+		return nil
+	}
+	rate, err := p.rateProvider.Rate(ctx, currency)
+	if err != nil {
+		return fmt.Errorf("exchange rate for %s: %w", currency, err)
+	}
+	amountUSD, err := order.Amount.mulRate(rate, baseCurrencyPlaces)
+	if err != nil {
+		return fmt.Errorf("exchange rate for %s: %w", currency, err)
+	}
+	order.AmountUSD = amountUSD
+	return nil
+}
+
+// cachedRateProvider remembers each currency's rate for ttl, so a batch of
+// orders in one currency costs a single lookup. Failed lookups are not
+// cached.
+type cachedRateProvider struct {
+	provider RateProvider
+	ttl      time.Duration
+	// now defaults to time.Now
+	now func() time.Time
+
+	mu    sync.Mutex
+	rates map[string]cachedRate
+}
+
+type cachedRate struct {
+	rate      float64
+	fetchedAt time.Time
+}
+
+func newCachedRateProvider(provider RateProvider, ttl time.Duration) *cachedRateProvider {
+	return &cachedRateProvider{provider: provider, ttl: ttl, rates: make(map[string]cachedRate)}
+}
+
+func (c *cachedRateProvider) Rate(ctx context.Context, currency string) (float64, error) {
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+
+	c.mu.Lock()
+	cached, ok := c.rates[currency]
+	c.mu.Unlock()
+	if ok && now().Sub(cached.fetchedAt) < c.ttl {
+		return cached.rate, nil
+	}
+
+	rate, err := c.provider.Rate(ctx, currency)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	c.rates[currency] = cachedRate{rate: rate, fetchedAt: now()}
+	c.mu.Unlock()
+	return rate, nil
+}
+
+// httpRateProvider reads rates from a JSON endpoint shaped like
+// {"rates": {"EUR": 1.08, ...}}, each rate being the USD value of one
+// unit of that currency.
+type httpRateProvider struct {
+	client *http.Client
+	url    string
+}
+
+func newHTTPRateProvider(url string) *httpRateProvider {
+	return &httpRateProvider{client: &http.Client{Timeout: exchangeRateFetchTimeout}, url: url}
+}
+
+func (h *httpRateProvider) Rate(ctx context.Context, currency string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("build request: %w", err)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var table struct {
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&table); err != nil {
+		return 0, fmt.Errorf("decode rates: %w", err)
+	}
+	rate, ok := table.Rates[currency]
+	if !ok || rate <= 0 {
+		return 0, fmt.Errorf("no rate for %s", currency)
+	}
+	return rate, nil
+}
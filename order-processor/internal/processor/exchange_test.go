@@ -0,0 +1,165 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRateProvider struct {
+	mock.Mock
+}
+
+func (m *MockRateProvider) Rate(ctx context.Context, currency string) (float64, error) {
+	args := m.Called(ctx, currency)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func newRateProcessor(rates RateProvider) (*Processor, *MockDynamoDBClient) {
+	mockDDB := &MockDynamoDBClient{}
+	return &Processor{
+		ddbClient:       mockDDB,
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		rateProvider:    rates,
+	}, mockDDB
+}
+
+func TestHandleMessage_StoresConvertedAmount(t *testing.T) {
+	rates := &MockRateProvider{}
+	proc, mockDDB := newRateProcessor(rates)
+
+	rates.On("Rate", mock.Anything, "EUR").Return(1.085, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "EUR"}, input.Item["currency"]) &&
+			assert.Equal(t, &dtypes.AttributeValueMemberN{Value: "2000"}, input.Item["amount"]) &&
+			assert.Equal(t, &dtypes.AttributeValueMemberN{Value: "2170"}, input.Item["amount_usd"])
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	err := proc.handleMessage(context.Background(), stypes.Message{
+		Body: aws.String(`{"order_id":"o1","user_id":"u1","amount":2000,"currency":"eur"}`),
+	})
+
+	require.NoError(t, err)
+	rates.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestHandleMessage_USDNeedsNoRate(t *testing.T) {
+	rates := &MockRateProvider{}
+	proc, mockDDB := newRateProcessor(rates)
+
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, &dtypes.AttributeValueMemberN{Value: "500"}, input.Item["amount_usd"])
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	err := proc.handleMessage(context.Background(), stypes.Message{
+		Body: aws.String(`{"order_id":"o1","amount":500,"currency":"USD"}`),
+	})
+
+	require.NoError(t, err)
+	rates.AssertNotCalled(t, "Rate", mock.Anything, mock.Anything)
+}
+
+func TestHandleMessage_InvalidCurrency(t *testing.T) {
+	proc, mockDDB := newRateProcessor(&MockRateProvider{})
+
+	err := proc.handleMessage(context.Background(), stypes.Message{
+		Body: aws.String(`{"order_id":"o1","amount":500,"currency":"EURO"}`),
+	})
+
+	var vErr *ValidationError
+	require.True(t, errors.As(err, &vErr))
+	assert.Equal(t, "invalid_currency", vErr.Type)
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+}
+
+func TestPollAndProcess_MissingRateIsRetried(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	rates := &MockRateProvider{}
+	proc, mockDDB := newRateProcessor(rates)
+	proc.sqsClient = mockSQS
+	proc.queueURL = "test-queue"
+	proc.failuresTable = "Failures"
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{{
+			MessageId:     aws.String("m1"),
+			Body:          aws.String(`{"order_id":"o1","amount":500,"currency":"CHF"}`),
+			ReceiptHandle: aws.String("r1"),
+		}}}, nil).Once()
+	rates.On("Rate", mock.Anything, "CHF").Return(0.0, errors.New("no rate for CHF")).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	// Neither stored, nor recorded as a failure, nor deleted
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue")))
+}
+
+func TestCachedRateProvider_CachesUntilTTL(t *testing.T) {
+	rates := &MockRateProvider{}
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	cached := newCachedRateProvider(rates, time.Hour)
+	cached.now = func() time.Time { return now }
+
+	rates.On("Rate", mock.Anything, "EUR").Return(1.08, nil).Once()
+	rates.On("Rate", mock.Anything, "EUR").Return(1.09, nil).Once()
+
+	for range 3 {
+		rate, err := cached.Rate(context.Background(), "EUR")
+		require.NoError(t, err)
+		assert.Equal(t, 1.08, rate)
+	}
+
+	now = now.Add(time.Hour)
+	rate, err := cached.Rate(context.Background(), "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, 1.09, rate)
+	rates.AssertExpectations(t)
+}
+
+func TestCachedRateProvider_ErrorsNotCached(t *testing.T) {
+	rates := &MockRateProvider{}
+	cached := newCachedRateProvider(rates, time.Hour)
+
+	rates.On("Rate", mock.Anything, "EUR").Return(0.0, errors.New("unavailable")).Once()
+	rates.On("Rate", mock.Anything, "EUR").Return(1.08, nil).Once()
+
+	_, err := cached.Rate(context.Background(), "EUR")
+	assert.Error(t, err)
+	rate, err := cached.Rate(context.Background(), "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, 1.08, rate)
+}
+
+func TestHTTPRateProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"rates":{"EUR":1.08,"GBP":1.27}}`))
+	}))
+	defer srv.Close()
+	provider := newHTTPRateProvider(srv.URL)
+
+	rate, err := provider.Rate(context.Background(), "GBP")
+	require.NoError(t, err)
+	assert.Equal(t, 1.27, rate)
+
+	_, err = provider.Rate(context.Background(), "JPY")
+	assert.ErrorContains(t, err, "no rate for JPY")
+}
@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// FailureRecord is the item written to the failures table for triage. The
+// table's hash key is message_id (string); see infra/dynamodb.tf.
+type FailureRecord struct {
+	MessageID string `dynamodbav:"message_id"`
+	OrderID   string `dynamodbav:"order_id,omitempty"`
+	RawBody   string `dynamodbav:"raw_body,omitempty"`
+	Error     string `dynamodbav:"error"`
+	ErrorType string `dynamodbav:"error_type"`
+	FailedAt  string `dynamodbav:"failed_at"`
+}
+
+// recordFailure writes a failure record for a rejected message. It is a
+// no-op when no failures table is configured. Once the record is written
+// pollAndProcess deletes the message, so each failure is recorded once.
+func (p *Processor) recordFailure(ctx context.Context, msg types.Message, vErr *ValidationError) error {
+	if p.failuresTable == "" {
+		return nil
+	}
+
+	record := FailureRecord{
+		MessageID: "unknown",
+		OrderID:   vErr.OrderID,
+		Error:     vErr.Error(),
+		ErrorType: vErr.Type,
+		FailedAt:  p.now().UTC().Format(time.RFC3339),
+	}
+	if msg.MessageId != nil {
+		record.MessageID = *msg.MessageId
+	}
+	// Keep the raw body only when the order ID is unknown; otherwise the
+	// order ID is enough to find the original payload.
+	if record.OrderID == "" && msg.Body != nil {
+		record.RawBody = *msg.Body
+	}
+
+	item, err := attributevalue.MarshalMap(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal failure record: %w", err)
+	}
+
+	_, err = p.ddbClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: &p.failuresTable,
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put failure record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPollAndProcess_ValidationErrorWritesFailureRecord(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		failuresTable:   "Failures",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	msg := stypes.Message{
+		MessageId:     aws.String("msg-123"),
+		Body:          aws.String(`{"user_id":"u1","amount":100}`),
+		ReceiptHandle: aws.String("r1"),
+	}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil)
+
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		if *input.TableName != "Failures" {
+			return false
+		}
+		assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "msg-123"}, input.Item["message_id"])
+		assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "missing_order_id"}, input.Item["error_type"])
+		assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "order_id is required"}, input.Item["error"])
+		assert.Equal(t, &dtypes.AttributeValueMemberS{Value: `{"user_id":"u1","amount":100}`}, input.Item["raw_body"])
+		assert.Contains(t, input.Item, "failed_at")
+		return true
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	// Recorded failures are removed from the queue
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+
+	errorCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue"))
+	assert.Equal(t, 1.0, errorCount)
+}
+
+func TestPollAndProcess_RetryableErrorSkipsFailureRecord(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		failuresTable:   "Failures",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	msg := stypes.Message{
+		MessageId:     aws.String("msg-123"),
+		Body:          aws.String(`{"order_id":"o1","user_id":"u1","amount":100}`),
+		ReceiptHandle: aws.String("r1"),
+	}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil)
+
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return *input.TableName == "Orders"
+	})).Return((*dynamodb.PutItemOutput)(nil), errors.New("DynamoDB error")).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestRecordFailure_NoTableConfigured(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{ddbClient: mockDDB}
+
+	err := proc.recordFailure(context.Background(), stypes.Message{}, &ValidationError{
+		Type: "nil_body",
+		Err:  errors.New("message body is nil"),
+	})
+
+	assert.NoError(t, err)
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+}
+
+func TestPollAndProcess_FailureRecordErrorKeepsMessage(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		failuresTable:   "Failures",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	msg := stypes.Message{
+		MessageId:     aws.String("msg-123"),
+		Body:          aws.String(`invalid json`),
+		ReceiptHandle: aws.String("r1"),
+	}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("DynamoDB error")).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+
+	assert.NoError(t, err)
+	mockDDB.AssertExpectations(t)
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// isFIFOQueue reports whether url names a FIFO queue, which SQS requires
+// to end in .fifo.
+func isFIFOQueue(url string) bool {
+	return strings.HasSuffix(url, ".fifo")
+}
+
+// messageGroup returns msg's MessageGroupId, or "" when SQS did not report
+// one.
+func messageGroup(msg types.Message) string {
+	return msg.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]
+}
+
+// batchJobs splits a batch into the units handed to workers. Off FIFO
+// each message is its own job. On a FIFO queue a job is every message of
+// one MessageGroupId, in the order received, so a group is processed
+// sequentially while different groups run in parallel. A message without
+// a group is a job of its own.
+func batchJobs(messages []types.Message, fifo bool) [][]types.Message {
+	jobs := make([][]types.Message, 0, len(messages))
+	if !fifo {
+		for _, msg := range messages {
+			jobs = append(jobs, []types.Message{msg})
+		}
+		return jobs
+	}
+
+	index := make(map[string]int)
+	for _, msg := range messages {
+		group := messageGroup(msg)
+		if group == "" {
+			jobs = append(jobs, []types.Message{msg})
+			continue
+		}
+		if i, ok := index[group]; ok {
+			jobs[i] = append(jobs[i], msg)
+			continue
+		}
+		index[group] = len(jobs)
+		jobs = append(jobs, []types.Message{msg})
+	}
+	return jobs
+}
+
+// sourceDedupID returns the MessageDeduplicationId msg was sent with on a
+// FIFO queue, or its message ID when there was none.
+func sourceDedupID(msg types.Message) *string {
+	if id := msg.Attributes[string(types.MessageSystemAttributeNameMessageDeduplicationId)]; id != "" {
+		return aws.String(id)
+	}
+	return msg.MessageId
+}
@@ -0,0 +1,195 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// groupedBatch is orderBatch with message i in groups[i].
+func groupedBatch(groups ...string) []stypes.Message {
+	msgs := orderBatch(len(groups))
+	for i, group := range groups {
+		msgs[i].Attributes = map[string]string{
+			string(stypes.MessageSystemAttributeNameMessageGroupId):         group,
+			string(stypes.MessageSystemAttributeNameMessageDeduplicationId): fmt.Sprintf("dedup-%d", i),
+		}
+	}
+	return msgs
+}
+
+func newFIFOProcessor(mockSQS *MockSQSClient, mockDDB *MockDynamoDBClient) *Processor {
+	return &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "orders.fifo",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		fifo:            true,
+	}
+}
+
+func putOf(id string) interface{} {
+	return mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return input.Item["order_id"].(*dtypes.AttributeValueMemberS).Value == id
+	})
+}
+
+func TestBatchJobs(t *testing.T) {
+	msgs := groupedBatch("a", "b", "a", "", "b")
+
+	ids := func(jobs [][]stypes.Message) [][]string {
+		out := make([][]string, len(jobs))
+		for i, job := range jobs {
+			for _, msg := range job {
+				out[i] = append(out[i], aws.ToString(msg.MessageId))
+			}
+		}
+		return out
+	}
+
+	assert.Equal(t, [][]string{{"msg-0"}, {"msg-1"}, {"msg-2"}, {"msg-3"}, {"msg-4"}}, ids(batchJobs(msgs, false)))
+	assert.Equal(t, [][]string{{"msg-0", "msg-2"}, {"msg-1", "msg-4"}, {"msg-3"}}, ids(batchJobs(msgs, true)))
+}
+
+func TestPollAndProcess_FIFOGroupsSequentialAcrossGroupsParallel(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newFIFOProcessor(mockSQS, mockDDB)
+	require.NoError(t, proc.SetConcurrency(4))
+
+	var (
+		mu      sync.Mutex
+		written []string
+	)
+	record := func(args mock.Arguments) {
+		mu.Lock()
+		defer mu.Unlock()
+		written = append(written, args.Get(1).(*dynamodb.PutItemInput).Item["order_id"].(*dtypes.AttributeValueMemberS).Value)
+	}
+
+	// o0 is held until o3, from the other group, is written: the groups
+	// run in parallel, and o1 cannot overtake o0 in its own group
+	o3Written := make(chan struct{})
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: groupedBatch("a", "a", "a", "b", "b")}, nil)
+	mockDDB.On("PutItem", mock.Anything, putOf("o0")).
+		Run(func(args mock.Arguments) {
+			select {
+			case <-o3Written:
+			case <-time.After(5 * time.Second):
+				t.Error("groups did not run in parallel")
+			}
+			record(args)
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, putOf("o3")).
+		Run(func(args mock.Arguments) {
+			record(args)
+			close(o3Written)
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(record).
+		Return(&dynamodb.PutItemOutput{}, nil).Times(3)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "r2", "r3", "r4")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	var groupA []string
+	for _, id := range written {
+		if id == "o0" || id == "o1" || id == "o2" {
+			groupA = append(groupA, id)
+		}
+	}
+	assert.Equal(t, []string{"o0", "o1", "o2"}, groupA)
+}
+
+func TestPollAndProcess_FIFOFailureHoldsBackRestOfGroup(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newFIFOProcessor(mockSQS, mockDDB)
+	require.NoError(t, proc.SetConcurrency(2))
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: groupedBatch("a", "a", "a", "b")}, nil)
+	mockDDB.On("PutItem", mock.Anything, putOf("o0")).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, putOf("o1")).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("throttled")).Once()
+	mockDDB.On("PutItem", mock.Anything, putOf("o3")).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r3")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	// o2 follows the failed o1, so it is left for redelivery unprocessed
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, putOf("o2"))
+}
+
+func TestSendToDLQ_FIFOKeepsGroupAndDedupID(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := newFIFOProcessor(mockSQS, &MockDynamoDBClient{})
+	proc.dlqURL = "orders-dlq.fifo"
+
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		return aws.ToString(input.QueueUrl) == "orders-dlq.fifo" &&
+			aws.ToString(input.MessageGroupId) == "customer-7" &&
+			aws.ToString(input.MessageDeduplicationId) == "dedup-0"
+	})).Return(&sqs.SendMessageOutput{}, nil).Once()
+
+	require.NoError(t, proc.sendToDLQ(context.Background(), groupedBatch("customer-7")[0], "invalid_amount"))
+
+	mockSQS.AssertExpectations(t)
+}
+
+func TestNewProcessor_DetectsFIFO(t *testing.T) {
+	tests := []struct {
+		name     string
+		queueURL string
+		env      string
+		want     bool
+	}{
+		{name: "standard queue", queueURL: "https://sqs.example.com/1/orders", want: false},
+		{name: "fifo suffix", queueURL: "https://sqs.example.com/1/orders.fifo", want: true},
+		{name: "explicit flag", queueURL: "https://sqs.example.com/1/orders", env: "true", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{envReportBucket, envOTLPEndpoint} {
+				t.Setenv(name, "")
+			}
+			t.Setenv(envSQSFIFO, tt.env)
+
+			proc, err := NewProcessor(context.Background(),
+				WithQueueURL(tt.queueURL),
+				WithTableName("Orders"),
+				WithSQSClient(&MockSQSClient{}),
+				WithDDBClient(&MockDynamoDBClient{}),
+			)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, proc.fifo)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrDuplicateOrder is returned by an OrderHandler for an order it has
+// already handled. The message is deleted and counted as a duplicate
+// rather than a failure.
+var ErrDuplicateOrder = errors.New("order already handled")
+
+// OrderHandler delivers a parsed, validated and enriched order to where it
+// is kept. Returning a *ValidationError fails the message terminally; any
+// other error leaves it on the queue to be retried. Metrics, the DLQ and
+// message deletion are handled by the Processor whichever handler is used.
+type OrderHandler interface {
+	Handle(ctx context.Context, order Order) error
+}
+
+// DynamoOrderHandler is the default OrderHandler: it stores each order in
+// the processor's DynamoDB table without overwriting one already there.
+type DynamoOrderHandler struct {
+	p *Processor
+}
+
+func (h DynamoOrderHandler) Handle(ctx context.Context, order Order) error {
+	return h.p.storeOrder(ctx, order)
+}
+
+// WithOrderHandler replaces the DynamoDB handler, reusing the polling,
+// retry and metrics machinery for another sink. Batch writes, key
+// strategies and the audit trail only apply to the DynamoDB handler. It
+// returns p.
+func (p *Processor) WithOrderHandler(h OrderHandler) *Processor {
+	p.handler = h
+	return p
+}
+
+// orderHandler returns the configured handler, DynamoOrderHandler if none.
+func (p *Processor) orderHandler() OrderHandler {
+	if p.handler == nil {
+		return DynamoOrderHandler{p: p}
+	}
+	return p.handler
+}
+
+// storesToDynamo reports whether orders go to the DynamoDB table.
+func (p *Processor) storesToDynamo() bool {
+	_, ok := p.orderHandler().(DynamoOrderHandler)
+	return ok
+}
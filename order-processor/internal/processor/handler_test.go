@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler is an OrderHandler collecting the orders it is given
+// and failing those listed in errs.
+type recordingHandler struct {
+	mu     sync.Mutex
+	orders []string
+	errs   map[string]error
+}
+
+func (h *recordingHandler) Handle(_ context.Context, order Order) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.orders = append(h.orders, order.OrderID)
+	return h.errs[order.OrderID]
+}
+
+func TestPollAndProcess_CustomOrderHandler(t *testing.T) {
+	for _, batchWrite := range []bool{false, true} {
+		t.Run(map[bool]string{false: "per message", true: "batch write"}[batchWrite], func(t *testing.T) {
+			mockSQS := &MockSQSClient{}
+			// No DynamoDB calls are expected
+			mockDDB := &MockDynamoDBClient{}
+			handler := &recordingHandler{errs: map[string]error{
+				"o1": ErrDuplicateOrder,
+				"o2": errors.New("REST API unavailable"),
+			}}
+			proc := (&Processor{
+				sqsClient:       mockSQS,
+				ddbClient:       mockDDB,
+				queueURL:        "test-queue",
+				tableName:       "Orders",
+				ordersProcessed: NewCounterVec(),
+				environment:     "test",
+				batchWrite:      batchWrite,
+				audit:           &auditTrail{processorID: "worker-1"},
+			}).WithOrderHandler(handler)
+
+			mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+				Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(3)}, nil).Once()
+			// o2 stays on the queue for a retry
+			mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1")).
+				Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+			_, err := proc.pollAndProcess(context.Background())
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, []string{"o0", "o1", "o2"}, handler.orders)
+			mockSQS.AssertExpectations(t)
+			mockDDB.AssertExpectations(t)
+			// Metrics are counted whichever handler is used
+			assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+			assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("duplicate", "test", "test-queue")))
+			assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue")))
+		})
+	}
+}
+
+func TestOrderHandler_DefaultsToDynamoDB(t *testing.T) {
+	proc := &Processor{}
+
+	assert.Equal(t, DynamoOrderHandler{p: proc}, proc.orderHandler())
+	assert.True(t, proc.storesToDynamo())
+	assert.False(t, proc.WithOrderHandler(&recordingHandler{}).storesToDynamo())
+}
@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultHandleTimeout bounds the handling of one message when
+// HANDLE_TIMEOUT is unset.
+const defaultHandleTimeout = 30 * time.Second
+
+// errHandleTimeout is the cause of a message context ended by
+// HANDLE_TIMEOUT.
+var errHandleTimeout = errors.New("message handling timed out")
+
+// withHandleTimeout bounds the handling of one message by p.handleTimeout,
+// so a hung call fails the message rather than stalling its batch. Work
+// only stops early if it honors ctx.
+func (p *Processor) withHandleTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.handleTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, p.handleTimeout, errHandleTimeout)
+}
+
+// handleTimeoutError marks err as a handling timeout when ctx, from
+// withHandleTimeout, ended for that reason. It stays retryable, so the
+// message is redelivered.
+func (p *Processor) handleTimeoutError(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(context.Cause(ctx), errHandleTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w after %s: %w", errHandleTimeout, p.handleTimeout, err)
+}
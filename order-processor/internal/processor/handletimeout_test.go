@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// blockPutItem makes PutItem hang until its context ends.
+func blockPutItem(mockDDB *MockDynamoDBClient) {
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return((*dynamodb.PutItemOutput)(nil), context.DeadlineExceeded)
+}
+
+func TestRunHandler_TimesOut(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders", handleTimeout: 10 * time.Millisecond}
+	blockPutItem(mockDDB)
+
+	err := proc.runHandler(context.Background(), orderBatch(1)[0])
+
+	assert.ErrorIs(t, err, errHandleTimeout)
+	assert.ErrorContains(t, err, "after 10ms")
+}
+
+func TestPollAndProcess_HandleTimeoutIsRetryable(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		failuresByClass: newFailuresCounter(),
+		environment:     "test",
+		handleTimeout:   10 * time.Millisecond,
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(1)}, nil).Once()
+	blockPutItem(mockDDB)
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	// The message is left on the queue to be redelivered
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+	mockSQS.AssertNotCalled(t, "DeleteMessage", mock.Anything, mock.Anything)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.failuresByClass.WithLabelValues(failureRetryable, "test")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue")))
+}
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newInFlightGauge() prometheus.Gauge {
+	return prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "orders_in_flight",
+		Help: "Messages currently being processed",
+	})
+}
+
+// startInFlight counts n messages as in flight, both for the shutdown
+// drain and the orders_in_flight gauge, until the returned func is called.
+func (p *Processor) startInFlight(n int) (done func()) {
+	p.inFlight.Add(1)
+	if p.inFlightGauge != nil {
+		p.inFlightGauge.Add(float64(n))
+	}
+	return func() {
+		if p.inFlightGauge != nil {
+			p.inFlightGauge.Sub(float64(n))
+		}
+		p.inFlight.Done()
+	}
+}
+
+// inFlightMessages tracks the messages being processed by any poller, so
+// a message received again while still in flight, e.g. because its
+// visibility timeout ran out mid-batch or overlapping receives both
+// returned it, is skipped instead of written twice. Entries are keyed by
+// queue URL and message ID. The zero value is ready to use.
+type inFlightMessages struct {
+	mu  sync.Mutex
+	ids map[string]struct{}
+}
+
+func inFlightKey(queueURL string, msg types.Message) string {
+	return queueURL + "\x00" + aws.ToString(msg.MessageId)
+}
+
+// claim splits msgs into those now claimed by the caller and those
+// already in flight elsewhere. Messages without an ID are always claimed.
+func (m *inFlightMessages) claim(queueURL string, msgs []types.Message) (claimed, inFlight []types.Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ids == nil {
+		m.ids = make(map[string]struct{})
+	}
+	for _, msg := range msgs {
+		if msg.MessageId == nil {
+			claimed = append(claimed, msg)
+			continue
+		}
+		key := inFlightKey(queueURL, msg)
+		if _, ok := m.ids[key]; ok {
+			inFlight = append(inFlight, msg)
+			continue
+		}
+		m.ids[key] = struct{}{}
+		claimed = append(claimed, msg)
+	}
+	return claimed, inFlight
+}
+
+// release gives up the claims on msgs once they are done with.
+func (m *inFlightMessages) release(queueURL string, msgs []types.Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range msgs {
+		delete(m.ids, inFlightKey(queueURL, msg))
+	}
+}
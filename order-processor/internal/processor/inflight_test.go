@@ -0,0 +1,172 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollAndProcess_InFlightGauge(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		inFlightGauge:   newInFlightGauge(),
+	}
+
+	var during float64
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(1)}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { during = testutil.ToFloat64(proc.inFlightGauge) }).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, during, "counted while the handler runs")
+	assert.Equal(t, 0.0, testutil.ToFloat64(proc.inFlightGauge), "released when it returns")
+}
+
+func TestPollAndProcess_InFlightGaugeConcurrentWorkers(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		inFlightGauge:   newInFlightGauge(),
+	}
+	require.NoError(t, proc.SetConcurrency(3))
+
+	// Every write waits until all three handlers are in flight at once
+	arrived := make(chan struct{}, 3)
+	release := make(chan struct{})
+	go func() {
+		for range 3 {
+			<-arrived
+		}
+		assert.Equal(t, 3.0, testutil.ToFloat64(proc.inFlightGauge))
+		close(release)
+	}()
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(3)}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			arrived <- struct{}{}
+			<-release
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Times(3)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(proc.inFlightGauge))
+}
+
+func TestInFlightMessages_ClaimAndRelease(t *testing.T) {
+	var m inFlightMessages
+	msgs := orderBatch(2)
+
+	claimed, inFlight := m.claim("q1", msgs)
+	assert.Len(t, claimed, 2)
+	assert.Empty(t, inFlight)
+
+	// The same IDs are in flight on q1 but not on another queue
+	claimed, inFlight = m.claim("q1", msgs[:1])
+	assert.Empty(t, claimed)
+	assert.Len(t, inFlight, 1)
+	claimed, _ = m.claim("q2", msgs[:1])
+	assert.Len(t, claimed, 1)
+
+	m.release("q1", msgs)
+	claimed, _ = m.claim("q1", msgs)
+	assert.Len(t, claimed, 2)
+}
+
+func TestPollAndProcess_ConcurrentPollersProcessMessageOnce(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		pollersPerQueue: 2,
+	}
+	pollers := proc.pollers()
+	assert.Len(t, pollers, 2)
+
+	// Both receives return msg-1, under different receipt handles. The
+	// write is held until the second poller has seen it in flight
+	first := stypes.Message{
+		MessageId:     aws.String("msg-1"),
+		Body:          aws.String(`{"order_id":"o1","user_id":"u1","amount":100}`),
+		ReceiptHandle: aws.String("r1"),
+	}
+	second := first
+	second.ReceiptHandle = aws.String("r1-again")
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{first}}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{second}}, nil).Once()
+
+	writing := make(chan struct{})
+	secondDone := make(chan struct{})
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			close(writing)
+			select {
+			case <-secondDone:
+			case <-time.After(5 * time.Second):
+				t.Error("second poller did not finish")
+			}
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := proc.pollAndProcess(withQueue(context.Background(), pollers[0]))
+		assert.NoError(t, err)
+	}()
+	<-writing
+	_, err := proc.pollAndProcess(withQueue(context.Background(), pollers[1]))
+	assert.NoError(t, err)
+	close(secondDone)
+	wg.Wait()
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, deleteBatchOf("r1-again"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("in_flight_duplicate", "test", "test-queue")))
+}
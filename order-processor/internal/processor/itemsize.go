@@ -0,0 +1,190 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Oversize modes selectable with OVERSIZE_MODE
+	oversizeReject    = "reject"
+	oversizeS3Offload = "s3offload"
+
+	// maxItemBytes is DynamoDB's item size limit
+	maxItemBytes = 400 * 1024
+)
+
+// ObjectPutter uploads offloaded orders; *s3.Client implements it.
+type ObjectPutter interface {
+	PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// itemSizePolicy handles orders whose item outgrows the table once
+// enriched. The message body itself is bounded by SQS, so only enrichment
+// (attachment metadata, the converted amount) can push an order over.
+type itemSizePolicy struct {
+	maxBytes int
+	// mode is oversizeReject or oversizeS3Offload; offloading puts the
+	// whole enriched order under prefix in bucket and stores the order
+	// without its enrichment, pointing at the offloaded copy
+	mode   string
+	putter ObjectPutter
+	bucket string
+	prefix string
+}
+
+// offloadedOrder is the S3 copy of an offloaded order: the order as
+// received plus the enrichment left out of its item.
+type offloadedOrder struct {
+	Order
+	AmountUSD  Decimal             `json:"amount_usd,omitempty"`
+	Attachment *AttachmentMetadata `json:"attachment,omitempty"`
+}
+
+// checkItemSize applies the item size policy to an enriched order about
+// to be stored in DynamoDB. An order over the limit is rejected as
+// item_too_large, or with s3offload is stripped of its enrichment after
+// that has been uploaded, and rejected only if it is still too large.
+func (p *Processor) checkItemSize(ctx context.Context, order *Order) error {
+	if p.itemSize == nil || !p.storesToDynamo() {
+		return nil
+	}
+	size, err := p.orderItemSize(*order)
+	if err != nil || size <= p.itemSize.maxBytes {
+		return err
+	}
+
+	if p.itemSize.mode == oversizeS3Offload {
+		uri, err := p.offloadOrder(ctx, *order)
+		if err != nil {
+			return err
+		}
+		order.AmountUSD = ""
+		order.Attachment = nil
+		order.OffloadedTo = uri
+		if size, err = p.orderItemSize(*order); err != nil {
+			return err
+		}
+		if size <= p.itemSize.maxBytes {
+			log.Warn().
+				Str("order_id", order.OrderID).
+				Str("offloaded_to", uri).
+				Msg("enriched order exceeds item size limit - enrichment offloaded to S3")
+			return nil
+		}
+	}
+	return &ValidationError{
+		Type:    "item_too_large",
+		OrderID: order.OrderID,
+		Err:     fmt.Errorf("order item is %d bytes after enrichment, limit is %d", size, p.itemSize.maxBytes),
+	}
+}
+
+// orderItemSize returns the DynamoDB size of the item stored for order.
+func (p *Processor) orderItemSize(order Order) (int, error) {
+	item, err := p.orderItem(order)
+	if err != nil {
+		return 0, err
+	}
+	return itemSize(item), nil
+}
+
+// offloadOrder uploads order with its enrichment as JSON, returning the
+// object's s3:// URI. The key is derived from the order ID, so a
+// redelivered order overwrites its own copy.
+func (p *Processor) offloadOrder(ctx context.Context, order Order) (string, error) {
+	body, err := json.Marshal(offloadedOrder{
+		Order:      order,
+		AmountUSD:  order.AmountUSD,
+		Attachment: order.Attachment,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal offloaded order: %w", err)
+	}
+
+	key := path.Join(p.itemSize.prefix, url.PathEscape(order.OrderID)+".json")
+	_, err = p.itemSize.putter.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(p.itemSize.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("offload order to S3: %w", err)
+	}
+	return "s3://" + p.itemSize.bucket + "/" + key, nil
+}
+
+// itemSize computes an item's size the way DynamoDB counts it against the
+// item size limit: each attribute name plus its value.
+func itemSize(item map[string]types.AttributeValue) int {
+	size := 0
+	for name, value := range item {
+		size += len(name) + attributeSize(value)
+	}
+	return size
+}
+
+// attributeSize is the size of one attribute value. Numbers take about one
+// byte per two significant digits plus one; lists and maps take 3 bytes
+// plus one per element on top of their contents.
+func attributeSize(value types.AttributeValue) int {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value)
+	case *types.AttributeValueMemberN:
+		return numberSize(v.Value)
+	case *types.AttributeValueMemberB:
+		return len(v.Value)
+	case *types.AttributeValueMemberBOOL, *types.AttributeValueMemberNULL:
+		return 1
+	case *types.AttributeValueMemberSS:
+		size := 0
+		for _, s := range v.Value {
+			size += len(s)
+		}
+		return size
+	case *types.AttributeValueMemberNS:
+		size := 0
+		for _, n := range v.Value {
+			size += numberSize(n)
+		}
+		return size
+	case *types.AttributeValueMemberBS:
+		size := 0
+		for _, b := range v.Value {
+			size += len(b)
+		}
+		return size
+	case *types.AttributeValueMemberL:
+		size := 3
+		for _, elem := range v.Value {
+			size += 1 + attributeSize(elem)
+		}
+		return size
+	case *types.AttributeValueMemberM:
+		return 3 + len(v.Value) + itemSize(v.Value)
+	default:
+		return 0
+	}
+}
+
+// numberSize is the size of a DynamoDB Number with n's significant digits.
+func numberSize(n string) int {
+	digits := 0
+	for _, c := range n {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	return (digits+1)/2 + 1
+}
@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// oversizeProcessor fetches attachments whose metadata carries a long
+// presigned URL, which pushes the order item over a 1 KB limit.
+func oversizeProcessor(t *testing.T, policy itemSizePolicy) (*Processor, *MockDynamoDBClient) {
+	fetcher := &MockAttachmentFetcher{}
+	proc, mockDDB := newAttachmentProcessor(fetcher)
+	policy.maxBytes = 1024
+	proc.itemSize = &policy
+
+	fetcher.On("Fetch", mock.Anything, "https://files.example.com/r1.pdf").Return(AttachmentMetadata{
+		URL:         "https://files.example.com/r1.pdf?X-Amz-Signature=" + strings.Repeat("a", 2048),
+		ContentType: "application/pdf",
+		SizeBytes:   512,
+		SHA256:      "abc",
+		FetchedAt:   "2024-01-01T00:00:00Z",
+	}, nil)
+
+	// The order as received fits
+	size, err := proc.orderItemSize(Order{OrderID: "o1", UserID: "u1", Amount: "100", AttachmentURL: "https://files.example.com/r1.pdf"})
+	require.NoError(t, err)
+	require.Less(t, size, policy.maxBytes)
+	return proc, mockDDB
+}
+
+func TestHandleMessage_OversizedAfterEnrichmentRejected(t *testing.T) {
+	proc, mockDDB := oversizeProcessor(t, itemSizePolicy{mode: oversizeReject})
+
+	err := proc.handleMessage(context.Background(), attachmentMessage("https://files.example.com/r1.pdf"))
+
+	var vErr *ValidationError
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "item_too_large", vErr.Type)
+	assert.Equal(t, "o1", vErr.OrderID)
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+}
+
+func TestHandleMessage_OversizedAfterEnrichmentOffloaded(t *testing.T) {
+	putter := &MockReportPutter{}
+	proc, mockDDB := oversizeProcessor(t, itemSizePolicy{
+		mode:   oversizeS3Offload,
+		putter: putter,
+		bucket: "oversize",
+		prefix: "orders",
+	})
+
+	putter.On("PutObject", mock.Anything, mock.MatchedBy(func(in *s3.PutObjectInput) bool {
+		return aws.ToString(in.Bucket) == "oversize" && aws.ToString(in.Key) == "orders/o1.json"
+	})).Run(func(args mock.Arguments) {
+		body, err := io.ReadAll(args.Get(1).(*s3.PutObjectInput).Body)
+		require.NoError(t, err)
+		var offloaded offloadedOrder
+		require.NoError(t, json.Unmarshal(body, &offloaded))
+		assert.Equal(t, "o1", offloaded.OrderID)
+		require.NotNil(t, offloaded.Attachment)
+		assert.Equal(t, int64(512), offloaded.Attachment.SizeBytes)
+	}).Return(&s3.PutObjectOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		_, hasAttachment := input.Item["attachment"]
+		return !hasAttachment &&
+			assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "s3://oversize/orders/o1.json"}, input.Item["offloaded_to"]) &&
+			assert.LessOrEqual(t, itemSize(input.Item), 1024)
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	err := proc.handleMessage(context.Background(), attachmentMessage("https://files.example.com/r1.pdf"))
+
+	assert.NoError(t, err)
+	putter.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestHandleMessage_OffloadFailureIsRetryable(t *testing.T) {
+	putter := &MockReportPutter{}
+	proc, mockDDB := oversizeProcessor(t, itemSizePolicy{mode: oversizeS3Offload, putter: putter, bucket: "oversize"})
+	putter.On("PutObject", mock.Anything, mock.Anything).
+		Return((*s3.PutObjectOutput)(nil), errors.New("SlowDown")).Once()
+
+	err := proc.handleMessage(context.Background(), attachmentMessage("https://files.example.com/r1.pdf"))
+
+	assert.Error(t, err)
+	assert.Equal(t, failureRetryable, failureClass(err))
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+}
+
+func TestItemSize(t *testing.T) {
+	assert.Equal(t, 0, itemSize(nil))
+	assert.Equal(t, len("order_id")+len("o1"), itemSize(map[string]dtypes.AttributeValue{
+		"order_id": &dtypes.AttributeValueMemberS{Value: "o1"},
+	}))
+	// 5 significant digits take 3 bytes plus 1
+	assert.Equal(t, len("amount")+4, itemSize(map[string]dtypes.AttributeValue{
+		"amount": &dtypes.AttributeValueMemberN{Value: "123.45"},
+	}))
+	// A map is 3 bytes plus 1 per element plus its contents
+	assert.Equal(t, len("m")+3+1+len("k")+len("v"), itemSize(map[string]dtypes.AttributeValue{
+		"m": &dtypes.AttributeValueMemberM{Value: map[string]dtypes.AttributeValue{
+			"k": &dtypes.AttributeValueMemberS{Value: "v"},
+		}},
+	}))
+}
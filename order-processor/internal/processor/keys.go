@@ -0,0 +1,228 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// Key strategies selectable with KEY_STRATEGY
+	keyStrategyPlain   = "plain"
+	keyStrategyHashed  = "hashed"
+	keyStrategySharded = "sharded"
+	keyStrategyDedupID = "dedup_id"
+
+	// Key defaults
+	defaultPartitionKeyAttr = "order_id"
+	defaultKeyShards        = 16
+	maxKeyShards            = 1000
+	defaultShardPrefix      = "shard-"
+)
+
+// TableKey is the primary key an order is stored under.
+type TableKey struct {
+	Partition string
+	// Sort is empty for tables without a sort key
+	Sort string
+}
+
+// KeyStrategy derives the table key an order is stored under, for tables
+// keyed by something other than the plain order ID.
+type KeyStrategy interface {
+	Key(order Order) TableKey
+}
+
+// plainKeys keys orders by order ID.
+type plainKeys struct{}
+
+func (plainKeys) Key(order Order) TableKey {
+	return TableKey{Partition: order.OrderID}
+}
+
+// hashedKeys keys orders by the hex SHA-256 of the order ID, so sequential
+// IDs spread evenly over partitions.
+type hashedKeys struct{}
+
+func (hashedKeys) Key(order Order) TableKey {
+	sum := sha256.Sum256([]byte(order.OrderID))
+	return TableKey{Partition: hex.EncodeToString(sum[:])}
+}
+
+// shardedKeys spreads orders over a fixed number of partitions named
+// prefix plus shard number, picked by hashing the order ID, with the order
+// ID as sort key.
+type shardedKeys struct {
+	prefix string
+	shards int
+}
+
+func (s shardedKeys) Key(order Order) TableKey {
+	h := fnv.New32a()
+	h.Write([]byte(order.OrderID))
+	return TableKey{
+		Partition: fmt.Sprintf("%s%d", s.prefix, h.Sum32()%uint32(s.shards)),
+		Sort:      order.OrderID,
+	}
+}
+
+// dedupIDKeys keys orders by their derived dedup ID, so the conditional
+// write rejects an equivalent order stored under another order ID.
+type dedupIDKeys struct{}
+
+func (dedupIDKeys) Key(order Order) TableKey {
+	return TableKey{Partition: order.DedupID}
+}
+
+// templatedKeys fills the partition and sort key in from order fields with
+// templates such as "USER#{user_id}" and "ORDER#{order_id}", for tables
+// with composite keys. An empty partition template keys by order ID; an
+// empty sort template sets no sort key.
+type templatedKeys struct {
+	partition string
+	sort      string
+}
+
+func (t templatedKeys) Key(order Order) TableKey {
+	key := TableKey{Partition: order.OrderID}
+	if t.partition != "" {
+		key.Partition, _ = fillOrderTemplate(t.partition, order)
+	}
+	if t.sort != "" {
+		key.Sort, _ = fillOrderTemplate(t.sort, order)
+	}
+	return key
+}
+
+// keyLayout is how orders are keyed: the strategy and the attributes its
+// partition and sort keys are stored in.
+type keyLayout struct {
+	// strategy nil keys orders by order ID
+	strategy KeyStrategy
+	// partitionAttr empty means order_id; sortAttr empty means the table
+	// has no sort key
+	partitionAttr string
+	sortAttr      string
+}
+
+// keyLayoutFromEnv reads KEY_STRATEGY, the key templates and the key
+// attribute names. Hashed, sharded, dedup ID and templated partition keys
+// must not be stored in order_id, which keeps the order ID; sharded keys
+// and a sort key template need a sort key attribute, and dedup ID keys a
+// DEDUP_ID_TEMPLATE.
+func keyLayoutFromEnv() (keyLayout, error) {
+	layout := keyLayout{
+		partitionAttr: envString(envPartitionKeyAttr, defaultPartitionKeyAttr),
+		sortAttr:      os.Getenv(envSortKeyAttr),
+	}
+
+	name := envString(envKeyStrategy, keyStrategyPlain)
+	if pk, sk := os.Getenv(envPKTemplate), os.Getenv(envSKTemplate); pk != "" || sk != "" {
+		if name != keyStrategyPlain {
+			return keyLayout{}, fmt.Errorf("%s / %s cannot be combined with %s=%s", envPKTemplate, envSKTemplate, envKeyStrategy, name)
+		}
+		return templatedKeyLayout(layout, pk, sk)
+	}
+	switch name {
+	case keyStrategyPlain:
+		return layout, nil
+	case keyStrategyHashed:
+		layout.strategy = hashedKeys{}
+	case keyStrategySharded:
+		if layout.sortAttr == "" {
+			return keyLayout{}, fmt.Errorf("%s=%s needs %s", envKeyStrategy, name, envSortKeyAttr)
+		}
+		layout.strategy = shardedKeys{
+			prefix: envString(envKeyShardPrefix, defaultShardPrefix),
+			shards: int(envInt64(envKeyShards, defaultKeyShards, 1, maxKeyShards)),
+		}
+	case keyStrategyDedupID:
+		if os.Getenv(envDedupIDTemplate) == "" {
+			return keyLayout{}, fmt.Errorf("%s=%s needs %s", envKeyStrategy, name, envDedupIDTemplate)
+		}
+		layout.strategy = dedupIDKeys{}
+	default:
+		return keyLayout{}, fmt.Errorf("unknown %s %q: want %s, %s, %s or %s",
+			envKeyStrategy, name, keyStrategyPlain, keyStrategyHashed, keyStrategySharded, keyStrategyDedupID)
+	}
+	if layout.partitionAttr == defaultPartitionKeyAttr {
+		return keyLayout{}, fmt.Errorf("%s=%s needs %s other than %s",
+			envKeyStrategy, name, envPartitionKeyAttr, defaultPartitionKeyAttr)
+	}
+	return layout, nil
+}
+
+// templatedKeyLayout keys layout by the partition and sort key templates
+// pk and sk, either of which may be empty.
+func templatedKeyLayout(layout keyLayout, pk, sk string) (keyLayout, error) {
+	if pk != "" {
+		if err := checkOrderTemplate("partition key", pk); err != nil {
+			return keyLayout{}, err
+		}
+		if layout.partitionAttr == defaultPartitionKeyAttr {
+			return keyLayout{}, fmt.Errorf("%s needs %s other than %s", envPKTemplate, envPartitionKeyAttr, defaultPartitionKeyAttr)
+		}
+	}
+	if sk != "" {
+		if err := checkOrderTemplate("sort key", sk); err != nil {
+			return keyLayout{}, err
+		}
+		if layout.sortAttr == "" {
+			return keyLayout{}, fmt.Errorf("%s needs %s", envSKTemplate, envSortKeyAttr)
+		}
+	}
+	layout.strategy = templatedKeys{partition: pk, sort: sk}
+	return layout, nil
+}
+
+// WithKeyStrategy overrides the configured key strategy; keys are still
+// stored in the configured attributes. It returns p.
+func (p *Processor) WithKeyStrategy(strategy KeyStrategy) *Processor {
+	p.keys.strategy = strategy
+	return p
+}
+
+// partitionKeyAttr returns the attribute holding the partition key.
+func (p *Processor) partitionKeyAttr() string {
+	if p.keys.partitionAttr == "" {
+		return defaultPartitionKeyAttr
+	}
+	return p.keys.partitionAttr
+}
+
+// tableKey returns the primary key attributes of order.
+func (p *Processor) tableKey(order Order) map[string]types.AttributeValue {
+	var strategy KeyStrategy = plainKeys{}
+	if p.keys.strategy != nil {
+		strategy = p.keys.strategy
+	}
+	key := strategy.Key(order)
+
+	attrs := map[string]types.AttributeValue{
+		p.partitionKeyAttr(): &types.AttributeValueMemberS{Value: key.Partition},
+	}
+	if p.keys.sortAttr != "" && key.Sort != "" {
+		attrs[p.keys.sortAttr] = &types.AttributeValueMemberS{Value: key.Sort}
+	}
+	return attrs
+}
+
+// orderItem marshals order into the item stored for it, key included.
+func (p *Processor) orderItem(order Order) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMap(order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal order: %w", err)
+	}
+	maps.Copy(item, p.tableKey(order))
+	// Line items stored on their own are left out of the order
+	if p.lineItems != nil {
+		delete(item, "items")
+	}
+	return item, nil
+}
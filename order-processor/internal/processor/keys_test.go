@@ -0,0 +1,152 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyStrategies(t *testing.T) {
+	order := Order{OrderID: "o1", UserID: "u1", Amount: "100"}
+
+	tests := []struct {
+		name     string
+		strategy KeyStrategy
+		want     TableKey
+	}{
+		{"plain", plainKeys{}, TableKey{Partition: "o1"}},
+		{"hashed", hashedKeys{}, TableKey{Partition: "2352da7280f1decc3acf1ba84eb945c9fc2b7b541094e1d0992dbffd1b6664cc"}},
+		{"sharded", shardedKeys{prefix: "shard-", shards: 16}, TableKey{Partition: "shard-13", Sort: "o1"}},
+		{"sharded single", shardedKeys{prefix: "s", shards: 1}, TableKey{Partition: "s0", Sort: "o1"}},
+		{"templated", templatedKeys{partition: "USER#{user_id}", sort: "ORDER#{order_id}"}, TableKey{Partition: "USER#u1", Sort: "ORDER#o1"}},
+		{"templated sort only", templatedKeys{sort: "{user_id}/{amount}"}, TableKey{Partition: "o1", Sort: "u1/100"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.strategy.Key(order))
+		})
+	}
+}
+
+func TestKeyLayoutFromEnv(t *testing.T) {
+	t.Run("defaults to plain order_id", func(t *testing.T) {
+		layout, err := keyLayoutFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, keyLayout{partitionAttr: "order_id"}, layout)
+	})
+
+	t.Run("sharded", func(t *testing.T) {
+		t.Setenv(envKeyStrategy, "sharded")
+		t.Setenv(envPartitionKeyAttr, "pk")
+		t.Setenv(envSortKeyAttr, "sk")
+		t.Setenv(envKeyShards, "4")
+		layout, err := keyLayoutFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, keyLayout{
+			strategy:      shardedKeys{prefix: "shard-", shards: 4},
+			partitionAttr: "pk",
+			sortAttr:      "sk",
+		}, layout)
+	})
+
+	t.Run("templated", func(t *testing.T) {
+		t.Setenv(envPKTemplate, "USER#{user_id}")
+		t.Setenv(envSKTemplate, "ORDER#{order_id}")
+		t.Setenv(envPartitionKeyAttr, "pk")
+		t.Setenv(envSortKeyAttr, "sk")
+		layout, err := keyLayoutFromEnv()
+		require.NoError(t, err)
+		assert.Equal(t, keyLayout{
+			strategy:      templatedKeys{partition: "USER#{user_id}", sort: "ORDER#{order_id}"},
+			partitionAttr: "pk",
+			sortAttr:      "sk",
+		}, layout)
+	})
+
+	for name, env := range map[string]map[string]string{
+		"unknown strategy":          {envKeyStrategy: "random"},
+		"hashed into order_id":      {envKeyStrategy: "hashed"},
+		"sharded without sort":      {envKeyStrategy: "sharded", envPartitionKeyAttr: "pk"},
+		"sharded into order_id":     {envKeyStrategy: "sharded", envSortKeyAttr: "sk"},
+		"dedup_id without template": {envKeyStrategy: "dedup_id", envPartitionKeyAttr: "pk"},
+		"pk template into order_id": {envPKTemplate: "USER#{user_id}"},
+		"sk template without sort":  {envSKTemplate: "ORDER#{order_id}"},
+		"template unknown field":    {envPKTemplate: "USER#{email}", envPartitionKeyAttr: "pk"},
+		"template with strategy":    {envPKTemplate: "USER#{user_id}", envPartitionKeyAttr: "pk", envKeyStrategy: "hashed"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			_, err := keyLayoutFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPollAndProcess_ShardedKeys(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		clock:           fixedClock{at: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)},
+		keys: keyLayout{
+			strategy:      shardedKeys{prefix: "shard-", shards: 16},
+			partitionAttr: "pk",
+			sortAttr:      "sk",
+		},
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{{
+			MessageId:     aws.String("msg-1"),
+			Body:          aws.String(`{"order_id":"o1","user_id":"u1","amount":100}`),
+			ReceiptHandle: aws.String("r1"),
+		}}}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "shard-13"}, input.Item["pk"]) &&
+			assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "o1"}, input.Item["sk"]) &&
+			// The order ID is kept alongside the key
+			assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "o1"}, input.Item["order_id"]) &&
+			assert.Equal(t, "attribute_not_exists(#pk)", aws.ToString(input.ConditionExpression)) &&
+			assert.Equal(t, map[string]string{"#pk": "pk"}, input.ExpressionAttributeNames)
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
+
+// tenantKeys keys orders by user, for TestWithKeyStrategy.
+type tenantKeys struct{}
+
+func (tenantKeys) Key(order Order) TableKey {
+	return TableKey{Partition: "user#" + order.UserID, Sort: "order#" + order.OrderID}
+}
+
+func TestWithKeyStrategy(t *testing.T) {
+	proc := (&Processor{keys: keyLayout{partitionAttr: "pk", sortAttr: "sk"}}).
+		WithKeyStrategy(tenantKeys{})
+
+	assert.Equal(t, map[string]dtypes.AttributeValue{
+		"pk": &dtypes.AttributeValueMemberS{Value: "user#u1"},
+		"sk": &dtypes.AttributeValueMemberS{Value: "order#o1"},
+	}, proc.tableKey(Order{OrderID: "o1", UserID: "u1"}))
+}
@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Kill switch defaults
+	defaultKillSwitchKey      = "order-processor"
+	defaultKillSwitchInterval = 10 * time.Second
+)
+
+// KillSwitch reports whether processing must halt, e.g. during a security
+// incident.
+type KillSwitch interface {
+	Engaged(ctx context.Context) (bool, error)
+}
+
+// ddbKillSwitch reads the switch from a DynamoDB item keyed by name (S),
+// engaged while its "engaged" attribute is true.
+type ddbKillSwitch struct {
+	client ddbClientI
+	table  string
+	key    string
+}
+
+func (k *ddbKillSwitch) Engaged(ctx context.Context) (bool, error) {
+	out, err := k.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &k.table,
+		Key:            map[string]types.AttributeValue{"name": &types.AttributeValueMemberS{Value: k.key}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, fmt.Errorf("get kill switch item: %w", err)
+	}
+	engaged, ok := out.Item["engaged"].(*types.AttributeValueMemberBOOL)
+	return ok && engaged.Value, nil
+}
+
+// watchKillSwitch checks the kill switch every interval until ctx ends.
+func (p *Processor) watchKillSwitch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.checkKillSwitch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkKillSwitch refreshes the kill switch state. If the switch cannot be
+// read the last known state is kept, so an outage of the switch store
+// neither halts a healthy processor nor resumes a halted one.
+func (p *Processor) checkKillSwitch(ctx context.Context) {
+	engaged, err := p.killSwitch.Engaged(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to check kill switch - keeping current state")
+		return
+	}
+	if p.killSwitchEngaged.Swap(engaged) != engaged {
+		log.Warn().Bool("engaged", engaged).Msg("kill switch changed")
+	}
+}
+
+// paused reports whether polling is halted by read-only mode, the kill
+// switch or the orders table not accepting writes.
+func (p *Processor) paused() bool {
+	return p.ReadOnly() || p.killSwitchEngaged.Load() || p.tableInactive.Load()
+}
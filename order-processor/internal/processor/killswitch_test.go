@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockKillSwitch struct {
+	mock.Mock
+}
+
+func (m *MockKillSwitch) Engaged(ctx context.Context) (bool, error) {
+	args := m.Called(ctx)
+	return args.Bool(0), args.Error(1)
+}
+
+func readyStatus(proc *Processor) int {
+	rec := httptest.NewRecorder()
+	proc.handleReady(rec, httptest.NewRequest(http.MethodGet, readinessPath, nil))
+	return rec.Code
+}
+
+func TestKillSwitch_TogglesProcessing(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockSwitch := &MockKillSwitch{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		killSwitch:      mockSwitch,
+	}
+	ctx := context.Background()
+
+	mockSwitch.On("Engaged", mock.Anything).Return(true, nil).Once()
+	proc.checkKillSwitch(ctx)
+
+	_, err := proc.pollAndProcess(ctx)
+	assert.NoError(t, err)
+	mockSQS.AssertNotCalled(t, "ReceiveMessage", mock.Anything, mock.Anything)
+	assert.Equal(t, http.StatusServiceUnavailable, readyStatus(proc))
+
+	// An unreadable switch keeps the processor halted
+	mockSwitch.On("Engaged", mock.Anything).Return(false, errors.New("DynamoDB error")).Once()
+	proc.checkKillSwitch(ctx)
+	assert.True(t, proc.paused())
+
+	mockSwitch.On("Engaged", mock.Anything).Return(false, nil).Once()
+	proc.checkKillSwitch(ctx)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{}}, nil).Once()
+	_, err = proc.pollAndProcess(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, readyStatus(proc))
+	mockSQS.AssertExpectations(t)
+	mockSwitch.AssertExpectations(t)
+}
+
+func TestDDBKillSwitch_Engaged(t *testing.T) {
+	tests := []struct {
+		name string
+		item map[string]dtypes.AttributeValue
+		want bool
+	}{
+		{name: "missing item", item: nil, want: false},
+		{name: "engaged", item: map[string]dtypes.AttributeValue{"engaged": &dtypes.AttributeValueMemberBOOL{Value: true}}, want: true},
+		{name: "disengaged", item: map[string]dtypes.AttributeValue{"engaged": &dtypes.AttributeValueMemberBOOL{Value: false}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDDB := &MockDynamoDBClient{}
+			ks := &ddbKillSwitch{client: mockDDB, table: "Switches", key: "order-processor"}
+
+			mockDDB.On("GetItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+				return *input.TableName == "Switches" &&
+					input.Key["name"].(*dtypes.AttributeValueMemberS).Value == "order-processor"
+			})).Return(&dynamodb.GetItemOutput{Item: tt.item}, nil).Once()
+
+			engaged, err := ks.Engaged(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, engaged)
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultItemMaxAttempts = 3
+	maxItemMaxAttempts     = 100
+
+	// lineItemProgressTTL is how long the progress of an unfinished order
+	// is kept; its message may have been redelivered to another processor
+	lineItemProgressTTL = time.Hour
+
+	// dlqReasonItemFailed tags a line item dead-lettered on its own
+	dlqReasonItemFailed = "item_failed"
+
+	// Line item results
+	itemStored       = "stored"
+	itemFailed       = "failed"
+	itemDeadLettered = "dead_lettered"
+)
+
+// OrderItem is one line item of a multi-item order.
+type OrderItem struct {
+	ItemID    string  `json:"item_id" dynamodbav:"item_id"`
+	ProductID string  `json:"product_id,omitempty" dynamodbav:"product_id,omitempty"`
+	Quantity  int64   `json:"quantity" dynamodbav:"quantity"`
+	Amount    Decimal `json:"amount" dynamodbav:"amount"`
+}
+
+// deadLetteredItem is the DLQ body of a line item dead-lettered on its
+// own.
+type deadLetteredItem struct {
+	OrderID string `json:"order_id"`
+	OrderItem
+}
+
+// lineItemStore writes the items of multi-item orders to their own table,
+// one by one, so a failed item is retried without the others. Progress is
+// kept per order until the order itself is stored: a redelivered or
+// retried message only writes the items not yet resolved, and an item that
+// fails maxAttempts times is dead-lettered on its own. Items are written
+// conditionally, so one already stored by another processor counts as
+// resolved.
+type lineItemStore struct {
+	table       string
+	maxAttempts int
+
+	mu sync.Mutex
+	// progress is keyed by order ID
+	progress map[string]*itemProgress
+}
+
+// itemProgress tracks the items of one order, by item ID.
+type itemProgress struct {
+	resolved map[string]bool
+	attempts map[string]int
+	updated  time.Time
+}
+
+func newLineItemStore(table string, maxAttempts int) *lineItemStore {
+	return &lineItemStore{table: table, maxAttempts: maxAttempts, progress: make(map[string]*itemProgress)}
+}
+
+func newOrderItemsCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_items_total",
+			Help: "Line item writes by result: stored, failed or dead_lettered",
+		},
+		[]string{"result", "env"},
+	)
+}
+
+// pending returns the items of order not yet resolved, dropping progress
+// that has gone stale.
+func (s *lineItemStore) pending(order Order, now time.Time) []OrderItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, progress := range s.progress {
+		if now.Sub(progress.updated) > lineItemProgressTTL {
+			delete(s.progress, id)
+		}
+	}
+	progress, ok := s.progress[order.OrderID]
+	if !ok {
+		progress = &itemProgress{resolved: make(map[string]bool), attempts: make(map[string]int)}
+		s.progress[order.OrderID] = progress
+	}
+	progress.updated = now
+
+	var pending []OrderItem
+	for _, item := range order.Items {
+		if !progress.resolved[item.ItemID] {
+			pending = append(pending, item)
+		}
+	}
+	return pending
+}
+
+// resolve marks an item stored or dead-lettered.
+func (s *lineItemStore) resolve(orderID, itemID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if progress, ok := s.progress[orderID]; ok {
+		progress.resolved[itemID] = true
+	}
+}
+
+// fail counts a failed write of an item, returning its attempts so far.
+func (s *lineItemStore) fail(orderID, itemID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	progress, ok := s.progress[orderID]
+	if !ok {
+		return 1
+	}
+	progress.attempts[itemID]++
+	return progress.attempts[itemID]
+}
+
+// forget drops the progress of an order that has been stored.
+func (s *lineItemStore) forget(orderID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.progress, orderID)
+}
+
+// checkLineItems rejects orders whose items cannot be stored one by one:
+// each needs a unique item ID and a positive quantity.
+func (p *Processor) checkLineItems(order Order) error {
+	if p.lineItems == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(order.Items))
+	for i, item := range order.Items {
+		var err error
+		switch {
+		case item.ItemID == "":
+			err = fmt.Errorf("item %d has no item_id", i)
+		case seen[item.ItemID]:
+			err = fmt.Errorf("item_id %q appears more than once", item.ItemID)
+		case item.Quantity <= 0:
+			err = fmt.Errorf("item %q quantity must be positive, got %d", item.ItemID, item.Quantity)
+		}
+		if err != nil {
+			return &ValidationError{Type: "invalid_items", OrderID: order.OrderID, Err: err}
+		}
+		seen[item.ItemID] = true
+	}
+	return nil
+}
+
+// storeLineItems writes the items of order not yet resolved. It returns a
+// retryable error naming the items that failed, so the message is only
+// deleted once every item is stored or dead-lettered; the order itself is
+// stored after that, without its items.
+func (p *Processor) storeLineItems(ctx context.Context, msg types.Message, order Order) error {
+	if p.lineItems == nil || len(order.Items) == 0 || !p.storesToDynamo() {
+		return nil
+	}
+	ctx, span := p.startChildSpan(ctx, "store_line_items")
+	var errs []error
+	defer func() { endSpan(span, errors.Join(errs...)) }()
+
+	for _, item := range p.lineItems.pending(order, p.now()) {
+		err := p.putLineItem(ctx, order.OrderID, item)
+		if err == nil || isDuplicateWrite(err) {
+			p.lineItems.resolve(order.OrderID, item.ItemID)
+			p.countLineItem(itemStored)
+			continue
+		}
+		if interrupted(ctx, err) {
+			return err
+		}
+
+		p.countLineItem(itemFailed)
+		attempts := p.lineItems.fail(order.OrderID, item.ItemID)
+		if attempts >= p.lineItems.maxAttempts && p.dlqURL != "" {
+			dlqErr := p.deadLetterItem(ctx, msg, order.OrderID, item)
+			if dlqErr == nil {
+				p.lineItems.resolve(order.OrderID, item.ItemID)
+				p.countLineItem(itemDeadLettered)
+				log.Warn().
+					Str("order_id", order.OrderID).
+					Str("item_id", item.ItemID).
+					Int("attempts", attempts).
+					Err(err).
+					Msg("line item failed too often - moved to DLQ")
+				continue
+			}
+			err = errors.Join(err, dlqErr)
+		}
+		errs = append(errs, fmt.Errorf("item %s: %w", item.ItemID, err))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d order items failed: %w", len(errs), len(order.Items), errors.Join(errs...))
+	}
+	return nil
+}
+
+// putLineItem writes one item, keyed by order_id and item_id, unless the
+// items table already holds it.
+func (p *Processor) putLineItem(ctx context.Context, orderID string, item OrderItem) error {
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order item: %w", err)
+	}
+	av["order_id"] = &dtypes.AttributeValueMemberS{Value: orderID}
+	_, err = p.ddbClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           &p.lineItems.table,
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(order_id)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put order item: %w", err)
+	}
+	return nil
+}
+
+// deadLetterItem sends one line item to the DLQ, as a body of the item and
+// its order ID. On a FIFO DLQ each item gets its own dedup ID, derived
+// from the message's and the item ID.
+func (p *Processor) deadLetterItem(ctx context.Context, msg types.Message, orderID string, item OrderItem) error {
+	body, err := json.Marshal(deadLetteredItem{OrderID: orderID, OrderItem: item})
+	if err != nil {
+		return fmt.Errorf("marshal order item: %w", err)
+	}
+	input := p.dlqInput(ctx, msg, dlqReasonItemFailed)
+	input.MessageBody = aws.String(string(body))
+	setDLQTag(ctx, input.MessageAttributes, "item_id", item.ItemID)
+	if input.MessageDeduplicationId != nil {
+		sum := sha256.Sum256([]byte(*input.MessageDeduplicationId + "/" + item.ItemID))
+		input.MessageDeduplicationId = aws.String(hex.EncodeToString(sum[:]))
+	}
+	return p.sendDLQInput(ctx, input)
+}
+
+func (p *Processor) countLineItem(result string) {
+	if p.orderItems != nil {
+		p.orderItems.WithLabelValues(result, p.environment).Inc()
+	}
+	p.statsd.count(statsdOrderItems, 1, "result:"+result, "env:"+p.environment)
+}
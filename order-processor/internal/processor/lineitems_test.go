@@ -0,0 +1,154 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const multiItemOrder = `{"order_id":"o1","user_id":"u1","amount":30,"items":[
+	{"item_id":"i1","product_id":"p1","quantity":1,"amount":10},
+	{"item_id":"i2","product_id":"p2","quantity":2,"amount":10},
+	{"item_id":"i3","product_id":"p3","quantity":1,"amount":10}]}`
+
+func newLineItemProcessor(mockSQS *MockSQSClient, mockDDB *MockDynamoDBClient) *Processor {
+	return &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		orderItems:      newOrderItemsCounter(),
+		environment:     "test",
+		lineItems:       newLineItemStore("OrderItems", defaultItemMaxAttempts),
+	}
+}
+
+func itemPut(id string) interface{} {
+	return mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return *input.TableName == "OrderItems" &&
+			input.Item["order_id"].(*dtypes.AttributeValueMemberS).Value == "o1" &&
+			input.Item["item_id"].(*dtypes.AttributeValueMemberS).Value == id
+	})
+}
+
+func orderPut() interface{} {
+	return mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		_, hasItems := input.Item["items"]
+		return *input.TableName == "Orders" && !hasItems
+	})
+}
+
+func TestHandleMessage_OnlyFailedItemRetried(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := newLineItemProcessor(&MockSQSClient{}, mockDDB)
+	msg := bodyMessage("m1", multiItemOrder)
+
+	// First delivery: i2 fails, so the order is not stored and the
+	// message stays on the queue
+	mockDDB.On("PutItem", mock.Anything, itemPut("i1")).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, itemPut("i2")).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("throttled")).Once()
+	mockDDB.On("PutItem", mock.Anything, itemPut("i3")).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	err := proc.handleMessage(context.Background(), msg)
+
+	require.ErrorContains(t, err, "1 of 3 order items failed")
+	var vErr *ValidationError
+	assert.False(t, errors.As(err, &vErr))
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, orderPut())
+
+	// Retry: only i2 is written, then the order
+	mockDDB.On("PutItem", mock.Anything, itemPut("i2")).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, orderPut()).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	require.NoError(t, proc.handleMessage(context.Background(), msg))
+
+	mockDDB.AssertExpectations(t)
+	mockDDB.AssertNumberOfCalls(t, "PutItem", 5)
+	assert.Equal(t, 3.0, testutil.ToFloat64(proc.orderItems.WithLabelValues(itemStored, "test")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.orderItems.WithLabelValues(itemFailed, "test")))
+	assert.Empty(t, proc.lineItems.progress)
+}
+
+func TestHandleMessage_ItemAlreadyStoredCountsAsResolved(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := newLineItemProcessor(&MockSQSClient{}, mockDDB)
+
+	mockDDB.On("PutItem", mock.Anything, itemPut("i1")).
+		Return((*dynamodb.PutItemOutput)(nil), &dtypes.ConditionalCheckFailedException{}).Once()
+	mockDDB.On("PutItem", mock.Anything, itemPut("i2")).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, itemPut("i3")).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, orderPut()).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	require.NoError(t, proc.handleMessage(context.Background(), bodyMessage("m1", multiItemOrder)))
+
+	mockDDB.AssertExpectations(t)
+}
+
+func TestHandleMessage_PersistentlyFailingItemDeadLettered(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newLineItemProcessor(mockSQS, mockDDB)
+	proc.lineItems.maxAttempts = 2
+	proc.dlqURL = "test-dlq"
+	msg := bodyMessage("m1", multiItemOrder)
+
+	mockDDB.On("PutItem", mock.Anything, itemPut("i1")).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, itemPut("i2")).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("item rejected")).Twice()
+	mockDDB.On("PutItem", mock.Anything, itemPut("i3")).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		return aws.ToString(input.QueueUrl) == "test-dlq" &&
+			aws.ToString(input.MessageBody) == `{"order_id":"o1","item_id":"i2","product_id":"p2","quantity":2,"amount":10}` &&
+			aws.ToString(input.MessageAttributes["dlq_reason"].StringValue) == dlqReasonItemFailed &&
+			aws.ToString(input.MessageAttributes["item_id"].StringValue) == "i2"
+	})).Return(&sqs.SendMessageOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, orderPut()).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	assert.Error(t, proc.handleMessage(context.Background(), msg))
+	// The second failure of i2 uses up its attempts: it is dead-lettered
+	// and the order completes without it
+	assert.NoError(t, proc.handleMessage(context.Background(), msg))
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.orderItems.WithLabelValues(itemDeadLettered, "test")))
+}
+
+func TestHandleMessage_InvalidItemsRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   string
+		wantErr string
+	}{
+		{name: "missing item ID", items: `[{"quantity":1}]`, wantErr: "item 0 has no item_id"},
+		{name: "duplicate item ID", items: `[{"item_id":"i1","quantity":1},{"item_id":"i1","quantity":1}]`, wantErr: `item_id "i1" appears more than once`},
+		{name: "zero quantity", items: `[{"item_id":"i1","quantity":0}]`, wantErr: `item "i1" quantity must be positive`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDDB := &MockDynamoDBClient{}
+			proc := newLineItemProcessor(&MockSQSClient{}, mockDDB)
+
+			err := proc.handleMessage(context.Background(), bodyMessage("m1",
+				`{"order_id":"o1","user_id":"u1","amount":10,"items":`+tt.items+`}`))
+
+			var vErr *ValidationError
+			require.ErrorAs(t, err, &vErr)
+			assert.Equal(t, "invalid_items", vErr.Type)
+			assert.ErrorContains(t, err, tt.wantErr)
+			mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+		})
+	}
+}
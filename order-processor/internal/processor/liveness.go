@@ -0,0 +1,51 @@
+package processor
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultHealthStaleness is how long the poll loop may go without a
+// successful poll before /health reports it stuck; a poll waits at most
+// 20 seconds for messages, so this allows for many failed polls in a row
+const defaultHealthStaleness = 5 * time.Minute
+
+// markPollSuccess records that the poll loop made progress: a batch was
+// polled and handled, the queue was empty, or polling is deliberately
+// paused.
+func (p *Processor) markPollSuccess() {
+	p.lastPollSuccess.Store(p.now().UnixNano())
+}
+
+// pollStale reports whether the last successful poll is older than
+// healthStaleness, returning when it was. Before the poll loop starts, and
+// with healthStaleness 0, polling is never stale.
+func (p *Processor) pollStale() (time.Time, bool) {
+	last := p.lastPollSuccess.Load()
+	if p.healthStaleness <= 0 || last == 0 {
+		return time.Time{}, false
+	}
+	at := time.Unix(0, last).UTC()
+	return at, p.now().Sub(at) > p.healthStaleness
+}
+
+// handleHealth reports whether the process is alive: it is unless the poll
+// loop has stopped making progress, which a restart may cure.
+func (p *Processor) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if last, stale := p.pollStale(); stale {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		body := fmt.Sprintf(`{"status":"unhealthy","reason":"no successful poll","last_poll_success":%q}`, last.Format(time.RFC3339))
+		if _, err := w.Write([]byte(body)); err != nil {
+			log.Error().Err(err).Msg("failed to write health check response")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(`{"status":"healthy"}`)); err != nil {
+		log.Error().Err(err).Msg("failed to write health check response")
+	}
+}
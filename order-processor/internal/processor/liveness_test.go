@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func healthResponse(proc *Processor) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	proc.handleHealth(rec, httptest.NewRequest(http.MethodGet, healthPath, nil))
+	return rec
+}
+
+func TestHandleHealth_FailsWhenPollingStalls(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		clock:           clock,
+		healthStaleness: time.Minute,
+	}
+	empty := &sqs.ReceiveMessageOutput{Messages: []stypes.Message{}}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).Return(empty, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return((*sqs.ReceiveMessageOutput)(nil), errors.New("service unavailable")).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).Return(empty, nil).Once()
+
+	// Healthy before the first poll, however long startup takes
+	clock.advance(time.Hour)
+	assert.Equal(t, http.StatusOK, healthResponse(proc).Code)
+
+	// An empty queue is progress
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+	clock.advance(time.Minute)
+	assert.Equal(t, http.StatusOK, healthResponse(proc).Code)
+
+	// A failed poll is not
+	_, err = proc.pollAndProcess(context.Background())
+	assert.Error(t, err)
+	clock.advance(time.Second)
+	rec := healthResponse(proc)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status":"unhealthy","reason":"no successful poll","last_poll_success":"2024-05-01T09:00:00Z"}`, rec.Body.String())
+
+	_, err = proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, healthResponse(proc).Code)
+}
+
+func TestHandleHealth_PausedPollingIsAlive(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	proc := &Processor{clock: clock, healthStaleness: time.Minute}
+	proc.markPollSuccess()
+	proc.SetReadOnly(true)
+
+	clock.advance(2 * time.Minute)
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, healthResponse(proc).Code)
+}
+
+func TestHandleHealth_StalenessDisabled(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	proc := &Processor{clock: clock}
+	proc.markPollSuccess()
+
+	clock.advance(24 * time.Hour)
+	assert.Equal(t, http.StatusOK, healthResponse(proc).Code)
+}
@@ -0,0 +1,51 @@
+package processor
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// correlationIDAttribute is the message attribute an upstream service may
+// set to correlate its logs with ours
+const correlationIDAttribute = "correlation_id"
+
+// correlationID returns the message's correlation_id attribute, or a new
+// UUID when it has none.
+func correlationID(msg types.Message) string {
+	if attr, ok := msg.MessageAttributes[correlationIDAttribute]; ok && aws.ToString(attr.StringValue) != "" {
+		return aws.ToString(attr.StringValue)
+	}
+	return uuid.NewString()
+}
+
+// withMessageLogger returns ctx carrying a logger that tags every line with
+// the message ID and its correlation ID, so the lines for one message can
+// be picked out from those of the other workers.
+func withMessageLogger(ctx context.Context, msg types.Message) context.Context {
+	l := log.With().
+		Str("msg_id", aws.ToString(msg.MessageId)).
+		Str("correlation_id", correlationID(msg)).
+		Logger()
+	return l.WithContext(ctx)
+}
+
+// withOrderLogger returns ctx with its logger also tagging lines with the
+// order ID.
+func withOrderLogger(ctx context.Context, orderID string) context.Context {
+	l := logger(ctx).With().Str("order_id", orderID).Logger()
+	return l.WithContext(ctx)
+}
+
+// logger returns the logger ctx carries, or the global one outside the
+// handling of a message.
+func logger(ctx context.Context) *zerolog.Logger {
+	if l := log.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return &log.Logger
+}
@@ -0,0 +1,85 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// logBuffer collects log output; zerolog may write from several workers.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// lines returns the logged lines with the given message.
+func (b *logBuffer) lines(t *testing.T, msg string) []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var lines []map[string]any
+	for _, raw := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var line map[string]any
+		require.NoError(t, json.Unmarshal([]byte(raw), &line), raw)
+		if line["message"] == msg {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// captureLogs sends the global logger's output to a buffer for the rest of
+// the test.
+func captureLogs(t *testing.T) *logBuffer {
+	b := &logBuffer{}
+	saved := log.Logger
+	log.Logger = zerolog.New(b)
+	t.Cleanup(func() { log.Logger = saved })
+	return b
+}
+
+func TestProcessMessage_LogsCarryMessageAndCorrelationIDs(t *testing.T) {
+	logs := captureLogs(t)
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders", ordersProcessed: NewCounterVec()}
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	msg := withAttributes(bodyMessage("m1", `{"order_id":"o1","user_id":"u1","amount":100}`),
+		map[string]string{correlationIDAttribute: "req-42"})
+	require.True(t, proc.processMessage(context.Background(), msg))
+
+	lines := logs.lines(t, "order processed successfully")
+	require.Len(t, lines, 1)
+	assert.Equal(t, "m1", lines[0]["msg_id"])
+	assert.Equal(t, "o1", lines[0]["order_id"])
+	assert.Equal(t, "req-42", lines[0]["correlation_id"])
+}
+
+func TestProcessMessage_GeneratesCorrelationID(t *testing.T) {
+	logs := captureLogs(t)
+	proc := &Processor{ordersProcessed: NewCounterVec()}
+
+	assert.True(t, proc.processMessage(context.Background(), bodyMessage("m1", `not json`)))
+
+	lines := logs.lines(t, "failed to process message")
+	require.Len(t, lines, 1)
+	assert.Equal(t, "m1", lines[0]["msg_id"])
+	_, err := uuid.Parse(lines[0]["correlation_id"].(string))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// messageAgeBuckets span a message picked up at once to one left on the
+// queue for the 14 day maximum retention.
+var messageAgeBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 6 * 3600, 24 * 3600, 4 * 24 * 3600, 14 * 24 * 3600}
+
+func newMessageAgeHistogram() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "sqs_message_age_seconds",
+			Help:    "Time from a message being sent to SQS to its receipt",
+			Buckets: messageAgeBuckets,
+		},
+		[]string{"env", "queue"},
+	)
+}
+
+// sentTime returns when msg was sent to SQS, from its SentTimestamp system
+// attribute, or false when the attribute is missing or not epoch
+// milliseconds.
+func sentTime(msg types.Message) (time.Time, bool) {
+	raw, ok := msg.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)]
+	if !ok {
+		return time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+// observeMessageAges records how long each received message waited on the
+// queue. Messages without a usable SentTimestamp are skipped rather than
+// observed as zero, and ages are floored at zero so clock skew between SQS
+// and this host cannot record a negative wait.
+func (p *Processor) observeMessageAges(ctx context.Context, received []types.Message) {
+	if p.messageAge == nil || len(received) == 0 {
+		return
+	}
+	age := p.messageAge.WithLabelValues(p.environment, p.queueOf(ctx).name)
+	now := p.now()
+	for _, msg := range received {
+		sent, ok := sentTime(msg)
+		if !ok {
+			logger(ctx).Debug().
+				Str("msg_id", aws.ToString(msg.MessageId)).
+				Msg("message has no usable SentTimestamp; age not recorded")
+			continue
+		}
+		age.Observe(max(now.Sub(sent), 0).Seconds())
+	}
+}
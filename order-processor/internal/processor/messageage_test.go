@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// sentAt returns msg with its SentTimestamp system attribute set to raw.
+func sentAt(msg stypes.Message, raw string) stypes.Message {
+	msg.Attributes = map[string]string{string(stypes.MessageSystemAttributeNameSentTimestamp): raw}
+	return msg
+}
+
+func messageAgeHistogram(t *testing.T, proc *Processor) *dto.Histogram {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, proc.messageAge.WithLabelValues(proc.environment, queueName(proc.queueURL)).(prometheus.Metric).Write(&m))
+	return m.GetHistogram()
+}
+
+func TestSentTime(t *testing.T) {
+	sent, ok := sentTime(sentAt(stypes.Message{}, "1714550400000"))
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC), sent.UTC())
+
+	for _, raw := range []string{"", "soon", "-5", "0", "1.5e12"} {
+		_, ok := sentTime(sentAt(stypes.Message{}, raw))
+		assert.False(t, ok, raw)
+	}
+	_, ok = sentTime(stypes.Message{})
+	assert.False(t, ok, "no attributes")
+}
+
+func TestObserveMessageAges(t *testing.T) {
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	proc := &Processor{
+		queueURL:    "test-queue",
+		environment: "test",
+		messageAge:  newMessageAgeHistogram(),
+		clock:       fixedClock{at: now},
+	}
+	ms := func(d time.Duration) string {
+		return strconv.FormatInt(now.Add(-d).UnixMilli(), 10)
+	}
+
+	proc.observeMessageAges(context.Background(), []stypes.Message{
+		sentAt(bodyMessage("m1", "{}"), ms(30*time.Second)),
+		sentAt(bodyMessage("m2", "{}"), ms(90*time.Second)),
+		// Sent "after" receipt by a skewed clock: recorded as no wait
+		sentAt(bodyMessage("m3", "{}"), ms(-2*time.Second)),
+		// Skipped rather than observed as zero
+		sentAt(bodyMessage("m4", "{}"), "garbage"),
+		bodyMessage("m5", "{}"),
+	})
+
+	h := messageAgeHistogram(t, proc)
+	assert.Equal(t, uint64(3), h.GetSampleCount())
+	assert.Equal(t, 120.0, h.GetSampleSum())
+}
+
+func TestPollAndProcess_ObservesMessageAge(t *testing.T) {
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		messageAge:      newMessageAgeHistogram(),
+		clock:           fixedClock{at: now},
+	}
+	msg := sentAt(bodyMessage("m1", `{"order_id":"o1","user_id":"u1","amount":100}`), strconv.FormatInt(now.Add(-45*time.Second).UnixMilli(), 10))
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r-m1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	h := messageAgeHistogram(t, proc)
+	assert.Equal(t, uint64(1), h.GetSampleCount())
+	assert.Equal(t, 45.0, h.GetSampleSum())
+	mockSQS.AssertExpectations(t)
+}
@@ -0,0 +1,134 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Failure classes: a terminal failure can never succeed on redelivery, a
+// retryable one may.
+const (
+	failureTerminal  = "terminal"
+	failureRetryable = "retryable"
+)
+
+// failureClass classifies a processing error. Validation errors are
+// terminal; anything else, e.g. DynamoDB throttling, is retryable.
+func failureClass(err error) string {
+	var vErr *ValidationError
+	if errors.As(err, &vErr) {
+		return failureTerminal
+	}
+	return failureRetryable
+}
+
+func newFailuresCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_failures_total",
+			Help: "Failed messages by failure class",
+		},
+		[]string{"class", "env"},
+	)
+}
+
+// countOrder counts a message outcome under status on every metrics
+// backend.
+func (p *Processor) countOrder(ctx context.Context, status string) {
+	queue := p.queueOf(ctx).name
+	p.ordersProcessed.WithLabelValues(status, p.environment, queue).Inc()
+	p.statsd.count(statsdOrdersProcessed, 1, "status:"+status, "env:"+p.environment, "queue:"+queue)
+}
+
+// countFailure counts a failed message under its failure class on every
+// metrics backend.
+func (p *Processor) countFailure(class string) {
+	if p.failuresByClass != nil {
+		p.failuresByClass.WithLabelValues(class, p.environment).Inc()
+	}
+	p.statsd.count(statsdOrderFailures, 1, "class:"+class, "env:"+p.environment)
+}
+
+func newDeleteFailuresCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sqs_delete_failures_total",
+			Help: "Messages SQS failed to delete, which will be reprocessed",
+		},
+		[]string{"env"},
+	)
+}
+
+// countDeleteFailures counts n messages that could not be deleted on every
+// metrics backend.
+func (p *Processor) countDeleteFailures(n int) {
+	if p.deleteFailures != nil {
+		p.deleteFailures.WithLabelValues(p.environment).Add(float64(n))
+	}
+	p.statsd.count(statsdDeleteFailures, int64(n), "env:"+p.environment)
+}
+
+func newMessagesReceivedCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sqs_messages_received_total",
+			Help: "Messages received from SQS",
+		},
+		[]string{"env", "queue"},
+	)
+}
+
+func newEmptyPollsCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sqs_empty_polls_total",
+			Help: "Polls that received no messages",
+		},
+		[]string{"env", "queue"},
+	)
+}
+
+// countReceived counts a poll that received n messages on every metrics
+// backend, as an empty poll when n is zero.
+func (p *Processor) countReceived(ctx context.Context, n int) {
+	queue := p.queueOf(ctx).name
+	if p.messagesReceived != nil {
+		p.messagesReceived.WithLabelValues(p.environment, queue).Add(float64(n))
+	}
+	if n == 0 && p.emptyPolls != nil {
+		p.emptyPolls.WithLabelValues(p.environment, queue).Inc()
+	}
+	if n == 0 {
+		p.statsd.count(statsdEmptyPolls, 1, "env:"+p.environment, "queue:"+queue)
+	} else {
+		p.statsd.count(statsdMessagesReceived, int64(n), "env:"+p.environment, "queue:"+queue)
+	}
+}
+
+// collectorRegistrar registers collectors on a registry, keeping the first
+// failure for NewProcessor to return.
+type collectorRegistrar struct {
+	registry prometheus.Registerer
+	err      error
+}
+
+// register registers c with r. A collector registered before under the
+// same descriptors, e.g. by another processor sharing the registry, is
+// returned in place of c so both update the same series. Any other
+// failure is kept in r.err and c is returned unregistered.
+func register[C prometheus.Collector](r *collectorRegistrar, c C) C {
+	err := r.registry.Register(c)
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		if existing, ok := already.ExistingCollector.(C); ok {
+			return existing
+		}
+	}
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("register metrics: %w", err)
+	}
+	return c
+}
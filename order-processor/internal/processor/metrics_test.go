@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFailureClass(t *testing.T) {
+	invalid := &ValidationError{Type: "invalid_json", Err: errors.New("bad")}
+
+	assert.Equal(t, failureTerminal, failureClass(invalid))
+	assert.Equal(t, failureTerminal, failureClass(fmt.Errorf("handle: %w", invalid)))
+	assert.Equal(t, failureRetryable, failureClass(errors.New("ProvisionedThroughputExceededException")))
+}
+
+func TestPollAndProcess_TerminalDeletedRetryableKept(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		failuresByClass: newFailuresCounter(),
+		environment:     "test",
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			{MessageId: aws.String("m1"), Body: aws.String(`not json`), ReceiptHandle: aws.String("r1")},
+			{MessageId: aws.String("m2"), Body: aws.String(`{"order_id":"o2","amount":100}`), ReceiptHandle: aws.String("r2")},
+		}}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("ProvisionedThroughputExceededException")).Once()
+	// Without a failures table or DLQ the terminal failure is still dropped
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.failuresByClass.WithLabelValues(failureTerminal, "test")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.failuresByClass.WithLabelValues(failureRetryable, "test")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue")))
+}
+
+func TestPollAndProcess_CountsReceivedAndEmptyPolls(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:        mockSQS,
+		ddbClient:        mockDDB,
+		queueURL:         "test-queue",
+		tableName:        "Orders",
+		ordersProcessed:  NewCounterVec(),
+		messagesReceived: newMessagesReceivedCounter(),
+		emptyPolls:       newEmptyPollsCounter(),
+		environment:      "test",
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(3)}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Twice()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Times(3)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	for range 3 {
+		_, err := proc.pollAndProcess(context.Background())
+		assert.NoError(t, err)
+	}
+
+	mockSQS.AssertExpectations(t)
+	assert.Equal(t, 3.0, testutil.ToFloat64(proc.messagesReceived.WithLabelValues("test", "test-queue")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(proc.emptyPolls.WithLabelValues("test", "test-queue")))
+}
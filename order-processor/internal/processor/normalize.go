@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// normalizableFields maps the JSON names of the order's string fields to
+// accessors, so casing rules can be configured by field name.
+var normalizableFields = map[string]func(*Order) *string{
+	"order_id":        func(o *Order) *string { return &o.OrderID },
+	"user_id":         func(o *Order) *string { return &o.UserID },
+	"status":          func(o *Order) *string { return &o.Status },
+	"attachment_url":  func(o *Order) *string { return &o.AttachmentURL },
+	"parent_order_id": func(o *Order) *string { return &o.ParentOrderID },
+}
+
+// orderNormalization canonicalizes string fields after parsing, before any
+// validation sees them. The zero value leaves orders untouched.
+type orderNormalization struct {
+	// trim strips leading and trailing whitespace from every string field
+	trim bool
+	// lower and upper list fields (by JSON name) to case-fold
+	lower []string
+	upper []string
+}
+
+// newOrderNormalization drops unknown field names, and fields listed for
+// both casings, with a warning.
+func newOrderNormalization(trim bool, lower, upper []string) orderNormalization {
+	known := func(fields []string, other []string) []string {
+		var out []string
+		for _, f := range fields {
+			switch {
+			case normalizableFields[f] == nil:
+				log.Warn().Str("field", f).Msg("unknown order field in normalization config, ignoring")
+			case slices.Contains(other, f):
+				log.Warn().Str("field", f).Msg("order field configured for both lower and upper case, ignoring")
+			default:
+				out = append(out, f)
+			}
+		}
+		return out
+	}
+	return orderNormalization{trim: trim, lower: known(lower, upper), upper: known(upper, lower)}
+}
+
+// apply normalizes order in place.
+func (n orderNormalization) apply(order *Order) {
+	if n.trim {
+		for _, field := range normalizableFields {
+			v := field(order)
+			*v = strings.TrimSpace(*v)
+		}
+	}
+	for _, name := range n.lower {
+		v := normalizableFields[name](order)
+		*v = strings.ToLower(*v)
+	}
+	for _, name := range n.upper {
+		v := normalizableFields[name](order)
+		*v = strings.ToUpper(*v)
+	}
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandleMessage_StoresNormalizedFields(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		ddbClient:       mockDDB,
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		normalization:   newOrderNormalization(true, []string{"user_id"}, []string{"order_id"}),
+	}
+
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "ORD-1"}, input.Item["order_id"])
+		assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "alice"}, input.Item["user_id"])
+		return true
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	err := proc.handleMessage(t.Context(), stypes.Message{
+		Body: aws.String(`{"order_id":" ord-1\t","user_id":"  Alice ","amount":100}`),
+	})
+
+	assert.NoError(t, err)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestHandleMessage_WhitespaceOrderIDRejectedAfterTrim(t *testing.T) {
+	proc := &Processor{
+		ordersProcessed: NewCounterVec(),
+		normalization:   orderNormalization{trim: true},
+	}
+
+	err := proc.handleMessage(t.Context(), stypes.Message{Body: aws.String(`{"order_id":"   "}`)})
+
+	var vErr *ValidationError
+	assert.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "missing_order_id", vErr.Type)
+}
+
+func TestOrderNormalization(t *testing.T) {
+	tests := []struct {
+		name string
+		norm orderNormalization
+		in   Order
+		want Order
+	}{
+		{
+			name: "zero value leaves order untouched",
+			in:   Order{OrderID: " o1 ", UserID: "U1"},
+			want: Order{OrderID: " o1 ", UserID: "U1"},
+		},
+		{
+			name: "trim only",
+			norm: newOrderNormalization(true, nil, nil),
+			in:   Order{OrderID: " o1 ", UserID: "U1\n", AttachmentURL: " https://x/y "},
+			want: Order{OrderID: "o1", UserID: "U1", AttachmentURL: "https://x/y"},
+		},
+		{
+			name: "unknown and conflicting fields ignored",
+			norm: newOrderNormalization(false, []string{"nope", "user_id", "status"}, []string{"status"}),
+			in:   Order{UserID: "MiXeD", Status: "Pending"},
+			want: Order{UserID: "mixed", Status: "Pending"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := tt.in
+			tt.norm.apply(&order)
+			assert.Equal(t, tt.want, order)
+		})
+	}
+}
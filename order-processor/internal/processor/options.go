@@ -0,0 +1,133 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// configLoader loads the AWS config, as config.LoadDefaultConfig does.
+type configLoader func(context.Context, ...func(*config.LoadOptions) error) (aws.Config, error)
+
+// Option overrides part of the configuration NewProcessor otherwise reads
+// from the environment.
+type Option func(*options)
+
+// options holds what NewProcessor needs before anything else is built.
+// Unset fields fall back to the environment.
+type options struct {
+	queueURL string
+	// queueName is resolved to queueURL at startup when that is unset
+	queueName string
+	// queueURLs are further queues polled alongside queueURL
+	queueURLs   []string
+	tableName   string
+	environment string
+	region      string
+	// Injected clients are used as they are; when both are set the AWS
+	// config is only loaded if something else needs it
+	sqsClient sqsClientI
+	ddbClient ddbClientI
+	// registry nil gives the processor a registry of its own
+	registry *prometheus.Registry
+	// configLoader nil loads the AWS config with config.LoadDefaultConfig;
+	// tests set a stub so no files, environment or credentials are read
+	configLoader configLoader
+}
+
+// optionsFromEnv applies opts over the environment and defaults.
+func optionsFromEnv(opts []Option) options {
+	o := options{
+		queueURL:    os.Getenv(envSQSQueueURL),
+		queueName:   os.Getenv(envSQSQueueName),
+		queueURLs:   envList(envSQSQueueURLs, nil),
+		tableName:   os.Getenv(envDDBTable),
+		environment: envString(envEnvironment, defaultEnvironment),
+		region:      envString(envAWSRegion, defaultRegion),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithQueueURL sets the queue polled for orders, instead of SQS_QUEUE_URL.
+func WithQueueURL(url string) Option {
+	return func(o *options) { o.queueURL = url }
+}
+
+// WithQueueName sets the name of the queue polled for orders, instead of
+// SQS_QUEUE_NAME. NewProcessor resolves it to a URL unless one is set too.
+func WithQueueName(name string) Option {
+	return func(o *options) { o.queueName = name }
+}
+
+// WithQueueURLs sets further queues polled alongside the queue, instead
+// of SQS_QUEUE_URLS.
+func WithQueueURLs(urls ...string) Option {
+	return func(o *options) { o.queueURLs = urls }
+}
+
+// queues returns every queue to poll, queueURL first, without repeats.
+func (o options) queues() []string {
+	var urls []string
+	for _, url := range append([]string{o.queueURL}, o.queueURLs...) {
+		if url != "" && !slices.Contains(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// WithTableName sets the orders table, instead of DDB_TABLE.
+func WithTableName(table string) Option {
+	return func(o *options) { o.tableName = table }
+}
+
+// WithEnvironment sets the env metric label, instead of ENVIRONMENT.
+func WithEnvironment(environment string) Option {
+	return func(o *options) { o.environment = environment }
+}
+
+// WithRegion sets the AWS region, instead of AWS_REGION.
+func WithRegion(region string) Option {
+	return func(o *options) { o.region = region }
+}
+
+// WithSQSClient sets the SQS client, such as an *sqs.Client or a fake,
+// instead of building one from the AWS config.
+func WithSQSClient(client sqsClientI) Option {
+	return func(o *options) { o.sqsClient = client }
+}
+
+// WithDDBClient sets the DynamoDB client, such as a *dynamodb.Client or a
+// fake, instead of building one from the AWS config.
+func WithDDBClient(client ddbClientI) Option {
+	return func(o *options) { o.ddbClient = client }
+}
+
+// WithRegistry registers the processor's collectors on registry instead
+// of a new registry of its own. Processors sharing a registry share their
+// collectors, as they have the same names; NewProcessor fails if registry
+// holds a different collector under one of those names.
+func WithRegistry(registry *prometheus.Registry) Option {
+	return func(o *options) { o.registry = registry }
+}
+
+// withConfigLoader sets what loads the AWS config, instead of
+// config.LoadDefaultConfig.
+func withConfigLoader(load configLoader) Option {
+	return func(o *options) { o.configLoader = load }
+}
+
+// loadConfig loads the AWS config with o's configLoader.
+func (o options) loadConfig(ctx context.Context, opts ...func(*config.LoadOptions) error) (aws.Config, error) {
+	if o.configLoader == nil {
+		return config.LoadDefaultConfig(ctx, opts...)
+	}
+	return o.configLoader(ctx, opts...)
+}
@@ -0,0 +1,269 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv(envSQSQueueURL, "env-queue")
+	t.Setenv(envDDBTable, "EnvOrders")
+	t.Setenv(envEnvironment, "staging")
+	t.Setenv(envAWSRegion, "eu-west-1")
+
+	assert.Equal(t, options{
+		queueURL:    "env-queue",
+		tableName:   "EnvOrders",
+		environment: "staging",
+		region:      "eu-west-1",
+	}, optionsFromEnv(nil))
+
+	// Options win over the environment
+	o := optionsFromEnv([]Option{WithQueueURL("opt-queue"), WithRegion("ap-south-1")})
+	assert.Equal(t, "opt-queue", o.queueURL)
+	assert.Equal(t, "EnvOrders", o.tableName)
+	assert.Equal(t, "ap-south-1", o.region)
+}
+
+func TestNewProcessor_WithOptions(t *testing.T) {
+	// Nothing comes from the environment, and with both clients injected
+	// the AWS config is never loaded
+	for _, name := range []string{envSQSQueueURL, envDDBTable, envEnvironment, envReportBucket, envOTLPEndpoint} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent/config")
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc, err := NewProcessor(context.Background(),
+		WithQueueURL("test-queue"),
+		WithTableName("Orders"),
+		WithEnvironment("test"),
+		WithRegion("eu-west-1"),
+		WithSQSClient(mockSQS),
+		WithDDBClient(mockDDB),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, "test-queue", proc.queueURL)
+	assert.Equal(t, "Orders", proc.tableName)
+	assert.Equal(t, "test", proc.environment)
+	assert.Same(t, mockSQS, proc.sqsClient)
+	assert.Same(t, mockDDB, proc.ddbClient)
+	assert.Nil(t, proc.decrypter)
+}
+
+func TestNewProcessor_CanBeCalledRepeatedly(t *testing.T) {
+	t.Setenv(envReportBucket, "")
+	t.Setenv(envOTLPEndpoint, "")
+	newProc := func(opts ...Option) *Processor {
+		proc, err := NewProcessor(context.Background(), append([]Option{
+			WithQueueURL("test-queue"),
+			WithTableName("Orders"),
+			WithSQSClient(&MockSQSClient{}),
+			WithDDBClient(&MockDynamoDBClient{}),
+		}, opts...)...)
+		require.NoError(t, err)
+		return proc
+	}
+
+	first, second := newProc(), newProc()
+	assert.NotSame(t, first.registry, second.registry)
+	assert.Nil(t, first.metricsServer, "the metrics server only starts with StartMetricsServer")
+
+	registry := prometheus.NewRegistry()
+	shared := newProc(WithRegistry(registry))
+	assert.Same(t, registry, shared.registry)
+	// A second processor on the registry reuses the collectors
+	again := newProc(WithRegistry(registry))
+	assert.Same(t, shared.ordersProcessed, again.ordersProcessed)
+	assert.Same(t, shared.failuresByClass, again.failuresByClass)
+}
+
+func TestNewProcessor_RegistrationConflictIsAnError(t *testing.T) {
+	t.Setenv(envReportBucket, "")
+	t.Setenv(envOTLPEndpoint, "")
+
+	// Same name, different labels
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "orders_processed_total", Help: "Total number of orders processed"},
+		[]string{"status"},
+	))
+
+	var err error
+	assert.NotPanics(t, func() {
+		_, err = NewProcessor(context.Background(),
+			WithQueueURL("test-queue"),
+			WithTableName("Orders"),
+			WithSQSClient(&MockSQSClient{}),
+			WithDDBClient(&MockDynamoDBClient{}),
+			WithRegistry(registry),
+		)
+	})
+	assert.ErrorContains(t, err, "register metrics")
+}
+
+func TestNewProcessor_RequiresQueueAndTable(t *testing.T) {
+	t.Setenv(envSQSQueueURL, "")
+	t.Setenv(envSQSQueueName, "")
+	t.Setenv(envDDBTable, "")
+
+	_, err := NewProcessor(context.Background())
+	assert.ErrorIs(t, err, ErrMissingQueueURL)
+
+	_, err = NewProcessor(context.Background(), WithQueueURL("test-queue"))
+	assert.ErrorIs(t, err, ErrMissingTableName)
+}
+
+func TestNewProcessor_ResolvesQueueName(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockSQS.On("GetQueueUrl", mock.Anything, mock.MatchedBy(func(input *sqs.GetQueueUrlInput) bool {
+		return aws.ToString(input.QueueName) == "orders"
+	})).Return(&sqs.GetQueueUrlOutput{QueueUrl: aws.String("https://sqs.eu-west-1.amazonaws.com/123456789012/orders")}, nil).Once()
+
+	proc, err := NewProcessor(context.Background(),
+		WithQueueURL(""),
+		WithQueueName("orders"),
+		WithTableName("Orders"),
+		WithSQSClient(mockSQS),
+		WithDDBClient(&MockDynamoDBClient{}),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789012/orders", proc.queueURL)
+	mockSQS.AssertExpectations(t)
+}
+
+func TestNewProcessor_QueueURLPreferredToName(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+
+	proc, err := NewProcessor(context.Background(),
+		WithQueueURL("test-queue"),
+		WithQueueName("orders"),
+		WithTableName("Orders"),
+		WithSQSClient(mockSQS),
+		WithDDBClient(&MockDynamoDBClient{}),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, "test-queue", proc.queueURL)
+	mockSQS.AssertNotCalled(t, "GetQueueUrl", mock.Anything, mock.Anything)
+}
+
+func TestNewProcessor_UnresolvedQueueNameFails(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockSQS.On("GetQueueUrl", mock.Anything, mock.Anything).
+		Return((*sqs.GetQueueUrlOutput)(nil), errors.New("AWS.SimpleQueueService.NonExistentQueue")).Once()
+
+	_, err := NewProcessor(context.Background(),
+		WithQueueURL(""),
+		WithQueueName("missing"),
+		WithTableName("Orders"),
+		WithSQSClient(mockSQS),
+		WithDDBClient(&MockDynamoDBClient{}),
+	)
+
+	assert.ErrorContains(t, err, `resolve SQS_QUEUE_NAME "missing": AWS.SimpleQueueService.NonExistentQueue`)
+}
+
+func TestNewProcessor_BuildsClientsFromStubConfig(t *testing.T) {
+	for _, name := range []string{envSQSQueueURL, envDDBTable, envEnvironment, envReportBucket, envOTLPEndpoint} {
+		t.Setenv(name, "")
+	}
+	var loaded config.LoadOptions
+	stub := func(_ context.Context, opts ...func(*config.LoadOptions) error) (aws.Config, error) {
+		for _, opt := range opts {
+			require.NoError(t, opt(&loaded))
+		}
+		return aws.Config{Region: loaded.Region}, nil
+	}
+
+	proc, err := NewProcessor(context.Background(),
+		WithQueueURL("test-queue"),
+		WithTableName("Orders"),
+		WithRegion("eu-west-1"),
+		withConfigLoader(stub),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", loaded.Region)
+	assert.IsType(t, &sqs.Client{}, proc.sqsClient)
+	assert.IsType(t, &dynamodb.Client{}, proc.ddbClient)
+}
+
+func TestNewProcessor_ConfigLoaderErrorFails(t *testing.T) {
+	stub := func(context.Context, ...func(*config.LoadOptions) error) (aws.Config, error) {
+		return aws.Config{}, errors.New("no credentials")
+	}
+
+	_, err := NewProcessor(context.Background(),
+		WithQueueURL("test-queue"),
+		WithTableName("Orders"),
+		withConfigLoader(stub),
+	)
+
+	assert.EqualError(t, err, "failed to load AWS config: no credentials")
+}
+
+func TestNewProcessor_DisableIMDS(t *testing.T) {
+	for _, name := range []string{envSQSQueueURL, envDDBTable, envEnvironment, envReportBucket, envOTLPEndpoint, envAWSEndpoint, "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv(envDisableIMDS, "true")
+	noCredentials := errors.New("failed to refresh cached credentials, no EC2 IMDS role found")
+	var loaded config.LoadOptions
+	stub := func(_ context.Context, opts ...func(*config.LoadOptions) error) (aws.Config, error) {
+		for _, opt := range opts {
+			require.NoError(t, opt(&loaded))
+		}
+		return aws.Config{Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{}, noCredentials
+		})}, nil
+	}
+
+	_, err := NewProcessor(context.Background(),
+		WithQueueURL("test-queue"),
+		WithTableName("Orders"),
+		withConfigLoader(stub),
+	)
+
+	// Startup fails on the missing credentials rather than on first use
+	assert.Equal(t, imds.ClientDisabled, loaded.EC2IMDSClientEnableState)
+	assert.ErrorIs(t, err, noCredentials)
+	assert.ErrorContains(t, err, "no AWS credentials found with DISABLE_IMDS set")
+}
+
+func TestNewProcessor_DisableIMDSWithStaticCredentials(t *testing.T) {
+	for _, name := range []string{envSQSQueueURL, envDDBTable, envEnvironment, envReportBucket, envOTLPEndpoint, envAWSEndpoint} {
+		t.Setenv(name, "")
+	}
+	t.Setenv(envDisableIMDS, "true")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	stub := func(context.Context, ...func(*config.LoadOptions) error) (aws.Config, error) {
+		return aws.Config{Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			t.Error("static credentials need no lookup")
+			return aws.Credentials{}, nil
+		})}, nil
+	}
+
+	_, err := NewProcessor(context.Background(),
+		WithQueueURL("test-queue"),
+		WithTableName("Orders"),
+		withConfigLoader(stub),
+	)
+
+	assert.NoError(t, err)
+}
@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pacer is a leaky bucket between receiving and processing: however large
+// a received burst is, messages leak out to the workers one per interval.
+// Idle time earns no credit, so a burst after a quiet period is paced too.
+// A nil *pacer releases messages immediately.
+type pacer struct {
+	interval time.Duration
+	clock    Clock
+
+	mu sync.Mutex
+	// next is the earliest time the next message may be released
+	next time.Time
+}
+
+// newPacer returns a pacer releasing rate messages per second.
+func newPacer(rate float64, clock Clock) *pacer {
+	return &pacer{
+		interval: time.Duration(float64(time.Second) / rate),
+		clock:    clock,
+	}
+}
+
+// wait blocks until the caller's message may be released or ctx ends. A
+// release slot is reserved even if ctx ends while waiting for it.
+func (pc *pacer) wait(ctx context.Context) error {
+	if pc == nil {
+		return ctx.Err()
+	}
+
+	pc.mu.Lock()
+	now := pc.clock.Now()
+	slot := pc.next
+	if slot.Before(now) {
+		slot = now
+	}
+	pc.next = slot.Add(pc.interval)
+	pc.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-pc.clock.After(delay):
+		return nil
+	}
+}
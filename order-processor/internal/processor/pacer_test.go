@@ -0,0 +1,155 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock advances only when waited on: After moves the clock forward by
+// d and fires at once. With block set, After never fires instead.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+	block bool
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if !c.block {
+		c.now = c.now.Add(d)
+		ch <- c.now
+	}
+	return ch
+}
+
+// pollWaits returns the waits on c before shutdown, which may add the
+// drain timeout of a poller it catches mid-batch.
+func (c *fakeClock) pollWaits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n := len(c.waits); n > 0 && c.waits[n-1] == defaultShutdownTimeout {
+		return c.waits[:n-1]
+	}
+	return c.waits
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestPacer_ReleasesBurstAtSteadyRate(t *testing.T) {
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	pc := newPacer(10, clock)
+
+	var released []time.Duration
+	for range 5 {
+		require.NoError(t, pc.wait(context.Background()))
+		released = append(released, clock.Now().Sub(start))
+	}
+
+	assert.Equal(t, []time.Duration{
+		0, 100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 400 * time.Millisecond,
+	}, released)
+}
+
+func TestPacer_IdleTimeEarnsNoBurst(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	pc := newPacer(4, clock)
+
+	require.NoError(t, pc.wait(context.Background()))
+	clock.advance(10 * time.Second)
+	for range 3 {
+		require.NoError(t, pc.wait(context.Background()))
+	}
+
+	// Only the first message after the quiet period goes out at once
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, 250 * time.Millisecond}, clock.waits)
+}
+
+func TestPacer_WaitRespectsCancellation(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC), block: true}
+	pc := newPacer(1, clock)
+	require.NoError(t, pc.wait(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- pc.wait(ctx) }()
+	cancel()
+
+	select {
+	case err := <-errc:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("wait did not return after cancellation")
+	}
+}
+
+func TestPollAndProcess_PacesBatch(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		pacer:           newPacer(5, clock),
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(4)}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Times(4)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "r2", "r3")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	mockDDB.AssertExpectations(t)
+	mockSQS.AssertExpectations(t)
+	assert.Equal(t, []time.Duration{200 * time.Millisecond, 200 * time.Millisecond, 200 * time.Millisecond}, clock.waits)
+}
+
+func TestPacer_CapsThroughputAcrossWorkers(t *testing.T) {
+	pc := newPacer(50, realClock{})
+
+	// 11 releases at 50/s take at least 10 intervals, however many
+	// workers ask at once
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 11 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, pc.wait(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
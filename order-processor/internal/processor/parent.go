@@ -0,0 +1,46 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// checkParent verifies that a sub-order's parent is already stored. A
+// missing parent is retryable: it may still be queued or in flight, and
+// the sub-order is written once a redelivery finds it. A parent belongs to
+// the sub-order's user and tenant, which templated keys and tables need.
+func (p *Processor) checkParent(ctx context.Context, order Order) error {
+	if !p.parentCheck || order.ParentOrderID == "" {
+		return nil
+	}
+	if order.ParentOrderID == order.OrderID {
+		return &ValidationError{
+			Type:    "invalid_parent",
+			OrderID: order.OrderID,
+			Err:     errors.New("order cannot be its own parent"),
+		}
+	}
+
+	table, err := p.orderTable(order)
+	if err != nil {
+		return err
+	}
+	out, err := p.ddbClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:                &table,
+		Key:                      p.tableKey(Order{OrderID: order.ParentOrderID, UserID: order.UserID, TenantID: order.TenantID}),
+		ProjectionExpression:     aws.String("#pk"),
+		ExpressionAttributeNames: map[string]string{"#pk": p.partitionKeyAttr()},
+		ConsistentRead:           aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to look up parent order: %w", err)
+	}
+	if len(out.Item) == 0 {
+		return fmt.Errorf("parent order %q not found yet", order.ParentOrderID)
+	}
+	return nil
+}
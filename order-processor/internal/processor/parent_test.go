@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newParentCheckProcessor(mockSQS *MockSQSClient, mockDDB *MockDynamoDBClient) *Processor {
+	return &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		parentCheck:     true,
+	}
+}
+
+func subOrderMessage() stypes.Message {
+	return stypes.Message{
+		MessageId:     aws.String("msg-123"),
+		Body:          aws.String(`{"order_id":"o1-a","parent_order_id":"o1","user_id":"u1","amount":100}`),
+		ReceiptHandle: aws.String("r1"),
+	}
+}
+
+func parentLookup(id string) interface{} {
+	return mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+		return *input.TableName == "Orders" &&
+			input.Key["order_id"].(*dtypes.AttributeValueMemberS).Value == id
+	})
+}
+
+func TestPollAndProcess_ParentPresentProcessed(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newParentCheckProcessor(mockSQS, mockDDB)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{subOrderMessage()}}, nil)
+	mockDDB.On("GetItem", mock.Anything, parentLookup("o1")).
+		Return(&dynamodb.GetItemOutput{Item: map[string]dtypes.AttributeValue{
+			"order_id": &dtypes.AttributeValueMemberS{Value: "o1"},
+		}}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, &dtypes.AttributeValueMemberS{Value: "o1"}, input.Item["parent_order_id"])
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestPollAndProcess_ParentAbsentRetried(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newParentCheckProcessor(mockSQS, mockDDB)
+	proc.failuresTable = "Failures"
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{subOrderMessage()}}, nil)
+	mockDDB.On("GetItem", mock.Anything, parentLookup("o1")).
+		Return(&dynamodb.GetItemOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	// Neither written, recorded as a failure, nor deleted
+	mockDDB.AssertExpectations(t)
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue")))
+}
+
+func TestCheckParent_SelfReferenceRejected(t *testing.T) {
+	proc := &Processor{parentCheck: true}
+
+	err := proc.checkParent(context.Background(), Order{OrderID: "o1", ParentOrderID: "o1"})
+
+	var vErr *ValidationError
+	assert.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "invalid_parent", vErr.Type)
+}
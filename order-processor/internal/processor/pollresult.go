@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// PollResult is the outcome of one poll, for callers that want it without
+// reading the metrics.
+type PollResult struct {
+	// Received is how many messages SQS returned
+	Received int
+	// Succeeded is how many messages were handled, including duplicates
+	// and messages superseded by another in the batch
+	Succeeded int
+	// Failed is how many failed, terminally or to be retried. Messages cut
+	// short by shutdown are neither succeeded nor failed
+	Failed int
+	// Deleted is how many were deleted from the queue
+	Deleted int
+}
+
+// pollTally counts a poll's outcomes as its workers report them.
+type pollTally struct {
+	succeeded atomic.Int64
+	failed    atomic.Int64
+	deleted   atomic.Int64
+}
+
+// pollTallyKey carries the *pollTally of the poll a message belongs to.
+type pollTallyKey struct{}
+
+func withPollTally(ctx context.Context, t *pollTally) context.Context {
+	return context.WithValue(ctx, pollTallyKey{}, t)
+}
+
+// tallyOf returns the tally of the poll ctx belongs to; nil outside a
+// poll, which counts nothing.
+func tallyOf(ctx context.Context) *pollTally {
+	t, _ := ctx.Value(pollTallyKey{}).(*pollTally)
+	return t
+}
+
+func (t *pollTally) addSucceeded(n int) {
+	if t != nil {
+		t.succeeded.Add(int64(n))
+	}
+}
+
+func (t *pollTally) addFailed(n int) {
+	if t != nil {
+		t.failed.Add(int64(n))
+	}
+}
+
+func (t *pollTally) addDeleted(n int) {
+	if t != nil {
+		t.deleted.Add(int64(n))
+	}
+}
+
+// result returns the tallied outcomes of a poll that received received
+// messages.
+func (t *pollTally) result(received int) PollResult {
+	return PollResult{
+		Received:  received,
+		Succeeded: int(t.succeeded.Load()),
+		Failed:    int(t.failed.Load()),
+		Deleted:   int(t.deleted.Load()),
+	}
+}
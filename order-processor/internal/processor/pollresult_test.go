@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollAndProcess_ReturnsResult(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			bodyMessage("m1", `{"order_id":"o1","user_id":"u1","amount":100}`),
+			bodyMessage("m2", `{"order_id":"o2","user_id":"u1","amount":100}`),
+			bodyMessage("m3", `not json`),
+		}}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return input.Item["order_id"].(*dtypes.AttributeValueMemberS).Value == "o1"
+	})).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("ProvisionedThroughputExceededException")).Once()
+	// The stored and the invalid message are deleted; SQS fails one of them
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r-m1", "r-m3")).
+		Return(&sqs.DeleteMessageBatchOutput{
+			Successful: []stypes.DeleteMessageBatchResultEntry{{Id: aws.String("0")}},
+			Failed:     []stypes.BatchResultErrorEntry{{Id: aws.String("1"), Code: aws.String("InternalError")}},
+		}, nil).Once()
+
+	result, err := proc.pollAndProcess(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, PollResult{Received: 3, Succeeded: 1, Failed: 2, Deleted: 1}, result)
+	mockSQS.AssertExpectations(t)
+}
+
+func TestPollAndProcess_EmptyPollResult(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{sqsClient: mockSQS, queueURL: "test-queue", ordersProcessed: NewCounterVec()}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+
+	result, err := proc.pollAndProcess(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, PollResult{}, result)
+}
@@ -2,114 +2,653 @@ package processor
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	// Default AWS region for LocalStack or development
 	defaultRegion = "us-east-1"
 
-	// SQS polling configuration
+	// SQS polling defaults, overridable via SQS_MAX_MESSAGES,
+	// SQS_WAIT_TIME_SECONDS and SQS_VISIBILITY_TIMEOUT
 	maxMessagesPerPoll = 5
 	waitTimeSeconds    = 10
 	visibilityTimeout  = 60
 
-	// Retry configuration
-	pollRetryDelay = 2 * time.Second
+	// Retry configuration: failed polls back off exponentially from
+	// pollRetryDelay up to POLL_MAX_RETRY_DELAY
+	pollRetryDelay           = 2 * time.Second
+	defaultPollMaxRetryDelay = time.Minute
 
 	// Metrics server configuration
-	metricsPort   = ":9090"
-	metricsPath   = "/metrics"
-	healthPath    = "/health"
-	readinessPath = "/ready"
+	defaultMetricsAddr = ":9090"
+	metricsPath        = "/metrics"
+	healthPath         = "/health"
+	readinessPath      = "/ready"
+	configPath         = "/config"
 
 	// Order status
-	orderStatusProcessed = "PROCESSED"
+	orderStatusProcessed     = "PROCESSED"
+	orderStatusPendingReview = "PENDING_REVIEW"
 
 	// Environment variable names
-	envAWSEndpoint  = "AWS_ENDPOINT_URL"
-	envSQSQueueURL  = "SQS_QUEUE_URL"
-	envDDBTable     = "DDB_TABLE"
-	envEnvironment  = "ENVIRONMENT"
-	envAWSRegion    = "AWS_REGION"
-	envAWSAccessKey = "AWS_ACCESS_KEY_ID"
-	envAWSSecretKey = "AWS_SECRET_ACCESS_KEY"
+	envAWSEndpoint          = "AWS_ENDPOINT_URL"
+	envSQSQueueURL          = "SQS_QUEUE_URL"
+	envSQSQueueURLs         = "SQS_QUEUE_URLS"
+	envSQSQueueName         = "SQS_QUEUE_NAME"
+	envPollersPerQueue      = "POLLERS_PER_QUEUE"
+	envDDBTable             = "DDB_TABLE"
+	envDDBTableTemplate     = "DDB_TABLE_TEMPLATE"
+	envFailuresTable        = "FAILURES_TABLE"
+	envEnvironment          = "ENVIRONMENT"
+	envAWSRegion            = "AWS_REGION"
+	envAWSAccessKey         = "AWS_ACCESS_KEY_ID"
+	envAWSSecretKey         = "AWS_SECRET_ACCESS_KEY"
+	envUseFIPS              = "USE_FIPS_ENDPOINT"
+	envUseDualStack         = "USE_DUALSTACK_ENDPOINT"
+	envDisableIMDS          = "DISABLE_IMDS"
+	envAWSMaxRetries        = "AWS_MAX_RETRIES"
+	envAWSHTTPTimeout       = "AWS_HTTP_TIMEOUT"
+	envEventsTopicARN       = "EVENTS_TOPIC_ARN"
+	envBatchBody            = "BATCH_BODY"
+	envMaxBodyBytes         = "MAX_BODY_BYTES"
+	envMessageFormat        = "MESSAGE_FORMAT"
+	envTraceSampleRate      = "TRACE_SAMPLE_RATE"
+	envOTLPEndpoint         = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	envSQSWarmUp            = "SQS_WARMUP"
+	envMetricsAddr          = "METRICS_ADDR"
+	envHealthStaleness      = "HEALTH_STALENESS"
+	envAuditTrail           = "AUDIT_TRAIL"
+	envProcessorID          = "PROCESSOR_ID"
+	envBatchConflictField   = "BATCH_CONFLICT_FIELD"
+	envAmountMin            = "AMOUNT_MIN"
+	envAmountMax            = "AMOUNT_MAX"
+	envAttachments          = "ATTACHMENTS_ENABLED"
+	envAttachmentBytes      = "ATTACHMENT_MAX_BYTES"
+	envAttachmentTypes      = "ATTACHMENT_ALLOWED_TYPES"
+	envAttachmentHosts      = "ATTACHMENT_ALLOWED_HOSTS"
+	envShutdownPhaseTimeout = "SHUTDOWN_PHASE_TIMEOUT"
+	envShutdownTimeout      = "SHUTDOWN_TIMEOUT"
+	envContentDedupWindow   = "CONTENT_DEDUP_WINDOW"
+	envContentDedupEntries  = "CONTENT_DEDUP_MAX_ENTRIES"
+	envDedupIDTemplate      = "DEDUP_ID_TEMPLATE"
+	envDedupIDRepublish     = "DEDUP_ID_REPUBLISH"
+	envSQSFIFO              = "SQS_FIFO"
+	envItemsTable           = "ITEMS_TABLE"
+	envItemMaxAttempts      = "ITEM_MAX_ATTEMPTS"
+	envReferenceTable       = "REFERENCE_TABLE"
+	envReferenceKey         = "REFERENCE_KEY"
+	envReferenceFields      = "REFERENCE_FIELDS"
+	envReferenceCacheTTL    = "REFERENCE_CACHE_TTL"
+	envSchemaRegistryURL    = "SCHEMA_REGISTRY_URL"
+	envShadowMode           = "SHADOW_MODE"
+	envShadowTable          = "SHADOW_TABLE"
+	envDryRun               = "DRY_RUN"
+	envReviewThreshold      = "REVIEW_THRESHOLD"
+	envHandleTimeout        = "HANDLE_TIMEOUT"
+	envReadOnly             = "READONLY"
+	envAdminToken           = "ADMIN_TOKEN"
+	envMetricsAuthToken     = "METRICS_AUTH_TOKEN"
+	envBatchRetryBudget     = "BATCH_RETRY_BUDGET"
+	envDLQURL               = "DLQ_QUEUE_URL"
+	envRedriveMax           = "REDRIVE_MAX"
+	envRedriveBatchSize     = "REDRIVE_BATCH_SIZE"
+	envNormalizeTrim        = "NORMALIZE_TRIM"
+	envNormalizeLower       = "NORMALIZE_LOWERCASE_FIELDS"
+	envNormalizeUpper       = "NORMALIZE_UPPERCASE_FIELDS"
+	envKillSwitchTable      = "KILL_SWITCH_TABLE"
+	envKillSwitchKey        = "KILL_SWITCH_KEY"
+	envKillSwitchInterval   = "KILL_SWITCH_INTERVAL"
+	envWALEnabled           = "WAL_ENABLED"
+	envWALFile              = "WAL_FILE"
+	envWALTable             = "WAL_TABLE"
+	envWorkers              = "PROCESSOR_WORKERS"
+	envParentCheck          = "PARENT_CHECK_ENABLED"
+	envBatchWrite           = "DDB_BATCH_WRITE"
+	envWriteRetries         = "DDB_WRITE_RETRIES"
+	envReportBucket         = "REPORT_S3_BUCKET"
+	envReportPrefix         = "REPORT_S3_PREFIX"
+	envReportInterval       = "REPORT_INTERVAL"
+	envSQSMaxMessages       = "SQS_MAX_MESSAGES"
+	envSQSWaitTime          = "SQS_WAIT_TIME_SECONDS"
+	envSQSVisibility        = "SQS_VISIBILITY_TIMEOUT"
+	envSQSReceiveTarget     = "SQS_RECEIVE_TARGET"
+	envPacerRate            = "PACER_RATE"
+	envAllowZeroAmount      = "ALLOW_ZERO_AMOUNT"
+	envEncryptedTypes       = "ENCRYPTED_CONTENT_TYPES"
+	envKMSKeyID             = "KMS_KEY_ID"
+	envSQSKMSKeyID          = "SQS_KMS_KEY_ID"
+	envSNSEnvelope          = "SNS_ENVELOPE"
+	envExchangeRateURL      = "EXCHANGE_RATE_URL"
+	envExchangeRateTTL      = "EXCHANGE_RATE_TTL"
+	envTableStatusInterval  = "TABLE_STATUS_INTERVAL"
+	envQueueDepthInterval   = "QUEUE_DEPTH_INTERVAL"
+	envPollMaxRetryDelay    = "POLL_MAX_RETRY_DELAY"
+	envEmptyPollBackoff     = "EMPTY_POLL_BACKOFF"
+	envEmptyPollsBefore     = "EMPTY_POLL_BACKOFF_AFTER"
+	envReceiveBreakerFails  = "RECEIVE_BREAKER_THRESHOLD"
+	envReceiveBreakerWait   = "RECEIVE_BREAKER_COOLDOWN"
+	envWriteBreakerFails    = "WRITE_BREAKER_THRESHOLD"
+	envWriteBreakerWait     = "WRITE_BREAKER_COOLDOWN"
+	envRetryQueueSize       = "RETRY_QUEUE_SIZE"
+	envRetryQueueBackoff    = "RETRY_QUEUE_BACKOFF"
+	envMetricsBackend       = "METRICS_BACKEND"
+	envStatsDAddr           = "STATSD_ADDR"
+	envKeyStrategy          = "KEY_STRATEGY"
+	envKeyShards            = "KEY_SHARDS"
+	envKeyShardPrefix       = "KEY_SHARD_PREFIX"
+	envPartitionKeyAttr     = "DDB_PARTITION_KEY"
+	envSortKeyAttr          = "DDB_SORT_KEY"
+	envPKTemplate           = "DDB_PK_TEMPLATE"
+	envSKTemplate           = "DDB_SK_TEMPLATE"
+	envItemMaxBytes         = "ITEM_MAX_BYTES"
+	envOversizeMode         = "OVERSIZE_MODE"
+	envOversizeBucket       = "OVERSIZE_S3_BUCKET"
+	envOversizePrefix       = "OVERSIZE_S3_PREFIX"
 
 	// Default environment for metrics
 	defaultEnvironment = "local"
 )
 
 var (
-	// ErrMissingQueueURL is returned when SQS_QUEUE_URL is not set
-	ErrMissingQueueURL = errors.New("SQS_QUEUE_URL environment variable is required")
+	// ErrMissingQueueURL is returned when neither SQS_QUEUE_URL nor
+	// SQS_QUEUE_NAME is set
+	ErrMissingQueueURL = errors.New("SQS_QUEUE_URL or SQS_QUEUE_NAME environment variable is required")
 	// ErrMissingTableName is returned when DDB_TABLE is not set
 	ErrMissingTableName = errors.New("DDB_TABLE environment variable is required")
+	// ErrUncleanShutdown is joined to Start's error when a shutdown phase
+	// failed
+	ErrUncleanShutdown = errors.New("unclean shutdown")
 )
 
+// ValidationError is returned for messages that can never be processed
+// successfully, no matter how often they are redelivered.
+type ValidationError struct {
+	// Type is a short machine-readable reason, e.g. "invalid_json"
+	Type string
+	// Rule is the OrderValidator that failed the order, if any
+	Rule string
+	// OrderID is set when the order was parsed far enough to know it
+	OrderID string
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
 type Order struct {
-	OrderID string `json:"order_id" dynamodbav:"order_id"`
-	UserID  string `json:"user_id" dynamodbav:"user_id"`
-	Amount  int    `json:"amount" dynamodbav:"amount"`
-	Status  string `json:"status" dynamodbav:"status"`
+	OrderID string  `json:"order_id" dynamodbav:"order_id"`
+	UserID  string  `json:"user_id" dynamodbav:"user_id"`
+	Amount  Decimal `json:"amount" dynamodbav:"amount"`
+	Status  string  `json:"status" dynamodbav:"status"`
+
+	// TenantID picks the tenant's table when DDB_TABLE_TEMPLATE is set
+	TenantID string `json:"tenant_id,omitempty" dynamodbav:"tenant_id,omitempty"`
+
+	// Currency is the ISO 4217 code of Amount, USD unless given;
+	// AmountUSD holds Amount converted to USD when exchange rate
+	// enrichment is enabled
+	Currency  string  `json:"currency" dynamodbav:"currency"`
+	AmountUSD Decimal `json:"-" dynamodbav:"amount_usd,omitempty"`
+
+	// CreatedAt is when the order was placed, RFC 3339 in the message and
+	// the processing time if absent; ProcessedAt is when it was stored
+	CreatedAt   time.Time `json:"created_at" dynamodbav:"created_at"`
+	ProcessedAt time.Time `json:"-" dynamodbav:"processed_at"`
+
+	// ParentOrderID links a sub-order to its parent order
+	ParentOrderID string `json:"parent_order_id,omitempty" dynamodbav:"parent_order_id,omitempty"`
+
+	// AttachmentURL optionally points at a receipt; Attachment holds the
+	// metadata recorded after fetching and validating it.
+	AttachmentURL string              `json:"attachment_url,omitempty" dynamodbav:"attachment_url,omitempty"`
+	Attachment    *AttachmentMetadata `json:"-" dynamodbav:"attachment,omitempty"`
+
+	// OffloadedTo is the s3:// URI of the full enriched order when its
+	// enrichment was left out of the item for size (see checkItemSize)
+	OffloadedTo string `json:"-" dynamodbav:"offloaded_to,omitempty"`
+
+	// DedupID is derived from the order's fields when DEDUP_ID_TEMPLATE is
+	// set (see dedupIDTemplate)
+	DedupID string `json:"-" dynamodbav:"dedup_id,omitempty"`
+
+	// Items are the order's line items. They are stored with the order
+	// unless ITEMS_TABLE is set, which stores each on its own (see
+	// lineItemStore)
+	Items []OrderItem `json:"items,omitempty" dynamodbav:"items,omitempty"`
+
+	// SourceSystem is the system that produced the order, from the
+	// message's source_system attribute
+	SourceSystem string `json:"-" dynamodbav:"source_system,omitempty"`
+}
+
+// receiveOptions are the ReceiveMessage parameters used on every poll.
+type receiveOptions struct {
+	maxMessages int32
+	// waitTimeSeconds 0 short-polls. The SDK leaves a zero out of the
+	// request, so the queue's ReceiveMessageWaitTimeSeconds applies,
+	// which is 0 unless the queue is set up for long polling
+	waitTimeSeconds   int32
+	visibilityTimeout int32
+}
+
+var defaultReceiveOptions = receiveOptions{
+	maxMessages:       maxMessagesPerPoll,
+	waitTimeSeconds:   waitTimeSeconds,
+	visibilityTimeout: visibilityTimeout,
+}
+
+// receiveOptions returns the ReceiveMessage parameters to poll with.
+func (p *Processor) receiveOptions() receiveOptions {
+	if p.receive != nil {
+		return *p.receive
+	}
+	return defaultReceiveOptions
+}
+
+// receiveOptionsFromEnv reads the SQS polling overrides, keeping the
+// default for any value that is unset or outside the range SQS accepts.
+func receiveOptionsFromEnv() receiveOptions {
+	return receiveOptions{
+		maxMessages:       int32(envInt64(envSQSMaxMessages, maxMessagesPerPoll, 1, 10)),
+		waitTimeSeconds:   int32(envInt64(envSQSWaitTime, waitTimeSeconds, 0, 20)),
+		visibilityTimeout: int32(envInt64(envSQSVisibility, visibilityTimeout, 0, 43200)),
+	}
 }
 
 type sqsClientI interface {
 	ReceiveMessage(context.Context, *sqs.ReceiveMessageInput, ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
 	DeleteMessage(context.Context, *sqs.DeleteMessageInput, ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	SendMessage(context.Context, *sqs.SendMessageInput, ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	DeleteMessageBatch(context.Context, *sqs.DeleteMessageBatchInput, ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+	GetQueueAttributes(context.Context, *sqs.GetQueueAttributesInput, ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+	GetQueueUrl(context.Context, *sqs.GetQueueUrlInput, ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error)
 }
 
 type ddbClientI interface {
 	PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	GetItem(context.Context, *dynamodb.GetItemInput, ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	BatchGetItem(context.Context, *dynamodb.BatchGetItemInput, ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(context.Context, *dynamodb.BatchWriteItemInput, ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	DescribeTable(context.Context, *dynamodb.DescribeTableInput, ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	UpdateItem(context.Context, *dynamodb.UpdateItemInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
 }
 
 type Processor struct {
-	sqsClient       sqsClientI
-	ddbClient       ddbClientI
-	queueURL        string
-	tableName       string
+	sqsClient sqsClientI
+	ddbClient ddbClientI
+	queueURL  string
+	tableName string
+	// keys is how orders are keyed in tableName
+	keys            keyLayout
 	ordersProcessed *prometheus.CounterVec
 	environment     string
-	metricsServer   *http.Server
+	// region, awsEndpoint and credentialsSource describe the AWS setup
+	// on /config; no credential itself is kept
+	region            string
+	awsEndpoint       string
+	credentialsSource string
+	// registry holds the processor's collectors, served on /metrics by
+	// StartMetricsServer along with health, readiness and, when adminToken
+	// is set, the admin endpoints
+	registry   *prometheus.Registry
+	adminToken string
+	// metricsToken, when set, is the bearer token /metrics requires
+	metricsToken string
+	// metricsAddr is the address StartMetricsServer listens on; empty
+	// means defaultMetricsAddr
+	metricsAddr   string
+	metricsServer *http.Server
+	// failuresByClass counts failures as terminal or retryable
+	failuresByClass *prometheus.CounterVec
+	// deleteFailures counts messages left on the queue by failed deletes
+	deleteFailures *prometheus.CounterVec
+	// messagesReceived and emptyPolls count received messages and the
+	// polls that received none
+	messagesReceived *prometheus.CounterVec
+	emptyPolls       *prometheus.CounterVec
+	// messageAge observes how long each received message was on the
+	// queue; nil disables it
+	messageAge *prometheus.HistogramVec
+	// validationFailures counts orders rejected by each validation rule
+	validationFailures *prometheus.CounterVec
+	// statsd, when set by METRICS_BACKEND=statsd, receives the order
+	// counters and timers as well
+	statsd *statsdClient
+	// failuresTable is an optional DynamoDB table receiving a record for
+	// every message rejected as invalid; empty disables failure records.
+	failuresTable string
+	// clock stamps orders, attachments, failure records and lifecycle
+	// events and times retry waits and backoffs; nil uses the system
+	// clock (see WithClock)
+	clock Clock
+	// tracer starts poll, order, write and delete spans, order spans
+	// sampled at traceSampleRate using randFloat (math/rand when nil), which
+	// also jitters poll retries. It exports over OTLP when
+	// OTEL_EXPORTER_OTLP_ENDPOINT is set and otherwise comes from the
+	// global OTel TracerProvider, so spans are no-ops unless main installs
+	// one.
+	tracer          trace.Tracer
+	traceSampleRate float64
+	randFloat       func() float64
+	// amountRange restricts accepted amounts; nil accepts defaultAmountRange
+	amountRange *amountRange
+	// allowZeroAmount accepts orders with an amount of exactly 0;
+	// negative amounts are always rejected
+	allowZeroAmount bool
+	// receive overrides the ReceiveMessage parameters; nil uses
+	// defaultReceiveOptions
+	receive *receiveOptions
+	// receiveTarget is how many messages a poll receives, over several
+	// receives when more than one receive's worth (see receiveN); 0
+	// receives once
+	receiveTarget int
+	// rateProvider converts amounts to USD; nil disables conversion
+	rateProvider RateProvider
+	// decrypter decrypts bodies whose content-type message attribute is
+	// one of encryptedContentTypes, or every body with decryptAll; other
+	// bodies are used as they are
+	decrypter             Decrypter
+	encryptedContentTypes []string
+	decryptAll            bool
+	// snsEnvelope unwraps bodies delivered by SNS (see unwrapSNS)
+	snsEnvelope bool
+	// pacer smooths received bursts to a steady release rate; nil hands
+	// messages to the workers as fast as they take them
+	pacer *pacer
+	// attachmentFetcher is nil unless attachment processing is enabled
+	attachmentFetcher AttachmentFetcher
+	attachmentPolicy  attachmentPolicy
+	// inFlight tracks handlers still running, drained during shutdown;
+	// inFlightGauge counts the messages they are processing
+	inFlight      sync.WaitGroup
+	inFlightGauge prometheus.Gauge
+	// sinks are flushed during shutdown, after handlers have drained
+	sinks []Flusher
+	// retries, when set, holds retryably failed messages to be retried
+	// ahead of fresh ones
+	retries *retryQueue
+	// pollMaxRetryDelay caps the backoff between failed polls; 0 means
+	// defaultPollMaxRetryDelay
+	pollMaxRetryDelay time.Duration
+	// emptyPollBackoff caps the sleep added once emptyPollsBeforeBackoff
+	// polls in a row are empty (see emptyPollBackoff); 0 disables it
+	emptyPollBackoff        time.Duration
+	emptyPollsBeforeBackoff int
+	// lastPollSuccess is when the poll loop last made progress, in Unix
+	// nanoseconds; /health fails once it is older than healthStaleness
+	lastPollSuccess atomic.Int64
+	healthStaleness time.Duration
+	// receiveBreaker stops receiving after consecutive ReceiveMessage
+	// failures; nil disables it
+	receiveBreaker *circuitBreaker
+	// lifecycle lets Stop end Start
+	lifecycle lifecycle
+	// shutdownPhaseTimeout bounds each shutdown phase
+	shutdownPhaseTimeout time.Duration
+	// shutdownTimeout bounds how long the batch in hand at shutdown may
+	// keep running before it is abandoned
+	shutdownTimeout time.Duration
+	// dedupID, when set, derives each order's DedupID, which then keys
+	// content dedup in place of the body hash
+	dedupID *dedupIDTemplate
+	// dedupIDRepublish makes messages re-published to a FIFO DLQ carry
+	// the derived dedup ID
+	dedupIDRepublish bool
+	// contentDedup skips bodies already stored within its window; nil
+	// disables content-hash dedup
+	contentDedup *contentDedup
+	// readOnly pauses receiving, writes and deletes; see SetReadOnly
+	readOnly      atomic.Bool
+	readOnlyGauge prometheus.Gauge
+	// phaseDuration times the parse/validate/enrich/store phases of each
+	// order; nil disables phase timing
+	phaseDuration *prometheus.HistogramVec
+	// processingDuration times each message end to end by outcome; nil
+	// disables it
+	processingDuration *prometheus.HistogramVec
+	// batchRetryBudget is how often a batch in which every message fails
+	// may be redelivered before it is moved to dlqURL; 0 disables it
+	batchRetryBudget int
+	dlqURL           string
+	// redriveMax and redriveBatchSize bound a Redrive run and its DLQ
+	// receives; 0 means the defaults
+	redriveMax       int
+	redriveBatchSize int
+	// normalization is applied to every order right after parsing
+	normalization orderNormalization
+	// killSwitch, when set, is checked every killSwitchInterval; while it
+	// is engaged polling stops and readiness reports not ready
+	killSwitch         KillSwitch
+	killSwitchInterval time.Duration
+	killSwitchEngaged  atomic.Bool
+	// readinessProbe, when set, makes readiness checks probe SQS and
+	// DynamoDB (see unreachableDependency)
+	readinessProbe *dependencyProbe
+	// tableStatusInterval, when positive, is how often the orders table's
+	// status is checked; processing waits for the table at startup and
+	// pauses while tableInactive is set
+	tableStatusInterval time.Duration
+	tableInactive       atomic.Bool
+	// queueDepthInterval, when positive, is how often queueDepthGauge is
+	// refreshed with each polled queue's approximate depth
+	queueDepthInterval time.Duration
+	queueDepthGauge    *prometheus.GaugeVec
+	// conflictField, when set, is the order field deciding which of several
+	// messages for one order ID in a batch is applied (see
+	// resolveConflicts)
+	conflictField string
+	// handler receives each prepared order; nil means DynamoOrderHandler
+	handler OrderHandler
+	// validators are rules run after the built-in ones (see
+	// orderValidators)
+	validators []OrderValidator
+	// itemSize, when set, handles orders too large for the table once
+	// enriched
+	itemSize *itemSizePolicy
+	// analytics, when set, receives each stored order after the handler;
+	// analyticsWrites counts its writes by result
+	analytics       AnalyticsSink
+	analyticsWrites *prometheus.CounterVec
+	// events, when set, is told of each stored order after the handler;
+	// eventsPublished counts its publishes by result
+	events          EventPublisher
+	eventsPublished *prometheus.CounterVec
+	// audit, when set, appends an entry to each order's audit_trail as it
+	// is stored or found already stored
+	audit *auditTrail
+	// sqsWarmUp makes a GetQueueAttributes call before the first poll (see
+	// warmUp)
+	sqsWarmUp bool
+	// wal records each message's received/processed/deleted lifecycle;
+	// nil unless WAL_ENABLED is set
+	wal LifecycleLog
+	// workerCount is how many messages of a batch are processed at once,
+	// adjustable at runtime via SetConcurrency; values below 1 mean 1
+	workerCount      atomic.Int64
+	concurrencyGauge prometheus.Gauge
+	// deleted holds the receipt handles deleted during the current poll
+	deleted deletedHandles
+	// inFlightIDs holds the messages being processed by every poller
+	inFlightIDs inFlightMessages
+	// pollersPerQueue is how many pollers receive from each queue at once
+	pollersPerQueue int
+	// references, when set, must know every ID in the order's
+	// referenceFields (see checkReferences)
+	references      ReferenceStore
+	referenceFields []string
+	// shadow writes to the shadow table and leaves every message on the
+	// queue (see WithShadowTable)
+	shadow bool
+	// handleTimeout, when positive, bounds the handling of each message
+	// (see withHandleTimeout)
+	handleTimeout time.Duration
+	// reviewThreshold, when set, is the amount above which orders are
+	// stored as PENDING_REVIEW (see orderStatus); ordersByStatus counts
+	// stored orders by status
+	reviewThreshold *Decimal
+	ordersByStatus  *prometheus.CounterVec
+	// tenantTables, when set, routes orders with a tenant ID to their
+	// tenant's table instead of tableName (see orderTable)
+	tenantTables *tenantTables
+	// dryRun validates orders without storing them and leaves every
+	// message on the queue (see WithDryRun)
+	dryRun bool
+	// contracts, when set, validates bodies against the registered schema
+	// their schema_id attribute names (see checkContract)
+	contracts *contractValidator
+	// decoder, when set, decodes bodies in place of the JSON schemas (see
+	// WithDecoder)
+	decoder Decoder
+	// parentCheck requires a sub-order's parent to be stored before the
+	// sub-order is written
+	parentCheck bool
+	// batchWrite stores all orders of a poll with one BatchWriteItem call
+	// instead of a PutItem per order
+	batchWrite bool
+	// batchBody reads each message body as JSON lines, one order per
+	// line (see handleBatchBody)
+	batchBody bool
+	// maxBodyBytes bounds the plaintext body parsed; 0 means no bound
+	maxBodyBytes int
+	// writeRetries is how many times a throttled or server-failed order
+	// PutItem is repeated (see putItemWithRetry)
+	writeRetries int
+	// writeBreaker stops order PutItems after consecutive failures of
+	// DynamoDB itself; nil disables it
+	writeBreaker *circuitBreaker
+	// lineItems, when set, stores line items one by one in their own
+	// table; orderItems counts those writes by result
+	lineItems  *lineItemStore
+	orderItems *prometheus.CounterVec
+	// fifo processes the messages of a MessageGroupId in order, one at a
+	// time, and only the groups of a batch in parallel
+	fifo bool
+	// queues, when set, are all the queues polled, one poller each;
+	// otherwise only queueURL is
+	queues []*sourceQueue
+	// stats feeds the processing report; report is nil unless
+	// REPORT_S3_BUCKET is set, and is also uploaded every reportInterval
+	// when that is positive
+	stats          *processingStats
+	report         *reportSink
+	reportInterval time.Duration
 }
 
-func NewProcessor(ctx context.Context) (*Processor, error) {
-	queueURL := os.Getenv(envSQSQueueURL)
-	if queueURL == "" {
+// NewProcessor builds a Processor configured from the environment, with
+// opts overriding the queue, table, environment, region and AWS clients.
+func NewProcessor(ctx context.Context, opts ...Option) (*Processor, error) {
+	o := optionsFromEnv(opts)
+	queueURLs := o.queues()
+	if len(queueURLs) == 0 && o.queueName == "" {
 		return nil, ErrMissingQueueURL
 	}
 
-	tableName := os.Getenv(envDDBTable)
+	tableName := o.tableName
 	if tableName == "" {
 		return nil, ErrMissingTableName
 	}
 
-	environment := os.Getenv(envEnvironment)
-	if environment == "" {
-		environment = defaultEnvironment
+	failuresTable := os.Getenv(envFailuresTable)
+
+	environment := o.environment
+
+	receive := receiveOptionsFromEnv()
+	clientOpts, err := clientOptionsFromEnv(receive)
+	if err != nil {
+		return nil, err
 	}
 
-	endpoint := os.Getenv(envAWSEndpoint)
-	region := os.Getenv(envAWSRegion)
-	if region == "" {
-		region = defaultRegion
+	amountBounds := amountRange{
+		min: envInt64(envAmountMin, defaultAmountRange.min, defaultAmountRange.min, defaultAmountRange.max),
+		max: envInt64(envAmountMax, defaultAmountRange.max, defaultAmountRange.min, defaultAmountRange.max),
+	}
+	if amountBounds.min > amountBounds.max {
+		log.Warn().
+			Int64("min", amountBounds.min).
+			Int64("max", amountBounds.max).
+			Msg("AMOUNT_MIN is greater than AMOUNT_MAX, ignoring configured amount range")
+		amountBounds = defaultAmountRange
+	}
+
+	policy := attachmentPolicy{
+		maxBytes:     envInt64(envAttachmentBytes, defaultAttachmentMaxBytes, 1, math.MaxInt64-1),
+		allowedTypes: envList(envAttachmentTypes, defaultAttachmentTypes),
+		allowedHosts: envList(envAttachmentHosts, nil),
+	}
+	for i, host := range policy.allowedHosts {
+		policy.allowedHosts[i] = strings.ToLower(host)
+	}
+	var fetcher AttachmentFetcher
+	if envBool(envAttachments, false) {
+		if len(policy.allowedHosts) == 0 {
+			log.Warn().Msg("ATTACHMENTS_ENABLED is set but ATTACHMENT_ALLOWED_HOSTS is empty - all attachments will be rejected")
+		}
+		fetcher = newHTTPAttachmentFetcher(policy.maxBytes)
+	}
+
+	var dedup *contentDedup
+	if window := envDuration(envContentDedupWindow, 0, 0, 24*time.Hour); window > 0 {
+		dedup = newContentDedup(window, int(envInt64(envContentDedupEntries, defaultContentDedupMaxEntries, 1, math.MaxInt32)))
+	}
+
+	dlqURL := os.Getenv(envDLQURL)
+	batchRetryBudget := int(envInt64(envBatchRetryBudget, 0, 0, math.MaxInt32))
+	if batchRetryBudget > 0 && dlqURL == "" {
+		log.Warn().Msg("BATCH_RETRY_BUDGET is set but DLQ_QUEUE_URL is empty - failing batches will not be dead-lettered")
 	}
 
+	var rates RateProvider
+	if url := os.Getenv(envExchangeRateURL); url != "" {
+		rates = newCachedRateProvider(newHTTPRateProvider(url), envDuration(envExchangeRateTTL, defaultExchangeRateTTL, time.Second, 24*time.Hour))
+	}
+
+	encryptedTypes := envList(envEncryptedTypes, defaultEncryptedContentTypes)
+	for i, ct := range encryptedTypes {
+		encryptedTypes[i] = strings.ToLower(ct)
+	}
+
+	var pace *pacer
+	if rate := envFloat(envPacerRate, 0, 0, 10000); rate > 0 {
+		pace = newPacer(rate, realClock{})
+	}
+
+	endpoint := os.Getenv(envAWSEndpoint)
+	region := o.region
+
 	// Get credentials - use static credentials for LocalStack, default chain for production
 	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
 	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
@@ -143,224 +682,1258 @@ func NewProcessor(ctx context.Context) (*Processor, error) {
 	}
 
 	// Load AWS config
-	cfgOpts := []func(*config.LoadOptions) error{
-		config.WithRegion(region),
+	endpointOpts := endpointOptions{
+		fips:      aws.FIPSEndpointStateUnset,
+		dualStack: aws.DualStackEndpointStateUnset,
 	}
-
-	// Only set credentials if we have static credentials
-	// Otherwise, use default credential chain (IAM roles, etc.)
-	if credsProvider != nil {
-		cfgOpts = append(cfgOpts, config.WithCredentialsProvider(credsProvider))
+	if useFIPS, ok := envOptionalBool(envUseFIPS); ok {
+		endpointOpts.fips = aws.FIPSEndpointStateDisabled
+		if useFIPS {
+			endpointOpts.fips = aws.FIPSEndpointStateEnabled
+		}
+	}
+	if useDualStack, ok := envOptionalBool(envUseDualStack); ok {
+		endpointOpts.dualStack = aws.DualStackEndpointStateDisabled
+		if useDualStack {
+			endpointOpts.dualStack = aws.DualStackEndpointStateEnabled
+		}
+	}
+	cfgOpts := append(awsConfigOptions(region, credsProvider, endpointOpts), clientOpts.configOptions()...)
+	// Off EC2 the credential chain's IMDS lookup only times out slowly;
+	// static credentials above never reach it
+	disableIMDS := envBool(envDisableIMDS, false)
+	if disableIMDS {
+		cfgOpts = append(cfgOpts, config.WithEC2IMDSClientEnableState(imds.ClientDisabled))
 	}
 
-	cfg, err := config.LoadDefaultConfig(ctx, cfgOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	// The AWS config is loaded on first use, so a processor given both
+	// clients never touches the credential chain unless a feature such as
+	// S3 reports needs it
+	var cfg *aws.Config
+	loadConfig := func() (aws.Config, error) {
+		if cfg == nil {
+			loaded, err := o.loadConfig(ctx, cfgOpts...)
+			if err != nil {
+				return aws.Config{}, fmt.Errorf("failed to load AWS config: %w", err)
+			}
+			// Credentials are otherwise only resolved on the first call;
+			// without IMDS a chain that finds none fails here instead
+			if disableIMDS && credsProvider == nil && loaded.Credentials != nil {
+				if _, err := loaded.Credentials.Retrieve(ctx); err != nil {
+					return aws.Config{}, fmt.Errorf("no AWS credentials found with %s set: %w", envDisableIMDS, err)
+				}
+			}
+			cfg = &loaded
+		}
+		return *cfg, nil
 	}
 
 	// Set custom endpoint for LocalStack using service-specific options
-	var sqsClient *sqs.Client
-	var ddbClient *dynamodb.Client
-	if endpoint != "" {
-		// Use BaseEndpoint option for service-specific endpoint resolution
-		sqsClient = sqs.NewFromConfig(cfg, func(o *sqs.Options) {
-			o.BaseEndpoint = aws.String(endpoint)
+	sqsClient, ddbClient := o.sqsClient, o.ddbClient
+	if sqsClient == nil || ddbClient == nil {
+		if _, err := loadConfig(); err != nil {
+			return nil, err
+		}
+	}
+	if sqsClient == nil {
+		sqsClient = sqs.NewFromConfig(*cfg, func(o *sqs.Options) {
+			if endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
+		})
+	}
+	if ddbClient == nil {
+		ddbClient = dynamodb.NewFromConfig(*cfg, func(o *dynamodb.Options) {
+			if endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
 		})
-		ddbClient = dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
-			o.BaseEndpoint = aws.String(endpoint)
+	}
+	// A queue known only by name is resolved now, so a wrong name or
+	// account fails at startup rather than on the first poll
+	if o.queueURL == "" && o.queueName != "" {
+		if o.queueURL, err = resolveQueueURL(ctx, sqsClient, o.queueName); err != nil {
+			return nil, err
+		}
+		queueURLs = o.queues()
+	}
+	queueURL := queueURLs[0]
+	// SQS_KMS_KEY_ID marks every body as encrypted under that key, so it
+	// needs a KMS client and the config to build one
+	kmsKeyID, decryptAll := os.Getenv(envKMSKeyID), false
+	if id := os.Getenv(envSQSKMSKeyID); id != "" {
+		if kmsKeyID != "" && kmsKeyID != id {
+			return nil, fmt.Errorf("%s and %s name different keys", envSQSKMSKeyID, envKMSKeyID)
+		}
+		kmsKeyID, decryptAll = id, true
+		if _, err := loadConfig(); err != nil {
+			return nil, err
+		}
+	}
+	// Without a loaded config there is no KMS client, and encrypted
+	// bodies fail as having no decrypter
+	var decrypter Decrypter
+	if cfg != nil {
+		kmsClient := kms.NewFromConfig(*cfg, func(o *kms.Options) {
+			if endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
 		})
-	} else {
-		sqsClient = sqs.NewFromConfig(cfg)
-		ddbClient = dynamodb.NewFromConfig(cfg)
+		decrypter = &kmsDecrypter{client: kmsClient, keyID: kmsKeyID}
 	}
 
-	ordersProcessed := prometheus.NewCounterVec(
+	var events EventPublisher
+	if topicARN := os.Getenv(envEventsTopicARN); topicARN != "" {
+		cfg, err := loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		if events, err = newSNSEventPublisher(cfg, topicARN, endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	// Collectors go on a registry per processor rather than the global
+	// one, so NewProcessor can be called more than once
+	registry := o.registry
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+	reg := &collectorRegistrar{registry: registry}
+	if o.registry == nil {
+		register(reg, collectors.NewGoCollector())
+		register(reg, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+	ordersProcessed := register(reg, prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "orders_processed_total",
 			Help: "Total number of orders processed",
 		},
-		[]string{"status", "env"},
+		[]string{"status", "env", "queue"},
+	))
+	processingDuration := register(reg, newProcessingDurationHistogram())
+	failuresByClass := register(reg, newFailuresCounter())
+	deleteFailures := register(reg, newDeleteFailuresCounter())
+	messagesReceived := register(reg, newMessagesReceivedCounter())
+	emptyPolls := register(reg, newEmptyPollsCounter())
+	messageAge := register(reg, newMessageAgeHistogram())
+	validationFailures := register(reg, newValidationFailuresCounter())
+	readOnlyGauge := register(reg, newReadOnlyGauge())
+	phaseDuration := register(reg, newPhaseDurationHistogram())
+	concurrencyGauge := register(reg, newConcurrencyGauge())
+	inFlightGauge := register(reg, newInFlightGauge())
+	receiveBreakerGauge := register(reg, newReceiveBreakerGauge())
+	writeBreakerGauge := register(reg, newWriteBreakerGauge())
+	analyticsWrites := register(reg, newAnalyticsWritesCounter())
+	eventsPublished := register(reg, newEventsPublishedCounter())
+	orderItems := register(reg, newOrderItemsCounter())
+	queueDepthGauge := register(reg, newQueueDepthGauge())
+	ordersByStatus := register(reg, newOrdersByStatusCounter())
+	if reg.err != nil {
+		return nil, reg.err
+	}
+
+	p := &Processor{
+		sqsClient:               sqsClient,
+		ddbClient:               ddbClient,
+		queueURL:                queueURL,
+		tableName:               tableName,
+		ordersProcessed:         ordersProcessed,
+		failuresByClass:         failuresByClass,
+		deleteFailures:          deleteFailures,
+		messagesReceived:        messagesReceived,
+		emptyPolls:              emptyPolls,
+		messageAge:              messageAge,
+		validationFailures:      validationFailures,
+		environment:             environment,
+		region:                  region,
+		awsEndpoint:             endpoint,
+		credentialsSource:       credentialsSource(credsProvider),
+		registry:                registry,
+		adminToken:              os.Getenv(envAdminToken),
+		metricsToken:            os.Getenv(envMetricsAuthToken),
+		failuresTable:           failuresTable,
+		tracer:                  otel.Tracer(tracerName),
+		traceSampleRate:         envFloat(envTraceSampleRate, defaultTraceSampleRate, 0, 1),
+		amountRange:             &amountBounds,
+		allowZeroAmount:         envBool(envAllowZeroAmount, false),
+		receive:                 &receive,
+		receiveTarget:           int(envInt64(envSQSReceiveTarget, 0, 0, maxReceiveTarget)),
+		pacer:                   pace,
+		rateProvider:            rates,
+		decrypter:               decrypter,
+		encryptedContentTypes:   encryptedTypes,
+		decryptAll:              decryptAll,
+		snsEnvelope:             envBool(envSNSEnvelope, false),
+		attachmentFetcher:       fetcher,
+		attachmentPolicy:        policy,
+		pollMaxRetryDelay:       envDuration(envPollMaxRetryDelay, defaultPollMaxRetryDelay, pollRetryDelay, time.Hour),
+		emptyPollBackoff:        envDuration(envEmptyPollBackoff, 0, 0, time.Hour),
+		emptyPollsBeforeBackoff: int(envInt64(envEmptyPollsBefore, defaultEmptyPollsBeforeBackoff, 1, 1000)),
+		shutdownPhaseTimeout:    envDuration(envShutdownPhaseTimeout, defaultShutdownPhaseTimeout, time.Millisecond, time.Hour),
+		shutdownTimeout:         envDuration(envShutdownTimeout, defaultShutdownTimeout, time.Millisecond, time.Hour),
+		handleTimeout:           envDuration(envHandleTimeout, defaultHandleTimeout, 0, time.Hour),
+		tableStatusInterval:     envDuration(envTableStatusInterval, defaultTableStatusInterval, 0, time.Hour),
+		queueDepthInterval:      envDuration(envQueueDepthInterval, defaultQueueDepthInterval, 0, time.Hour),
+		queueDepthGauge:         queueDepthGauge,
+		ordersByStatus:          ordersByStatus,
+		sqsWarmUp:               envBool(envSQSWarmUp, true),
+		healthStaleness:         envDuration(envHealthStaleness, defaultHealthStaleness, 0, 24*time.Hour),
+		conflictField:           os.Getenv(envBatchConflictField),
+		contentDedup:            dedup,
+		readOnlyGauge:           readOnlyGauge,
+		processingDuration:      processingDuration,
+		phaseDuration:           phaseDuration,
+		batchRetryBudget:        batchRetryBudget,
+		concurrencyGauge:        concurrencyGauge,
+		inFlightGauge:           inFlightGauge,
+		analyticsWrites:         analyticsWrites,
+		eventsPublished:         eventsPublished,
+		events:                  events,
+		orderItems:              orderItems,
+		readinessProbe:          &dependencyProbe{},
+		parentCheck:             envBool(envParentCheck, false),
+		batchWrite:              envBool(envBatchWrite, false),
+		batchBody:               envBool(envBatchBody, false),
+		maxBodyBytes:            int(envInt64(envMaxBodyBytes, defaultMaxBodyBytes, 0, 1<<30)),
+		writeRetries:            int(envInt64(envWriteRetries, defaultWriteRetries, 0, maxWriteRetries)),
+		fifo:                    isFIFOQueue(queueURL) || envBool(envSQSFIFO, false),
+		dlqURL:                  dlqURL,
+		redriveMax:              int(envInt64(envRedriveMax, defaultRedriveMax, 1, 1000000)),
+		redriveBatchSize:        int(envInt64(envRedriveBatchSize, defaultRedriveBatchSize, 1, sqsMaxBatch)),
+		normalization: newOrderNormalization(
+			envBool(envNormalizeTrim, true),
+			envList(envNormalizeLower, nil),
+			envList(envNormalizeUpper, nil),
+		),
+	}
+	p.SetReadOnly(envBool(envReadOnly, false))
+	if err := p.SetConcurrency(int(envInt64(envWorkers, minWorkerCount, minWorkerCount, maxWorkerCount))); err != nil {
+		return nil, err
+	}
+	keys, err := keyLayoutFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	if template := os.Getenv(envDedupIDTemplate); template != "" {
+		if p.dedupID, err = parseDedupIDTemplate(template); err != nil {
+			return nil, err
+		}
+		p.dedupIDRepublish = envBool(envDedupIDRepublish, false)
+	}
+	p.lifecycle.init()
+	if p.metricsAddr, err = metricsAddrFromEnv(); err != nil {
+		return nil, err
+	}
+	p.receiveBreaker = newReceiveBreaker(
+		int(envInt64(envReceiveBreakerFails, defaultReceiveBreakerThreshold, 0, 1000)),
+		envDuration(envReceiveBreakerWait, defaultReceiveBreakerCooldown, time.Second, time.Hour),
+		receiveBreakerGauge,
+	)
+	p.writeBreaker = newWriteBreaker(
+		int(envInt64(envWriteBreakerFails, defaultWriteBreakerThreshold, 0, 1000)),
+		envDuration(envWriteBreakerWait, defaultWriteBreakerCooldown, time.Second, time.Hour),
+		writeBreakerGauge,
 	)
-	prometheus.MustRegister(ordersProcessed)
+	if table := os.Getenv(envItemsTable); table != "" {
+		p.lineItems = newLineItemStore(table, int(envInt64(envItemMaxAttempts, defaultItemMaxAttempts, 1, maxItemMaxAttempts)))
+	}
+	if table := os.Getenv(envReferenceTable); table != "" {
+		store := &ddbReferenceStore{
+			client:  ddbClient,
+			table:   table,
+			keyAttr: envString(envReferenceKey, defaultReferenceKeyAttr),
+		}
+		p.references = newCachedReferenceStore(store, envDuration(envReferenceCacheTTL, defaultReferenceCacheTTL, 0, 24*time.Hour))
+		p.referenceFields = envList(envReferenceFields, []string{defaultReferenceFields})
+	}
+	if threshold := os.Getenv(envReviewThreshold); threshold != "" {
+		d, err := parseDecimal(threshold)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", envReviewThreshold, err)
+		}
+		p.reviewThreshold = &d
+	}
+	if template := os.Getenv(envDDBTableTemplate); template != "" {
+		if p.tenantTables, err = newTenantTables(template); err != nil {
+			return nil, err
+		}
+	}
+	if envBool(envShadowMode, false) {
+		table := os.Getenv(envShadowTable)
+		if table == "" {
+			return nil, fmt.Errorf("%s requires %s", envShadowMode, envShadowTable)
+		}
+		p.WithShadowTable(table)
+		log.Warn().Str("table", table).Msg("shadow mode - orders go to the shadow table and messages are never deleted")
+	}
+	if envBool(envDryRun, false) {
+		p.WithDryRun()
+		log.Warn().Msg("dry run - orders are validated but not stored, and messages are never deleted")
+	}
+	if p.decoder, err = decoderFor(p, envString(envMessageFormat, messageFormatJSON)); err != nil {
+		return nil, err
+	}
+	if url := os.Getenv(envSchemaRegistryURL); url != "" {
+		p.WithSchemaRegistry(newHTTPSchemaRegistry(url))
+	}
+	if envBool(envAuditTrail, false) {
+		p.audit = &auditTrail{processorID: envString(envProcessorID, defaultProcessorID())}
+	}
+	switch backend := envString(envMetricsBackend, metricsBackendPrometheus); backend {
+	case metricsBackendPrometheus:
+	case metricsBackendStatsD:
+		client, err := newStatsDClient(envString(envStatsDAddr, defaultStatsDAddr))
+		if err != nil {
+			return nil, err
+		}
+		p.statsd = client
+	default:
+		return nil, fmt.Errorf("unknown %s %q: want %s or %s", envMetricsBackend, backend, metricsBackendPrometheus, metricsBackendStatsD)
+	}
+	if size := envInt64(envRetryQueueSize, 0, 0, maxRetryQueueSize); size > 0 {
+		p.retries = newRetryQueue(int(size), envDuration(envRetryQueueBackoff, defaultRetryQueueBackoff, time.Millisecond, 15*time.Minute))
+	}
+	if table := os.Getenv(envKillSwitchTable); table != "" {
+		p.killSwitch = &ddbKillSwitch{
+			client: ddbClient,
+			table:  table,
+			key:    envString(envKillSwitchKey, defaultKillSwitchKey),
+		}
+		p.killSwitchInterval = envDuration(envKillSwitchInterval, defaultKillSwitchInterval, time.Second, time.Hour)
+	}
 
-	metricsServer := &http.Server{
-		Addr:    metricsPort,
-		Handler: http.DefaultServeMux,
+	if envBool(envWALEnabled, false) {
+		switch walFile, walTable := os.Getenv(envWALFile), os.Getenv(envWALTable); {
+		case walTable != "":
+			p.wal = &tableWAL{client: ddbClient, table: walTable}
+		case walFile != "":
+			fw, err := newFileWAL(walFile)
+			if err != nil {
+				return nil, err
+			}
+			p.wal = fw
+			p.sinks = append(p.sinks, fw)
+		default:
+			log.Warn().Msg("WAL_ENABLED is set but neither WAL_TABLE nor WAL_FILE is - lifecycle events will not be recorded")
+		}
 	}
 
-	http.Handle(metricsPath, promhttp.Handler())
+	newS3Client := func() (*s3.Client, error) {
+		cfg, err := loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		return s3.NewFromConfig(cfg, func(o *s3.Options) {
+			if endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+				// LocalStack does not serve virtual-hosted bucket names
+				o.UsePathStyle = true
+			}
+		}), nil
+	}
 
-	// Health check endpoint
-	http.HandleFunc(healthPath, func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte(`{"status":"healthy"}`)); err != nil {
-			log.Error().Err(err).Msg("failed to write health check response")
+	switch mode := envString(envOversizeMode, oversizeReject); mode {
+	case oversizeReject:
+		p.itemSize = &itemSizePolicy{mode: mode}
+	case oversizeS3Offload:
+		bucket := os.Getenv(envOversizeBucket)
+		if bucket == "" {
+			return nil, fmt.Errorf("%s=%s needs %s", envOversizeMode, mode, envOversizeBucket)
 		}
-	})
+		s3Client, err := newS3Client()
+		if err != nil {
+			return nil, err
+		}
+		p.itemSize = &itemSizePolicy{
+			mode:   mode,
+			putter: s3Client,
+			bucket: bucket,
+			prefix: os.Getenv(envOversizePrefix),
+		}
+	default:
+		return nil, fmt.Errorf("unknown %s %q: want %s or %s", envOversizeMode, mode, oversizeReject, oversizeS3Offload)
+	}
+	p.itemSize.maxBytes = int(envInt64(envItemMaxBytes, maxItemBytes, 1, maxItemBytes))
 
-	// Readiness check endpoint
-	http.HandleFunc(readinessPath, func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		// Check if required services are configured
-		if queueURL == "" || tableName == "" {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			if _, err := w.Write([]byte(`{"status":"not ready","reason":"missing configuration"}`)); err != nil {
-				log.Error().Err(err).Msg("failed to write readiness check response")
-			}
-			return
+	if bucket := os.Getenv(envReportBucket); bucket != "" {
+		s3Client, err := newS3Client()
+		if err != nil {
+			return nil, err
+		}
+		p.stats = newProcessingStats(p.now())
+		p.report = &reportSink{
+			putter:      s3Client,
+			bucket:      bucket,
+			prefix:      os.Getenv(envReportPrefix),
+			environment: environment,
+			stats:       p.stats,
+		}
+		p.reportInterval = envDuration(envReportInterval, 0, 0, 24*time.Hour)
+		p.sinks = append(p.sinks, p.report)
+	}
+
+	// Flushed last, so spans from the other sinks' shutdown are exported
+	if os.Getenv(envOTLPEndpoint) != "" {
+		provider, err := newTracerProvider(ctx)
+		if err != nil {
+			return nil, err
 		}
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte(`{"status":"ready"}`)); err != nil {
+		p.tracer = provider.Tracer(tracerName)
+		p.sinks = append(p.sinks, provider)
+	}
+
+	if len(queueURLs) > 1 {
+		p.addQueues(queueURLs[1:], envBool(envSQSFIFO, false))
+	}
+	p.pollersPerQueue = int(envInt64(envPollersPerQueue, 1, 1, maxPollersPerQueue))
+
+	return p, nil
+}
+
+// handleReady reports whether the processor should receive traffic.
+func (p *Processor) handleReady(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// Check if required services are configured
+	if p.queueURL == "" || p.tableName == "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if _, err := w.Write([]byte(`{"status":"not ready","reason":"missing configuration"}`)); err != nil {
 			log.Error().Err(err).Msg("failed to write readiness check response")
 		}
-	})
+		return
+	}
+	if p.killSwitchEngaged.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if _, err := w.Write([]byte(`{"status":"not ready","reason":"kill switch engaged"}`)); err != nil {
+			log.Error().Err(err).Msg("failed to write readiness check response")
+		}
+		return
+	}
+	if p.tableInactive.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if _, err := w.Write([]byte(`{"status":"not ready","reason":"table not active"}`)); err != nil {
+			log.Error().Err(err).Msg("failed to write readiness check response")
+		}
+		return
+	}
+	if dependency := p.unreachableDependency(r.Context()); dependency != "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		body := fmt.Sprintf(`{"status":"not ready","reason":"%s unreachable","dependency":%q}`, dependency, dependency)
+		if _, err := w.Write([]byte(body)); err != nil {
+			log.Error().Err(err).Msg("failed to write readiness check response")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(`{"status":"ready"}`)); err != nil {
+		log.Error().Err(err).Msg("failed to write readiness check response")
+	}
+}
 
+// endpointOptions selects regional endpoint variants for the AWS clients.
+type endpointOptions struct {
+	// Unset defers to the SDK's own AWS_USE_FIPS_ENDPOINT and
+	// AWS_USE_DUALSTACK_ENDPOINT resolution
+	fips      aws.FIPSEndpointState
+	dualStack aws.DualStackEndpointState
+}
+
+// awsConfigOptions builds the options passed to config.LoadDefaultConfig.
+func awsConfigOptions(region string, credsProvider aws.CredentialsProvider, endpointOpts endpointOptions) []func(*config.LoadOptions) error {
+	cfgOpts := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+	}
+
+	// Only set credentials if we have static credentials
+	// Otherwise, use default credential chain (IAM roles, etc.)
+	if credsProvider != nil {
+		cfgOpts = append(cfgOpts, config.WithCredentialsProvider(credsProvider))
+	}
+
+	// FIPS and dualstack only change how the SDK resolves regional endpoints;
+	// an explicit AWS_ENDPOINT_URL still takes precedence over both.
+	if endpointOpts.fips != aws.FIPSEndpointStateUnset {
+		cfgOpts = append(cfgOpts, config.WithUseFIPSEndpoint(endpointOpts.fips))
+	}
+	if endpointOpts.dualStack != aws.DualStackEndpointStateUnset {
+		cfgOpts = append(cfgOpts, config.WithUseDualStackEndpoint(endpointOpts.dualStack))
+	}
+
+	return cfgOpts
+}
+
+// Start polls until ctx is cancelled or Stop is called, then runs the
+// ordered shutdown sequence (see shutdownPhases) before returning
+// ctx.Err(), or the error that stopped polling. Failed shutdown phases,
+// such as the metrics server not shutting down, are joined to it as
+// ErrUncleanShutdown.
+func (p *Processor) Start(ctx context.Context) error {
+	ctx, finish := p.lifecycle.begin(ctx)
+	err := p.start(ctx)
+	finish(err)
+	return err
+}
+
+// start is Start under a context that Stop also ends.
+func (p *Processor) start(ctx context.Context) error {
+	if p.killSwitch != nil {
+		go p.watchKillSwitch(ctx, p.killSwitchInterval)
+	}
+	if p.report != nil && p.reportInterval > 0 {
+		go p.report.run(ctx, p.reportInterval)
+	}
+	if p.queueDepthGauge != nil && p.queueDepthInterval > 0 {
+		go p.watchQueueDepth(ctx, p.queueDepthInterval)
+	}
+
+	// The batches in hand when ctx ends are finished under workCtx, which
+	// outlives ctx until drainBatch gives up on them
+	workCtx, abandon := context.WithCancel(context.WithoutCancel(ctx))
+	defer abandon()
+
+	// Every queue has a poller of its own. The first fatal error stops
+	// them all, as ctx ending does, and is returned
+	pollCtx, stopPolling := context.WithCancel(ctx)
+	defer stopPolling()
+	fatal := make(chan error, 1)
+
+	pollerDone := make(chan struct{})
 	go func() {
-		log.Info().
-			Str("port", metricsPort).
-			Str("metrics_path", metricsPath).
-			Str("health_path", healthPath).
-			Str("readiness_path", readinessPath).
-			Msg("starting HTTP server for metrics and health checks")
-		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Error().Err(err).Msg("HTTP server failed")
+		defer close(pollerDone)
+		if p.tableStatusInterval > 0 {
+			if err := p.waitForTable(pollCtx); err != nil {
+				return
+			}
+			go p.watchTableStatus(pollCtx, p.tableStatusInterval)
 		}
+
+		var wg sync.WaitGroup
+		for _, q := range p.pollers() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				qCtx := withQueue(pollCtx, q)
+				if p.sqsWarmUp {
+					p.warmUp(qCtx)
+				}
+				if err := p.pollLoop(qCtx, withQueue(workCtx, q)); err != nil {
+					select {
+					case fatal <- err:
+						stopPolling()
+					default:
+					}
+				}
+			}()
+		}
+		wg.Wait()
 	}()
 
-	return &Processor{
-		sqsClient:       sqsClient,
-		ddbClient:       ddbClient,
-		queueURL:        queueURL,
-		tableName:       tableName,
-		ordersProcessed: ordersProcessed,
-		environment:     environment,
-		metricsServer:   metricsServer,
-	}, nil
+	<-pollCtx.Done()
+	p.drainBatch(pollerDone, abandon)
+
+	timeout := p.shutdownPhaseTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownPhaseTimeout
+	}
+	shutdownErr := runShutdown(p.shutdownPhases(pollerDone), timeout)
+
+	err := ctx.Err()
+	select {
+	case err = <-fatal:
+	default:
+	}
+	if shutdownErr != nil {
+		return errors.Join(err, fmt.Errorf("%w: %w", ErrUncleanShutdown, shutdownErr))
+	}
+	return err
 }
 
-func (p *Processor) Start(ctx context.Context) error {
-	defer p.shutdownMetricsServer()
+// pollLoop polls until ctx is cancelled, backing off after failed polls
+// and runs of empty ones (see emptyPollBackoff).
+// Each batch is handled under workCtx, so one received before ctx ends is
+// finished rather than cut short. It only returns an error when the queue
+// can never be polled (see fatalPollError).
+func (p *Processor) pollLoop(ctx, workCtx context.Context) error {
+	q := p.queueOf(ctx)
+	maxDelay := p.pollMaxRetryDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultPollMaxRetryDelay
+	}
+	backoff := &pollBackoff{max: maxDelay, random: p.random}
+	idle := newEmptyPollBackoff(p.emptyPollsBeforeBackoff, p.emptyPollBackoff, p.random)
+	// Staleness is measured from here, not from startup, which may wait
+	// for the table
+	p.markPollSuccess()
 
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return nil
 		default:
-			if err := p.pollAndProcess(ctx); err != nil {
-				log.Error().Err(err).Msg("poll failed")
+			if p.paused() {
+				p.markPollSuccess()
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-p.after(pollRetryDelay):
+					continue
+				}
+			}
+			result, err := p.pollBatch(ctx, workCtx)
+			if err == nil && result.Received > 0 {
+				log.Debug().
+					Str("queue", q.name).
+					Int("received", result.Received).
+					Int("succeeded", result.Succeeded).
+					Int("failed", result.Failed).
+					Int("deleted", result.Deleted).
+					Msg("poll finished")
+			}
+			if err != nil {
+				if interrupted(ctx, err) {
+					log.Info().Err(err).Msg("poll interrupted by shutdown")
+					return nil
+				}
+				if fatalPollError(err) {
+					log.Error().Str("queue", q.name).Err(err).Msg("queue cannot be polled - stopping")
+					return err
+				}
+				// An open breaker holds polling until its probe is due
+				delay := backoff.next()
+				if wait := q.breaker.retryIn(p.now()); wait > 0 {
+					delay = wait
+				}
+				log.Error().Str("queue", q.name).Err(err).Dur("retry_in", delay).Msg("poll failed")
 				select {
 				case <-ctx.Done():
-					return ctx.Err()
-				case <-time.After(pollRetryDelay):
+					return nil
+				case <-p.after(delay):
 					// Continue polling after delay
 				}
+				continue
+			}
+			backoff.reset()
+			if delay := idle.next(result.Received); delay > 0 {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-p.after(delay):
+				}
 			}
 		}
 	}
 }
 
-func (p *Processor) shutdownMetricsServer() {
-	if p.metricsServer != nil {
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+// pollAndProcess receives one batch and handles it, both under ctx.
+func (p *Processor) pollAndProcess(ctx context.Context) (PollResult, error) {
+	return p.pollBatch(ctx, ctx)
+}
+
+// pollBatch receives one batch under receiveCtx and handles and deletes it
+// under ctx. Once received, a batch only stops early when ctx ends.
+func (p *Processor) pollBatch(receiveCtx, ctx context.Context) (result PollResult, err error) {
+	defer func() {
+		if err == nil {
+			p.markPollSuccess()
+		}
+	}()
+	if p.paused() {
+		return PollResult{}, nil
+	}
+	q := p.queueOf(ctx)
+	if !q.breaker.allow(p.now()) {
+		return PollResult{}, errReceiveBreakerOpen
+	}
+	q.deleted.reset()
+
+	ctx, span := p.startPollSpan(ctx)
+	defer func() { endSpan(span, err) }()
+
+	// One receive's worth, unless SQS_RECEIVE_TARGET asks for more
+	n := max(p.receiveTarget, int(p.receiveOptions().maxMessages))
+	received, err := p.receiveN(withQueue(receiveCtx, q), n)
+	if err != nil {
+		return PollResult{}, err
+	}
+	span.SetAttributes(attribute.Int("messaging.batch.message_count", len(received)))
+	p.countReceived(ctx, len(received))
+	p.observeMessageAges(ctx, received)
+
+	tally := &pollTally{}
+	ctx = withBodyCache(withPollTally(ctx, tally))
 
-		if err := p.metricsServer.Shutdown(shutdownCtx); err != nil {
-			log.Error().Err(err).Msg("error shutting down metrics server")
+	// Retries whose backoff has elapsed go ahead of fresh messages
+	retries := q.retries.take(p.now(), received)
+	defer q.retries.release(retries)
+	messages := append(retries, received...)
+	// A message another poller is still processing is left to it; the
+	// receive that returned it again only made it visible later
+	messages, inFlight := p.inFlightIDs.claim(q.url, messages)
+	defer p.inFlightIDs.release(q.url, messages)
+	for _, msg := range inFlight {
+		log.Info().
+			Str("msg_id", aws.ToString(msg.MessageId)).
+			Str("queue", q.name).
+			Msg("message already in flight - skipping")
+		p.countOrder(ctx, "in_flight_duplicate")
+	}
+	if len(messages) == 0 {
+		return tally.result(len(received)), nil
+	}
+	messages, superseded := p.resolveConflicts(ctx, messages)
+	tally.addSucceeded(len(superseded))
+
+	batch := batchJobs(messages, q.fifo)
+	workers := min(p.concurrency(), len(batch))
+	jobs := make(chan []types.Message)
+
+	// done collects handled messages, deleted together once the batch is
+	// finished. failed collects messages left on the queue for a retry; if
+	// the whole batch ends up there it counts against the batch retry budget
+	var (
+		mu       sync.Mutex
+		done     []types.Message
+		failed   []types.Message
+		prepared []*preparedOrder
+		skipped  atomic.Bool
+		wg       sync.WaitGroup
+	)
+	done = append(done, superseded...)
+
+	// handle processes one message, reporting whether the rest of its
+	// FIFO group may follow
+	handle := func(msg types.Message) bool {
+		// A batch body is written in batches of its own
+		if p.batchWrite && !p.batchBody {
+			po, handled := p.prepareMessage(ctx, msg)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case po != nil:
+				prepared = append(prepared, po)
+			case handled:
+				done = append(done, msg)
+			default:
+				failed = append(failed, msg)
+				return false
+			}
+			return true
+		}
+
+		handled := p.processMessage(ctx, msg)
+		mu.Lock()
+		defer mu.Unlock()
+		if handled {
+			done = append(done, msg)
 		} else {
-			log.Info().Msg("metrics server shut down gracefully")
+			failed = append(failed, msg)
 		}
+		return handled
 	}
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				for i, msg := range job {
+					// A batch can be cancelled or paused part way
+					// through; the rest is redelivered after the
+					// visibility timeout
+					if ctx.Err() != nil || p.paused() {
+						skipped.Store(true)
+						break
+					}
+					// The first message of a job was paced when it was
+					// dispatched
+					if i > 0 && p.pacer.wait(ctx) != nil {
+						skipped.Store(true)
+						break
+					}
+					if !handle(msg) {
+						// Processing the rest of a FIFO group would put
+						// it out of order; it stays in flight and is
+						// redelivered after the failed message
+						mu.Lock()
+						failed = append(failed, job[i+1:]...)
+						mu.Unlock()
+						break
+					}
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, job := range batch {
+		if err := p.pacer.wait(ctx); err != nil {
+			skipped.Store(true)
+			break
+		}
+		select {
+		case jobs <- job:
+		case <-ctx.Done():
+			skipped.Store(true)
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if len(prepared) > 0 {
+		if ctx.Err() != nil || p.paused() {
+			skipped.Store(true)
+		} else {
+			written, notWritten := p.storePrepared(ctx, prepared)
+			done = append(done, written...)
+			failed = append(failed, notWritten...)
+		}
+	}
+
+	if err := p.deleteMessageBatch(ctx, done); err != nil {
+		log.Error().
+			Int("messages", len(done)).
+			Err(err).
+			Msg("failed to delete messages from queue - messages may be reprocessed")
+		// They become visible again after the visibility timeout
+	}
+
+	if skipped.Load() {
+		log.Warn().Msg("processing stopped mid-batch - remaining messages will be redelivered")
+		return tally.result(len(received)), nil
+	}
+	if len(failed) == len(messages) && p.batchBudgetExhausted(failed) {
+		q.retries.remove(failed)
+		p.deadLetterBatch(ctx, failed)
+	}
+
+	return tally.result(len(received)), nil
 }
 
-func (p *Processor) pollAndProcess(ctx context.Context) error {
-	out, err := p.sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
-		QueueUrl:            &p.queueURL,
-		MaxNumberOfMessages: int32(maxMessagesPerPoll),
-		WaitTimeSeconds:     int32(waitTimeSeconds),
-		VisibilityTimeout:   int32(visibilityTimeout),
+// processMessage handles one message, reporting whether it is done with
+// and should be deleted, or must stay on the queue to be redelivered. It
+// is called from several workers at once.
+func (p *Processor) processMessage(ctx context.Context, msg types.Message) bool {
+	ctx = withMessageLogger(ctx, msg)
+	p.recordLifecycle(ctx, msg, stageReceived)
+	if err := p.runHandler(ctx, msg); err != nil {
+		return p.handleFailure(ctx, msg, err)
+	}
+
+	p.recordLifecycle(ctx, msg, stageProcessed)
+	tallyOf(ctx).addSucceeded(1)
+	return true
+}
+
+// runHandler runs handleMessage with the message counted as in flight,
+// bounded by the handle timeout.
+func (p *Processor) runHandler(ctx context.Context, msg types.Message) error {
+	defer p.startInFlight(1)()
+
+	ctx, cancel := p.withHandleTimeout(ctx)
+	defer cancel()
+	return p.handleTimeoutError(ctx, p.handleMessage(ctx, msg))
+}
+
+// handleFailure counts and logs a failed message. Retryable failures are
+// left for a retry. Terminal (validation) failures are recorded when a
+// failures table is configured and dead-lettered when a DLQ is, then
+// dropped. Like processMessage it reports whether the message is done with
+// and should be deleted.
+func (p *Processor) handleFailure(ctx context.Context, msg types.Message, err error) bool {
+	// Work cut short by shutdown is not a failure; the message is simply
+	// redelivered
+	if interrupted(ctx, err) {
+		logger(ctx).Info().
+			Err(err).
+			Msg("processing interrupted - message will be redelivered")
+		return false
+	}
+
+	class := failureClass(err)
+	tallyOf(ctx).addFailed(1)
+	p.countOrder(ctx, "error")
+	p.countFailure(class)
+	p.stats.failure(err)
+	logger(ctx).Error().
+		Str("class", class).
+		Err(err).
+		Msg("failed to process message")
+
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) {
+		p.retryLater(ctx, msg)
+		return false
+	}
+	if p.failuresTable != "" {
+		if err := p.recordFailure(ctx, msg, vErr); err != nil {
+			logger(ctx).Error().
+				Err(err).
+				Msg("failed to write failure record - message will be retried")
+			return false
+		}
+	}
+	if p.dlqURL != "" && !p.deadLetterInvalid(ctx, msg, vErr) {
+		return false
+	}
+	// Redelivery cannot help, and the failure record or DLQ copy (if
+	// configured) holds everything needed for triage
+	return true
+}
+
+// interrupted reports whether err is ctx ending rather than a failure: ctx
+// is done and err, usually wrapped by the AWS SDK, is a cancellation or
+// deadline error.
+func interrupted(ctx context.Context, err error) bool {
+	return ctx.Err() != nil &&
+		(errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
+
+// deleteMessage deletes one message. Deleting a handle already deleted
+// this poll is a no-op.
+func (p *Processor) deleteMessage(ctx context.Context, msg types.Message) (err error) {
+	// The production processor deletes it, or nothing does in a dry run
+	if p.keepsMessages() {
+		return nil
+	}
+	q := p.queueOf(ctx)
+	handle := aws.ToString(msg.ReceiptHandle)
+	if !q.deleted.claim(handle) {
+		return nil
+	}
+
+	ctx, span := p.startChildSpan(ctx, "delete_message", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { endSpan(span, err) }()
+
+	_, err = p.sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      &q.url,
+		ReceiptHandle: msg.ReceiptHandle,
+	})
+	if err != nil {
+		q.deleted.release(handle)
+		p.countDeleteFailures(1)
+		return fmt.Errorf("delete message: %w", err)
+	}
+	p.recordLifecycle(ctx, msg, stageDeleted)
+	tallyOf(ctx).addDeleted(1)
+	return nil
+}
+
+// storeOrder writes the processed order to its table (see orderTable).
+func (p *Processor) storeOrder(ctx context.Context, order Order) (err error) {
+	table, err := p.orderTable(order)
+	if err != nil {
+		return err
+	}
+	ctx, span := p.startChildSpan(ctx, "put_order",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("aws.dynamodb.table_names", table)),
+	)
+	defer func() { endSpan(span, err) }()
+
+	item, err := p.orderItem(order)
+	if err != nil {
+		return err
+	}
+
+	if !p.writeBreaker.allow(p.now()) {
+		return errWriteBreakerOpen
+	}
+	// Never overwrite a stored order; a redelivered message fails the
+	// condition and is reported by isDuplicateWrite
+	_, err = p.putItemWithRetry(ctx, &dynamodb.PutItemInput{
+		TableName:                &table,
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{"#pk": p.partitionKeyAttr()},
 	})
+	// Only DynamoDB failing counts toward the breaker, not the order
+	// being refused
+	if !interrupted(ctx, err) {
+		p.writeBreaker.record(p.now(), writeBreakerFailure(err))
+	}
 	if err != nil {
-		return fmt.Errorf("receive message: %w", err)
+		return fmt.Errorf("failed to put item to DynamoDB: %w", err)
 	}
+	return nil
+}
 
-	if len(out.Messages) == 0 {
+// isDuplicateWrite reports whether err is the order handler finding the
+// order already handled: storeOrder's failed condition, or
+// ErrDuplicateOrder from another handler.
+func isDuplicateWrite(err error) bool {
+	var condErr *dtypes.ConditionalCheckFailedException
+	return errors.As(err, &condErr) || errors.Is(err, ErrDuplicateOrder)
+}
+
+// deleteMessageBatch deletes msgs with DeleteMessageBatch calls of up to
+// the 10 entries SQS accepts. Entries SQS fails to delete are logged and
+// become visible again after the visibility timeout.
+func (p *Processor) deleteMessageBatch(ctx context.Context, msgs []types.Message) error {
+	if p.keepsMessages() {
 		return nil
 	}
+	// Handles already deleted this poll are skipped
+	q := p.queueOf(ctx)
+	msgs = slices.DeleteFunc(slices.Clone(msgs), func(msg types.Message) bool {
+		return !q.deleted.claim(aws.ToString(msg.ReceiptHandle))
+	})
 
-	for _, msg := range out.Messages {
-		msgID := "unknown"
-		if msg.MessageId != nil {
-			msgID = *msg.MessageId
+	var errs []error
+	for chunk := range slices.Chunk(msgs, sqsMaxBatch) {
+		if err := p.deleteMessageChunk(ctx, q, chunk); err != nil {
+			errs = append(errs, err)
 		}
+	}
+	return errors.Join(errs...)
+}
 
-		if err := p.handleMessage(ctx, msg); err != nil {
-			p.ordersProcessed.WithLabelValues("error", p.environment).Inc()
-			log.Error().
-				Str("msg_id", msgID).
-				Err(err).
-				Msg("failed to process message - message will be retried or sent to DLQ")
-			continue
+// deleteMessageChunk deletes up to 10 msgs, already claimed in q.deleted,
+// with one DeleteMessageBatch call.
+func (p *Processor) deleteMessageChunk(ctx context.Context, q *sourceQueue, msgs []types.Message) (err error) {
+	ctx, span := p.startChildSpan(ctx, "delete_messages",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int("messaging.batch.message_count", len(msgs))),
+	)
+	defer func() { endSpan(span, err) }()
+
+	entries := make([]types.DeleteMessageBatchRequestEntry, len(msgs))
+	for i, msg := range msgs {
+		entries[i] = types.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: msg.ReceiptHandle,
 		}
+	}
 
-		if err := p.deleteMessage(ctx, msg); err != nil {
-			log.Error().
-				Str("msg_id", msgID).
-				Err(err).
-				Msg("failed to delete message from queue - message may be reprocessed")
-			// Continue processing other messages even if deletion fails
-			// The message will become visible again after visibility timeout
+	out, err := p.sqsClient.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: &q.url,
+		Entries:  entries,
+	})
+	if err != nil {
+		for _, msg := range msgs {
+			q.deleted.release(aws.ToString(msg.ReceiptHandle))
 		}
+		p.countDeleteFailures(len(msgs))
+		return fmt.Errorf("delete message batch: %w", err)
+	}
+
+	if len(out.Failed) > 0 {
+		p.countDeleteFailures(len(out.Failed))
 	}
 
+	for _, entry := range out.Failed {
+		msgID := "unknown"
+		if i, err := strconv.Atoi(aws.ToString(entry.Id)); err == nil && i < len(msgs) {
+			q.deleted.release(aws.ToString(msgs[i].ReceiptHandle))
+			if msgs[i].MessageId != nil {
+				msgID = *msgs[i].MessageId
+			}
+		}
+		log.Error().
+			Str("entry_id", aws.ToString(entry.Id)).
+			Str("msg_id", msgID).
+			Str("code", aws.ToString(entry.Code)).
+			Str("reason", aws.ToString(entry.Message)).
+			Msg("failed to delete message from queue - message may be reprocessed")
+	}
+	for _, entry := range out.Successful {
+		if i, err := strconv.Atoi(aws.ToString(entry.Id)); err == nil && i < len(msgs) {
+			p.recordLifecycle(ctx, msgs[i], stageDeleted)
+		}
+	}
+	tallyOf(ctx).addDeleted(len(out.Successful))
 	return nil
 }
 
-func (p *Processor) deleteMessage(ctx context.Context, msg types.Message) error {
-	_, err := p.sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
-		QueueUrl:      &p.queueURL,
-		ReceiptHandle: msg.ReceiptHandle,
-	})
+// handleMessage processes a single message: prepare, hand the order to the
+// order handler, complete. Its processing time is observed under the order
+// span, so sampled orders leave a trace exemplar.
+func (p *Processor) handleMessage(ctx context.Context, msg types.Message) (err error) {
+	start := time.Now()
+	ctx, span := p.startOrderSpan(ctx, msg)
+	defer func() {
+		p.observeProcessing(ctx, start, err)
+		endSpan(span, err)
+	}()
+
+	if p.batchBody {
+		return p.handleBatchBody(ctx, msg)
+	}
+	prepared, err := p.prepareOrder(ctx, msg)
+	if err != nil || prepared == nil {
+		return err
+	}
+	ctx = withOrderLogger(ctx, prepared.order.OrderID)
+
+	if err := p.storeLineItems(ctx, msg, prepared.order); err != nil {
+		return err
+	}
+
+	prepared.order.ProcessedAt = p.now().UTC()
+	start = time.Now()
+	err = p.orderHandler().Handle(ctx, prepared.order)
+	p.observePhase(ctx, phaseStore, start)
+	if isDuplicateWrite(err) {
+		p.skipDuplicateOrder(ctx, prepared)
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("delete message: %w", err)
+		return err
 	}
+
+	p.completeOrder(ctx, prepared)
 	return nil
 }
 
-func (p *Processor) handleMessage(ctx context.Context, msg types.Message) error {
+// preparedOrder is an order ready to be stored, with the message it came
+// from.
+type preparedOrder struct {
+	msg   types.Message
+	order Order
+	// dedupKey is what content dedup remembers the order by: the body
+	// hash, or the derived dedup ID when a template is configured
+	dedupKey string
+	// start is when processing of msg began
+	start time.Time
+	// span is the order span's context, for exemplars on observations
+	// made after the span has ended
+	span trace.SpanContext
+	// logger tags the lines logged for the order once it is written in a
+	// batch (see withMessageLogger); nil keeps the caller's
+	logger *zerolog.Logger
+}
+
+// prepareOrder runs everything before the write: parse, validate, and
+// enrich. It returns nil with no error for a body already stored within
+// the content dedup window, and for every valid order in a dry run.
+func (p *Processor) prepareOrder(ctx context.Context, msg types.Message) (*preparedOrder, error) {
 	if msg.Body == nil {
-		return fmt.Errorf("message body is nil")
+		return nil, &ValidationError{Type: "nil_body", Err: errors.New("message body is nil")}
 	}
 
-	var order Order
-	if err := json.Unmarshal([]byte(*msg.Body), &order); err != nil {
-		return fmt.Errorf("invalid JSON: %w", err)
+	body, err := p.messageBody(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkBodySize(body); err != nil {
+		return nil, err
 	}
+	return p.prepareBody(ctx, msg, body)
+}
 
-	if order.OrderID == "" {
-		return fmt.Errorf("order_id is required")
+// prepareBody is prepareOrder for the order in body, the plaintext of msg
+// or, with BATCH_BODY, one line of it.
+func (p *Processor) prepareBody(ctx context.Context, msg types.Message, body string) (*preparedOrder, error) {
+	if err := p.checkContract(ctx, msg, body); err != nil {
+		return nil, err
 	}
 
-	order.Status = orderStatusProcessed
+	var dedupKey string
+	if p.contentDedup != nil && p.dedupID == nil {
+		dedupKey = contentHash(body)
+		if p.contentDedup.Seen(dedupKey) {
+			p.countOrder(ctx, "content_duplicate")
+			logger(ctx).Info().Str("content_hash", dedupKey).Msg("skipping duplicate order body")
+			return nil, nil
+		}
+	}
 
-	item, err := attributevalue.MarshalMap(order)
+	start := time.Now()
+	order, err := p.parseMessageOrder(msg, body)
+	p.observePhase(ctx, phaseParse, start)
 	if err != nil {
-		return fmt.Errorf("failed to marshal order: %w", err)
+		return nil, err
 	}
 
-	_, err = p.ddbClient.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: &p.tableName,
-		Item:      item,
-	})
+	start = time.Now()
+	p.normalization.apply(&order)
+	if err := p.validateOrder(ctx, &order); err != nil {
+		p.observePhase(ctx, phaseValidate, start)
+		return nil, err
+	}
+	if p.dedupID != nil {
+		if order.DedupID, err = p.dedupID.derive(order); err != nil {
+			p.observePhase(ctx, phaseValidate, start)
+			return nil, err
+		}
+		if p.contentDedup != nil {
+			dedupKey = order.DedupID
+			if p.contentDedup.Seen(dedupKey) {
+				p.observePhase(ctx, phaseValidate, start)
+				p.countOrder(ctx, "content_duplicate")
+				logger(ctx).Info().Str("dedup_id", dedupKey).Msg("skipping order with duplicate dedup ID")
+				return nil, nil
+			}
+		}
+	}
+	if err := p.checkReferences(ctx, order, body); err != nil {
+		p.observePhase(ctx, phaseValidate, start)
+		return nil, err
+	}
+	err = p.checkParent(ctx, order)
+	p.observePhase(ctx, phaseValidate, start)
 	if err != nil {
-		return fmt.Errorf("failed to put item to DynamoDB: %w", err)
+		return nil, err
 	}
 
-	p.ordersProcessed.WithLabelValues("success", p.environment).Inc()
-	log.Info().
-		Str("order_id", order.OrderID).
-		Str("user_id", order.UserID).
-		Int("amount", order.Amount).
+	start = time.Now()
+	err = p.processAttachment(ctx, &order)
+	if err == nil {
+		err = p.convertAmount(ctx, &order)
+	}
+	p.observePhase(ctx, phaseEnrich, start)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stored in UTC so creation times sort as strings
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = p.now()
+	}
+	order.CreatedAt = order.CreatedAt.UTC()
+	order.Status = p.orderStatus(order)
+	if err := p.checkItemSize(ctx, &order); err != nil {
+		return nil, err
+	}
+	prepared := &preparedOrder{msg: msg, order: order, dedupKey: dedupKey}
+	if p.dryRun {
+		p.skipDryRunOrder(ctx, prepared)
+		return nil, nil
+	}
+	return prepared, nil
+}
+
+// skipDuplicateOrder records an order whose write found it already
+// stored, typically because SQS redelivered its message. The message is
+// done with, but the order is not counted as processed a second time.
+func (p *Processor) skipDuplicateOrder(ctx context.Context, prepared *preparedOrder) {
+	if p.contentDedup != nil {
+		p.contentDedup.Remember(prepared.dedupKey)
+	}
+
+	p.countOrder(ctx, "duplicate")
+	logger(ctx).Info().Msg("order already stored - skipping duplicate")
+	p.lineItems.forget(prepared.order.OrderID)
+	p.appendAudit(ctx, prepared.order, auditActionDuplicate)
+}
+
+// completeOrder records a stored order.
+func (p *Processor) completeOrder(ctx context.Context, prepared *preparedOrder) {
+	// Only stored bodies count as seen, so failed writes are still retried
+	if p.contentDedup != nil {
+		p.contentDedup.Remember(prepared.dedupKey)
+	}
+
+	p.lineItems.forget(prepared.order.OrderID)
+	p.countOrder(ctx, "success")
+	p.countOrderStatus(prepared.order.Status)
+	p.stats.success(prepared.order.Amount)
+	logger(ctx).Info().
+		Str("user_id", prepared.order.UserID).
+		Stringer("amount", prepared.order.Amount).
 		Msg("order processed successfully")
-	return nil
+	p.appendAudit(ctx, prepared.order, auditActionStored)
+	p.writeAnalytics(ctx, prepared.order)
+	p.publishEvent(ctx, prepared.order)
 }
@@ -4,13 +4,17 @@ package processor
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
@@ -40,6 +44,42 @@ func (m *MockSQSClient) DeleteMessage(
 	return args.Get(0).(*sqs.DeleteMessageOutput), args.Error(1)
 }
 
+func (m *MockSQSClient) DeleteMessageBatch(
+	ctx context.Context,
+	input *sqs.DeleteMessageBatchInput,
+	opts ...func(*sqs.Options),
+) (*sqs.DeleteMessageBatchOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*sqs.DeleteMessageBatchOutput), args.Error(1)
+}
+
+func (m *MockSQSClient) GetQueueAttributes(
+	ctx context.Context,
+	input *sqs.GetQueueAttributesInput,
+	opts ...func(*sqs.Options),
+) (*sqs.GetQueueAttributesOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*sqs.GetQueueAttributesOutput), args.Error(1)
+}
+
+func (m *MockSQSClient) GetQueueUrl(
+	ctx context.Context,
+	input *sqs.GetQueueUrlInput,
+	opts ...func(*sqs.Options),
+) (*sqs.GetQueueUrlOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*sqs.GetQueueUrlOutput), args.Error(1)
+}
+
+func (m *MockSQSClient) SendMessage(
+	ctx context.Context,
+	input *sqs.SendMessageInput,
+	opts ...func(*sqs.Options),
+) (*sqs.SendMessageOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*sqs.SendMessageOutput), args.Error(1)
+}
+
 type MockDynamoDBClient struct {
 	mock.Mock
 }
@@ -53,14 +93,73 @@ func (m *MockDynamoDBClient) PutItem(
 	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
 }
 
+func (m *MockDynamoDBClient) GetItem(
+	ctx context.Context,
+	input *dynamodb.GetItemInput,
+	opts ...func(*dynamodb.Options),
+) (*dynamodb.GetItemOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*dynamodb.GetItemOutput), args.Error(1)
+}
+
+func (m *MockDynamoDBClient) BatchWriteItem(
+	ctx context.Context,
+	input *dynamodb.BatchWriteItemInput,
+	opts ...func(*dynamodb.Options),
+) (*dynamodb.BatchWriteItemOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*dynamodb.BatchWriteItemOutput), args.Error(1)
+}
+
+func (m *MockDynamoDBClient) BatchGetItem(
+	ctx context.Context,
+	input *dynamodb.BatchGetItemInput,
+	opts ...func(*dynamodb.Options),
+) (*dynamodb.BatchGetItemOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*dynamodb.BatchGetItemOutput), args.Error(1)
+}
+
+func (m *MockDynamoDBClient) DescribeTable(
+	ctx context.Context,
+	input *dynamodb.DescribeTableInput,
+	opts ...func(*dynamodb.Options),
+) (*dynamodb.DescribeTableOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*dynamodb.DescribeTableOutput), args.Error(1)
+}
+
+func (m *MockDynamoDBClient) UpdateItem(
+	ctx context.Context,
+	input *dynamodb.UpdateItemInput,
+	opts ...func(*dynamodb.Options),
+) (*dynamodb.UpdateItemOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+
 // ────────────────────── TEST HELPER ──────────────────────
 func NewCounterVec() *prometheus.CounterVec {
 	return prometheus.NewCounterVec(
 		prometheus.CounterOpts{Name: "orders_processed_total", Help: "Total processed"},
-		[]string{"status", "env"},
+		[]string{"status", "env", "queue"},
 	)
 }
 
+// deleteBatchOf matches a DeleteMessageBatch call for exactly the given
+// receipt handles, in any order.
+func deleteBatchOf(handles ...string) interface{} {
+	return mock.MatchedBy(func(input *sqs.DeleteMessageBatchInput) bool {
+		got := make([]string, 0, len(input.Entries))
+		for _, e := range input.Entries {
+			got = append(got, aws.ToString(e.ReceiptHandle))
+		}
+		slices.Sort(got)
+		want := slices.Sorted(slices.Values(handles))
+		return slices.Equal(got, want)
+	})
+}
+
 // ────────────────────── TESTS ──────────────────────
 func TestPollAndProcess_Success(t *testing.T) {
 	mockSQS := &MockSQSClient{}
@@ -73,6 +172,7 @@ func TestPollAndProcess_Success(t *testing.T) {
 		tableName:       "Orders",
 		ordersProcessed: NewCounterVec(),
 		environment:     "test",
+		clock:           fixedClock{at: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)},
 	}
 
 	// Mock ReceiveMessage → returns one message
@@ -90,20 +190,23 @@ func TestPollAndProcess_Success(t *testing.T) {
 		"user_id":  &dtypes.AttributeValueMemberS{Value: "u1"},
 		"amount":   &dtypes.AttributeValueMemberN{Value: "100"},
 		"status":   &dtypes.AttributeValueMemberS{Value: "PROCESSED"},
+		// Defaulted: no currency or creation time in the message
+		"currency":     &dtypes.AttributeValueMemberS{Value: "USD"},
+		"created_at":   &dtypes.AttributeValueMemberS{Value: "2024-05-01T08:00:00Z"},
+		"processed_at": &dtypes.AttributeValueMemberS{Value: "2024-05-01T08:00:00Z"},
 	}
 	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
 		return *input.TableName == "Orders" && assert.Equal(t, expectedItem, input.Item)
 	})).Return(&dynamodb.PutItemOutput{}, nil)
 
-	// Mock DeleteMessage
-	mockSQS.On("DeleteMessage", mock.Anything, mock.MatchedBy(func(input *sqs.DeleteMessageInput) bool {
-		return *input.ReceiptHandle == "r1"
-	})).Return(&sqs.DeleteMessageOutput{}, nil)
+	// Mock DeleteMessageBatch
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil)
 
 	// Act: Call pollAndProcess
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	err := proc.pollAndProcess(ctx)
+	_, err := proc.pollAndProcess(ctx)
 
 	// Assert
 	assert.NoError(t, err)
@@ -111,10 +214,49 @@ func TestPollAndProcess_Success(t *testing.T) {
 	mockDDB.AssertExpectations(t)
 
 	// Metric
-	count := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test"))
+	count := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue"))
 	assert.Equal(t, 1.0, count)
 }
 
+func TestPollAndProcess_DuplicateOrderDeletedNotCounted(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	msg := stypes.Message{
+		MessageId:     aws.String("msg-123"),
+		Body:          aws.String(`{"order_id":"o1","user_id":"u1","amount":100}`),
+		ReceiptHandle: aws.String("r1"),
+	}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil)
+
+	// The order was stored by an earlier delivery of the same message
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return aws.ToString(input.ConditionExpression) == "attribute_not_exists(#pk)" &&
+			input.ExpressionAttributeNames["#pk"] == "order_id"
+	})).Return((*dynamodb.PutItemOutput)(nil), &dtypes.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("duplicate", "test", "test-queue")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue")))
+}
+
 func TestPollAndProcess_EmptyQueue(t *testing.T) {
 	mockSQS := &MockSQSClient{}
 	mockDDB := &MockDynamoDBClient{}
@@ -132,13 +274,54 @@ func TestPollAndProcess_EmptyQueue(t *testing.T) {
 		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{}}, nil)
 
 	ctx := context.Background()
-	err := proc.pollAndProcess(ctx)
+	_, err := proc.pollAndProcess(ctx)
 
 	assert.NoError(t, err)
 	mockSQS.AssertExpectations(t)
 	mockDDB.AssertExpectations(t)
 }
 
+func TestPollAndProcess_UsesReceiveOptions(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		receive:         &receiveOptions{maxMessages: 10, waitTimeSeconds: 20, visibilityTimeout: 900},
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.MatchedBy(func(input *sqs.ReceiveMessageInput) bool {
+		return input.MaxNumberOfMessages == 10 && input.WaitTimeSeconds == 20 && input.VisibilityTimeout == 900
+	})).Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+}
+
+func TestPollAndProcess_DefaultReceiveOptions(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.MatchedBy(func(input *sqs.ReceiveMessageInput) bool {
+		return input.MaxNumberOfMessages == maxMessagesPerPoll &&
+			input.WaitTimeSeconds == waitTimeSeconds &&
+			input.VisibilityTimeout == visibilityTimeout
+	})).Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+}
+
 func TestPollAndProcess_MultipleMessages(t *testing.T) {
 	mockSQS := &MockSQSClient{}
 	mockDDB := &MockDynamoDBClient{}
@@ -170,18 +353,18 @@ func TestPollAndProcess_MultipleMessages(t *testing.T) {
 		return *input.TableName == "Orders"
 	})).Return(&dynamodb.PutItemOutput{}, nil).Twice()
 
-	mockSQS.On("DeleteMessage", mock.Anything, mock.MatchedBy(func(input *sqs.DeleteMessageInput) bool {
-		return *input.ReceiptHandle == "r1" || *input.ReceiptHandle == "r2"
-	})).Return(&sqs.DeleteMessageOutput{}, nil).Twice()
+	// Both handled messages are deleted in one call
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
 
 	ctx := context.Background()
-	err := proc.pollAndProcess(ctx)
+	_, err := proc.pollAndProcess(ctx)
 
 	assert.NoError(t, err)
 	mockSQS.AssertExpectations(t)
 	mockDDB.AssertExpectations(t)
 
-	successCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test"))
+	successCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue"))
 	assert.Equal(t, 2.0, successCount)
 }
 
@@ -203,7 +386,7 @@ func TestPollAndProcess_ReceiveMessageError(t *testing.T) {
 		Return((*sqs.ReceiveMessageOutput)(nil), expectedErr)
 
 	ctx := context.Background()
-	err := proc.pollAndProcess(ctx)
+	_, err := proc.pollAndProcess(ctx)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "receive message")
@@ -231,15 +414,18 @@ func TestPollAndProcess_InvalidJSON(t *testing.T) {
 
 	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
 		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil)
+	// A terminal failure is dropped, since redelivery cannot help
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
 
 	ctx := context.Background()
-	err := proc.pollAndProcess(ctx)
+	_, err := proc.pollAndProcess(ctx)
 
 	assert.NoError(t, err) // pollAndProcess doesn't return error, just logs it
 	mockSQS.AssertExpectations(t)
 	mockDDB.AssertExpectations(t)
 
-	errorCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test"))
+	errorCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue"))
 	assert.Equal(t, 1.0, errorCount)
 }
 
@@ -264,15 +450,17 @@ func TestPollAndProcess_MissingOrderID(t *testing.T) {
 
 	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
 		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
 
 	ctx := context.Background()
-	err := proc.pollAndProcess(ctx)
+	_, err := proc.pollAndProcess(ctx)
 
 	assert.NoError(t, err)
 	mockSQS.AssertExpectations(t)
 	mockDDB.AssertExpectations(t)
 
-	errorCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test"))
+	errorCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue"))
 	assert.Equal(t, 1.0, errorCount)
 }
 
@@ -297,15 +485,17 @@ func TestPollAndProcess_NilMessageBody(t *testing.T) {
 
 	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
 		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
 
 	ctx := context.Background()
-	err := proc.pollAndProcess(ctx)
+	_, err := proc.pollAndProcess(ctx)
 
 	assert.NoError(t, err)
 	mockSQS.AssertExpectations(t)
 	mockDDB.AssertExpectations(t)
 
-	errorCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test"))
+	errorCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue"))
 	assert.Equal(t, 1.0, errorCount)
 }
 
@@ -336,13 +526,13 @@ func TestPollAndProcess_DynamoDBError(t *testing.T) {
 		Return((*dynamodb.PutItemOutput)(nil), ddbErr)
 
 	ctx := context.Background()
-	err := proc.pollAndProcess(ctx)
+	_, err := proc.pollAndProcess(ctx)
 
 	assert.NoError(t, err) // pollAndProcess doesn't return error, just logs it
 	mockSQS.AssertExpectations(t)
 	mockDDB.AssertExpectations(t)
 
-	errorCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test"))
+	errorCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue"))
 	assert.Equal(t, 1.0, errorCount)
 }
 
@@ -372,18 +562,18 @@ func TestPollAndProcess_DeleteMessageError(t *testing.T) {
 		Return(&dynamodb.PutItemOutput{}, nil)
 
 	deleteErr := errors.New("delete error")
-	mockSQS.On("DeleteMessage", mock.Anything, mock.Anything).
-		Return((*sqs.DeleteMessageOutput)(nil), deleteErr)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, mock.Anything).
+		Return((*sqs.DeleteMessageBatchOutput)(nil), deleteErr)
 
 	ctx := context.Background()
-	err := proc.pollAndProcess(ctx)
+	_, err := proc.pollAndProcess(ctx)
 
 	// pollAndProcess should continue even if delete fails
 	assert.NoError(t, err)
 	mockSQS.AssertExpectations(t)
 	mockDDB.AssertExpectations(t)
 
-	successCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test"))
+	successCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue"))
 	assert.Equal(t, 1.0, successCount)
 }
 
@@ -412,17 +602,17 @@ func TestPollAndProcess_MessageWithoutMessageID(t *testing.T) {
 	mockDDB.On("PutItem", mock.Anything, mock.Anything).
 		Return(&dynamodb.PutItemOutput{}, nil)
 
-	mockSQS.On("DeleteMessage", mock.Anything, mock.Anything).
-		Return(&sqs.DeleteMessageOutput{}, nil)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil)
 
 	ctx := context.Background()
-	err := proc.pollAndProcess(ctx)
+	_, err := proc.pollAndProcess(ctx)
 
 	assert.NoError(t, err)
 	mockSQS.AssertExpectations(t)
 	mockDDB.AssertExpectations(t)
 
-	successCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test"))
+	successCount := testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue"))
 	assert.Equal(t, 1.0, successCount)
 }
 
@@ -489,6 +679,26 @@ func TestHandleMessage_MissingOrderID(t *testing.T) {
 	assert.Contains(t, err.Error(), "order_id is required")
 }
 
+func TestHandleMessage_NegativeAmount(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		ddbClient:       mockDDB,
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	msg := stypes.Message{
+		Body: aws.String(`{"order_id":"o1","user_id":"u1","amount":-50}`),
+	}
+
+	err := proc.handleMessage(context.Background(), msg)
+
+	assert.ErrorContains(t, err, "amount must be positive")
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+}
+
 func TestHandleMessage_DynamoDBError(t *testing.T) {
 	mockDDB := &MockDynamoDBClient{}
 
@@ -542,8 +752,10 @@ func TestDeleteMessage_Error(t *testing.T) {
 	mockSQS := &MockSQSClient{}
 
 	proc := &Processor{
-		sqsClient: mockSQS,
-		queueURL:  "test-queue",
+		sqsClient:      mockSQS,
+		queueURL:       "test-queue",
+		environment:    "test",
+		deleteFailures: newDeleteFailuresCounter(),
 	}
 
 	msg := stypes.Message{
@@ -560,6 +772,68 @@ func TestDeleteMessage_Error(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "delete message")
 	mockSQS.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.deleteFailures.WithLabelValues("test")))
+}
+
+func TestDeleteMessageBatch_PartialFailure(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	wal := &memoryWAL{}
+
+	proc := &Processor{
+		sqsClient:      mockSQS,
+		queueURL:       "test-queue",
+		wal:            wal,
+		environment:    "test",
+		deleteFailures: newDeleteFailuresCounter(),
+	}
+
+	msgs := []stypes.Message{
+		{MessageId: aws.String("msg-1"), ReceiptHandle: aws.String("r1")},
+		{MessageId: aws.String("msg-2"), ReceiptHandle: aws.String("r2")},
+	}
+	mockSQS.On("DeleteMessageBatch", mock.Anything, mock.MatchedBy(func(input *sqs.DeleteMessageBatchInput) bool {
+		return *input.QueueUrl == "test-queue" &&
+			*input.Entries[0].Id == "0" && *input.Entries[1].Id == "1"
+	})).Return(&sqs.DeleteMessageBatchOutput{
+		Successful: []stypes.DeleteMessageBatchResultEntry{{Id: aws.String("0")}},
+		Failed: []stypes.BatchResultErrorEntry{{
+			Id:      aws.String("1"),
+			Code:    aws.String("ReceiptHandleIsInvalid"),
+			Message: aws.String("expired"),
+		}},
+	}, nil).Once()
+
+	err := proc.deleteMessageBatch(context.Background(), msgs)
+
+	// Partial failures are logged, not returned, and not recorded as deleted
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+	assert.Equal(t, []LifecycleEvent{{MessageID: "msg-1", Stage: stageDeleted, At: wal.events[0].At}}, wal.events)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.deleteFailures.WithLabelValues("test")))
+}
+
+func TestDeleteMessageBatch_ErrorCountsEveryMessage(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{
+		sqsClient:      mockSQS,
+		queueURL:       "test-queue",
+		environment:    "test",
+		deleteFailures: newDeleteFailuresCounter(),
+	}
+
+	mockSQS.On("DeleteMessageBatch", mock.Anything, mock.Anything).
+		Return((*sqs.DeleteMessageBatchOutput)(nil), errors.New("throttled")).Once()
+
+	assert.Error(t, proc.deleteMessageBatch(context.Background(), orderBatch(3)))
+	assert.Equal(t, 3.0, testutil.ToFloat64(proc.deleteFailures.WithLabelValues("test")))
+}
+
+func TestDeleteMessageBatch_Empty(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{sqsClient: mockSQS}
+
+	assert.NoError(t, proc.deleteMessageBatch(context.Background(), nil))
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
 }
 
 func TestStart_ContextCancellation_Immediate(t *testing.T) {
@@ -632,3 +906,89 @@ func TestStart_ContextCancellation_AfterError(t *testing.T) {
 	assert.Equal(t, context.Canceled, err)
 	mockSQS.AssertExpectations(t)
 }
+
+// cancelledCall is the error the AWS SDK returns for a call whose context
+// was cancelled.
+func cancelledCall(service, operation string) error {
+	return &smithy.OperationError{
+		ServiceID:     service,
+		OperationName: operation,
+		Err:           &smithy.CanceledError{Err: context.Canceled},
+	}
+}
+
+func TestStart_CancelledDuringReceive(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	receiving := make(chan struct{})
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			close(receiving)
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return((*sqs.ReceiveMessageOutput)(nil), cancelledCall("SQS", "ReceiveMessage")).Once()
+
+	done := make(chan error, 1)
+	go func() { done <- proc.Start(ctx) }()
+
+	<-receiving
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-done)
+	mockSQS.AssertExpectations(t)
+	assert.Equal(t, 0.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue")))
+}
+
+func TestPollAndProcess_CancelledHandlerNotCountedAsError(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		failuresTable:   "Failures",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{{
+			MessageId:     aws.String("msg-123"),
+			Body:          aws.String(`{"order_id":"o1","user_id":"u1","amount":100}`),
+			ReceiptHandle: aws.String("r1"),
+		}}}, nil).Once()
+	// Shutdown begins while the order is being written
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).
+		Return((*dynamodb.PutItemOutput)(nil), cancelledCall("DynamoDB", "PutItem")).Once()
+
+	_, err := proc.pollAndProcess(ctx)
+	assert.NoError(t, err)
+
+	mockDDB.AssertExpectations(t)
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+	assert.Equal(t, 0.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue")))
+}
+
+func TestInterrupted(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, interrupted(cancelled, cancelledCall("SQS", "ReceiveMessage")))
+	assert.False(t, interrupted(cancelled, errors.New("DynamoDB error")))
+	// A call that timed out on its own while the processor keeps running is
+	// an ordinary failure
+	assert.False(t, interrupted(context.Background(), fmt.Errorf("put item: %w", context.DeadlineExceeded)))
+}
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Queue depth check defaults
+	defaultQueueDepthInterval = 30 * time.Second
+	queueDepthTimeout         = 5 * time.Second
+)
+
+func newQueueDepthGauge() *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sqs_queue_depth",
+			Help: "Approximate number of messages available on the queue",
+		},
+		[]string{"queue_url"},
+	)
+}
+
+// queueDepth returns the approximate number of messages available on the
+// queue at url.
+func (p *Processor) queueDepth(ctx context.Context, url string) (int64, error) {
+	out, err := p.sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       &url,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("get queue attributes: %w", err)
+	}
+	depth, err := strconv.ParseInt(out.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessages)], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", types.QueueAttributeNameApproximateNumberOfMessages, err)
+	}
+	return depth, nil
+}
+
+// refreshQueueDepth sets sqs_queue_depth for every polled queue. A queue
+// whose depth cannot be read keeps its last value.
+func (p *Processor) refreshQueueDepth(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, queueDepthTimeout)
+	defer cancel()
+
+	for _, q := range p.pollQueues() {
+		depth, err := p.queueDepth(ctx, q.url)
+		if err != nil {
+			log.Warn().Err(err).Str("queue", q.name).Msg("failed to read queue depth")
+			continue
+		}
+		p.queueDepthGauge.WithLabelValues(q.url).Set(float64(depth))
+	}
+}
+
+// watchQueueDepth refreshes the queue depth gauge straight away and then
+// every interval until ctx ends.
+func (p *Processor) watchQueueDepth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.refreshQueueDepth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
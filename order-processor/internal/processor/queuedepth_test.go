@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func depthOf(url string) interface{} {
+	return mock.MatchedBy(func(input *sqs.GetQueueAttributesInput) bool {
+		return *input.QueueUrl == url && len(input.AttributeNames) == 1 &&
+			input.AttributeNames[0] == "ApproximateNumberOfMessages"
+	})
+}
+
+func depthOutput(depth string) *sqs.GetQueueAttributesOutput {
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]string{"ApproximateNumberOfMessages": depth}}
+}
+
+func TestRefreshQueueDepth_SetsGaugePerQueue(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queues:          []*sourceQueue{{url: "orders-eu", name: "orders-eu"}, {url: "orders-us", name: "orders-us"}},
+		queueDepthGauge: newQueueDepthGauge(),
+	}
+
+	mockSQS.On("GetQueueAttributes", mock.Anything, depthOf("orders-eu")).Return(depthOutput("42"), nil).Once()
+	mockSQS.On("GetQueueAttributes", mock.Anything, depthOf("orders-us")).Return(depthOutput("7"), nil).Once()
+	proc.refreshQueueDepth(context.Background())
+
+	assert.Equal(t, 42.0, testutil.ToFloat64(proc.queueDepthGauge.WithLabelValues("orders-eu")))
+	assert.Equal(t, 7.0, testutil.ToFloat64(proc.queueDepthGauge.WithLabelValues("orders-us")))
+
+	// A failed read keeps the last depth; the other queue is still refreshed
+	mockSQS.On("GetQueueAttributes", mock.Anything, depthOf("orders-eu")).
+		Return((*sqs.GetQueueAttributesOutput)(nil), errors.New("throttled")).Once()
+	mockSQS.On("GetQueueAttributes", mock.Anything, depthOf("orders-us")).Return(depthOutput("0"), nil).Once()
+	proc.refreshQueueDepth(context.Background())
+
+	assert.Equal(t, 42.0, testutil.ToFloat64(proc.queueDepthGauge.WithLabelValues("orders-eu")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(proc.queueDepthGauge.WithLabelValues("orders-us")))
+	mockSQS.AssertExpectations(t)
+}
+
+func TestWatchQueueDepth_StopsOnCancel(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{sqsClient: mockSQS, queueURL: "test-queue", queueDepthGauge: newQueueDepthGauge()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockSQS.On("GetQueueAttributes", mock.Anything, depthOf("test-queue")).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(depthOutput("3"), nil).Once()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		proc.watchQueueDepth(ctx, time.Hour)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchQueueDepth did not stop on cancel")
+	}
+	assert.Equal(t, 3.0, testutil.ToFloat64(proc.queueDepthGauge.WithLabelValues("test-queue")))
+	mockSQS.AssertExpectations(t)
+}
@@ -0,0 +1,139 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// sourceQueue is one queue the processor polls, with the state that must
+// not be shared between queues: receipt handles are only valid on the
+// queue that issued them, retried messages are deleted from their own
+// queue, and one queue failing says nothing about the others.
+type sourceQueue struct {
+	url string
+	// name labels the queue's metrics: the last segment of its URL
+	name    string
+	fifo    bool
+	deleted *deletedHandles
+	retries *retryQueue
+	breaker *circuitBreaker
+}
+
+// queueContextKey carries the *sourceQueue a poll and its messages belong
+// to.
+type queueContextKey struct{}
+
+func withQueue(ctx context.Context, q *sourceQueue) context.Context {
+	return context.WithValue(ctx, queueContextKey{}, q)
+}
+
+// queueOf returns the queue the work in ctx came from: the one its poller
+// set, or the processor's own queue.
+func (p *Processor) queueOf(ctx context.Context) *sourceQueue {
+	if q, ok := ctx.Value(queueContextKey{}).(*sourceQueue); ok {
+		return q
+	}
+	return p.primaryQueue()
+}
+
+// primaryQueue is queueURL with the processor's own per-queue state.
+func (p *Processor) primaryQueue() *sourceQueue {
+	return &sourceQueue{
+		url:     p.queueURL,
+		name:    queueName(p.queueURL),
+		fifo:    p.fifo,
+		deleted: &p.deleted,
+		retries: p.retries,
+		breaker: p.receiveBreaker,
+	}
+}
+
+// pollQueues returns the queues Start polls, each with a poller of its
+// own.
+func (p *Processor) pollQueues() []*sourceQueue {
+	if len(p.queues) > 0 {
+		return p.queues
+	}
+	return []*sourceQueue{p.primaryQueue()}
+}
+
+// maxPollersPerQueue bounds POLLERS_PER_QUEUE
+const maxPollersPerQueue = 10
+
+// pollers returns one sourceQueue per poller Start runs: pollersPerQueue
+// for each queue. Extra pollers of a queue get their own per-poll state;
+// inFlightIDs keeps them from processing the same message at once.
+func (p *Processor) pollers() []*sourceQueue {
+	var pollers []*sourceQueue
+	for _, q := range p.pollQueues() {
+		pollers = append(pollers, q)
+		for range p.pollersPerQueue - 1 {
+			pollers = append(pollers, q.clone())
+		}
+	}
+	return pollers
+}
+
+// clone returns a copy of q with state of its own: delete tracking, a
+// retry queue and a receive breaker (without a gauge) with q's settings.
+func (q *sourceQueue) clone() *sourceQueue {
+	c := &sourceQueue{
+		url:     q.url,
+		name:    q.name,
+		fifo:    q.fifo,
+		deleted: &deletedHandles{},
+	}
+	if q.retries != nil {
+		c.retries = newRetryQueue(q.retries.size, q.retries.backoff)
+	}
+	if b := q.breaker; b != nil {
+		c.breaker = newReceiveBreaker(b.threshold, b.cooldown, nil)
+	}
+	return c
+}
+
+// addQueues sets up polling of urls besides queueURL. Each gets the retry
+// queue and receive breaker settings of the primary queue; the breaker
+// gauge only follows the primary queue's breaker.
+func (p *Processor) addQueues(urls []string, forceFIFO bool) {
+	primary := p.primaryQueue()
+	p.queues = []*sourceQueue{primary}
+	for _, url := range urls {
+		q := primary.clone()
+		q.url = url
+		q.name = queueName(url)
+		q.fifo = forceFIFO || isFIFOQueue(url)
+		p.queues = append(p.queues, q)
+	}
+}
+
+// queueName returns the name of the queue at url, its last path segment.
+func queueName(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
+
+// fatalPollError reports whether err means a queue can never be polled,
+// so retrying is pointless and the processor should stop.
+func fatalPollError(err error) bool {
+	var missing *types.QueueDoesNotExist
+	return errors.As(err, &missing)
+}
+
+// resolveQueueURL looks up the URL of the queue called name in the
+// client's account and region.
+func resolveQueueURL(ctx context.Context, client sqsClientI, name string) (string, error) {
+	out, err := client.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(name)})
+	if err != nil {
+		return "", fmt.Errorf("resolve %s %q: %w", envSQSQueueName, name, err)
+	}
+	if aws.ToString(out.QueueUrl) == "" {
+		return "", fmt.Errorf("resolve %s %q: no queue URL returned", envSQSQueueName, name)
+	}
+	return aws.ToString(out.QueueUrl), nil
+}
@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func receiveFrom(url string) interface{} {
+	return mock.MatchedBy(func(input *sqs.ReceiveMessageInput) bool {
+		return aws.ToString(input.QueueUrl) == url
+	})
+}
+
+func deleteFrom(url, handle string) interface{} {
+	return mock.MatchedBy(func(input *sqs.DeleteMessageBatchInput) bool {
+		return aws.ToString(input.QueueUrl) == url &&
+			len(input.Entries) == 1 && aws.ToString(input.Entries[0].ReceiptHandle) == handle
+	})
+}
+
+func TestOptionsQueues(t *testing.T) {
+	o := options{queueURL: "q1", queueURLs: []string{"q2", "q1", "", "q3"}}
+	assert.Equal(t, []string{"q1", "q2", "q3"}, o.queues())
+
+	o = options{queueURLs: []string{"q2", "q3"}}
+	assert.Equal(t, []string{"q2", "q3"}, o.queues())
+}
+
+func TestNewProcessor_QueueURLs(t *testing.T) {
+	for _, name := range []string{envSQSQueueURL, envReportBucket, envOTLPEndpoint, envSQSFIFO} {
+		t.Setenv(name, "")
+	}
+	t.Setenv(envSQSQueueURLs, "https://sqs.example.com/1/orders-eu, https://sqs.example.com/1/orders-us.fifo")
+
+	proc, err := NewProcessor(context.Background(),
+		WithTableName("Orders"),
+		WithSQSClient(&MockSQSClient{}),
+		WithDDBClient(&MockDynamoDBClient{}),
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://sqs.example.com/1/orders-eu", proc.queueURL)
+	queues := proc.pollQueues()
+	require.Len(t, queues, 2)
+	assert.Equal(t, "orders-eu", queues[0].name)
+	assert.False(t, queues[0].fifo)
+	assert.Equal(t, "orders-us.fifo", queues[1].name)
+	assert.True(t, queues[1].fifo)
+	assert.NotSame(t, queues[0].deleted, queues[1].deleted)
+}
+
+func TestStart_PollsEveryQueue(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "https://sqs.example.com/1/orders-eu",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+	proc.addQueues([]string{"https://sqs.example.com/1/orders-us", "https://sqs.example.com/1/orders-ap"}, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var deleted sync.WaitGroup
+	deleted.Add(3)
+
+	for i, q := range proc.pollQueues() {
+		msg := stypes.Message{
+			MessageId:     aws.String(fmt.Sprintf("msg-%d", i)),
+			Body:          aws.String(fmt.Sprintf(`{"order_id":"o%d","user_id":"u1","amount":100}`, i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("r%d", i)),
+		}
+		mockSQS.On("ReceiveMessage", mock.Anything, receiveFrom(q.url)).
+			Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil).Once()
+		mockSQS.On("ReceiveMessage", mock.Anything, receiveFrom(q.url)).
+			Return(&sqs.ReceiveMessageOutput{}, nil)
+		mockSQS.On("DeleteMessageBatch", mock.Anything, deleteFrom(q.url, *msg.ReceiptHandle)).
+			Run(func(mock.Arguments) { deleted.Done() }).
+			Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+	}
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Times(3)
+
+	go func() {
+		deleted.Wait()
+		cancel()
+	}()
+	assert.Equal(t, context.Canceled, proc.Start(ctx))
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	for _, queue := range []string{"orders-eu", "orders-us", "orders-ap"} {
+		assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", queue)), queue)
+	}
+}
+
+func TestStart_FatalQueueErrorStopsAllPollers(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "https://sqs.example.com/1/orders-eu",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+	proc.addQueues([]string{"https://sqs.example.com/1/orders-gone"}, false)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, receiveFrom("https://sqs.example.com/1/orders-eu")).
+		Return(&sqs.ReceiveMessageOutput{}, nil)
+	mockSQS.On("ReceiveMessage", mock.Anything, receiveFrom("https://sqs.example.com/1/orders-gone")).
+		Return((*sqs.ReceiveMessageOutput)(nil), &stypes.QueueDoesNotExist{Message: aws.String("no such queue")}).Once()
+
+	done := make(chan error, 1)
+	go func() { done <- proc.Start(context.Background()) }()
+
+	select {
+	case err := <-done:
+		var missing *stypes.QueueDoesNotExist
+		assert.ErrorAs(t, err, &missing)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after a fatal poll error")
+	}
+}
@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// readinessProbeTimeout bounds each dependency probe, well inside a
+	// typical readiness probe timeout
+	readinessProbeTimeout = 2 * time.Second
+	// readinessCacheTTL is how long a probe result is reused, so frequent
+	// readiness checks do not each call AWS
+	readinessCacheTTL = 5 * time.Second
+)
+
+// dependencyProbe caches whether SQS and DynamoDB are reachable.
+type dependencyProbe struct {
+	// mu is held while probing, so concurrent checks share one probe
+	mu        sync.Mutex
+	checkedAt time.Time
+	// failed is the dependency that failed the last probe, empty if none
+	failed string
+}
+
+// unreachableDependency returns the first dependency that does not answer
+// a lightweight call, "sqs" or "dynamodb", or "" if both do. Without a
+// dependency probe nothing is checked.
+func (p *Processor) unreachableDependency(ctx context.Context) string {
+	probe := p.readinessProbe
+	if probe == nil {
+		return ""
+	}
+	probe.mu.Lock()
+	defer probe.mu.Unlock()
+
+	now := p.now()
+	if !probe.checkedAt.IsZero() && now.Sub(probe.checkedAt) < readinessCacheTTL {
+		return probe.failed
+	}
+	probe.failed = p.probeDependencies(ctx)
+	probe.checkedAt = now
+	return probe.failed
+}
+
+// probeDependencies calls GetQueueAttributes on every polled queue and
+// DescribeTable on the orders table.
+func (p *Processor) probeDependencies(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, readinessProbeTimeout)
+	defer cancel()
+
+	for _, q := range p.pollQueues() {
+		if _, err := p.sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+			QueueUrl:       &q.url,
+			AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+		}); err != nil {
+			log.Warn().Str("queue", q.name).Err(err).Msg("readiness probe: SQS unreachable")
+			return "sqs"
+		}
+	}
+	if _, err := p.ddbClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: &p.tableName}); err != nil {
+		log.Warn().Err(err).Msg("readiness probe: DynamoDB unreachable")
+		return "dynamodb"
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func probedProcessor(mockSQS *MockSQSClient, mockDDB *MockDynamoDBClient, clock Clock) *Processor {
+	return &Processor{
+		sqsClient:      mockSQS,
+		ddbClient:      mockDDB,
+		queueURL:       "test-queue",
+		tableName:      "Orders",
+		clock:          clock,
+		readinessProbe: &dependencyProbe{},
+	}
+}
+
+func TestHandleReady_ProbesDependencies(t *testing.T) {
+	outage := errors.New("dial tcp: i/o timeout")
+	tests := []struct {
+		name     string
+		sqsErr   error
+		ddbErr   error
+		wantCode int
+		wantBody string
+	}{
+		{"both reachable", nil, nil, http.StatusOK, `{"status":"ready"}`},
+		{"sqs unreachable", outage, nil, http.StatusServiceUnavailable,
+			`{"status":"not ready","reason":"sqs unreachable","dependency":"sqs"}`},
+		{"dynamodb unreachable", nil, outage, http.StatusServiceUnavailable,
+			`{"status":"not ready","reason":"dynamodb unreachable","dependency":"dynamodb"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSQS := &MockSQSClient{}
+			mockDDB := &MockDynamoDBClient{}
+			mockSQS.On("GetQueueAttributes", mock.Anything, mock.MatchedBy(func(input *sqs.GetQueueAttributesInput) bool {
+				return *input.QueueUrl == "test-queue"
+			})).Return(&sqs.GetQueueAttributesOutput{}, tt.sqsErr).Once()
+			if tt.sqsErr == nil {
+				mockDDB.On("DescribeTable", mock.Anything, mock.MatchedBy(func(input *dynamodb.DescribeTableInput) bool {
+					return *input.TableName == "Orders"
+				})).Return(&dynamodb.DescribeTableOutput{}, tt.ddbErr).Once()
+			}
+			proc := probedProcessor(mockSQS, mockDDB, fixedClock{at: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)})
+
+			rec := httptest.NewRecorder()
+			proc.handleReady(rec, httptest.NewRequest(http.MethodGet, readinessPath, nil))
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			mockSQS.AssertExpectations(t)
+			mockDDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandleReady_CachesProbeResult(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	proc := probedProcessor(mockSQS, mockDDB, clock)
+	mockSQS.On("GetQueueAttributes", mock.Anything, mock.Anything).
+		Return(&sqs.GetQueueAttributesOutput{}, errors.New("dial tcp: i/o timeout")).Once()
+	mockSQS.On("GetQueueAttributes", mock.Anything, mock.Anything).
+		Return(&sqs.GetQueueAttributesOutput{}, nil).Once()
+	mockDDB.On("DescribeTable", mock.Anything, mock.Anything).
+		Return(&dynamodb.DescribeTableOutput{}, nil).Once()
+
+	assert.Equal(t, http.StatusServiceUnavailable, readyStatus(proc))
+	// Within the TTL the failure is served from the cache
+	clock.advance(readinessCacheTTL - time.Second)
+	assert.Equal(t, http.StatusServiceUnavailable, readyStatus(proc))
+	mockSQS.AssertNumberOfCalls(t, "GetQueueAttributes", 1)
+
+	clock.advance(time.Second)
+	assert.Equal(t, http.StatusOK, readyStatus(proc))
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPollAndProcess_ReadOnlySkipsWritesUntilResumed(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		readOnlyGauge:   newReadOnlyGauge(),
+	}
+	proc.SetReadOnly(true)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.readOnlyGauge))
+
+	_, err := proc.pollAndProcess(context.Background())
+
+	assert.NoError(t, err)
+	mockSQS.AssertNotCalled(t, "ReceiveMessage", mock.Anything, mock.Anything)
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+
+	proc.SetReadOnly(false)
+	assert.Equal(t, 0.0, testutil.ToFloat64(proc.readOnlyGauge))
+
+	msg := stypes.Message{
+		MessageId:     aws.String("msg-123"),
+		Body:          aws.String(`{"order_id":"o1","user_id":"u1","amount":100}`),
+		ReceiptHandle: aws.String("r1"),
+	}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err = proc.pollAndProcess(context.Background())
+
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestPollAndProcess_ReadOnlyMidBatchLeavesRemainder(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	msgs := []stypes.Message{
+		{MessageId: aws.String("m1"), Body: aws.String(`{"order_id":"o1","amount":100}`), ReceiptHandle: aws.String("r1")},
+		{MessageId: aws.String("m2"), Body: aws.String(`{"order_id":"o2","amount":100}`), ReceiptHandle: aws.String("r2")},
+	}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs}, nil)
+	// The first write succeeds, then an operator switches to read-only
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { proc.SetReadOnly(true) }).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	// Only the message handled before the switch is deleted
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestAdminReadOnlyEndpoint(t *testing.T) {
+	proc := &Processor{}
+	mux := http.NewServeMux()
+	proc.registerAdminHandlers(mux, "secret")
+
+	do := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/admin/readonly?enabled=true", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/admin/readonly?enabled=true", "wrong").Code)
+	assert.False(t, proc.ReadOnly())
+
+	rec := do(http.MethodPost, "/admin/readonly?enabled=true", "secret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"readonly":true}`, rec.Body.String())
+	assert.True(t, proc.ReadOnly())
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/admin/readonly?enabled=maybe", "secret").Code)
+
+	rec = do(http.MethodPost, "/admin/readonly?enabled=false", "secret")
+	assert.JSONEq(t, `{"readonly":false}`, rec.Body.String())
+	assert.False(t, proc.ReadOnly())
+}
+
+func TestAdminEndpointsDisabledWithoutToken(t *testing.T) {
+	mux := http.NewServeMux()
+	(&Processor{}).registerAdminHandlers(mux, "")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/readonly?enabled=true", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
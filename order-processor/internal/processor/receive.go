@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// sqsMaxBatch is the most messages one ReceiveMessage returns and the
+	// most entries one DeleteMessageBatch takes
+	sqsMaxBatch = 10
+	// maxReceiveTarget bounds SQS_RECEIVE_TARGET
+	maxReceiveTarget = 100
+)
+
+// receiveN receives up to n messages from the queue in ctx, receiving
+// again while each receive returns messages, so a poll can drain more than
+// one receive's worth during catch-up. Only the first receive waits for
+// messages; later ones return at once. Receives are capped at twice the
+// number needed to reach n, as SQS often returns short batches, and stop
+// when ctx ends. A receive failing after the first ends the loop but keeps
+// what was received, since those messages are already in flight.
+func (p *Processor) receiveN(ctx context.Context, n int) ([]types.Message, error) {
+	q := p.queueOf(ctx)
+	opts := p.receiveOptions()
+	perReceive := int(opts.maxMessages)
+	calls := 1
+	if n > perReceive {
+		calls = 2 * ((n + perReceive - 1) / perReceive)
+	}
+
+	var messages []types.Message
+	for call := 0; call < calls && len(messages) < n; call++ {
+		wait := opts.waitTimeSeconds
+		if call > 0 {
+			if ctx.Err() != nil {
+				break
+			}
+			wait = 0
+		}
+		out, err := p.sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            &q.url,
+			MaxNumberOfMessages: int32(min(perReceive, n-len(messages))),
+			WaitTimeSeconds:     wait,
+			VisibilityTimeout:   opts.visibilityTimeout,
+			// Producers' metadata attributes are open-ended, so all are
+			// requested rather than a list that drifts behind them
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameAll},
+		})
+		// Failures caused by shutdown say nothing about SQS
+		if !interrupted(ctx, err) {
+			q.breaker.record(p.now(), err)
+		}
+		if err != nil {
+			if call == 0 {
+				return nil, fmt.Errorf("receive message: %w", err)
+			}
+			if !interrupted(ctx, err) {
+				log.Warn().Str("queue", q.name).Err(err).Int("received", len(messages)).
+					Msg("follow-up receive failed - processing what was received")
+			}
+			break
+		}
+		if len(out.Messages) == 0 {
+			break
+		}
+		messages = append(messages, out.Messages...)
+	}
+	return messages, nil
+}
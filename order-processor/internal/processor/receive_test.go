@@ -0,0 +1,173 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// receiveOf matches a receive asking for up to n messages, waiting wait
+// seconds.
+func receiveOf(n, wait int32) interface{} {
+	return mock.MatchedBy(func(input *sqs.ReceiveMessageInput) bool {
+		return input.MaxNumberOfMessages == n && input.WaitTimeSeconds == wait
+	})
+}
+
+func newReceiveProcessor(mockSQS *MockSQSClient) *Processor {
+	return &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		receive:         &receiveOptions{maxMessages: 10, waitTimeSeconds: 20},
+	}
+}
+
+func TestReceiveN_AggregatesReceives(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := newReceiveProcessor(mockSQS)
+	msgs := orderBatch(25)
+
+	// Only the first receive long-polls; the last asks for what is left
+	mockSQS.On("ReceiveMessage", mock.Anything, receiveOf(10, 20)).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs[:10]}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, receiveOf(10, 0)).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs[10:13]}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, receiveOf(10, 0)).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs[13:23]}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, receiveOf(2, 0)).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs[23:]}, nil).Once()
+
+	got, err := proc.receiveN(context.Background(), 25)
+
+	require.NoError(t, err)
+	assert.Equal(t, msgs, got)
+	mockSQS.AssertExpectations(t)
+}
+
+func TestReceiveN_StopsWhenQueueEmpty(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := newReceiveProcessor(mockSQS)
+	msgs := orderBatch(4)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+
+	got, err := proc.receiveN(context.Background(), 50)
+
+	require.NoError(t, err)
+	assert.Equal(t, msgs, got)
+	mockSQS.AssertNumberOfCalls(t, "ReceiveMessage", 2)
+}
+
+func TestReceiveN_CapsShortReceives(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := newReceiveProcessor(mockSQS)
+
+	// Two receives would do for 20, so four short ones are the limit
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(1)}, nil)
+
+	got, err := proc.receiveN(context.Background(), 20)
+
+	require.NoError(t, err)
+	assert.Len(t, got, 4)
+	mockSQS.AssertNumberOfCalls(t, "ReceiveMessage", 4)
+}
+
+func TestReceiveN_Errors(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := newReceiveProcessor(mockSQS)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return((*sqs.ReceiveMessageOutput)(nil), errors.New("throttled")).Once()
+	_, err := proc.receiveN(context.Background(), 30)
+	assert.ErrorContains(t, err, "receive message: throttled")
+
+	// Once messages are in flight a failed follow-up keeps them
+	msgs := orderBatch(10)
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return((*sqs.ReceiveMessageOutput)(nil), errors.New("throttled")).Once()
+	got, err := proc.receiveN(context.Background(), 30)
+	require.NoError(t, err)
+	assert.Equal(t, msgs, got)
+}
+
+func TestReceiveN_StopsWhenContextEnds(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := newReceiveProcessor(mockSQS)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(10)}, nil).Once()
+
+	got, err := proc.receiveN(ctx, 30)
+
+	require.NoError(t, err)
+	assert.Len(t, got, 10)
+	mockSQS.AssertNumberOfCalls(t, "ReceiveMessage", 1)
+}
+
+func TestPollAndProcess_ReceiveTargetDeletesInChunks(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newReceiveProcessor(mockSQS)
+	proc.ddbClient = mockDDB
+	proc.receiveTarget = 15
+	msgs := orderBatch(15)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, receiveOf(10, 20)).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs[:10]}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, receiveOf(5, 0)).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs[10:]}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Times(15)
+	var deleted int
+	mockSQS.On("DeleteMessageBatch", mock.Anything, mock.MatchedBy(func(input *sqs.DeleteMessageBatchInput) bool {
+		return len(input.Entries) <= sqsMaxBatch
+	})).Run(func(args mock.Arguments) {
+		deleted += len(args.Get(1).(*sqs.DeleteMessageBatchInput).Entries)
+	}).Return(&sqs.DeleteMessageBatchOutput{}, nil).Twice()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 15, deleted)
+}
+
+func TestPollAndProcess_ZeroWaitTimeShortPolls(t *testing.T) {
+	for _, name := range []string{envReportBucket, envOTLPEndpoint} {
+		t.Setenv(name, "")
+	}
+	// A configured 0 is short polling, not "unset"
+	t.Setenv(envSQSWaitTime, "0")
+	mockSQS := &MockSQSClient{}
+	proc, err := NewProcessor(context.Background(),
+		WithQueueURL("test-queue"),
+		WithTableName("Orders"),
+		WithSQSClient(mockSQS),
+		WithDDBClient(&MockDynamoDBClient{}),
+	)
+	require.NoError(t, err)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, receiveOf(maxMessagesPerPoll, 0)).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+
+	_, err = proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+}
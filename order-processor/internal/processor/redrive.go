@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Redrive defaults: messages moved per run (REDRIVE_MAX) and received
+	// per DLQ receive (REDRIVE_BATCH_SIZE)
+	defaultRedriveMax       = 1000
+	defaultRedriveBatchSize = sqsMaxBatch
+	// redriveVisibility hides a received DLQ message while it is re-sent
+	redriveVisibility = 60
+	// redriveWaitSeconds is how long a DLQ receive waits; a receive that
+	// returns nothing ends the run
+	redriveWaitSeconds = 1
+)
+
+// errRedriveNoDLQ is returned by Redrive without DLQ_QUEUE_URL.
+var errRedriveNoDLQ = errors.New("redrive needs DLQ_QUEUE_URL")
+
+// RedriveResult counts what a Redrive run did with the DLQ messages it
+// received.
+type RedriveResult struct {
+	// Redriven messages were sent to the queue and deleted from the DLQ
+	Redriven int
+	// Failed messages could not be sent and are left in the DLQ
+	Failed int
+}
+
+// Redrive moves messages from the DLQ back to the queue, to be processed
+// again once whatever dead-lettered them is fixed. Up to REDRIVE_MAX
+// messages are received, REDRIVE_BATCH_SIZE at a time, until the DLQ is
+// empty. Each is re-sent with its body and attributes, less the DLQ tags
+// sendToDLQ added, and only deleted from the DLQ once sent; one that
+// cannot be sent stays there and is counted as failed.
+func (p *Processor) Redrive(ctx context.Context) (RedriveResult, error) {
+	var result RedriveResult
+	if p.dlqURL == "" {
+		return result, errRedriveNoDLQ
+	}
+	limit := p.redriveMax
+	if limit <= 0 {
+		limit = defaultRedriveMax
+	}
+	batchSize := p.redriveBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRedriveBatchSize
+	}
+	defer func() {
+		log.Info().
+			Str("dlq", p.dlqURL).
+			Str("queue", p.queueURL).
+			Int("redriven", result.Redriven).
+			Int("failed", result.Failed).
+			Msg("DLQ redrive finished")
+	}()
+
+	for result.Redriven+result.Failed < limit {
+		out, err := p.sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    &p.dlqURL,
+			MaxNumberOfMessages:         int32(min(batchSize, limit-result.Redriven-result.Failed)),
+			WaitTimeSeconds:             redriveWaitSeconds,
+			VisibilityTimeout:           redriveVisibility,
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameAll},
+		})
+		if err != nil {
+			return result, fmt.Errorf("receive from DLQ: %w", err)
+		}
+		if len(out.Messages) == 0 {
+			break
+		}
+		for _, msg := range out.Messages {
+			if err := p.redriveMessage(ctx, msg); err != nil {
+				log.Error().Str("msg_id", aws.ToString(msg.MessageId)).Err(err).Msg("failed to redrive message - it stays in the DLQ")
+				result.Failed++
+				continue
+			}
+			result.Redriven++
+		}
+	}
+
+	if result.Failed > 0 {
+		return result, fmt.Errorf("%d of %d messages could not be redriven", result.Failed, result.Redriven+result.Failed)
+	}
+	return result, nil
+}
+
+// redriveMessage sends msg to the queue and deletes it from the DLQ.
+func (p *Processor) redriveMessage(ctx context.Context, msg types.Message) error {
+	attrs := maps.Clone(msg.MessageAttributes)
+	delete(attrs, "dlq_reason")
+	delete(attrs, "source_message_id")
+	input := &sqs.SendMessageInput{
+		QueueUrl:          &p.queueURL,
+		MessageBody:       msg.Body,
+		MessageAttributes: attrs,
+	}
+	if isFIFOQueue(p.queueURL) {
+		group := messageGroup(msg)
+		if group == "" {
+			group = "redriven"
+		}
+		input.MessageGroupId = aws.String(group)
+		input.MessageDeduplicationId = sourceDedupID(msg)
+	}
+	if _, err := p.sqsClient.SendMessage(ctx, input); err != nil {
+		return fmt.Errorf("send message to queue: %w", err)
+	}
+
+	// Sent but not deleted, the message would be redriven again
+	if _, err := p.sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      &p.dlqURL,
+		ReceiptHandle: msg.ReceiptHandle,
+	}); err != nil {
+		log.Warn().Str("msg_id", aws.ToString(msg.MessageId)).Err(err).
+			Msg("redriven message not deleted from DLQ - it may be redriven twice")
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedriveProcessor(mockSQS *MockSQSClient) *Processor {
+	return &Processor{
+		sqsClient: mockSQS,
+		queueURL:  "test-queue",
+		dlqURL:    "test-dlq",
+	}
+}
+
+// dlqReceiveOf matches a DLQ receive asking for up to n messages.
+func dlqReceiveOf(n int32) interface{} {
+	return mock.MatchedBy(func(input *sqs.ReceiveMessageInput) bool {
+		return aws.ToString(input.QueueUrl) == "test-dlq" && input.MaxNumberOfMessages == n
+	})
+}
+
+// deadLettered is a DLQ copy of an order message, as sendToDLQ tags it.
+func deadLettered(id string) stypes.Message {
+	return withAttributes(bodyMessage(id, `{"order_id":"o1","user_id":"u1","amount":100}`), map[string]string{
+		"dlq_reason":        "invalid_json",
+		"source_message_id": "src-" + id,
+		"correlation_id":    "corr-" + id,
+	})
+}
+
+func TestRedrive_MovesMessagesBackToQueue(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := newRedriveProcessor(mockSQS)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, dlqReceiveOf(10)).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{deadLettered("m1"), deadLettered("m2")}}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, dlqReceiveOf(10)).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+	// Producer attributes are kept, the DLQ tags dropped
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		_, tagged := input.MessageAttributes["dlq_reason"]
+		_, source := input.MessageAttributes["source_message_id"]
+		return aws.ToString(input.QueueUrl) == "test-queue" && !tagged && !source &&
+			input.MessageAttributes["correlation_id"].StringValue != nil
+	})).Return(&sqs.SendMessageOutput{}, nil).Twice()
+	for _, handle := range []string{"r-m1", "r-m2"} {
+		mockSQS.On("DeleteMessage", mock.Anything, mock.MatchedBy(func(input *sqs.DeleteMessageInput) bool {
+			return aws.ToString(input.QueueUrl) == "test-dlq" && aws.ToString(input.ReceiptHandle) == handle
+		})).Return(&sqs.DeleteMessageOutput{}, nil).Once()
+	}
+
+	result, err := proc.Redrive(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, RedriveResult{Redriven: 2}, result)
+	mockSQS.AssertExpectations(t)
+}
+
+func TestRedrive_FailedSendStaysInDLQ(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := newRedriveProcessor(mockSQS)
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{deadLettered("m1")}}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+	mockSQS.On("SendMessage", mock.Anything, mock.Anything).
+		Return((*sqs.SendMessageOutput)(nil), errors.New("access denied")).Once()
+
+	result, err := proc.Redrive(context.Background())
+
+	assert.EqualError(t, err, "1 of 1 messages could not be redriven")
+	assert.Equal(t, RedriveResult{Failed: 1}, result)
+	mockSQS.AssertNotCalled(t, "DeleteMessage", mock.Anything, mock.Anything)
+}
+
+func TestRedrive_StopsAtMax(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := newRedriveProcessor(mockSQS)
+	proc.redriveMax = 3
+	proc.redriveBatchSize = 2
+
+	mockSQS.On("ReceiveMessage", mock.Anything, dlqReceiveOf(2)).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{deadLettered("m1"), deadLettered("m2")}}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, dlqReceiveOf(1)).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{deadLettered("m3")}}, nil).Once()
+	mockSQS.On("SendMessage", mock.Anything, mock.Anything).Return(&sqs.SendMessageOutput{}, nil).Times(3)
+	mockSQS.On("DeleteMessage", mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil).Times(3)
+
+	result, err := proc.Redrive(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, RedriveResult{Redriven: 3}, result)
+	mockSQS.AssertExpectations(t)
+}
+
+func TestRedrive_FIFOQueueKeepsGroup(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := newRedriveProcessor(mockSQS)
+	proc.queueURL = "orders.fifo"
+	msg := deadLettered("m1")
+	msg.Attributes = map[string]string{string(stypes.MessageSystemAttributeNameMessageGroupId): "user-1"}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		return aws.ToString(input.MessageGroupId) == "user-1" && aws.ToString(input.MessageDeduplicationId) == "m1"
+	})).Return(&sqs.SendMessageOutput{}, nil).Once()
+	mockSQS.On("DeleteMessage", mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil).Once()
+
+	_, err := proc.Redrive(context.Background())
+
+	require.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+}
+
+func TestRedrive_NeedsDLQ(t *testing.T) {
+	_, err := (&Processor{queueURL: "test-queue"}).Redrive(context.Background())
+	assert.ErrorIs(t, err, errRedriveNoDLQ)
+}
+
+func TestRedrive_RestoresAttributesDeadLetteringAdded(t *testing.T) {
+	full := map[string]string{}
+	for i := range sqsMaxMessageAttributes {
+		full[fmt.Sprintf("attr_%d", i)] = fmt.Sprintf("v%d", i)
+	}
+	tests := []struct {
+		name     string
+		attrs    map[string]string
+		wantTags []string
+	}{
+		{"few attributes", map[string]string{"correlation_id": "corr-1", "schema_version": "v2"}, []string{"dlq_reason", "source_message_id"}},
+		{"one slot free", func() map[string]string {
+			attrs := maps.Clone(full)
+			delete(attrs, "attr_0")
+			return attrs
+		}(), []string{"dlq_reason"}},
+		{"no slot free", full, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSQS := &MockSQSClient{}
+			proc := newRedriveProcessor(mockSQS)
+			source := withAttributes(bodyMessage("m1", `{"order_id":"o1"}`), tt.attrs)
+
+			copied := proc.dlqInput(context.Background(), source, "invalid_json")
+			assert.LessOrEqual(t, len(copied.MessageAttributes), sqsMaxMessageAttributes)
+			for _, tag := range tt.wantTags {
+				assert.Contains(t, copied.MessageAttributes, tag)
+			}
+			dead := stypes.Message{
+				MessageId:         aws.String("dlq-m1"),
+				ReceiptHandle:     aws.String("r-dlq-m1"),
+				Body:              copied.MessageBody,
+				MessageAttributes: copied.MessageAttributes,
+			}
+
+			var redriven map[string]stypes.MessageAttributeValue
+			mockSQS.On("SendMessage", mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) {
+					redriven = args.Get(1).(*sqs.SendMessageInput).MessageAttributes
+				}).
+				Return(&sqs.SendMessageOutput{}, nil).Once()
+			mockSQS.On("DeleteMessage", mock.Anything, mock.Anything).Return(&sqs.DeleteMessageOutput{}, nil).Once()
+
+			require.NoError(t, proc.redriveMessage(context.Background(), dead))
+			assert.Equal(t, source.MessageAttributes, redriven)
+			mockSQS.AssertExpectations(t)
+		})
+	}
+}
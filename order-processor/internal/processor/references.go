@@ -0,0 +1,208 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// Reference validation defaults
+	defaultReferenceFields   = "product_id"
+	defaultReferenceKeyAttr  = "id"
+	defaultReferenceCacheTTL = 5 * time.Minute
+
+	// maxBatchGetKeys is the most keys BatchGetItem takes per call
+	maxBatchGetKeys = 100
+	// referenceLookupAttempts bounds the calls spent on unprocessed keys,
+	// retried with the batch write backoff
+	referenceLookupAttempts = 3
+)
+
+// ReferenceStore reports which reference IDs, such as product IDs, exist.
+type ReferenceStore interface {
+	Known(ctx context.Context, ids []string) (map[string]bool, error)
+}
+
+// ddbReferenceStore looks references up in a DynamoDB table whose string
+// partition key, keyAttr, holds the reference ID.
+type ddbReferenceStore struct {
+	client  ddbClientI
+	table   string
+	keyAttr string
+	// after waits between attempts; nil is time.After
+	after func(time.Duration) <-chan time.Time
+}
+
+func (s *ddbReferenceStore) Known(ctx context.Context, ids []string) (map[string]bool, error) {
+	after := time.After
+	if s.after != nil {
+		after = s.after
+	}
+	known := make(map[string]bool, len(ids))
+	for chunk := range slices.Chunk(ids, maxBatchGetKeys) {
+		keys := make([]map[string]types.AttributeValue, len(chunk))
+		for i, id := range chunk {
+			keys[i] = map[string]types.AttributeValue{s.keyAttr: &types.AttributeValueMemberS{Value: id}}
+		}
+		request := map[string]types.KeysAndAttributes{s.table: {
+			Keys:                     keys,
+			ProjectionExpression:     aws.String("#k"),
+			ExpressionAttributeNames: map[string]string{"#k": s.keyAttr},
+		}}
+
+		backoff := batchWriteBaseBackoff
+		for attempt := 0; len(request) > 0; attempt++ {
+			if attempt == referenceLookupAttempts {
+				return nil, fmt.Errorf("reference lookup left %d keys unprocessed", len(request[s.table].Keys))
+			}
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-after(backoff):
+				}
+				backoff *= 2
+			}
+			out, err := s.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: request})
+			if err != nil {
+				return nil, fmt.Errorf("batch get references: %w", err)
+			}
+			for _, item := range out.Responses[s.table] {
+				if id, ok := item[s.keyAttr].(*types.AttributeValueMemberS); ok {
+					known[id.Value] = true
+				}
+			}
+			request = out.UnprocessedKeys
+		}
+	}
+	return known, nil
+}
+
+// cachedReferenceStore remembers known references for ttl. Unknown ones
+// are not cached, so a reference added to the table is accepted on the
+// next lookup rather than rejected until the entry expires.
+type cachedReferenceStore struct {
+	store ReferenceStore
+	ttl   time.Duration
+	// now defaults to time.Now
+	now func() time.Time
+
+	mu    sync.Mutex
+	known map[string]time.Time
+}
+
+// WithReferenceStore validates orders' reference fields against store,
+// e.g. an in-memory product catalog. It returns p.
+func (p *Processor) WithReferenceStore(store ReferenceStore, fields ...string) *Processor {
+	if len(fields) == 0 {
+		fields = []string{defaultReferenceFields}
+	}
+	p.references = store
+	p.referenceFields = fields
+	return p
+}
+
+func newCachedReferenceStore(store ReferenceStore, ttl time.Duration) *cachedReferenceStore {
+	return &cachedReferenceStore{store: store, ttl: ttl, known: make(map[string]time.Time)}
+}
+
+func (c *cachedReferenceStore) Known(ctx context.Context, ids []string) (map[string]bool, error) {
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+
+	known := make(map[string]bool, len(ids))
+	var missing []string
+	c.mu.Lock()
+	for _, id := range ids {
+		if at, ok := c.known[id]; ok && now().Sub(at) < c.ttl {
+			known[id] = true
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	c.mu.Unlock()
+	if len(missing) == 0 {
+		return known, nil
+	}
+
+	found, err := c.store.Known(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range missing {
+		if found[id] {
+			known[id] = true
+			c.known[id] = now()
+		}
+	}
+	return known, nil
+}
+
+// checkReferences verifies that every ID in the configured reference
+// fields of body exists. A field may hold one ID or an array of them. A
+// missing field or unknown ID fails the order for good; a failed lookup is
+// retryable.
+func (p *Processor) checkReferences(ctx context.Context, order Order, body string) error {
+	if p.references == nil || len(p.referenceFields) == 0 {
+		return nil
+	}
+	invalid := func(err error) error {
+		return &ValidationError{Type: "invalid_reference", OrderID: order.OrderID, Err: err}
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body), &fields); err != nil {
+		return invalid(fmt.Errorf("read reference fields: %w", err))
+	}
+	var ids []string
+	fieldOf := make(map[string]string)
+	for _, field := range p.referenceFields {
+		raw, ok := fields[field]
+		if !ok || string(raw) == "null" {
+			return invalid(fmt.Errorf("%s is required", field))
+		}
+		var values []string
+		var single string
+		if err := json.Unmarshal(raw, &single); err == nil {
+			values = []string{single}
+		} else if err := json.Unmarshal(raw, &values); err != nil {
+			return invalid(fmt.Errorf("%s must be a string or an array of strings", field))
+		}
+		for _, id := range values {
+			if id == "" {
+				return invalid(fmt.Errorf("%s must not be empty", field))
+			}
+			if _, seen := fieldOf[id]; !seen {
+				fieldOf[id] = field
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	known, err := p.references.Known(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("failed to look up references: %w", err)
+	}
+	for _, id := range ids {
+		if !known[id] {
+			return invalid(fmt.Errorf("unknown %s %q", fieldOf[id], id))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockReferenceStore struct {
+	mock.Mock
+}
+
+func (m *MockReferenceStore) Known(ctx context.Context, ids []string) (map[string]bool, error) {
+	args := m.Called(ctx, ids)
+	known, _ := args.Get(0).(map[string]bool)
+	return known, args.Error(1)
+}
+
+func referenceProcessor(store ReferenceStore) (*Processor, *MockDynamoDBClient) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		ddbClient:       mockDDB,
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+	return proc.WithReferenceStore(store, "product_id"), mockDDB
+}
+
+func TestHandleMessage_KnownReferencesStored(t *testing.T) {
+	store := &MockReferenceStore{}
+	proc, mockDDB := referenceProcessor(store)
+
+	store.On("Known", mock.Anything, []string{"p1", "p2"}).
+		Return(map[string]bool{"p1": true, "p2": true}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	err := proc.handleMessage(context.Background(), bodyMessage("m1",
+		`{"order_id":"o1","user_id":"u1","amount":100,"product_id":["p1","p2","p1"]}`))
+
+	assert.NoError(t, err)
+	store.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestHandleMessage_InvalidReferencesRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		known   map[string]bool
+		wantErr string
+	}{
+		{
+			name:    "unknown product",
+			body:    `{"order_id":"o1","user_id":"u1","amount":100,"product_id":"p9"}`,
+			known:   map[string]bool{},
+			wantErr: `unknown product_id "p9"`,
+		},
+		{
+			name:    "one of several unknown",
+			body:    `{"order_id":"o1","user_id":"u1","amount":100,"product_id":["p1","p9"]}`,
+			known:   map[string]bool{"p1": true},
+			wantErr: `unknown product_id "p9"`,
+		},
+		{
+			name:    "missing field",
+			body:    `{"order_id":"o1","user_id":"u1","amount":100}`,
+			wantErr: "product_id is required",
+		},
+		{
+			name:    "empty ID",
+			body:    `{"order_id":"o1","user_id":"u1","amount":100,"product_id":""}`,
+			wantErr: "product_id must not be empty",
+		},
+		{
+			name:    "wrong type",
+			body:    `{"order_id":"o1","user_id":"u1","amount":100,"product_id":42}`,
+			wantErr: "product_id must be a string or an array of strings",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MockReferenceStore{}
+			proc, mockDDB := referenceProcessor(store)
+			if tt.known != nil {
+				store.On("Known", mock.Anything, mock.Anything).Return(tt.known, nil).Once()
+			}
+
+			err := proc.handleMessage(context.Background(), bodyMessage("m1", tt.body))
+
+			var vErr *ValidationError
+			require.ErrorAs(t, err, &vErr)
+			assert.Equal(t, "invalid_reference", vErr.Type)
+			assert.Equal(t, "o1", vErr.OrderID)
+			assert.ErrorContains(t, err, tt.wantErr)
+			store.AssertExpectations(t)
+			mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestHandleMessage_ReferenceLookupFailureRetryable(t *testing.T) {
+	store := &MockReferenceStore{}
+	proc, mockDDB := referenceProcessor(store)
+
+	store.On("Known", mock.Anything, []string{"p1"}).Return(nil, errors.New("throttled")).Once()
+
+	err := proc.handleMessage(context.Background(), bodyMessage("m1",
+		`{"order_id":"o1","user_id":"u1","amount":100,"product_id":"p1"}`))
+
+	require.Error(t, err)
+	var vErr *ValidationError
+	assert.False(t, errors.As(err, &vErr))
+	mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+}
+
+func TestCachedReferenceStore_CachesKnownOnly(t *testing.T) {
+	store := &MockReferenceStore{}
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	cached := newCachedReferenceStore(store, time.Minute)
+	cached.now = clock.Now
+	ctx := context.Background()
+
+	store.On("Known", mock.Anything, []string{"p1", "p2"}).Return(map[string]bool{"p1": true}, nil).Once()
+	known, err := cached.Known(ctx, []string{"p1", "p2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"p1": true}, known)
+
+	// p1 is served from the cache; p2, unknown, is looked up again
+	store.On("Known", mock.Anything, []string{"p2"}).Return(map[string]bool{"p2": true}, nil).Once()
+	known, err = cached.Known(ctx, []string{"p1", "p2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"p1": true, "p2": true}, known)
+
+	// Once the TTL has passed p1 is looked up again
+	clock.advance(time.Minute)
+	store.On("Known", mock.Anything, []string{"p1"}).Return(map[string]bool{"p1": true}, nil).Once()
+	_, err = cached.Known(ctx, []string{"p1"})
+	require.NoError(t, err)
+
+	store.AssertExpectations(t)
+}
+
+func TestDDBReferenceStore_RetriesUnprocessedKeys(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	store := &ddbReferenceStore{client: mockDDB, table: "Products", keyAttr: "sku"}
+	item := func(id string) map[string]dtypes.AttributeValue {
+		return map[string]dtypes.AttributeValue{"sku": &dtypes.AttributeValueMemberS{Value: id}}
+	}
+	requested := func(ids ...string) interface{} {
+		return mock.MatchedBy(func(input *dynamodb.BatchGetItemInput) bool {
+			keys := input.RequestItems["Products"].Keys
+			if len(keys) != len(ids) {
+				return false
+			}
+			for i, key := range keys {
+				if key["sku"].(*dtypes.AttributeValueMemberS).Value != ids[i] {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	mockDDB.On("BatchGetItem", mock.Anything, requested("p1", "p2", "p3")).Return(&dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]dtypes.AttributeValue{"Products": {item("p1")}},
+		UnprocessedKeys: map[string]dtypes.KeysAndAttributes{"Products": {
+			Keys: []map[string]dtypes.AttributeValue{item("p3")},
+		}},
+	}, nil).Once()
+	mockDDB.On("BatchGetItem", mock.Anything, requested("p3")).Return(&dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]dtypes.AttributeValue{"Products": {item("p3")}},
+	}, nil).Once()
+
+	known, err := store.Known(context.Background(), []string{"p1", "p2", "p3"})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"p1": true, "p3": true}, known)
+	mockDDB.AssertExpectations(t)
+}
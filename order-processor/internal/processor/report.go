@@ -0,0 +1,157 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/rs/zerolog/log"
+)
+
+// ReportPutter uploads processing reports; *s3.Client implements it.
+type ReportPutter interface {
+	PutObject(context.Context, *s3.PutObjectInput, ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// ProcessingReport is the reconciliation summary uploaded by the report
+// sink. Counts are cumulative since the processor started.
+type ProcessingReport struct {
+	Environment    string           `json:"environment"`
+	StartedAt      string           `json:"started_at"`
+	GeneratedAt    string           `json:"generated_at"`
+	Processed      int64            `json:"processed"`
+	Failed         int64            `json:"failed"`
+	FailuresByType map[string]int64 `json:"failures_by_type"`
+	AmountTotal    Decimal          `json:"amount_total"`
+}
+
+// processingStats accumulates the counts reported for reconciliation. A nil
+// *processingStats ignores all updates.
+type processingStats struct {
+	mu             sync.Mutex
+	startedAt      time.Time
+	processed      int64
+	failed         int64
+	failuresByType map[string]int64
+	amountTotal    Decimal
+}
+
+func newProcessingStats(startedAt time.Time) *processingStats {
+	return &processingStats{startedAt: startedAt, failuresByType: make(map[string]int64)}
+}
+
+// success counts a stored order and adds its amount to the total.
+func (s *processingStats) success(amount Decimal) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.processed++
+	s.amountTotal = s.amountTotal.Add(amount)
+}
+
+// failure counts a failed message; validation errors are keyed by their
+// type and everything else as "retryable".
+func (s *processingStats) failure(err error) {
+	if s == nil {
+		return
+	}
+	kind := "retryable"
+	var vErr *ValidationError
+	if errors.As(err, &vErr) {
+		kind = vErr.Type
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed++
+	s.failuresByType[kind]++
+}
+
+// snapshot returns the current counts as a report generated at now.
+func (s *processingStats) snapshot(environment string, now time.Time) ProcessingReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ProcessingReport{
+		Environment:    environment,
+		StartedAt:      s.startedAt.UTC().Format(time.RFC3339),
+		GeneratedAt:    now.UTC().Format(time.RFC3339),
+		Processed:      s.processed,
+		Failed:         s.failed,
+		FailuresByType: maps.Clone(s.failuresByType),
+		AmountTotal:    s.amountTotal,
+	}
+}
+
+// reportSink uploads processing reports to S3: on every interval tick when
+// an interval is configured, and once more when flushed at shutdown. Each
+// upload is a new object named after the time it was generated.
+type reportSink struct {
+	putter      ReportPutter
+	bucket      string
+	prefix      string
+	environment string
+	stats       *processingStats
+	// now defaults to time.Now
+	now func() time.Time
+}
+
+// upload serializes the current stats and puts them under prefix as
+// report-<timestamp>.json.
+func (r *reportSink) upload(ctx context.Context) error {
+	now := time.Now
+	if r.now != nil {
+		now = r.now
+	}
+	generatedAt := now()
+
+	body, err := json.Marshal(r.stats.snapshot(r.environment, generatedAt))
+	if err != nil {
+		return fmt.Errorf("marshal processing report: %w", err)
+	}
+
+	key := path.Join(r.prefix, "report-"+generatedAt.UTC().Format("20060102T150405Z")+".json")
+	_, err = r.putter.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("upload processing report: %w", err)
+	}
+	log.Info().Str("bucket", r.bucket).Str("key", key).Msg("processing report uploaded")
+	return nil
+}
+
+// Flush uploads the final report; it runs once, during shutdown.
+func (r *reportSink) Flush(ctx context.Context) error {
+	return r.upload(ctx)
+}
+
+// run uploads a report every interval until ctx ends. Failed uploads are
+// logged; the next tick tries again with fresh counts.
+func (r *reportSink) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.upload(ctx); err != nil {
+				log.Error().Err(err).Msg("failed to upload processing report")
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockReportPutter struct {
+	mock.Mock
+}
+
+func (m *MockReportPutter) PutObject(
+	ctx context.Context,
+	input *s3.PutObjectInput,
+	opts ...func(*s3.Options),
+) (*s3.PutObjectOutput, error) {
+	args := m.Called(ctx, input)
+	return args.Get(0).(*s3.PutObjectOutput), args.Error(1)
+}
+
+func TestReportSink_UploadedOnShutdown(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	putter := &MockReportPutter{}
+
+	startedAt := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	stats := newProcessingStats(startedAt)
+	report := &reportSink{
+		putter:      putter,
+		bucket:      "reports",
+		prefix:      "orders/daily",
+		environment: "test",
+		stats:       stats,
+		now:         func() time.Time { return startedAt.Add(90 * time.Minute) },
+	}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		stats:           stats,
+		report:          report,
+		sinks:           []Flusher{report},
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			{MessageId: aws.String("m1"), ReceiptHandle: aws.String("r1"), Body: aws.String(`{"order_id":"o1","amount":100}`)},
+			{MessageId: aws.String("m2"), ReceiptHandle: aws.String("r2"), Body: aws.String(`{"order_id":"o2","amount":250.25}`)},
+			{MessageId: aws.String("m3"), ReceiptHandle: aws.String("r3"), Body: aws.String(`not json`)},
+			{MessageId: aws.String("m4"), ReceiptHandle: aws.String("r4"), Body: aws.String(`{"order_id":"o4","amount":7}`)},
+		}}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1", "r2", "r3")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(in *dynamodb.PutItemInput) bool {
+		return in.Item["order_id"].(*dtypes.AttributeValueMemberS).Value == "o4"
+	})).Return((*dynamodb.PutItemOutput)(nil), errors.New("throttled")).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Twice()
+
+	var uploaded ProcessingReport
+	putter.On("PutObject", mock.Anything, mock.MatchedBy(func(in *s3.PutObjectInput) bool {
+		return aws.ToString(in.Bucket) == "reports"
+	})).Run(func(args mock.Arguments) {
+		in := args.Get(1).(*s3.PutObjectInput)
+		assert.Equal(t, "orders/daily/report-20240501T093000Z.json", aws.ToString(in.Key))
+		assert.Equal(t, "application/json", aws.ToString(in.ContentType))
+		body, err := io.ReadAll(in.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &uploaded))
+	}).Return(&s3.PutObjectOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+	putter.AssertNotCalled(t, "PutObject", mock.Anything, mock.Anything)
+
+	pollerDone := make(chan struct{})
+	close(pollerDone)
+	require.NoError(t, runShutdown(proc.shutdownPhases(pollerDone), time.Second))
+
+	putter.AssertExpectations(t)
+	assert.Equal(t, ProcessingReport{
+		Environment:    "test",
+		StartedAt:      "2024-05-01T08:00:00Z",
+		GeneratedAt:    "2024-05-01T09:30:00Z",
+		Processed:      2,
+		Failed:         2,
+		FailuresByType: map[string]int64{"invalid_json": 1, "retryable": 1},
+		AmountTotal:    "350.25",
+	}, uploaded)
+}
+
+func TestReportSink_UploadError(t *testing.T) {
+	putter := &MockReportPutter{}
+	putter.On("PutObject", mock.Anything, mock.Anything).
+		Return((*s3.PutObjectOutput)(nil), errors.New("access denied")).Once()
+
+	report := &reportSink{putter: putter, bucket: "reports", stats: newProcessingStats(time.Now())}
+
+	err := report.Flush(context.Background())
+
+	assert.ErrorContains(t, err, "upload processing report: access denied")
+}
+
+func TestProcessingStats_NilIgnoresUpdates(t *testing.T) {
+	var stats *processingStats
+
+	assert.NotPanics(t, func() {
+		stats.success("100")
+		stats.failure(errors.New("boom"))
+	})
+}
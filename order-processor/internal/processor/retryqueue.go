@@ -0,0 +1,152 @@
+package processor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Retry queue defaults
+	defaultRetryQueueBackoff = 5 * time.Second
+	maxRetryQueueSize        = 10000
+	// retryQueueMaxAttempts is how often a message is retried in-process
+	// before it is left to SQS redelivery
+	retryQueueMaxAttempts = 3
+)
+
+// retryQueue holds messages whose processing failed retryably, so they are
+// retried ahead of fresh messages once a backoff elapses instead of waiting
+// out the visibility timeout. The backoff doubles with each attempt; keep
+// it well under the visibility timeout, after which SQS redelivers the
+// message anyway. A nil *retryQueue holds nothing.
+type retryQueue struct {
+	size    int
+	backoff time.Duration
+
+	mu sync.Mutex
+	// entries is keyed by message ID
+	entries map[string]*retryEntry
+}
+
+type retryEntry struct {
+	msg      types.Message
+	attempts int
+	due      time.Time
+	// taken is set while the message is being retried
+	taken bool
+}
+
+func newRetryQueue(size int, backoff time.Duration) *retryQueue {
+	return &retryQueue{size: size, backoff: backoff, entries: make(map[string]*retryEntry)}
+}
+
+// add schedules msg to be retried. It reports false when msg is not queued
+// because the queue is full or msg has used up its attempts; it is then
+// left to SQS redelivery.
+func (q *retryQueue) add(msg types.Message, now time.Time) bool {
+	if q == nil || msg.MessageId == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	id := *msg.MessageId
+	entry, ok := q.entries[id]
+	if !ok {
+		if len(q.entries) >= q.size {
+			return false
+		}
+		entry = &retryEntry{}
+		q.entries[id] = entry
+	}
+	if entry.attempts >= retryQueueMaxAttempts {
+		delete(q.entries, id)
+		return false
+	}
+
+	entry.msg = msg
+	entry.due = now.Add(q.backoff << entry.attempts)
+	entry.attempts++
+	entry.taken = false
+	return true
+}
+
+// take returns the messages due at now, earliest due first, marking them
+// taken. Entries for messages in fresh are dropped: SQS redelivered them,
+// and the fresh copy supersedes the queued one.
+func (q *retryQueue) take(now time.Time, fresh []types.Message) []types.Message {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, msg := range fresh {
+		delete(q.entries, aws.ToString(msg.MessageId))
+	}
+
+	var due []*retryEntry
+	for _, entry := range q.entries {
+		if !entry.taken && !entry.due.After(now) {
+			due = append(due, entry)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].due.Before(due[j].due) })
+
+	msgs := make([]types.Message, len(due))
+	for i, entry := range due {
+		entry.taken = true
+		msgs[i] = entry.msg
+	}
+	return msgs
+}
+
+// release drops the taken entries for msgs that were not rescheduled by
+// add, i.e. that succeeded, failed for good or were skipped.
+func (q *retryQueue) release(msgs []types.Message) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, msg := range msgs {
+		id := aws.ToString(msg.MessageId)
+		if entry, ok := q.entries[id]; ok && entry.taken {
+			delete(q.entries, id)
+		}
+	}
+}
+
+// remove drops any entries for msgs.
+func (q *retryQueue) remove(msgs []types.Message) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, msg := range msgs {
+		delete(q.entries, aws.ToString(msg.MessageId))
+	}
+}
+
+// retryLater queues a retryably failed message on the retry queue, if
+// there is one.
+func (p *Processor) retryLater(ctx context.Context, msg types.Message) {
+	retries := p.queueOf(ctx).retries
+	if retries == nil {
+		return
+	}
+	if !retries.add(msg, p.now()) {
+		log.Warn().
+			Str("msg_id", aws.ToString(msg.MessageId)).
+			Msg("retry queue full or attempts used up - leaving message to redelivery")
+	}
+}
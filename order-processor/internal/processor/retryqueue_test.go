@@ -0,0 +1,120 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func retryMessage(id string) stypes.Message {
+	return stypes.Message{MessageId: aws.String(id), ReceiptHandle: aws.String("r-" + id)}
+}
+
+func TestPollAndProcess_RetriesFailureAheadOfNewMessages(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		clock:           clock,
+		retries:         newRetryQueue(10, 5*time.Second),
+	}
+	ctx := context.Background()
+
+	var stored []string
+	recordOrder := func(args mock.Arguments) {
+		item := args.Get(1).(*dynamodb.PutItemInput).Item
+		stored = append(stored, item["order_id"].(*dtypes.AttributeValueMemberS).Value)
+	}
+
+	// The first attempt is throttled
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(1)}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(recordOrder).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("ProvisionedThroughputExceededException")).Once()
+	_, err := proc.pollAndProcess(ctx)
+	require.NoError(t, err)
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+
+	// Once its backoff has elapsed it goes ahead of the next fresh message
+	clock.advance(5 * time.Second)
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{{
+			MessageId:     aws.String("msg-new"),
+			Body:          aws.String(`{"order_id":"o-new","user_id":"u1","amount":100}`),
+			ReceiptHandle: aws.String("r-new"),
+		}}}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(recordOrder).
+		Return(&dynamodb.PutItemOutput{}, nil).Twice()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r-new")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+	_, err = proc.pollAndProcess(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"o0", "o0", "o-new"}, stored)
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Empty(t, proc.retries.take(clock.Now().Add(time.Hour), nil))
+}
+
+func TestRetryQueue_BacksOffPerAttempt(t *testing.T) {
+	q := newRetryQueue(10, time.Second)
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	msg := retryMessage("m1")
+
+	for attempt, backoff := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		require.True(t, q.add(msg, now), "attempt %d", attempt+1)
+		assert.Empty(t, q.take(now.Add(backoff-time.Millisecond), nil))
+		now = now.Add(backoff)
+		assert.Equal(t, []stypes.Message{msg}, q.take(now, nil))
+	}
+
+	// Attempts used up: left to SQS redelivery
+	assert.False(t, q.add(msg, now))
+	assert.Empty(t, q.take(now.Add(time.Hour), nil))
+}
+
+func TestRetryQueue_Bounded(t *testing.T) {
+	q := newRetryQueue(1, time.Second)
+	now := time.Now()
+
+	assert.True(t, q.add(retryMessage("m1"), now))
+	assert.False(t, q.add(retryMessage("m2"), now))
+	// Rescheduling a queued message needs no room
+	assert.True(t, q.add(retryMessage("m1"), now))
+}
+
+func TestRetryQueue_ReleaseAndRedelivery(t *testing.T) {
+	q := newRetryQueue(10, time.Second)
+	now := time.Now()
+	m1, m2, m3 := retryMessage("m1"), retryMessage("m2"), retryMessage("m3")
+	q.add(m1, now)
+	q.add(m2, now)
+	q.add(m3, now)
+
+	// m3 was redelivered by SQS, so its queued copy is dropped
+	taken := q.take(now.Add(time.Second), []stypes.Message{m3})
+	assert.ElementsMatch(t, []stypes.Message{m1, m2}, taken)
+
+	// m1 failed again and was rescheduled; m2 is done with
+	q.add(m1, now)
+	q.release(taken)
+	assert.Equal(t, []stypes.Message{m1}, q.take(now.Add(time.Hour), nil))
+}
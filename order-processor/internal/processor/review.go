@@ -0,0 +1,29 @@
+package processor
+
+import "github.com/prometheus/client_golang/prometheus"
+
+func newOrdersByStatusCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "orders_stored_by_status_total",
+			Help: "Stored orders by the status they were stored with",
+		},
+		[]string{"order_status", "env"},
+	)
+}
+
+// orderStatus returns the status order is stored with: PENDING_REVIEW when
+// its amount is above the review threshold, PROCESSED otherwise.
+func (p *Processor) orderStatus(order Order) string {
+	if p.reviewThreshold != nil && order.Amount.Cmp(*p.reviewThreshold) > 0 {
+		return orderStatusPendingReview
+	}
+	return orderStatusProcessed
+}
+
+// countOrderStatus counts a stored order under its status.
+func (p *Processor) countOrderStatus(status string) {
+	if p.ordersByStatus != nil {
+		p.ordersByStatus.WithLabelValues(status, p.environment).Inc()
+	}
+}
@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderStatus(t *testing.T) {
+	threshold := Decimal("1000")
+	tests := []struct {
+		name      string
+		threshold *Decimal
+		amount    Decimal
+		want      string
+	}{
+		{"no threshold", nil, "1000000", orderStatusProcessed},
+		{"below", &threshold, "999.99", orderStatusProcessed},
+		{"at threshold", &threshold, "1000", orderStatusProcessed},
+		{"above", &threshold, "1000.01", orderStatusPendingReview},
+		{"far above", &threshold, "25000", orderStatusPendingReview},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := &Processor{reviewThreshold: tt.threshold}
+			assert.Equal(t, tt.want, proc.orderStatus(Order{Amount: tt.amount}))
+		})
+	}
+}
+
+func TestPollAndProcess_LargeOrdersPendingReview(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	threshold := Decimal("500")
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		ordersByStatus:  newOrdersByStatusCounter(),
+		environment:     "test",
+		reviewThreshold: &threshold,
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			bodyMessage("m1", `{"order_id":"o1","user_id":"u1","amount":100}`),
+			bodyMessage("m2", `{"order_id":"o2","user_id":"u1","amount":750.5}`),
+		}}, nil).Once()
+	statuses := map[string]string{}
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			item := args.Get(1).(*dynamodb.PutItemInput).Item
+			statuses[item["order_id"].(*dtypes.AttributeValueMemberS).Value] = item["status"].(*dtypes.AttributeValueMemberS).Value
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Twice()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, mock.Anything).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"o1": "PROCESSED", "o2": "PENDING_REVIEW"}, statuses)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersByStatus.WithLabelValues("PROCESSED", "test")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersByStatus.WithLabelValues("PENDING_REVIEW", "test")))
+	mockDDB.AssertExpectations(t)
+}
+
+func TestNewProcessor_InvalidReviewThreshold(t *testing.T) {
+	for _, name := range []string{envReportBucket, envOTLPEndpoint, envSQSQueueURLs} {
+		t.Setenv(name, "")
+	}
+	t.Setenv(envReviewThreshold, "lots")
+
+	_, err := NewProcessor(context.Background(),
+		WithQueueURL("test-queue"),
+		WithTableName("Orders"),
+		WithSQSClient(&MockSQSClient{}),
+		WithDDBClient(&MockDynamoDBClient{}),
+	)
+	assert.ErrorContains(t, err, "invalid REVIEW_THRESHOLD")
+}
@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Message attributes producers use to describe the order in a body
+const (
+	schemaVersionAttribute = "schema_version"
+	sourceSystemAttribute  = "source_system"
+)
+
+// Order schema versions. Version 1, assumed when a message names none, is
+// the flat schema with amount as a JSON number; version 2 carries amount as
+// a decimal string, so producers never round it through a float.
+const (
+	schemaV1 = "1"
+	schemaV2 = "2"
+)
+
+// orderParser parses a body of one schema version into the canonical Order.
+type orderParser func(p *Processor, body string) (Order, error)
+
+// orderSchemas maps each supported schema version to its parser. Adding a
+// version means adding its parser here.
+var orderSchemas = map[string]orderParser{
+	schemaV1: (*Processor).parseOrder,
+	schemaV2: (*Processor).parseOrderV2,
+}
+
+// messageAttribute returns the string value of msg's name attribute, or ""
+// when it is unset.
+func messageAttribute(msg types.Message, name string) string {
+	if attr, ok := msg.MessageAttributes[name]; ok && attr.StringValue != nil {
+		return *attr.StringValue
+	}
+	return ""
+}
+
+// schemaVersion returns the schema version of body: its own
+// schema_version field, or failing that msg's schema_version attribute,
+// or version 1. Versions may be written "2", 2 or "v2".
+func schemaVersion(msg types.Message, body string) string {
+	var versioned struct {
+		SchemaVersion json.RawMessage `json:"schema_version"`
+	}
+	version := ""
+	// A body that is not JSON is left for the parser to reject
+	if json.Unmarshal([]byte(body), &versioned) == nil && len(versioned.SchemaVersion) > 0 {
+		version = string(versioned.SchemaVersion)
+		var s string
+		if json.Unmarshal(versioned.SchemaVersion, &s) == nil {
+			version = s
+		}
+	}
+	if version == "" || version == "null" {
+		version = messageAttribute(msg, schemaVersionAttribute)
+	}
+	version = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "v")
+	if version == "" {
+		return schemaV1
+	}
+	return version
+}
+
+// parseMessageOrder parses body, the body of msg, with the decoder of
+// MESSAGE_FORMAT, or as JSON of its schema version by default, and records
+// the system msg says it came from.
+func (p *Processor) parseMessageOrder(msg types.Message, body string) (Order, error) {
+	var order Order
+	var err error
+	if p.decoder != nil {
+		order, err = p.decoder.Decode([]byte(body))
+	} else {
+		order, err = p.parseJSONOrder(msg, body)
+	}
+	if err != nil {
+		return Order{}, err
+	}
+	order.SourceSystem = messageAttribute(msg, sourceSystemAttribute)
+	return order, nil
+}
+
+// parseJSONOrder parses the JSON body of msg with the parser of its
+// schema version. An unknown version is a validation error.
+func (p *Processor) parseJSONOrder(msg types.Message, body string) (Order, error) {
+	version := schemaVersion(msg, body)
+	parse, ok := orderSchemas[version]
+	if !ok {
+		return Order{}, &ValidationError{
+			Type: "unsupported_schema_version",
+			Err:  fmt.Errorf("unsupported schema_version %q", version),
+		}
+	}
+	return parse(p, body)
+}
+
+// parseOrderV2 is parseOrder for schema version 2, where amount is a string
+// such as "99.99". It is then checked like a version 1 amount.
+func (p *Processor) parseOrderV2(body string) (Order, error) {
+	var payload orderPayload
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		return Order{}, &ValidationError{Type: "invalid_json", Err: fmt.Errorf("invalid JSON: %w", err)}
+	}
+
+	order := payload.Order
+	raw := payload.Amount
+	if len(raw) > 0 && string(raw) != "null" {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil || s == "" {
+			return Order{}, &ValidationError{
+				Type:    "invalid_amount",
+				OrderID: order.OrderID,
+				Err:     fmt.Errorf("amount must be a decimal string, got %s", raw),
+			}
+		}
+		raw = json.RawMessage(s)
+	}
+	amount, err := p.checkAmount(raw)
+	if err != nil {
+		return Order{}, &ValidationError{Type: "invalid_amount", OrderID: order.OrderID, Err: err}
+	}
+	order.Amount = amount
+	return order, nil
+}
@@ -0,0 +1,164 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func withAttributes(msg stypes.Message, attrs map[string]string) stypes.Message {
+	msg.MessageAttributes = map[string]stypes.MessageAttributeValue{}
+	for name, value := range attrs {
+		msg.MessageAttributes[name] = stypes.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+	}
+	return msg
+}
+
+func TestHandleMessage_SchemaVersionAndSourceSystem(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		attrs      map[string]string
+		wantAmount string
+		wantSource string
+	}{
+		{
+			name:       "no attributes",
+			body:       `{"order_id":"o1","user_id":"u1","amount":100}`,
+			wantAmount: "100",
+		},
+		{
+			name:       "version 1",
+			body:       `{"order_id":"o1","user_id":"u1","amount":100}`,
+			attrs:      map[string]string{schemaVersionAttribute: "1", sourceSystemAttribute: "web"},
+			wantAmount: "100",
+			wantSource: "web",
+		},
+		{
+			name:       "version 2 string amount",
+			body:       `{"order_id":"o1","user_id":"u1","amount":"99.99"}`,
+			attrs:      map[string]string{schemaVersionAttribute: "2", sourceSystemAttribute: "pos"},
+			wantAmount: "99.99",
+			wantSource: "pos",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDDB := &MockDynamoDBClient{}
+			proc := &Processor{
+				ddbClient:       mockDDB,
+				tableName:       "Orders",
+				ordersProcessed: NewCounterVec(),
+				environment:     "test",
+			}
+			var item map[string]dtypes.AttributeValue
+			mockDDB.On("PutItem", mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { item = args.Get(1).(*dynamodb.PutItemInput).Item }).
+				Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+			err := proc.handleMessage(context.Background(), withAttributes(bodyMessage("m1", tt.body), tt.attrs))
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAmount, item["amount"].(*dtypes.AttributeValueMemberN).Value)
+			if tt.wantSource == "" {
+				assert.NotContains(t, item, "source_system")
+			} else {
+				assert.Equal(t, tt.wantSource, item["source_system"].(*dtypes.AttributeValueMemberS).Value)
+			}
+		})
+	}
+}
+
+func TestHandleMessage_SchemaVersionRejections(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		version  string
+		wantType string
+	}{
+		{name: "unknown version", body: `{"order_id":"o1","amount":1}`, version: "3", wantType: "unsupported_schema_version"},
+		{name: "version 2 number amount", body: `{"order_id":"o1","amount":1}`, version: "2", wantType: "invalid_amount"},
+		{name: "version 2 bad decimal", body: `{"order_id":"o1","amount":"abc"}`, version: "2", wantType: "invalid_amount"},
+		{name: "version 1 string amount", body: `{"order_id":"o1","amount":"1"}`, version: "1", wantType: "invalid_amount"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDDB := &MockDynamoDBClient{}
+			proc := &Processor{ddbClient: mockDDB, tableName: "Orders", ordersProcessed: NewCounterVec(), environment: "test"}
+
+			err := proc.handleMessage(context.Background(),
+				withAttributes(bodyMessage("m1", tt.body), map[string]string{schemaVersionAttribute: tt.version}))
+
+			var vErr *ValidationError
+			require.ErrorAs(t, err, &vErr)
+			assert.Equal(t, tt.wantType, vErr.Type)
+			mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		attr string
+		want string
+	}{
+		{name: "none", body: `{"order_id":"o1"}`, want: schemaV1},
+		{name: "attribute", body: `{"order_id":"o1"}`, attr: "2", want: schemaV2},
+		{name: "body string", body: `{"schema_version":"v2"}`, want: schemaV2},
+		{name: "body number", body: `{"schema_version":2}`, want: schemaV2},
+		{name: "body wins over attribute", body: `{"schema_version":"1"}`, attr: "2", want: schemaV1},
+		{name: "null body version", body: `{"schema_version":null}`, attr: "V2", want: schemaV2},
+		{name: "invalid JSON", body: `not json`, attr: "2", want: schemaV2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := bodyMessage("m1", tt.body)
+			if tt.attr != "" {
+				msg = withAttributes(msg, map[string]string{schemaVersionAttribute: tt.attr})
+			}
+			assert.Equal(t, tt.want, schemaVersion(msg, tt.body))
+		})
+	}
+}
+
+func TestParseMessageOrder_RegisteredVersions(t *testing.T) {
+	bodies := map[string]string{
+		schemaV1: `{"schema_version":"v1","order_id":"o1","user_id":"u1","amount":12.5}`,
+		schemaV2: `{"schema_version":"v2","order_id":"o1","user_id":"u1","amount":"12.5"}`,
+	}
+	require.Len(t, bodies, len(orderSchemas), "every registered version needs a sample")
+	proc := &Processor{}
+
+	for version := range orderSchemas {
+		t.Run("v"+version, func(t *testing.T) {
+			body, ok := bodies[version]
+			require.True(t, ok)
+
+			order, err := proc.parseMessageOrder(bodyMessage("m1", body), body)
+
+			require.NoError(t, err)
+			assert.Equal(t, "o1", order.OrderID)
+			assert.Equal(t, "u1", order.UserID)
+			assert.Equal(t, Decimal("12.5"), order.Amount)
+		})
+	}
+
+	body := `{"schema_version":"v9","order_id":"o1"}`
+	_, err := proc.parseMessageOrder(bodyMessage("m1", body), body)
+	var vErr *ValidationError
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "unsupported_schema_version", vErr.Type)
+	assert.ErrorContains(t, err, `"9"`)
+}
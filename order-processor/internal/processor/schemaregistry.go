@@ -0,0 +1,210 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// schemaIDAttribute is the message attribute naming the registered
+	// schema a body was produced against
+	schemaIDAttribute = "schema_id"
+
+	schemaRegistryFetchTimeout = 5 * time.Second
+
+	// Schema types a registry reports. A registry that reports none means
+	// Avro, as Confluent's does.
+	schemaTypeJSON = "JSON"
+	schemaTypeAvro = "AVRO"
+)
+
+// errSchemaNotFound is returned by a SchemaRegistry for an ID it has no
+// schema under.
+var errSchemaNotFound = errors.New("schema not found")
+
+// RegisteredSchema is a schema as a registry stores it: its type and its
+// definition text.
+type RegisteredSchema struct {
+	Type   string
+	Schema string
+}
+
+// SchemaRegistry returns the schema registered under an ID, or
+// errSchemaNotFound.
+type SchemaRegistry interface {
+	Schema(ctx context.Context, id string) (RegisteredSchema, error)
+}
+
+// WithSchemaRegistry validates the body of every message carrying a
+// schema_id attribute against that schema in registry (see checkContract).
+func (p *Processor) WithSchemaRegistry(registry SchemaRegistry) *Processor {
+	p.contracts = newContractValidator(registry)
+	return p
+}
+
+// checkContract validates body, the body of msg, against the schema msg
+// names in its schema_id attribute. Messages without one are not checked.
+// A body breaking its contract, or naming a schema the registry does not
+// have, is a validation error and so is dead-lettered; a registry that
+// cannot be reached fails retryably.
+func (p *Processor) checkContract(ctx context.Context, msg types.Message, body string) error {
+	if p.contracts == nil {
+		return nil
+	}
+	id := messageAttribute(msg, schemaIDAttribute)
+	if id == "" {
+		return nil
+	}
+
+	c, err := p.contracts.contract(ctx, id)
+	var vErr *ValidationError
+	if errors.As(err, &vErr) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("fetch schema %s: %w", id, err)
+	}
+
+	var value any
+	if err := decodeJSONNumbers(body, &value); err != nil {
+		return &ValidationError{Type: "contract_violation", Err: fmt.Errorf("schema %s: body is not JSON: %w", id, err)}
+	}
+	if err := c.validate("$", value); err != nil {
+		return &ValidationError{Type: "contract_violation", Err: fmt.Errorf("schema %s: %w", id, err)}
+	}
+	return nil
+}
+
+// contractValidator fetches and compiles each registered schema once. A
+// registry never changes the schema under an ID, so compiled schemas are
+// kept for the processor's lifetime; failed fetches are not cached.
+type contractValidator struct {
+	registry SchemaRegistry
+
+	mu       sync.Mutex
+	compiled map[string]contract
+}
+
+func newContractValidator(registry SchemaRegistry) *contractValidator {
+	return &contractValidator{registry: registry, compiled: make(map[string]contract)}
+}
+
+// contract returns the compiled schema registered under id. An unknown ID
+// or a schema that cannot be compiled is a ValidationError.
+func (v *contractValidator) contract(ctx context.Context, id string) (contract, error) {
+	v.mu.Lock()
+	c, ok := v.compiled[id]
+	v.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	registered, err := v.registry.Schema(ctx, id)
+	if errors.Is(err, errSchemaNotFound) {
+		return nil, &ValidationError{Type: "contract_violation", Err: fmt.Errorf("schema %s: %w", id, err)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c, err = compileContract(registered); err != nil {
+		return nil, &ValidationError{Type: "invalid_schema", Err: fmt.Errorf("schema %s: %w", id, err)}
+	}
+
+	v.mu.Lock()
+	v.compiled[id] = c
+	v.mu.Unlock()
+	return c, nil
+}
+
+// httpSchemaRegistry reads schemas from a Confluent-compatible registry:
+// GET {baseURL}/schemas/ids/{id} returning {"schema": "...",
+// "schemaType": "JSON"}.
+type httpSchemaRegistry struct {
+	client  *http.Client
+	baseURL string
+}
+
+func newHTTPSchemaRegistry(baseURL string) *httpSchemaRegistry {
+	return &httpSchemaRegistry{
+		client:  &http.Client{Timeout: schemaRegistryFetchTimeout},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (h *httpSchemaRegistry) Schema(ctx context.Context, id string) (RegisteredSchema, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.baseURL+"/schemas/ids/"+url.PathEscape(id), nil)
+	if err != nil {
+		return RegisteredSchema{}, fmt.Errorf("build request: %w", err)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return RegisteredSchema{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return RegisteredSchema{}, errSchemaNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return RegisteredSchema{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return RegisteredSchema{}, fmt.Errorf("decode schema: %w", err)
+	}
+	schemaType := strings.ToUpper(body.SchemaType)
+	if schemaType == "" {
+		schemaType = schemaTypeAvro
+	}
+	return RegisteredSchema{Type: schemaType, Schema: body.Schema}, nil
+}
+
+// compileContract parses a registered schema into a contract.
+func compileContract(s RegisteredSchema) (contract, error) {
+	var def any
+	if err := decodeJSONNumbers(s.Schema, &def); err != nil {
+		return nil, fmt.Errorf("parse %s schema: %w", s.Type, err)
+	}
+	var c contract
+	var err error
+	switch strings.ToUpper(s.Type) {
+	case schemaTypeJSON:
+		c, err = compileJSONSchema(def)
+	case schemaTypeAvro, "":
+		c, err = compileAvroSchema(def)
+	default:
+		return nil, fmt.Errorf("unsupported schema type %q", s.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// decodeJSONNumbers decodes the JSON document in s into v, numbers as
+// json.Number. Anything after the document is an error.
+func decodeJSONNumbers(s string, v any) error {
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected data after the JSON document")
+	}
+	return nil
+}
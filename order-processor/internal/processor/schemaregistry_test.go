@@ -0,0 +1,200 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockSchemaRegistry struct {
+	mock.Mock
+}
+
+func (m *MockSchemaRegistry) Schema(ctx context.Context, id string) (RegisteredSchema, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(RegisteredSchema), args.Error(1)
+}
+
+const orderJSONSchema = `{
+	"type": "object",
+	"required": ["order_id", "user_id", "amount"],
+	"properties": {
+		"order_id": {"type": "string", "minLength": 1},
+		"user_id": {"type": "string"},
+		"amount": {"type": "number", "minimum": 0},
+		"currency": {"enum": ["USD", "EUR"]}
+	}
+}`
+
+const orderAvroSchema = `{
+	"type": "record", "name": "Order", "namespace": "shop",
+	"fields": [
+		{"name": "order_id", "type": "string"},
+		{"name": "user_id", "type": "string"},
+		{"name": "amount", "type": "double"},
+		{"name": "currency", "type": ["null", {"type": "enum", "name": "Currency", "symbols": ["USD", "EUR"]}], "default": null},
+		{"name": "items", "type": {"type": "array", "items": {
+			"type": "record", "name": "Item",
+			"fields": [{"name": "item_id", "type": "string"}, {"name": "quantity", "type": "int"}]
+		}}, "default": []}
+	]
+}`
+
+func newContractProcessor(mockSQS *MockSQSClient, mockDDB *MockDynamoDBClient, registry SchemaRegistry) *Processor {
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		dlqURL:          "test-dlq",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+	return proc.WithSchemaRegistry(registry)
+}
+
+func TestProcessMessage_ValidContractStored(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	registry := &MockSchemaRegistry{}
+	proc := newContractProcessor(mockSQS, mockDDB, registry)
+
+	// The schema is fetched once and reused
+	registry.On("Schema", mock.Anything, "7").
+		Return(RegisteredSchema{Type: schemaTypeJSON, Schema: orderJSONSchema}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Twice()
+
+	for _, id := range []string{"m1", "m2"} {
+		msg := withAttributes(bodyMessage(id, `{"order_id":"o1","user_id":"u1","amount":10.5,"currency":"EUR"}`),
+			map[string]string{schemaIDAttribute: "7"})
+		assert.True(t, proc.processMessage(context.Background(), msg))
+	}
+
+	registry.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	mockSQS.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything)
+}
+
+func TestProcessMessage_ContractViolationDeadLettered(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  RegisteredSchema
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "JSON missing field",
+			schema:  RegisteredSchema{Type: schemaTypeJSON, Schema: orderJSONSchema},
+			body:    `{"order_id":"o1","amount":10}`,
+			wantErr: "$.user_id is required",
+		},
+		{
+			name:    "JSON wrong type",
+			schema:  RegisteredSchema{Type: schemaTypeJSON, Schema: orderJSONSchema},
+			body:    `{"order_id":"o1","user_id":"u1","amount":"10"}`,
+			wantErr: "$.amount must be number, got string",
+		},
+		{
+			name:    "JSON enum",
+			schema:  RegisteredSchema{Type: schemaTypeJSON, Schema: orderJSONSchema},
+			body:    `{"order_id":"o1","user_id":"u1","amount":10,"currency":"GBP"}`,
+			wantErr: "$.currency is not one of the allowed values",
+		},
+		{
+			name:    "Avro nested record",
+			schema:  RegisteredSchema{Type: schemaTypeAvro, Schema: orderAvroSchema},
+			body:    `{"order_id":"o1","user_id":"u1","amount":10,"items":[{"item_id":"i1","quantity":1.5}]}`,
+			wantErr: "$.items[0].quantity must be an integer",
+		},
+		{
+			name:    "Avro union",
+			schema:  RegisteredSchema{Type: schemaTypeAvro, Schema: orderAvroSchema},
+			body:    `{"order_id":"o1","user_id":"u1","amount":10,"currency":"GBP"}`,
+			wantErr: "$.currency matches no type of its union",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSQS := &MockSQSClient{}
+			mockDDB := &MockDynamoDBClient{}
+			registry := &MockSchemaRegistry{}
+			proc := newContractProcessor(mockSQS, mockDDB, registry)
+
+			registry.On("Schema", mock.Anything, "7").Return(tt.schema, nil).Once()
+			mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+				return aws.ToString(input.QueueUrl) == "test-dlq" &&
+					aws.ToString(input.MessageAttributes["dlq_reason"].StringValue) == "contract_violation"
+			})).Return(&sqs.SendMessageOutput{}, nil).Once()
+
+			msg := withAttributes(bodyMessage("m1", tt.body), map[string]string{schemaIDAttribute: "7"})
+			assert.ErrorContains(t, proc.handleMessage(context.Background(), msg), tt.wantErr)
+			assert.True(t, proc.processMessage(context.Background(), msg), "dead-lettered and deleted")
+
+			mockSQS.AssertExpectations(t)
+			mockDDB.AssertNotCalled(t, "PutItem", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestHandleMessage_ContractRegistryFailures(t *testing.T) {
+	registry := &MockSchemaRegistry{}
+	proc := newContractProcessor(&MockSQSClient{}, &MockDynamoDBClient{}, registry)
+	msg := func(id string) stypes.Message {
+		return withAttributes(bodyMessage("m1", `{"order_id":"o1"}`), map[string]string{schemaIDAttribute: id})
+	}
+
+	registry.On("Schema", mock.Anything, "down").Return(RegisteredSchema{}, errors.New("connection refused")).Once()
+	err := proc.handleMessage(context.Background(), msg("down"))
+	var vErr *ValidationError
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &vErr), "an unreachable registry is retried")
+
+	registry.On("Schema", mock.Anything, "gone").Return(RegisteredSchema{}, errSchemaNotFound).Once()
+	err = proc.handleMessage(context.Background(), msg("gone"))
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "contract_violation", vErr.Type)
+
+	registry.On("Schema", mock.Anything, "bad").Return(RegisteredSchema{Type: "PROTOBUF", Schema: `{}`}, nil).Once()
+	err = proc.handleMessage(context.Background(), msg("bad"))
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "invalid_schema", vErr.Type)
+
+	registry.AssertExpectations(t)
+}
+
+func TestHTTPSchemaRegistry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/schemas/ids/1":
+			_, _ = w.Write([]byte(`{"schema":"{\"type\":\"string\"}","schemaType":"JSON"}`))
+		case "/schemas/ids/2":
+			_, _ = w.Write([]byte(`{"schema":"\"string\""}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	registry := newHTTPSchemaRegistry(srv.URL + "/")
+
+	schema, err := registry.Schema(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, RegisteredSchema{Type: schemaTypeJSON, Schema: `{"type":"string"}`}, schema)
+
+	schema, err = registry.Schema(context.Background(), "2")
+	require.NoError(t, err)
+	assert.Equal(t, schemaTypeAvro, schema.Type, "no schemaType means Avro")
+
+	_, err = registry.Schema(context.Background(), "3")
+	assert.ErrorIs(t, err, errSchemaNotFound)
+}
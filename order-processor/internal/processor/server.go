@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// metricsAddrFromEnv reads METRICS_ADDR, a host:port or bare port such as
+// "9091", defaulting to defaultMetricsAddr.
+func metricsAddrFromEnv() (string, error) {
+	addr := envString(envMetricsAddr, defaultMetricsAddr)
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err == nil {
+		_, err = strconv.ParseUint(port, 10, 16)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid %s %q: want host:port or port", envMetricsAddr, os.Getenv(envMetricsAddr))
+	}
+	return addr, nil
+}
+
+// StartMetricsServer serves the metrics, config, health, readiness and
+// admin endpoints on metricsAddr until the processor shuts down. It returns once
+// the address is bound, or with the error binding it.
+func (p *Processor) StartMetricsServer() error {
+	addr := p.metricsAddr
+	if addr == "" {
+		addr = defaultMetricsAddr
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	server := &http.Server{
+		// The bound address, resolving a port of 0
+		Addr:    listener.Addr().String(),
+		Handler: p.httpHandler(),
+	}
+	p.metricsServer = server
+
+	go func() {
+		log.Info().
+			Str("addr", server.Addr).
+			Str("metrics_path", metricsPath).
+			Str("health_path", healthPath).
+			Str("readiness_path", readinessPath).
+			Msg("starting HTTP server for metrics and health checks")
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("HTTP server failed")
+		}
+	}()
+	return nil
+}
+
+// httpHandler routes the endpoints served by StartMetricsServer.
+func (p *Processor) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	// Exemplars are only exposed in the OpenMetrics format, which scrapers
+	// ask for in their Accept header
+	var metrics http.Handler = promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+	var config http.Handler = http.HandlerFunc(p.handleConfig)
+	// Probes stay unauthenticated
+	if p.metricsToken != "" {
+		metrics = requireBearerToken(p.metricsToken, metrics)
+		config = requireBearerToken(p.metricsToken, config)
+	}
+	mux.Handle(metricsPath, metrics)
+	mux.Handle(configPath, config)
+	mux.HandleFunc(healthPath, p.handleHealth)
+	mux.HandleFunc(readinessPath, p.handleReady)
+	p.registerAdminHandlers(mux, p.adminToken)
+	return mux
+}
+
+func (p *Processor) shutdownMetricsServer(ctx context.Context) error {
+	if p.metricsServer == nil {
+		return nil
+	}
+
+	if err := p.metricsServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shut down metrics server: %w", err)
+	}
+	log.Info().Msg("metrics server shut down gracefully")
+	return nil
+}
@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPHandler_ServesProcessorRegistry(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	ordersProcessed := NewCounterVec()
+	registry.MustRegister(ordersProcessed)
+	ordersProcessed.WithLabelValues("success", "test", "test-queue").Inc()
+	proc := &Processor{
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		registry:        registry,
+		ordersProcessed: ordersProcessed,
+	}
+	handler := proc.httpHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `orders_processed_total{env="test",queue="test-queue",status="success"} 1`)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, healthPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"healthy"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, readinessPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// No admin token, no admin endpoints
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, adminReadOnlyPath, nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHTTPHandler_MetricsBearerToken(t *testing.T) {
+	proc := &Processor{
+		queueURL:     "test-queue",
+		tableName:    "Orders",
+		registry:     prometheus.NewRegistry(),
+		metricsToken: "s3cret",
+	}
+	handler := proc.httpHandler()
+
+	for auth, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Basic s3cret":  http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, metricsPath, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, want, rec.Code, auth)
+	}
+
+	// Probes need no token
+	for _, path := range []string{healthPath, readinessPath} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, rec.Code, path)
+	}
+}
+
+func TestMetricsAddrFromEnv(t *testing.T) {
+	for value, want := range map[string]string{
+		"":               ":9090",
+		"9091":           ":9091",
+		":9091":          ":9091",
+		"127.0.0.1:9091": "127.0.0.1:9091",
+		"[::1]:9091":     "[::1]:9091",
+	} {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv(envMetricsAddr, value)
+			addr, err := metricsAddrFromEnv()
+			require.NoError(t, err)
+			assert.Equal(t, want, addr)
+		})
+	}
+
+	for _, value := range []string{"http", "localhost:http", "localhost:70000", "::1"} {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv(envMetricsAddr, value)
+			_, err := metricsAddrFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestStartMetricsServer_ListensOnMetricsAddr(t *testing.T) {
+	proc := &Processor{
+		queueURL:    "test-queue",
+		tableName:   "Orders",
+		registry:    prometheus.NewRegistry(),
+		metricsAddr: "127.0.0.1:0",
+	}
+	require.NoError(t, proc.StartMetricsServer())
+	t.Cleanup(func() { _ = proc.shutdownMetricsServer(context.Background()) })
+
+	resp, err := http.Get("http://" + proc.metricsServer.Addr + healthPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The address is taken now
+	other := &Processor{registry: prometheus.NewRegistry(), metricsAddr: proc.metricsServer.Addr}
+	assert.Error(t, other.StartMetricsServer())
+}
@@ -0,0 +1,18 @@
+package processor
+
+// WithShadowTable puts the processor in shadow mode, for trying a new
+// version against the stable one: orders are written to table instead of
+// the orders table, and messages are never deleted, dead-lettered or
+// recorded as failures, so the production processor still handles every
+// one and a comparator can diff the two tables. Line items are stored
+// with their order rather than in ITEMS_TABLE, and tenants' orders go to
+// table too.
+func (p *Processor) WithShadowTable(table string) *Processor {
+	p.shadow = true
+	p.tableName = table
+	p.tenantTables = nil
+	p.dlqURL = ""
+	p.failuresTable = ""
+	p.lineItems = nil
+	return p
+}
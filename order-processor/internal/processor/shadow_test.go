@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newShadowProcessor(mockSQS *MockSQSClient, mockDDB *MockDynamoDBClient) *Processor {
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		dlqURL:          "test-dlq",
+		failuresTable:   "Failures",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+	return proc.WithShadowTable("OrdersShadow")
+}
+
+func TestPollAndProcess_ShadowWritesShadowTableAndNeverDeletes(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newShadowProcessor(mockSQS, mockDDB)
+
+	msgs := append(orderBatch(2), stypes.Message{
+		MessageId:     aws.String("bad"),
+		Body:          aws.String(`invalid`),
+		ReceiptHandle: aws.String("rb"),
+	})
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return aws.ToString(input.TableName) == "OrdersShadow"
+	})).Return(&dynamodb.PutItemOutput{}, nil).Twice()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	mockDDB.AssertExpectations(t)
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+	mockSQS.AssertNotCalled(t, "DeleteMessage", mock.Anything, mock.Anything)
+	// The invalid message is left for production to dead-letter
+	mockSQS.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything)
+	assert.Equal(t, 2.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+}
+
+func TestNewProcessor_ShadowMode(t *testing.T) {
+	for _, name := range []string{envReportBucket, envOTLPEndpoint, envSQSQueueURLs} {
+		t.Setenv(name, "")
+	}
+	newProc := func() (*Processor, error) {
+		return NewProcessor(context.Background(),
+			WithQueueURL("test-queue"),
+			WithTableName("Orders"),
+			WithSQSClient(&MockSQSClient{}),
+			WithDDBClient(&MockDynamoDBClient{}),
+		)
+	}
+
+	t.Setenv(envShadowMode, "true")
+	t.Setenv(envShadowTable, "")
+	_, err := newProc()
+	assert.ErrorContains(t, err, "SHADOW_MODE requires SHADOW_TABLE")
+
+	t.Setenv(envShadowTable, "OrdersShadow")
+	t.Setenv(envItemsTable, "OrderItems")
+	proc, err := newProc()
+	require.NoError(t, err)
+	assert.True(t, proc.shadow)
+	assert.Equal(t, "OrdersShadow", proc.tableName)
+	assert.Nil(t, proc.lineItems)
+}
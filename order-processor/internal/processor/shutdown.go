@@ -0,0 +1,125 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Default bound on each shutdown phase
+	defaultShutdownPhaseTimeout = 5 * time.Second
+	// Default bound on finishing the batch in hand at shutdown
+	defaultShutdownTimeout = 20 * time.Second
+)
+
+// shutdownPhase is one step of the ordered shutdown sequence.
+type shutdownPhase struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// Flusher is implemented by sinks that buffer work and must be flushed
+// before the process exits.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// runShutdown runs phases in order, each bounded by timeout. A phase that
+// fails or overruns is logged and abandoned so later phases still get to
+// release their resources; all phase errors are returned joined.
+func runShutdown(phases []shutdownPhase, timeout time.Duration) error {
+	var errs []error
+	for _, phase := range phases {
+		if err := runShutdownPhase(phase, timeout); err != nil {
+			log.Error().Str("phase", phase.name).Err(err).Msg("shutdown phase failed")
+			errs = append(errs, fmt.Errorf("shutdown phase %s: %w", phase.name, err))
+			continue
+		}
+		log.Info().Str("phase", phase.name).Msg("shutdown phase complete")
+	}
+	return errors.Join(errs...)
+}
+
+func runShutdownPhase(phase shutdownPhase, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- phase.run(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drainBatch waits for the poller to finish the batch it was handling when
+// shutdown began. Past p.shutdownTimeout the batch is abandoned via
+// abandon; whatever it had not deleted is redelivered once its visibility
+// timeout lapses.
+func (p *Processor) drainBatch(pollerDone <-chan struct{}, abandon context.CancelFunc) {
+	timeout := p.shutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	// A batch already drained is not raced against a clock whose waits
+	// end at once
+	select {
+	case <-pollerDone:
+		log.Info().Msg("in-flight batch drained")
+		return
+	default:
+	}
+	select {
+	case <-pollerDone:
+		log.Info().Msg("in-flight batch drained")
+	case <-p.after(timeout):
+		log.Warn().Dur("timeout", timeout).Msg("in-flight batch not drained in time - abandoning it")
+		abandon()
+	}
+}
+
+// shutdownPhases returns the processor's shutdown sequence: stop polling,
+// drain in-flight handlers, flush sinks, then stop the metrics server.
+func (p *Processor) shutdownPhases(pollerDone <-chan struct{}) []shutdownPhase {
+	return []shutdownPhase{
+		{name: "pollers", run: func(ctx context.Context) error {
+			return waitFor(ctx, pollerDone)
+		}},
+		{name: "handlers", run: func(ctx context.Context) error {
+			drained := make(chan struct{})
+			go func() {
+				p.inFlight.Wait()
+				close(drained)
+			}()
+			return waitFor(ctx, drained)
+		}},
+		{name: "sinks", run: func(ctx context.Context) error {
+			var errs []error
+			for _, sink := range p.sinks {
+				if err := sink.Flush(ctx); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			return errors.Join(errs...)
+		}},
+		{name: "metrics", run: p.shutdownMetricsServer},
+	}
+}
+
+// waitFor blocks until done is closed or ctx ends.
+func waitFor(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRunShutdown_PhasesRunInOrder(t *testing.T) {
+	var ran []string
+	phase := func(name string) shutdownPhase {
+		return shutdownPhase{name: name, run: func(context.Context) error {
+			ran = append(ran, name)
+			return nil
+		}}
+	}
+
+	err := runShutdown([]shutdownPhase{
+		phase("pollers"), phase("handlers"), phase("sinks"), phase("metrics"),
+	}, time.Second)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pollers", "handlers", "sinks", "metrics"}, ran)
+}
+
+func TestRunShutdown_PhaseTimeoutEnforced(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	var ran []string
+	start := time.Now()
+	err := runShutdown([]shutdownPhase{
+		{name: "handlers", run: func(context.Context) error {
+			// Ignores its context, as a stuck handler would
+			<-block
+			return nil
+		}},
+		{name: "metrics", run: func(context.Context) error {
+			ran = append(ran, "metrics")
+			return nil
+		}},
+	}, 50*time.Millisecond)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "shutdown phase handlers")
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, []string{"metrics"}, ran, "later phases still run after a timeout")
+}
+
+type fakeFlusher struct {
+	flushed bool
+	err     error
+}
+
+func (f *fakeFlusher) Flush(context.Context) error {
+	f.flushed = true
+	return f.err
+}
+
+func TestShutdownPhases_FlushesSinksAfterHandlersDrain(t *testing.T) {
+	sink := &fakeFlusher{err: errors.New("flush failed")}
+	proc := &Processor{sinks: []Flusher{sink}}
+
+	pollerDone := make(chan struct{})
+	close(pollerDone)
+
+	proc.inFlight.Add(1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		assert.False(t, sink.flushed, "sinks must not flush before handlers drain")
+		proc.inFlight.Done()
+	}()
+
+	err := runShutdown(proc.shutdownPhases(pollerDone), time.Second)
+
+	assert.True(t, sink.flushed)
+	assert.ErrorContains(t, err, "shutdown phase sinks: flush failed")
+}
+
+func TestStart_DrainsBatchInHandOnShutdown(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		shutdownTimeout: time.Second,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(3)}, nil).Once()
+	// SIGTERM arrives while the first order is being written
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(&dynamodb.PutItemOutput{}, nil).Times(3)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "r2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	assert.Equal(t, context.Canceled, proc.Start(ctx))
+
+	// The whole batch was stored and deleted, and nothing more was received
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 3.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+}
+
+func TestStart_AbandonsBatchAfterShutdownTimeout(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		failuresTable:   "Failures",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		shutdownTimeout: 20 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(1)}, nil).Once()
+	// The write hangs until the batch is abandoned
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			cancel()
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return((*dynamodb.PutItemOutput)(nil), cancelledCall("DynamoDB", "PutItem")).Once()
+
+	done := make(chan error, 1)
+	go func() { done <- proc.Start(ctx) }()
+
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("Start did not return after the shutdown timeout")
+	}
+
+	// Left on the queue to be redelivered, and not counted as a failure
+	mockSQS.AssertNotCalled(t, "DeleteMessageBatch", mock.Anything, mock.Anything)
+	assert.Equal(t, 0.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue")))
+}
+
+func TestStart_JoinsShutdownErrors(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		sinks:           []Flusher{&fakeFlusher{err: errors.New("flush failed")}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+
+	err := proc.Start(ctx)
+
+	assert.ErrorIs(t, err, context.Canceled, "cancellation is still the cause")
+	assert.ErrorIs(t, err, ErrUncleanShutdown)
+	assert.ErrorContains(t, err, "shutdown phase sinks: flush failed")
+}
@@ -0,0 +1,27 @@
+package processor
+
+import "encoding/json"
+
+// snsNotification is the envelope SNS wraps a message in when delivering
+// to an SQS subscription without raw message delivery.
+type snsNotification struct {
+	Type     string  `json:"Type"`
+	Message  *string `json:"Message"`
+	TopicArn string  `json:"TopicArn"`
+}
+
+// unwrapSNS returns the message inside body when body is an SNS
+// notification envelope, and body unchanged otherwise, so raw and
+// SNS-delivered messages can share a queue. The envelope's own message
+// attributes are not read; subscribe with raw message delivery to keep
+// them as SQS message attributes.
+func unwrapSNS(body string) string {
+	var envelope snsNotification
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return body
+	}
+	if envelope.Type != "Notification" || envelope.Message == nil {
+		return body
+	}
+	return *envelope.Message
+}
@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// snsWrapped wraps message in an SNS notification envelope.
+func snsWrapped(message string) string {
+	envelope, _ := json.Marshal(map[string]string{
+		"Type":      "Notification",
+		"MessageId": "sns-1",
+		"TopicArn":  "arn:aws:sns:us-east-1:123456789012:orders",
+		"Message":   message,
+		"Timestamp": "2024-05-01T08:00:00.000Z",
+	})
+	return string(envelope)
+}
+
+func TestUnwrapSNS(t *testing.T) {
+	order := `{"order_id":"o1","user_id":"u1","amount":10}`
+	for name, tt := range map[string]struct{ body, want string }{
+		"notification":     {snsWrapped(order), order},
+		"raw order":        {order, order},
+		"not JSON":         {"ciphertext", "ciphertext"},
+		"other SNS type":   {`{"Type":"SubscriptionConfirmation","Message":"confirm"}`, `{"Type":"SubscriptionConfirmation","Message":"confirm"}`},
+		"no Message field": {`{"Type":"Notification"}`, `{"Type":"Notification"}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, unwrapSNS(tt.body))
+		})
+	}
+}
+
+func TestPollAndProcess_SNSEnvelope(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		snsEnvelope:     true,
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			bodyMessage("m1", `{"order_id":"o1","user_id":"u1","amount":10}`),
+			bodyMessage("m2", snsWrapped(`{"order_id":"o2","user_id":"u2","amount":20}`)),
+		}}, nil).Once()
+	var stored []string
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			item := args.Get(1).(*dynamodb.PutItemInput).Item
+			stored = append(stored, item["order_id"].(*dtypes.AttributeValueMemberS).Value)
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Twice()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r-m1", "r-m2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"o1", "o2"}, stored)
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestHandleMessage_SNSEnvelopeIsOptIn(t *testing.T) {
+	proc := &Processor{tableName: "Orders", ordersProcessed: NewCounterVec(), environment: "test"}
+
+	err := proc.handleMessage(context.Background(), bodyMessage("m1", snsWrapped(`{"order_id":"o1","user_id":"u1","amount":10}`)))
+
+	var vErr *ValidationError
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "missing_order_id", vErr.Type)
+}
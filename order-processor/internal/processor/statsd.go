@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Metric backends selectable with METRICS_BACKEND
+	metricsBackendPrometheus = "prometheus"
+	metricsBackendStatsD     = "statsd"
+
+	defaultStatsDAddr = "127.0.0.1:8125"
+
+	// StatsD names of the instruments shared with Prometheus
+	statsdOrdersProcessed    = "orders_processed"
+	statsdOrderFailures      = "order_failures"
+	statsdProcessingDuration = "order_processing_duration"
+	statsdAnalyticsWrites    = "analytics_writes"
+	statsdOrderItems         = "order_items"
+	statsdDeleteFailures     = "sqs_delete_failures"
+	statsdMessagesReceived   = "sqs_messages_received"
+	statsdEmptyPolls         = "sqs_empty_polls"
+)
+
+// statsdClient sends DogStatsD lines over UDP, one metric per packet.
+// Sends are fire-and-forget: a lost packet loses a data point, never an
+// order. A nil *statsdClient sends nothing.
+type statsdClient struct {
+	conn net.Conn
+}
+
+func newStatsDClient(addr string) (*statsdClient, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial statsd %s: %w", addr, err)
+	}
+	return &statsdClient{conn: conn}, nil
+}
+
+// count adds value to the counter name.
+func (c *statsdClient) count(name string, value int64, tags ...string) {
+	c.send(name, fmt.Sprintf("%d|c", value), tags)
+}
+
+// timing records d, in milliseconds, on the timer name.
+func (c *statsdClient) timing(name string, d time.Duration, tags ...string) {
+	c.send(name, fmt.Sprintf("%g|ms", float64(d)/float64(time.Millisecond)), tags)
+}
+
+func (c *statsdClient) send(name, value string, tags []string) {
+	if c == nil {
+		return
+	}
+	line := name + ":" + value
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	if _, err := c.conn.Write([]byte(line)); err != nil {
+		log.Debug().Err(err).Str("metric", name).Msg("failed to send statsd metric")
+	}
+}
@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// statsdServer is a mock StatsD server collecting the lines it receives.
+type statsdServer struct {
+	conn net.PacketConn
+}
+
+func newStatsDServer(t *testing.T) *statsdServer {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &statsdServer{conn: conn}
+}
+
+// receive returns the next n lines, failing the test if they do not arrive.
+func (s *statsdServer) receive(t *testing.T, n int) []string {
+	t.Helper()
+	require.NoError(t, s.conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var lines []string
+	buf := make([]byte, 1500)
+	for len(lines) < n {
+		read, _, err := s.conn.ReadFrom(buf)
+		require.NoError(t, err)
+		lines = append(lines, string(buf[:read]))
+	}
+	return lines
+}
+
+func TestPollAndProcess_EmitsStatsDMetrics(t *testing.T) {
+	server := newStatsDServer(t)
+	client, err := newStatsDClient(server.conn.LocalAddr().String())
+	require.NoError(t, err)
+
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:          mockSQS,
+		ddbClient:          mockDDB,
+		queueURL:           "test-queue",
+		tableName:          "Orders",
+		ordersProcessed:    NewCounterVec(),
+		processingDuration: newProcessingDurationHistogram(),
+		environment:        "test",
+		statsd:             client,
+	}
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: append(orderBatch(1), stypes.Message{
+			MessageId:     aws.String("m-bad"),
+			Body:          aws.String(`{"order_id":"o-bad","amount":100}`),
+			ReceiptHandle: aws.String("r-bad"),
+		})}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), errors.New("DynamoDB error")).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err = proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	var counters, failures, timers, received []string
+	for _, line := range server.receive(t, 6) {
+		name, rest, ok := strings.Cut(line, ":")
+		require.True(t, ok, line)
+		switch name {
+		case statsdOrdersProcessed:
+			counters = append(counters, rest)
+		case statsdOrderFailures:
+			failures = append(failures, rest)
+		case statsdMessagesReceived:
+			received = append(received, rest)
+		case statsdProcessingDuration:
+			value, tags, ok := strings.Cut(rest, "|ms|")
+			require.True(t, ok, line)
+			assert.NotEmpty(t, value)
+			timers = append(timers, tags)
+		default:
+			t.Fatalf("unexpected metric %q", line)
+		}
+	}
+	assert.ElementsMatch(t, []string{"1|c|#status:success,env:test,queue:test-queue", "1|c|#status:error,env:test,queue:test-queue"}, counters)
+	assert.ElementsMatch(t, []string{"#status:success,env:test", "#status:error,env:test"}, timers)
+	assert.Equal(t, []string{"1|c|#class:retryable,env:test"}, failures)
+	assert.Equal(t, []string{"2|c|#env:test,queue:test-queue"}, received)
+
+	// Prometheus sees the same instruments
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("error", "test", "test-queue")))
+}
@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// lifecycle lets Stop end Start from outside Start's context. Its stop
+// context is created by NewProcessor, or on first use for a Processor
+// built otherwise, and once cancelled stays so: a stopped processor
+// cannot be started again.
+type lifecycle struct {
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// current is the running Start, if any
+	current *startRun
+}
+
+// startRun is one call of Start; err is set before done is closed.
+type startRun struct {
+	done chan struct{}
+	err  error
+}
+
+func (l *lifecycle) init() {
+	l.once.Do(func() {
+		l.ctx, l.cancel = context.WithCancel(context.Background())
+	})
+}
+
+// begin registers a Start under ctx, returning a context that also ends
+// on Stop and the function recording how it returned.
+func (l *lifecycle) begin(ctx context.Context) (context.Context, func(error)) {
+	l.init()
+	ctx, cancel := context.WithCancel(ctx)
+	stopAfter := context.AfterFunc(l.ctx, cancel)
+	// AfterFunc cancels asynchronously, too late for an already stopped
+	// processor not to poll
+	if l.ctx.Err() != nil {
+		cancel()
+	}
+
+	run := &startRun{done: make(chan struct{})}
+	l.mu.Lock()
+	l.current = run
+	l.mu.Unlock()
+
+	return ctx, func(err error) {
+		stopAfter()
+		cancel()
+		run.err = err
+		close(run.done)
+		l.mu.Lock()
+		if l.current == run {
+			l.current = nil
+		}
+		l.mu.Unlock()
+	}
+}
+
+// Stop stops p for an application embedding it, as cancelling Start's
+// context does: polling stops, the batch in hand and in-flight handlers
+// are drained, sinks are flushed and the metrics server is shut down. It
+// waits for Start to return, up to ctx, and returns its error unless it
+// is just the cancellation. Without a running Start, Stop shuts down the
+// metrics server and keeps a later Start from running.
+func (p *Processor) Stop(ctx context.Context) error {
+	p.lifecycle.init()
+	p.lifecycle.cancel()
+
+	p.lifecycle.mu.Lock()
+	run := p.lifecycle.current
+	p.lifecycle.mu.Unlock()
+	if run == nil {
+		return p.shutdownMetricsServer(ctx)
+	}
+
+	select {
+	case <-run.done:
+	case <-ctx.Done():
+		return fmt.Errorf("stop: %w", ctx.Err())
+	}
+	if errors.Is(run.err, ErrUncleanShutdown) || !errors.Is(run.err, context.Canceled) {
+		return run.err
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStop_DrainsBatchAndEndsStart(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		shutdownTimeout: time.Second,
+	}
+
+	stopped := make(chan error, 1)
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(2)}, nil).Once()
+	// The embedding application stops the processor mid-batch
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			go func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				stopped <- proc.Stop(ctx)
+			}()
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Times(2)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+	// Polls made before Stop takes effect find nothing
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Maybe()
+
+	assert.Equal(t, context.Canceled, proc.Start(context.Background()))
+	assert.NoError(t, <-stopped)
+	assert.NoError(t, <-stopped)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 2.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+}
+
+func TestStop_ReturnsUncleanShutdown(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		sinks:           []Flusher{&fakeFlusher{err: errors.New("flush failed")}},
+	}
+	received := make(chan struct{})
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { close(received) }).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Once()
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{}, nil).Maybe()
+
+	done := make(chan error, 1)
+	go func() { done <- proc.Start(context.Background()) }()
+	<-received
+
+	err := proc.Stop(context.Background())
+	assert.ErrorIs(t, err, ErrUncleanShutdown)
+	assert.ErrorContains(t, err, "shutdown phase sinks: flush failed")
+	assert.ErrorIs(t, <-done, ErrUncleanShutdown)
+}
+
+func TestStop_BeforeStart(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		registry:        prometheus.NewRegistry(),
+		metricsAddr:     "127.0.0.1:0",
+	}
+	require.NoError(t, proc.StartMetricsServer())
+	url := "http://" + proc.metricsServer.Addr + healthPath
+
+	// The metrics server is shut down even though nothing was started
+	require.NoError(t, proc.Stop(context.Background()))
+	_, err := http.Get(url)
+	assert.Error(t, err)
+
+	// and a later Start returns without polling
+	assert.Equal(t, context.Canceled, proc.Start(context.Background()))
+	mockSQS.AssertNotCalled(t, "ReceiveMessage", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Table status check defaults
+	defaultTableStatusInterval = 30 * time.Second
+	tableWaitInitialDelay      = time.Second
+	tableWaitMaxDelay          = 30 * time.Second
+
+	// tableStatusMissing stands in for the status of a table that does not
+	// exist (yet), e.g. while it is being restored
+	tableStatusMissing types.TableStatus = "NOT_FOUND"
+)
+
+// tableWritable reports whether a table in status accepts writes. An
+// UPDATING table, e.g. one building an index, still does.
+func tableWritable(status types.TableStatus) bool {
+	return status == types.TableStatusActive || status == types.TableStatusUpdating
+}
+
+// tableStatus describes the orders table.
+func (p *Processor) tableStatus(ctx context.Context) (types.TableStatus, error) {
+	out, err := p.ddbClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: &p.tableName})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return tableStatusMissing, nil
+		}
+		return "", fmt.Errorf("describe table: %w", err)
+	}
+	return out.Table.TableStatus, nil
+}
+
+// checkTableStatus refreshes whether the orders table accepts writes,
+// reporting the result. If the status cannot be read the last known state
+// is kept, as with the kill switch.
+func (p *Processor) checkTableStatus(ctx context.Context) bool {
+	status, err := p.tableStatus(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to check table status - keeping current state")
+		return !p.tableInactive.Load()
+	}
+
+	writable := tableWritable(status)
+	if p.tableInactive.Swap(!writable) == writable {
+		log.Warn().
+			Str("table", p.tableName).
+			Str("status", string(status)).
+			Msg("table status changed")
+	}
+	return writable
+}
+
+// waitForTable blocks until the orders table accepts writes, checking with
+// exponential backoff. Processing counts as paused until it does. It only
+// returns early when ctx ends.
+func (p *Processor) waitForTable(ctx context.Context) error {
+	p.tableInactive.Store(true)
+	delay := tableWaitInitialDelay
+	for !p.checkTableStatus(ctx) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.after(delay):
+		}
+		delay = min(delay*2, tableWaitMaxDelay)
+	}
+	return nil
+}
+
+// watchTableStatus checks the orders table every interval until ctx ends.
+func (p *Processor) watchTableStatus(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkTableStatus(ctx)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func tableIn(status dtypes.TableStatus) *dynamodb.DescribeTableOutput {
+	return &dynamodb.DescribeTableOutput{Table: &dtypes.TableDescription{TableStatus: status}}
+}
+
+func TestWaitForTable_BacksOffUntilActive(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	clock := &fakeClock{}
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders", clock: clock}
+
+	describe := mock.MatchedBy(func(input *dynamodb.DescribeTableInput) bool {
+		return *input.TableName == "Orders"
+	})
+	// Being restored: first missing, then created
+	mockDDB.On("DescribeTable", mock.Anything, describe).
+		Return((*dynamodb.DescribeTableOutput)(nil), &dtypes.ResourceNotFoundException{}).Once()
+	mockDDB.On("DescribeTable", mock.Anything, describe).
+		Run(func(mock.Arguments) { assert.True(t, proc.paused()) }).
+		Return(tableIn(dtypes.TableStatusCreating), nil).Once()
+	mockDDB.On("DescribeTable", mock.Anything, describe).
+		Return((*dynamodb.DescribeTableOutput)(nil), errors.New("throttled")).Once()
+	mockDDB.On("DescribeTable", mock.Anything, describe).
+		Return(tableIn(dtypes.TableStatusActive), nil).Once()
+
+	require.NoError(t, proc.waitForTable(context.Background()))
+
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, clock.waits)
+	assert.False(t, proc.paused())
+}
+
+func TestWaitForTable_StopsOnCancel(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders", clock: &fakeClock{block: true}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockDDB.On("DescribeTable", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(tableIn(dtypes.TableStatusCreating), nil).Once()
+
+	assert.Equal(t, context.Canceled, proc.waitForTable(ctx))
+}
+
+func TestStart_WaitsForActiveTableBeforePolling(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:           mockSQS,
+		ddbClient:           mockDDB,
+		queueURL:            "test-queue",
+		tableName:           "Orders",
+		ordersProcessed:     NewCounterVec(),
+		environment:         "test",
+		clock:               &fakeClock{},
+		tableStatusInterval: time.Hour,
+	}
+
+	var calls []string
+	mockDDB.On("DescribeTable", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { calls = append(calls, "DescribeTable") }).
+		Return(tableIn(dtypes.TableStatusCreating), nil).Twice()
+	mockDDB.On("DescribeTable", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { calls = append(calls, "DescribeTable") }).
+		Return(tableIn(dtypes.TableStatusActive), nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			calls = append(calls, "ReceiveMessage")
+			cancel()
+		}).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{}}, nil).Once()
+
+	assert.Equal(t, context.Canceled, proc.Start(ctx))
+	assert.Equal(t, []string{"DescribeTable", "DescribeTable", "DescribeTable", "ReceiveMessage"}, calls)
+}
+
+func TestCheckTableStatus_PausesWhileNotActive(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+	ctx := context.Background()
+
+	mockDDB.On("DescribeTable", mock.Anything, mock.Anything).
+		Return(tableIn(dtypes.TableStatusInaccessibleEncryptionCredentials), nil).Once()
+	assert.False(t, proc.checkTableStatus(ctx))
+
+	_, err := proc.pollAndProcess(ctx)
+	assert.NoError(t, err)
+	mockSQS.AssertNotCalled(t, "ReceiveMessage", mock.Anything, mock.Anything)
+	assert.Equal(t, http.StatusServiceUnavailable, readyStatus(proc))
+
+	// An unreadable status keeps processing paused
+	mockDDB.On("DescribeTable", mock.Anything, mock.Anything).
+		Return((*dynamodb.DescribeTableOutput)(nil), errors.New("DynamoDB error")).Once()
+	assert.False(t, proc.checkTableStatus(ctx))
+	assert.True(t, proc.paused())
+
+	// An updating table still takes writes
+	mockDDB.On("DescribeTable", mock.Anything, mock.Anything).
+		Return(tableIn(dtypes.TableStatusUpdating), nil).Once()
+	assert.True(t, proc.checkTableStatus(ctx))
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{}}, nil).Once()
+	_, err = proc.pollAndProcess(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, readyStatus(proc))
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
@@ -0,0 +1,76 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// tenantPlaceholder is replaced with an order's tenant ID in
+	// DDB_TABLE_TEMPLATE
+	tenantPlaceholder = "{tenant}"
+
+	// maxTenantTables bounds the resolved table names kept; tenants beyond
+	// it are resolved on every order
+	maxTenantTables = 10000
+)
+
+// tableNamePattern matches a valid DynamoDB table name.
+var tableNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,255}$`)
+
+// tenantTables routes orders to per-tenant tables named by filling a
+// template such as "Orders-{tenant}" with the order's tenant ID. Resolved
+// names are cached.
+type tenantTables struct {
+	template string
+
+	mu       sync.Mutex
+	resolved map[string]string
+}
+
+// newTenantTables checks that template names {tenant}.
+func newTenantTables(template string) (*tenantTables, error) {
+	if !strings.Contains(template, tenantPlaceholder) {
+		return nil, fmt.Errorf("table template %q has no %s", template, tenantPlaceholder)
+	}
+	return &tenantTables{template: template, resolved: make(map[string]string)}, nil
+}
+
+// table returns the table of tenant, and false when the filled-in
+// template is not a valid table name.
+func (t *tenantTables) table(tenant string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if name, ok := t.resolved[tenant]; ok {
+		return name, true
+	}
+
+	name := strings.ReplaceAll(t.template, tenantPlaceholder, tenant)
+	if !tableNamePattern.MatchString(name) {
+		return name, false
+	}
+	if len(t.resolved) < maxTenantTables {
+		t.resolved[tenant] = name
+	}
+	return name, true
+}
+
+// orderTable returns the table order is stored in: its tenant's table, or
+// the orders table when it has no tenant or no table template is set. A
+// tenant giving an invalid table name is a validation error.
+func (p *Processor) orderTable(order Order) (string, error) {
+	if p.tenantTables == nil || order.TenantID == "" {
+		return p.tableName, nil
+	}
+	table, ok := p.tenantTables.table(order.TenantID)
+	if !ok {
+		return "", &ValidationError{
+			Type:    "invalid_tenant",
+			OrderID: order.OrderID,
+			Err:     fmt.Errorf("tenant %q gives invalid table name %q", order.TenantID, table),
+		}
+	}
+	return table, nil
+}
@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTenantProcessor(t *testing.T, template string) *Processor {
+	tables, err := newTenantTables(template)
+	require.NoError(t, err)
+	return &Processor{tableName: "Orders", tenantTables: tables}
+}
+
+func TestOrderTable(t *testing.T) {
+	proc := newTenantProcessor(t, "Orders-{tenant}")
+
+	table, err := proc.orderTable(Order{OrderID: "o1", TenantID: "acme"})
+	require.NoError(t, err)
+	assert.Equal(t, "Orders-acme", table)
+	assert.Equal(t, map[string]string{"acme": "Orders-acme"}, proc.tenantTables.resolved, "cached")
+
+	// No tenant, or no template, falls back to DDB_TABLE
+	table, err = proc.orderTable(Order{OrderID: "o1"})
+	require.NoError(t, err)
+	assert.Equal(t, "Orders", table)
+	table, err = (&Processor{tableName: "Orders"}).orderTable(Order{OrderID: "o1", TenantID: "acme"})
+	require.NoError(t, err)
+	assert.Equal(t, "Orders", table)
+
+	for _, tenant := range []string{"acme/eu", "acme corp", string(make([]byte, 256))} {
+		_, err = proc.orderTable(Order{OrderID: "o1", TenantID: tenant})
+		var vErr *ValidationError
+		require.ErrorAs(t, err, &vErr, tenant)
+		assert.Equal(t, "invalid_tenant", vErr.Type)
+		assert.Equal(t, "o1", vErr.OrderID)
+	}
+	assert.Len(t, proc.tenantTables.resolved, 1, "invalid names are not cached")
+}
+
+func TestNewTenantTables_RequiresPlaceholder(t *testing.T) {
+	_, err := newTenantTables("Orders-tenant")
+	assert.ErrorContains(t, err, "has no {tenant}")
+}
+
+func TestPollAndProcess_RoutesTenantsToTheirTables(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := newTenantProcessor(t, "Orders-{tenant}")
+	proc.sqsClient = mockSQS
+	proc.ddbClient = mockDDB
+	proc.queueURL = "test-queue"
+	proc.dlqURL = "test-dlq"
+	proc.ordersProcessed = NewCounterVec()
+	proc.environment = "test"
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			bodyMessage("m1", `{"order_id":"o1","user_id":"u1","amount":10,"tenant_id":"acme"}`),
+			bodyMessage("m2", `{"order_id":"o2","user_id":"u1","amount":10}`),
+			bodyMessage("m3", `{"order_id":"o3","user_id":"u1","amount":10,"tenant_id":"no/such"}`),
+		}}, nil).Once()
+	var tables []string
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			input := args.Get(1).(*dynamodb.PutItemInput)
+			tables = append(tables, aws.ToString(input.TableName))
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Twice()
+	mockSQS.On("SendMessage", mock.Anything, mock.MatchedBy(func(input *sqs.SendMessageInput) bool {
+		return aws.ToString(input.MessageAttributes["dlq_reason"].StringValue) == "invalid_tenant"
+	})).Return(&sqs.SendMessageOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, mock.Anything).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil)
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"Orders-acme", "Orders"}, tables)
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+}
+
+func TestStoreOrders_TenantsShareOneRequest(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := newTenantProcessor(t, "Orders-{tenant}")
+	proc.ddbClient = mockDDB
+
+	noneStored(mockDDB)
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		return len(input.RequestItems["Orders-acme"]) == 2 && len(input.RequestItems["Orders"]) == 1
+	})).Return(&dynamodb.BatchWriteItemOutput{
+		// The same order ID in another table is another order
+		UnprocessedItems: map[string][]dtypes.WriteRequest{"Orders": {{PutRequest: &dtypes.PutRequest{
+			Item: map[string]dtypes.AttributeValue{"order_id": &dtypes.AttributeValueMemberS{Value: "o1"}},
+		}}}},
+	}, nil).Once()
+	mockDDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		return len(input.RequestItems) == 1 && len(input.RequestItems["Orders"]) == 1
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+
+	errs := proc.storeOrders(context.Background(), []*preparedOrder{
+		{order: Order{OrderID: "o1", TenantID: "acme"}},
+		{order: Order{OrderID: "o2", TenantID: "acme"}},
+		{order: Order{OrderID: "o1"}},
+		{order: Order{OrderID: "o3", TenantID: "bad tenant"}},
+	})
+
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.NoError(t, errs[2])
+	var vErr *ValidationError
+	assert.ErrorAs(t, errs[3], &vErr)
+	mockDDB.AssertExpectations(t)
+}
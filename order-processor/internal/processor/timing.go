@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Processing phases timed for every order
+const (
+	phaseParse    = "parse"
+	phaseValidate = "validate"
+	phaseEnrich   = "enrich"
+	phaseStore    = "store"
+)
+
+func newPhaseDurationHistogram() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "order_phase_duration_seconds",
+			Help:    "Time spent in each order processing phase",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"phase", "env"},
+	)
+}
+
+// observePhase records the time since start for phase. A phase is observed
+// whether it succeeded or not, so slow failures show up too.
+func (p *Processor) observePhase(ctx context.Context, phase string, start time.Time) {
+	if p.phaseDuration == nil {
+		return
+	}
+	observe(ctx, p.phaseDuration.WithLabelValues(phase, p.environment), time.Since(start).Seconds())
+}
+
+// processingDurationBuckets span sub-second to several-second processing.
+var processingDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+func newProcessingDurationHistogram() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "order_processing_duration_seconds",
+			Help:    "Time spent processing each message, by outcome",
+			Buckets: processingDurationBuckets,
+		},
+		[]string{"status", "env"},
+	)
+}
+
+// observeProcessing records the time since start for a message that ended
+// with err, on every metrics backend: "success" when nil, "error"
+// otherwise.
+func (p *Processor) observeProcessing(ctx context.Context, start time.Time, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	elapsed := time.Since(start)
+	if p.processingDuration != nil {
+		observe(ctx, p.processingDuration.WithLabelValues(status, p.environment), elapsed.Seconds())
+	}
+	p.statsd.timing(statsdProcessingDuration, elapsed, "status:"+status, "env:"+p.environment)
+}
+
+// observe records v on o. When ctx carries a sampled span the observation
+// gets its trace ID as an exemplar, so a latency spike on a graph leads
+// straight to a trace of one of the slow orders.
+func observe(ctx context.Context, o prometheus.Observer, v float64) {
+	sc := trace.SpanContextFromContext(ctx)
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && sc.IsSampled() {
+		eo.ObserveWithExemplar(v, prometheus.Labels{"trace_id": sc.TraceID().String()})
+		return
+	}
+	o.Observe(v)
+}
@@ -0,0 +1,152 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func phaseSampleCount(t *testing.T, proc *Processor, phase string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, proc.phaseDuration.WithLabelValues(phase, proc.environment).(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func processingSampleCount(t *testing.T, proc *Processor, status string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, proc.processingDuration.WithLabelValues(status, proc.environment).(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestHandleMessage_ObservesEachPhase(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		ddbClient:       mockDDB,
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		phaseDuration:   newPhaseDurationHistogram(),
+	}
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	err := proc.handleMessage(t.Context(), stypes.Message{
+		Body: aws.String(`{"order_id":"o1","user_id":"u1","amount":100}`),
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, testutil.CollectAndCount(proc.phaseDuration))
+	for _, phase := range []string{phaseParse, phaseValidate, phaseEnrich, phaseStore} {
+		assert.Equal(t, uint64(1), phaseSampleCount(t, proc, phase), phase)
+	}
+}
+
+func TestHandleMessage_InvalidJSONObservesParseOnly(t *testing.T) {
+	proc := &Processor{
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		phaseDuration:   newPhaseDurationHistogram(),
+	}
+
+	err := proc.handleMessage(t.Context(), stypes.Message{Body: aws.String(`invalid`)})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, testutil.CollectAndCount(proc.phaseDuration))
+	assert.Equal(t, uint64(1), phaseSampleCount(t, proc, phaseParse))
+}
+
+func TestPollAndProcess_ObservesProcessingDurationByStatus(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:          mockSQS,
+		ddbClient:          mockDDB,
+		queueURL:           "test-queue",
+		tableName:          "Orders",
+		ordersProcessed:    NewCounterVec(),
+		environment:        "test",
+		processingDuration: newProcessingDurationHistogram(),
+	}
+
+	msgs := orderBatch(2)
+	msgs = append(msgs, stypes.Message{MessageId: aws.String("bad"), Body: aws.String(`invalid`), ReceiptHandle: aws.String("rb")})
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: msgs}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Twice()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "rb")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(t.Context())
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(2), processingSampleCount(t, proc, "success"))
+	assert.Equal(t, uint64(1), processingSampleCount(t, proc, "error"))
+}
+
+// processingExemplars returns the trace IDs of the exemplars on the
+// processing histogram for status.
+func processingExemplars(t *testing.T, proc *Processor, status string) []string {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, proc.processingDuration.WithLabelValues(status, proc.environment).(prometheus.Metric).Write(&m))
+	var ids []string
+	for _, b := range m.GetHistogram().GetBucket() {
+		for _, l := range b.GetExemplar().GetLabel() {
+			if l.GetName() == "trace_id" {
+				ids = append(ids, l.GetValue())
+			}
+		}
+	}
+	return ids
+}
+
+func TestHandleMessage_ProcessingExemplarLinksTrace(t *testing.T) {
+	proc, mockDDB, recorder := newTracedProcessor(t, 1, nil)
+	proc.processingDuration = newProcessingDurationHistogram()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+	require.NoError(t, proc.handleMessage(t.Context(), orderMessage("msg-1")))
+
+	var orderTrace string
+	for _, span := range recorder.Ended() {
+		if span.Name() == "process_order" {
+			orderTrace = span.SpanContext().TraceID().String()
+		}
+	}
+	require.NotEmpty(t, orderTrace)
+	assert.Equal(t, []string{orderTrace}, processingExemplars(t, proc, "success"))
+}
+
+func TestHandleMessage_NoExemplarWhenUnsampled(t *testing.T) {
+	proc, mockDDB, _ := newTracedProcessor(t, 0, nil)
+	proc.processingDuration = newProcessingDurationHistogram()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+	require.NoError(t, proc.handleMessage(t.Context(), orderMessage("msg-1")))
+
+	assert.Equal(t, uint64(1), processingSampleCount(t, proc, "success"))
+	assert.Empty(t, processingExemplars(t, proc, "success"))
+}
+
+func TestProcessingDurationHistogram_Buckets(t *testing.T) {
+	h := newProcessingDurationHistogram()
+
+	var m dto.Metric
+	require.NoError(t, h.WithLabelValues("success", "test").(prometheus.Metric).Write(&m))
+	buckets := m.GetHistogram().GetBucket()
+	require.NotEmpty(t, buckets)
+	assert.Equal(t, 0.005, buckets[0].GetUpperBound())
+	assert.Equal(t, 10.0, buckets[len(buckets)-1].GetUpperBound())
+}
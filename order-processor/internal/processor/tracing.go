@@ -0,0 +1,176 @@
+package processor
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	// Instrumentation name reported on every span
+	tracerName = "order-processor"
+
+	// Trace every order unless TRACE_SAMPLE_RATE says otherwise
+	defaultTraceSampleRate = 1.0
+
+	// Message attributes carrying a producer's W3C trace context
+	traceparentAttribute = "traceparent"
+	tracestateAttribute  = "tracestate"
+)
+
+// newTracerProvider returns a TracerProvider exporting spans over OTLP/HTTP
+// to OTEL_EXPORTER_OTLP_ENDPOINT; the exporter reads the endpoint and the
+// other standard OTEL_EXPORTER_OTLP_* variables itself.
+func newTracerProvider(ctx context.Context) (*tracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP trace exporter: %w", err)
+	}
+	return &tracerProvider{sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(tracerName))),
+	)}, nil
+}
+
+// tracerProvider flushes buffered spans at shutdown.
+type tracerProvider struct {
+	*sdktrace.TracerProvider
+}
+
+func (t *tracerProvider) Flush(ctx context.Context) error {
+	return t.Shutdown(ctx)
+}
+
+// startPollSpan starts the span covering one poll: the receive, the orders
+// handled, and the delete. It is the root the other spans hang off.
+func (p *Processor) startPollSpan(ctx context.Context) (context.Context, trace.Span) {
+	if p.tracer == nil {
+		return ctx, noop.Span{}
+	}
+	return p.tracer.Start(ctx, "poll_orders",
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("messaging.destination.name", p.queueOf(ctx).url)),
+	)
+}
+
+// startOrderSpan starts the per-order span if the order is sampled. This is
+// the only place order spans are created. When the message carries its
+// producer's trace context the span continues that trace, linked to the
+// poll span; otherwise it is a child of the poll span.
+// Unsampled orders get a no-op span, so they still process and meter
+// normally and callers never need to check.
+func (p *Processor) startOrderSpan(ctx context.Context, msg types.Message) (context.Context, trace.Span) {
+	if p.tracer == nil || !p.sampleTrace() {
+		return ctx, noop.Span{}
+	}
+
+	msgID := "unknown"
+	if msg.MessageId != nil {
+		msgID = *msg.MessageId
+	}
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("messaging.message.id", msgID)),
+	}
+	if producer := messageSpanContext(msg); producer.IsValid() {
+		if poll := trace.SpanContextFromContext(ctx); poll.IsValid() {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: poll}))
+		}
+		ctx = trace.ContextWithRemoteSpanContext(ctx, producer)
+	}
+	return p.tracer.Start(ctx, "process_order", opts...)
+}
+
+// startChildSpan starts a span under the one in ctx. Without a recorded
+// span in ctx, as for an unsampled order, it returns a no-op span rather
+// than starting a new trace.
+func (p *Processor) startChildSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if p.tracer == nil || !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, noop.Span{}
+	}
+	return p.tracer.Start(ctx, name, opts...)
+}
+
+// messageSpanContext returns the producer's span context carried by msg: a
+// W3C traceparent message attribute, or failing that the AWSTraceHeader
+// system attribute set by X-Ray. It is invalid when msg carries neither.
+func messageSpanContext(msg types.Message) trace.SpanContext {
+	carrier := propagation.MapCarrier{}
+	for _, name := range []string{traceparentAttribute, tracestateAttribute} {
+		if attr, ok := msg.MessageAttributes[name]; ok && attr.StringValue != nil {
+			carrier[name] = *attr.StringValue
+		}
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc
+	}
+	return parseAWSTraceHeader(msg.Attributes[string(types.MessageSystemAttributeNameAWSTraceHeader)])
+}
+
+// parseAWSTraceHeader parses an X-Ray trace header such as
+// "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1".
+// The X-Ray trace ID is its epoch and random parts run together.
+func parseAWSTraceHeader(header string) trace.SpanContext {
+	var cfg trace.SpanContextConfig
+	for _, field := range strings.Split(header, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch key {
+		case "Root":
+			version, id, _ := strings.Cut(value, "-")
+			if version != "1" {
+				return trace.SpanContext{}
+			}
+			raw, err := hex.DecodeString(strings.ReplaceAll(id, "-", ""))
+			if err != nil || len(raw) != len(cfg.TraceID) {
+				return trace.SpanContext{}
+			}
+			copy(cfg.TraceID[:], raw)
+		case "Parent":
+			raw, err := hex.DecodeString(value)
+			if err != nil || len(raw) != len(cfg.SpanID) {
+				return trace.SpanContext{}
+			}
+			copy(cfg.SpanID[:], raw)
+		case "Sampled":
+			if value == "1" {
+				cfg.TraceFlags = trace.FlagsSampled
+			}
+		}
+	}
+	cfg.Remote = true
+	return trace.NewSpanContext(cfg)
+}
+
+// sampleTrace reports whether the next order should be traced.
+func (p *Processor) sampleTrace() bool {
+	switch {
+	case p.traceSampleRate >= 1:
+		return true
+	case p.traceSampleRate <= 0:
+		return false
+	}
+	return p.random() < p.traceSampleRate
+}
+
+// endSpan records err on span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
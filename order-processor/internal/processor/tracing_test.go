@@ -0,0 +1,191 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTracedProcessor(t *testing.T, rate float64, randFloat func() float64) (*Processor, *MockDynamoDBClient, *tracetest.SpanRecorder) {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		ddbClient:       mockDDB,
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		tracer:          provider.Tracer(tracerName),
+		traceSampleRate: rate,
+		randFloat:       randFloat,
+	}
+	return proc, mockDDB, recorder
+}
+
+func orderMessage(id string) stypes.Message {
+	return stypes.Message{
+		MessageId: aws.String(id),
+		Body:      aws.String(`{"order_id":"o1","user_id":"u1","amount":100}`),
+	}
+}
+
+func TestHandleMessage_TraceSampleRateZero(t *testing.T) {
+	proc, mockDDB, recorder := newTracedProcessor(t, 0, nil)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+	err := proc.handleMessage(context.Background(), orderMessage("msg-1"))
+
+	assert.NoError(t, err)
+	assert.Empty(t, recorder.Ended())
+	// Unsampled orders still count
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "")))
+}
+
+func TestHandleMessage_TraceSampleRateOne(t *testing.T) {
+	proc, mockDDB, recorder := newTracedProcessor(t, 1, nil)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+	err := proc.handleMessage(context.Background(), orderMessage("msg-1"))
+
+	assert.NoError(t, err)
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		put, order := spans[0], spans[1]
+		assert.Equal(t, "process_order", order.Name())
+		assert.Equal(t, "put_order", put.Name())
+		assert.Equal(t, order.SpanContext().SpanID(), put.Parent().SpanID())
+	}
+}
+
+func TestHandleMessage_TraceSampleRateUsesInjectedRand(t *testing.T) {
+	draws := []float64{0.1, 0.9}
+	randFloat := func() float64 {
+		v := draws[0]
+		draws = draws[1:]
+		return v
+	}
+	proc, mockDDB, recorder := newTracedProcessor(t, 0.5, randFloat)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil)
+
+	assert.NoError(t, proc.handleMessage(context.Background(), orderMessage("msg-1")))
+	assert.NoError(t, proc.handleMessage(context.Background(), orderMessage("msg-2")))
+
+	// One sampled order and its write
+	assert.Len(t, recorder.Ended(), 2)
+	assert.Equal(t, 2.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "")))
+}
+
+func TestHandleMessage_TraceRecordsError(t *testing.T) {
+	proc, _, recorder := newTracedProcessor(t, 1, nil)
+
+	err := proc.handleMessage(context.Background(), stypes.Message{MessageId: aws.String("msg-1")})
+
+	assert.Error(t, err)
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "message body is nil", spans[0].Status().Description)
+	}
+}
+
+func TestPollAndProcess_SpansLinkReceiveWriteAndDelete(t *testing.T) {
+	proc, mockDDB, recorder := newTracedProcessor(t, 1, nil)
+	mockSQS := &MockSQSClient{}
+	proc.sqsClient = mockSQS
+	proc.queueURL = "test-queue"
+
+	producer := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	traced := orderMessage("msg-1")
+	traced.ReceiptHandle = aws.String("r1")
+	traced.MessageAttributes = map[string]stypes.MessageAttributeValue{
+		traceparentAttribute: {DataType: aws.String("String"), StringValue: aws.String(producer)},
+	}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.MatchedBy(func(input *sqs.ReceiveMessageInput) bool {
+		return assert.Contains(t, input.MessageAttributeNames, "All") &&
+			assert.Contains(t, input.MessageSystemAttributeNames, stypes.MessageSystemAttributeNameAll)
+	})).Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{traced}}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	require.Len(t, spans, 4)
+	poll, order, put, del := spans["poll_orders"], spans["process_order"], spans["put_order"], spans["delete_messages"]
+
+	assert.False(t, poll.Parent().IsValid())
+	// The order continues the producer's trace and links back to the poll
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", order.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", order.Parent().SpanID().String())
+	if assert.Len(t, order.Links(), 1) {
+		assert.Equal(t, poll.SpanContext(), order.Links()[0].SpanContext)
+	}
+	assert.Equal(t, order.SpanContext().SpanID(), put.Parent().SpanID())
+	assert.Equal(t, poll.SpanContext().SpanID(), del.Parent().SpanID())
+}
+
+func TestPollAndProcess_OrderSpanIsPollChildWithoutProducerContext(t *testing.T) {
+	proc, mockDDB, recorder := newTracedProcessor(t, 1, nil)
+	mockSQS := &MockSQSClient{}
+	proc.sqsClient = mockSQS
+	proc.queueURL = "test-queue"
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(1)}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	poll, order := spans["poll_orders"], spans["process_order"]
+	require.NotNil(t, poll)
+	require.NotNil(t, order)
+	assert.Equal(t, poll.SpanContext().TraceID(), order.SpanContext().TraceID())
+	assert.Equal(t, poll.SpanContext().SpanID(), order.Parent().SpanID())
+	assert.Empty(t, order.Links())
+}
+
+func TestMessageSpanContext_AWSTraceHeader(t *testing.T) {
+	msg := stypes.Message{Attributes: map[string]string{
+		"AWSTraceHeader": "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+	}}
+
+	sc := messageSpanContext(msg)
+
+	assert.True(t, sc.IsRemote())
+	assert.Equal(t, "5759e988bd862e3fe1be46a994272793", sc.TraceID().String())
+	assert.Equal(t, "53995c3f42cd8ad8", sc.SpanID().String())
+	assert.Equal(t, trace.FlagsSampled, sc.TraceFlags())
+}
+
+func TestMessageSpanContext_Invalid(t *testing.T) {
+	for _, header := range []string{"", "Root=2-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8", "Root=1-zz;Parent=53995c3f42cd8ad8", "Root=1-5759e988-bd862e3fe1be46a994272793"} {
+		msg := stypes.Message{Attributes: map[string]string{"AWSTraceHeader": header}}
+		assert.False(t, messageSpanContext(msg).IsValid(), header)
+	}
+}
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Built-in validation rules, run in this order ahead of any added with
+// WithValidators
+const (
+	ruleOrderID   = "order_id"
+	ruleAmount    = "amount"
+	ruleCurrency  = "currency"
+	ruleLineItems = "line_items"
+	ruleTenant    = "tenant"
+)
+
+// OrderValidator is one rule a parsed order must pass to be stored. A
+// failed rule fails the message terminally: the error is returned as a
+// *ValidationError whose Rule is Name, and whose Type is Name too unless
+// Validate returned a *ValidationError of its own.
+type OrderValidator interface {
+	// Name identifies the rule in errors and metrics, e.g. "order_id"
+	Name() string
+	// Validate checks the order. It may normalize what it checks, as the
+	// currency rule upper-cases the currency code
+	Validate(ctx context.Context, order *Order) error
+}
+
+// ValidatorFunc adapts fn to an OrderValidator named name.
+func ValidatorFunc(name string, fn func(ctx context.Context, order *Order) error) OrderValidator {
+	return validatorFunc{name: name, fn: fn}
+}
+
+type validatorFunc struct {
+	name string
+	fn   func(ctx context.Context, order *Order) error
+}
+
+func (v validatorFunc) Name() string { return v.name }
+
+func (v validatorFunc) Validate(ctx context.Context, order *Order) error {
+	return v.fn(ctx, order)
+}
+
+// WithValidators adds rules run, in order, after the built-in ones. It
+// returns p.
+func (p *Processor) WithValidators(validators ...OrderValidator) *Processor {
+	p.validators = append(p.validators, validators...)
+	return p
+}
+
+// orderValidators returns the built-in rules followed by those added with
+// WithValidators.
+func (p *Processor) orderValidators() []OrderValidator {
+	builtin := []OrderValidator{
+		ValidatorFunc(ruleOrderID, func(_ context.Context, order *Order) error {
+			if order.OrderID == "" {
+				return &ValidationError{Type: "missing_order_id", Err: errors.New("order_id is required")}
+			}
+			return nil
+		}),
+		ValidatorFunc(ruleAmount, func(_ context.Context, order *Order) error {
+			return p.checkAmountSign(*order)
+		}),
+		ValidatorFunc(ruleCurrency, func(_ context.Context, order *Order) error {
+			return checkCurrency(order)
+		}),
+		ValidatorFunc(ruleLineItems, func(_ context.Context, order *Order) error {
+			return p.checkLineItems(*order)
+		}),
+		ValidatorFunc(ruleTenant, func(_ context.Context, order *Order) error {
+			_, err := p.orderTable(*order)
+			return err
+		}),
+	}
+	return append(builtin, p.validators...)
+}
+
+// validateOrder runs the validation rules in order, stopping at the first
+// that fails.
+func (p *Processor) validateOrder(ctx context.Context, order *Order) error {
+	for _, v := range p.orderValidators() {
+		err := v.Validate(ctx, order)
+		if err == nil {
+			continue
+		}
+
+		var vErr *ValidationError
+		if !errors.As(err, &vErr) {
+			vErr = &ValidationError{Type: v.Name(), OrderID: order.OrderID, Err: err}
+		}
+		vErr.Rule = v.Name()
+		if p.validationFailures != nil {
+			p.validationFailures.WithLabelValues(v.Name(), p.environment).Inc()
+		}
+		return vErr
+	}
+	return nil
+}
+
+func newValidationFailuresCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_validation_failures_total",
+			Help: "Orders rejected by a validation rule",
+		},
+		[]string{"rule", "env"},
+	)
+}
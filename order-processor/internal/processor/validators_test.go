@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateOrder_StopsAtFirstFailedRule(t *testing.T) {
+	proc := &Processor{validationFailures: newValidationFailuresCounter(), environment: "test"}
+	var ran []string
+	proc.WithValidators(
+		ValidatorFunc("user_id", func(_ context.Context, order *Order) error {
+			ran = append(ran, "user_id")
+			if !strings.HasPrefix(order.UserID, "u-") {
+				return errors.New("user_id must start with u-")
+			}
+			return nil
+		}),
+		ValidatorFunc("never", func(context.Context, *Order) error {
+			ran = append(ran, "never")
+			return nil
+		}),
+	)
+
+	order := Order{OrderID: "o1", UserID: "bob", Amount: "10", Currency: "eur"}
+	err := proc.validateOrder(context.Background(), &order)
+
+	var vErr *ValidationError
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, "user_id", vErr.Rule)
+	assert.Equal(t, "user_id", vErr.Type)
+	assert.Equal(t, "o1", vErr.OrderID)
+	assert.Equal(t, []string{"user_id"}, ran)
+	// Rules before the failed one have run, normalizing the currency
+	assert.Equal(t, "EUR", order.Currency)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.validationFailures.WithLabelValues("user_id", "test")))
+}
+
+func TestValidateOrder_BuiltinRuleKeepsItsType(t *testing.T) {
+	proc := &Processor{validationFailures: newValidationFailuresCounter(), environment: "test"}
+
+	err := proc.validateOrder(context.Background(), &Order{OrderID: "o1", Amount: "-1"})
+
+	var vErr *ValidationError
+	require.ErrorAs(t, err, &vErr)
+	assert.Equal(t, ruleAmount, vErr.Rule)
+	assert.Equal(t, "invalid_amount", vErr.Type)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.validationFailures.WithLabelValues(ruleAmount, "test")))
+}
+
+func TestPollAndProcess_CustomValidatorFailsTerminally(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{
+		sqsClient:          mockSQS,
+		ddbClient:          mockDDB,
+		queueURL:           "test-queue",
+		tableName:          "Orders",
+		ordersProcessed:    NewCounterVec(),
+		failuresByClass:    newFailuresCounter(),
+		validationFailures: newValidationFailuresCounter(),
+		environment:        "test",
+	}
+	proc.WithValidators(ValidatorFunc("no_test_users", func(_ context.Context, order *Order) error {
+		if order.UserID == "test" {
+			return errors.New("test users are not accepted")
+		}
+		return nil
+	}))
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{
+			bodyMessage("m1", `{"order_id":"o1","user_id":"u1","amount":100}`),
+			bodyMessage("m2", `{"order_id":"o2","user_id":"test","amount":100}`),
+		}}, nil).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r-m1", "r-m2")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	assert.NoError(t, err)
+
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.failuresByClass.WithLabelValues(failureTerminal, "test")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(proc.validationFailures.WithLabelValues("no_test_users", "test")))
+}
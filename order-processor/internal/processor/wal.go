@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog/log"
+)
+
+// Lifecycle stages recorded for every message
+const (
+	stageReceived  = "received"
+	stageProcessed = "processed"
+	stageDeleted   = "deleted"
+)
+
+// LifecycleEvent is one step of a message's receive-to-delete lifecycle.
+type LifecycleEvent struct {
+	MessageID string `json:"message_id" dynamodbav:"message_id"`
+	Stage     string `json:"stage" dynamodbav:"stage"`
+	At        string `json:"at" dynamodbav:"at"`
+}
+
+// LifecycleLog durably records lifecycle events for auditing.
+type LifecycleLog interface {
+	Record(ctx context.Context, event LifecycleEvent) error
+}
+
+// recordLifecycle writes a lifecycle event when a WAL is configured. A WAL
+// failure is logged but never fails the message itself.
+func (p *Processor) recordLifecycle(ctx context.Context, msg types.Message, stage string) {
+	if p.wal == nil {
+		return
+	}
+
+	event := LifecycleEvent{
+		MessageID: aws.ToString(msg.MessageId),
+		Stage:     stage,
+		At:        p.now().UTC().Format(time.RFC3339Nano),
+	}
+	if err := p.wal.Record(ctx, event); err != nil {
+		log.Error().
+			Str("msg_id", event.MessageID).
+			Str("stage", stage).
+			Err(err).
+			Msg("failed to write lifecycle event")
+	}
+}
+
+// fileWAL appends lifecycle events to a file as JSON lines, syncing after
+// every event.
+type fileWAL struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newFileWAL(path string) (*fileWAL, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open WAL file: %w", err)
+	}
+	return &fileWAL{file: f}, nil
+}
+
+func (w *fileWAL) Record(_ context.Context, event LifecycleEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal lifecycle event: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write WAL file: %w", err)
+	}
+	return w.file.Sync()
+}
+
+// Flush closes the file; it runs once, during shutdown.
+func (w *fileWAL) Flush(context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// tableWAL writes lifecycle events to a DynamoDB table with hash key
+// message_id and range key stage.
+type tableWAL struct {
+	client ddbClientI
+	table  string
+}
+
+func (w *tableWAL) Record(ctx context.Context, event LifecycleEvent) error {
+	item, err := attributevalue.MarshalMap(event)
+	if err != nil {
+		return fmt.Errorf("marshal lifecycle event: %w", err)
+	}
+	_, err = w.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: &w.table,
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("put lifecycle event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryWAL keeps lifecycle events in memory for assertions.
+type memoryWAL struct {
+	events []LifecycleEvent
+}
+
+func (w *memoryWAL) Record(_ context.Context, event LifecycleEvent) error {
+	w.events = append(w.events, event)
+	return nil
+}
+
+func TestPollAndProcess_RecordsLifecycleInOrder(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+	wal := &memoryWAL{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+		wal:             wal,
+	}
+
+	msg := stypes.Message{
+		MessageId:     aws.String("msg-123"),
+		Body:          aws.String(`{"order_id":"o1","user_id":"u1","amount":100}`),
+		ReceiptHandle: aws.String("r1"),
+	}
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{msg}}, nil)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r1")).
+		Return(&sqs.DeleteMessageBatchOutput{
+			Successful: []stypes.DeleteMessageBatchResultEntry{{Id: aws.String("0")}},
+		}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, wal.events, 3)
+	var stages []string
+	for _, e := range wal.events {
+		assert.Equal(t, "msg-123", e.MessageID)
+		stages = append(stages, e.Stage)
+	}
+	assert.Equal(t, []string{stageReceived, stageProcessed, stageDeleted}, stages)
+	assert.LessOrEqual(t, wal.events[0].At, wal.events[2].At)
+}
+
+func TestFileWAL_AppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.jsonl")
+	w, err := newFileWAL(path)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, w.Record(ctx, LifecycleEvent{MessageID: "m1", Stage: stageReceived, At: "t1"}))
+	require.NoError(t, w.Record(ctx, LifecycleEvent{MessageID: "m1", Stage: stageDeleted, At: "t2"}))
+	require.NoError(t, w.Flush(ctx))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var got []LifecycleEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e LifecycleEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		got = append(got, e)
+	}
+	assert.Equal(t, []LifecycleEvent{
+		{MessageID: "m1", Stage: stageReceived, At: "t1"},
+		{MessageID: "m1", Stage: stageDeleted, At: "t2"},
+	}, got)
+}
@@ -0,0 +1,35 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/rs/zerolog/log"
+)
+
+// warmUpTimeout bounds the warm-up call, so a slow endpoint delays the
+// first poll by at most this much
+const warmUpTimeout = 10 * time.Second
+
+// warmUp makes a cheap GetQueueAttributes call before the first poll, so
+// DNS, the TLS handshake and credential resolution happen outside it and
+// stay out of the poll span and processing metrics. Nothing here is
+// measured. A failed warm-up is logged and polling starts anyway; the
+// first receive then pays the cost as it did before.
+func (p *Processor) warmUp(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, warmUpTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := p.sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       &p.queueOf(ctx).url,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("SQS warm-up failed - polling anyway")
+		return
+	}
+	log.Info().Dur("took", time.Since(start)).Msg("SQS connection warmed up")
+}
@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStart_WarmsUpBeforeFirstReceive(t *testing.T) {
+	for name, warmUpErr := range map[string]error{
+		"succeeds": nil,
+		// A failed warm-up does not hold up polling
+		"fails": errors.New("dial tcp: i/o timeout"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			mockSQS := &MockSQSClient{}
+			proc := &Processor{
+				sqsClient:       mockSQS,
+				queueURL:        "test-queue",
+				tableName:       "Orders",
+				ordersProcessed: NewCounterVec(),
+				environment:     "test",
+				sqsWarmUp:       true,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			var calls []string
+			mockSQS.On("GetQueueAttributes", mock.Anything, mock.MatchedBy(func(input *sqs.GetQueueAttributesInput) bool {
+				return *input.QueueUrl == "test-queue"
+			})).
+				Run(func(mock.Arguments) { calls = append(calls, "GetQueueAttributes") }).
+				Return(&sqs.GetQueueAttributesOutput{}, warmUpErr).Once()
+			mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+				Run(func(mock.Arguments) {
+					calls = append(calls, "ReceiveMessage")
+					cancel()
+				}).
+				Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{}}, nil).Once()
+
+			assert.Equal(t, context.Canceled, proc.Start(ctx))
+
+			mockSQS.AssertExpectations(t)
+			assert.Equal(t, []string{"GetQueueAttributes", "ReceiveMessage"}, calls)
+		})
+	}
+}
+
+func TestStart_WarmUpDisabled(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(&sqs.ReceiveMessageOutput{Messages: []stypes.Message{}}, nil).Once()
+
+	assert.Equal(t, context.Canceled, proc.Start(ctx))
+	mockSQS.AssertNotCalled(t, "GetQueueAttributes", mock.Anything, mock.Anything)
+}
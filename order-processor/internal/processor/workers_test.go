@@ -0,0 +1,107 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	stypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func orderBatch(n int) []stypes.Message {
+	msgs := make([]stypes.Message, n)
+	for i := range msgs {
+		msgs[i] = stypes.Message{
+			MessageId:     aws.String(fmt.Sprintf("msg-%d", i)),
+			Body:          aws.String(fmt.Sprintf(`{"order_id":"o%d","user_id":"u1","amount":100}`, i)),
+			ReceiptHandle: aws.String(fmt.Sprintf("r%d", i)),
+		}
+	}
+	return msgs
+}
+
+func TestPollAndProcess_WorkersProcessBatchConcurrently(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+	assert.NoError(t, proc.SetConcurrency(5))
+
+	// Every write waits until all five are in flight, which can only
+	// happen if five workers run at once
+	var arrived sync.WaitGroup
+	arrived.Add(5)
+	allArrived := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(allArrived)
+	}()
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(5)}, nil)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			arrived.Done()
+			select {
+			case <-allArrived:
+			case <-time.After(5 * time.Second):
+				t.Error("writes did not run concurrently")
+			}
+		}).
+		Return(&dynamodb.PutItemOutput{}, nil).Times(5)
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0", "r1", "r2", "r3", "r4")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(context.Background())
+
+	// Every message is done by the time pollAndProcess returns
+	assert.NoError(t, err)
+	mockSQS.AssertExpectations(t)
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, 5.0, testutil.ToFloat64(proc.ordersProcessed.WithLabelValues("success", "test", "test-queue")))
+}
+
+func TestPollAndProcess_CancellationStopsDispatch(t *testing.T) {
+	mockSQS := &MockSQSClient{}
+	mockDDB := &MockDynamoDBClient{}
+
+	proc := &Processor{
+		sqsClient:       mockSQS,
+		ddbClient:       mockDDB,
+		queueURL:        "test-queue",
+		tableName:       "Orders",
+		ordersProcessed: NewCounterVec(),
+		environment:     "test",
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockSQS.On("ReceiveMessage", mock.Anything, mock.Anything).
+		Return(&sqs.ReceiveMessageOutput{Messages: orderBatch(3)}, nil)
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+	mockSQS.On("DeleteMessageBatch", mock.Anything, deleteBatchOf("r0")).
+		Return(&sqs.DeleteMessageBatchOutput{}, nil).Once()
+
+	_, err := proc.pollAndProcess(ctx)
+
+	assert.NoError(t, err)
+	mockDDB.AssertNumberOfCalls(t, "PutItem", 1)
+}
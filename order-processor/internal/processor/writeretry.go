@@ -0,0 +1,76 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/smithy-go"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Order write retries, backing off exponentially from
+	// writeRetryBaseBackoff up to writeRetryMaxBackoff
+	defaultWriteRetries   = 3
+	maxWriteRetries       = 10
+	writeRetryBaseBackoff = 50 * time.Millisecond
+	writeRetryMaxBackoff  = 2 * time.Second
+)
+
+// isTransientWriteError reports whether a failed DynamoDB write may succeed
+// if repeated: throttling such as ProvisionedThroughputExceededException,
+// or a server-side (5xx) failure. Client errors, a failed condition
+// included, are not.
+func isTransientWriteError(err error) bool {
+	if (retry.ThrottleErrorCode{Codes: retry.DefaultThrottleErrorCodes}).IsErrorThrottle(err) == aws.TrueTernary {
+		return true
+	}
+	if (retry.RetryableHTTPStatusCode{Codes: retry.DefaultRetryableHTTPStatusCodes}).IsErrorRetryable(err) == aws.TrueTernary {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorFault() == smithy.FaultServer
+}
+
+// putItemWithRetry calls PutItem, repeating it up to p.writeRetries times
+// with exponential backoff while it fails transiently (see
+// isTransientWriteError). Any other error is returned straight away, as is
+// the last one when ctx ends during a wait.
+func (p *Processor) putItemWithRetry(ctx context.Context, input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	backoff := writeRetryBaseBackoff
+	for attempt := 0; ; attempt++ {
+		out, err := p.ddbClient.PutItem(ctx, input)
+		if err == nil || attempt == p.writeRetries || !isTransientWriteError(err) {
+			return out, err
+		}
+
+		log.Warn().
+			Err(err).
+			Str("table", aws.ToString(input.TableName)).
+			Int("attempt", attempt+1).
+			Dur("backoff", backoff).
+			Msg("transient DynamoDB write failure - retrying")
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-p.after(backoff):
+		}
+		backoff = min(backoff*2, writeRetryMaxBackoff)
+	}
+}
+
+// writeBreakerFailure returns err when it counts toward the write breaker:
+// a transient failure (see isTransientWriteError) or DynamoDB not
+// answering at all. An error response to the request itself, a failed
+// condition included, is DynamoDB working and returns nil.
+func writeBreakerFailure(err error) error {
+	var apiErr smithy.APIError
+	if err == nil || (errors.As(err, &apiErr) && !isTransientWriteError(err)) {
+		return nil
+	}
+	return err
+}
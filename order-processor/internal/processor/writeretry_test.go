@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTransientWriteError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"throughput exceeded", &dtypes.ProvisionedThroughputExceededException{}, true},
+		{"request limit", &dtypes.RequestLimitExceeded{}, true},
+		{"internal server error", &dtypes.InternalServerError{}, true},
+		{"wrapped throttling", errors.Join(errors.New("put"), &dtypes.ProvisionedThroughputExceededException{}), true},
+		{"failed condition", &dtypes.ConditionalCheckFailedException{}, false},
+		{"validation", &smithy.GenericAPIError{Code: "ValidationException", Fault: smithy.FaultClient}, false},
+		{"plain error", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isTransientWriteError(tt.err))
+		})
+	}
+}
+
+func TestStoreOrder_RetriesThrottling(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	clock := &fakeClock{}
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders", writeRetries: 3, clock: clock}
+
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), &dtypes.ProvisionedThroughputExceededException{}).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), &dtypes.InternalServerError{}).Once()
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return(&dynamodb.PutItemOutput{}, nil).Once()
+
+	require.NoError(t, proc.storeOrder(context.Background(), Order{OrderID: "o1", UserID: "u1"}))
+
+	mockDDB.AssertExpectations(t)
+	assert.Equal(t, []time.Duration{50 * time.Millisecond, 100 * time.Millisecond}, clock.waits)
+}
+
+func TestStoreOrder_GivesUpAfterWriteRetries(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders", writeRetries: 2, clock: &fakeClock{}}
+
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Return((*dynamodb.PutItemOutput)(nil), &dtypes.ProvisionedThroughputExceededException{})
+
+	err := proc.storeOrder(context.Background(), Order{OrderID: "o1", UserID: "u1"})
+
+	var throttled *dtypes.ProvisionedThroughputExceededException
+	assert.ErrorAs(t, err, &throttled)
+	mockDDB.AssertNumberOfCalls(t, "PutItem", 3)
+}
+
+func TestStoreOrder_ClientErrorsNotRetried(t *testing.T) {
+	for _, err := range []error{
+		&dtypes.ConditionalCheckFailedException{},
+		&smithy.GenericAPIError{Code: "ValidationException", Fault: smithy.FaultClient},
+	} {
+		mockDDB := &MockDynamoDBClient{}
+		clock := &fakeClock{}
+		proc := &Processor{ddbClient: mockDDB, tableName: "Orders", writeRetries: 3, clock: clock}
+
+		mockDDB.On("PutItem", mock.Anything, mock.Anything).Return((*dynamodb.PutItemOutput)(nil), err).Once()
+
+		assert.ErrorIs(t, proc.storeOrder(context.Background(), Order{OrderID: "o1", UserID: "u1"}), err)
+		mockDDB.AssertExpectations(t)
+		assert.Empty(t, clock.waits)
+	}
+}
+
+func TestStoreOrder_ShutdownInterruptsRetries(t *testing.T) {
+	mockDDB := &MockDynamoDBClient{}
+	proc := &Processor{ddbClient: mockDDB, tableName: "Orders", writeRetries: 3, clock: &fakeClock{block: true}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockDDB.On("PutItem", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { cancel() }).
+		Return((*dynamodb.PutItemOutput)(nil), &dtypes.ProvisionedThroughputExceededException{}).Once()
+
+	err := proc.storeOrder(ctx, Order{OrderID: "o1", UserID: "u1"})
+
+	var throttled *dtypes.ProvisionedThroughputExceededException
+	assert.ErrorAs(t, err, &throttled)
+	mockDDB.AssertExpectations(t)
+}